@@ -0,0 +1,465 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/eluv-io/common-go/format/drm"
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
+	"github.com/eluv-io/common-go/format/link"
+	"github.com/eluv-io/common-go/format/token"
+	"github.com/eluv-io/common-go/util/ethutil"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// kind is the kind of an inspected string.
+type kind string
+
+// kinds are the kinds of strings recognized by inspect().
+var kinds = struct {
+	Unknown  kind
+	EAT      kind
+	JWS      kind
+	ID       kind
+	Hash     kind
+	Token    kind
+	Key      kind
+	Envelope kind
+	DRM      kind
+	Link     kind
+}{
+	Unknown:  "unknown",
+	EAT:      "eat",
+	JWS:      "jws",
+	ID:       "id",
+	Hash:     "hash",
+	Token:    "token",
+	Key:      "key",
+	Envelope: "envelope",
+	DRM:      "drm",
+	Link:     "link",
+}
+
+// result is the result of inspecting a single string.
+type result struct {
+	Input       string                 `json:"input"`
+	Kind        kind                   `json:"kind"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Signature   *signatureResult       `json:"signature,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// signatureResult is the result of verifying the signature of an inspected token.
+type signatureResult struct {
+	Signer   string `json:"signer"`
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// failed returns true if the inspection or the signature verification failed.
+func (r *result) failed() bool {
+	return r.Error != "" || (r.Signature != nil && !r.Signature.Verified)
+}
+
+// text returns the textual representation of the result.
+func (r *result) text() string {
+	sb := strings.Builder{}
+	sb.WriteString("input:  " + r.Input + "\n")
+	sb.WriteString("kind:   " + string(r.Kind) + "\n")
+	if r.Error != "" {
+		sb.WriteString("error:  " + r.Error + "\n")
+	}
+	if r.Description != "" {
+		sb.WriteString("\n")
+		sb.WriteString(r.Description)
+		if !strings.HasSuffix(r.Description, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	if r.Signature != nil {
+		sb.WriteString("\n")
+		if r.Signature.Verified {
+			sb.WriteString("signature: verified with " + r.Signature.Signer + "\n")
+		} else {
+			sb.WriteString("signature: NOT verified with " + r.Signature.Signer + "\n")
+			sb.WriteString("           " + r.Signature.Error + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// detect determines the kind of the given string based on its prefix.
+func detect(s string) kind {
+	switch {
+	case strings.HasPrefix(s, link.ABSOLUTE_LINK_PREFIX), strings.HasPrefix(s, link.RELATIVE_LINK_PREFIX):
+		return kinds.Link
+	case strings.Count(s, ".") == 2:
+		// auth tokens as JWS in compact serialization: header.claims.signature
+		return kinds.JWS
+	case strings.HasPrefix(s, "eyJ"):
+		// legacy tokens are base64-encoded JSON
+		return kinds.EAT
+	case strings.HasPrefix(s, keys.EnvelopePrefix):
+		return kinds.Envelope
+	case len(s) < 4:
+		return kinds.Unknown
+	}
+	switch s[0] {
+	case 'a':
+		return kinds.EAT
+	case 'i':
+		return kinds.ID
+	case 'h':
+		return kinds.Hash
+	case 't':
+		return kinds.Token
+	case 'k':
+		return kinds.Key
+	case 'd':
+		return kinds.DRM
+	}
+	return kinds.Unknown
+}
+
+// inspect detects the kind of the given string, decodes it and returns its description. If signer is not empty, the
+// signature of auth tokens is verified against it - see verifySignature().
+func inspect(s string, signer string) *result {
+	s = strings.TrimSpace(s)
+	res := &result{
+		Input: s,
+		Kind:  detect(s),
+	}
+
+	var err error
+	switch res.Kind {
+	case kinds.EAT:
+		err = inspectEAT(res, signer)
+	case kinds.JWS:
+		err = inspectJWS(res, signer)
+	case kinds.ID:
+		err = inspectID(res)
+	case kinds.Hash:
+		err = inspectHash(res)
+	case kinds.Token:
+		err = inspectToken(res)
+	case kinds.Key:
+		err = inspectKey(res)
+	case kinds.Envelope:
+		err = inspectEnvelope(res)
+	case kinds.DRM:
+		err = inspectDRM(res)
+	case kinds.Link:
+		err = inspectLink(res)
+	default:
+		err = errors.E("inspect", errors.K.Invalid, "reason", "unknown string format")
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if signer != "" && res.Kind != kinds.EAT && res.Kind != kinds.JWS && res.Signature == nil {
+		res.Signature = &signatureResult{
+			Signer: signer,
+			Error:  "signature verification not supported for " + string(res.Kind),
+		}
+	}
+	return res
+}
+
+func inspectEAT(res *result, signer string) error {
+	// Describe() explains partially decoded tokens, too
+	res.Description = eat.Describe(res.Input)
+
+	tok, err := eat.Parse(res.Input)
+	if err != nil {
+		return err
+	}
+	return addTokenFields(res, tok, signer)
+}
+
+func inspectJWS(res *result, signer string) error {
+	tok, err := eat.ParseJWS(res.Input)
+	if err != nil {
+		return err
+	}
+	res.Description = tok.Explain()
+	return addTokenFields(res, tok, signer)
+}
+
+// addTokenFields sets the fields of the given decoded auth token and verifies its signature if signer is not empty.
+func addTokenFields(res *result, tok *eat.Token, signer string) error {
+	res.Fields = map[string]interface{}{
+		"type":     tok.Type.String(),
+		"format":   tok.Format.String(),
+		"sig_type": tok.SigType.String(),
+	}
+	if tok.SigType.HasSig() {
+		addr, err := tok.SignerAddress()
+		if err == nil {
+			res.Fields["signer_address"] = addr.Hex()
+		}
+	}
+	var data map[string]interface{}
+	bts, err := json.Marshal(tok.TokenData)
+	if err == nil {
+		err = json.Unmarshal(bts, &data)
+	}
+	if err != nil {
+		return errors.E("inspect", errors.K.Invalid, err, "reason", "failed to convert token data")
+	}
+	res.Fields["data"] = data
+
+	if signer != "" {
+		res.Signature = verifySignature(tok, signer)
+	}
+	return nil
+}
+
+func inspectID(res *result) error {
+	i, err := id.Parse(res.Input)
+	if err != nil {
+		return err
+	}
+	res.Description = i.Describe()
+	res.Fields = map[string]interface{}{
+		"type":  i.Code().Describe(),
+		"bytes": "0x" + hex.EncodeToString(i.Bytes()),
+	}
+	return nil
+}
+
+func inspectHash(res *result) error {
+	h, err := hash.FromString(res.Input)
+	if err != nil {
+		return err
+	}
+	res.Description = h.Describe()
+	res.Fields = map[string]interface{}{
+		"type":      h.Type.Describe(),
+		"algorithm": h.Type.Algorithm.String(),
+		"digest":    "0x" + hex.EncodeToString(h.Digest),
+	}
+	if !h.IsLive() {
+		res.Fields["size"] = h.Size
+		if h.PreambleSize > 0 {
+			res.Fields["preamble_size"] = h.PreambleSize
+		}
+	} else {
+		res.Fields["expiration"] = h.Expiration.String()
+	}
+	if h.Type.Code == hash.Q {
+		res.Fields["qid"] = h.ID.String()
+		if qphash, err := h.As(hash.QPart, nil); err == nil {
+			res.Fields["part"] = qphash.String()
+		}
+	}
+	return nil
+}
+
+func inspectToken(res *result) error {
+	t, err := token.FromString(res.Input)
+	if err != nil {
+		return err
+	}
+	res.Description = t.Describe()
+
+	info := t.Info()
+	res.Fields = map[string]interface{}{
+		"type":  t.Code.Describe(),
+		"bytes": "0x" + hex.EncodeToString(t.Bytes),
+	}
+	if !info.QID.IsNil() {
+		res.Fields["qid"] = info.QID.String()
+	}
+	if !info.NID.IsNil() {
+		res.Fields["nid"] = info.NID.String()
+	}
+	if !info.AllocationID.IsNil() {
+		res.Fields["alloc"] = info.AllocationID.String()
+	}
+	if info.Index >= 0 {
+		res.Fields["index"] = info.Index
+	}
+	if t.Code == token.QPartWrite {
+		res.Fields["scheme"] = info.Scheme.String()
+		res.Fields["flags"] = token.DescribeFlags(info.Flags, token.QPWFlagNames)
+	}
+	if !info.Created.IsZero() {
+		res.Fields["created"] = utc.New(info.Created).String()
+	}
+	return nil
+}
+
+func inspectKey(res *result) error {
+	k, err := keys.Parse(res.Input)
+	if err != nil {
+		return err
+	}
+
+	d := newDescriber(res, 8)
+	d.add("type", k.Code().String(), "")
+	d.add("bytes", "0x"+hex.EncodeToString(k.Bytes()), "")
+	switch k.Code() {
+	case keys.ES256KPublicKey, keys.EthPublicKey, keys.FabricNodePublicKey, keys.UserPublicKey:
+		pub, err := crypto.DecompressPubkey(k.Bytes())
+		if err != nil {
+			pub, err = crypto.UnmarshalPubkey(k.Bytes())
+		}
+		if err != nil {
+			return errors.E("inspect", errors.K.Invalid, err, "reason", "invalid public key")
+		}
+		addr := crypto.PubkeyToAddress(*pub)
+		d.add("address", addr.Hex(), "")
+		d.add("user", ethutil.AddressToID(addr, id.User).String(), "")
+	case keys.ED25519PublicKey:
+		if len(k.Bytes()) == ed25519.PublicKeySize {
+			d.add("id", id.NewID(id.Ed25519, k.Bytes()).String(), "")
+		}
+	}
+	d.done()
+	return nil
+}
+
+// inspectEnvelope describes the header of a key envelope. The sealed key itself is not revealed.
+func inspectEnvelope(res *result) error {
+	env, err := keys.ParseEnvelope(res.Input)
+	if err != nil {
+		return err
+	}
+
+	d := newDescriber(res, 10)
+	d.add("algorithm", string(env.Algorithm), "")
+	if env.KID != "" {
+		d.add("kid", env.KID, "")
+	}
+	d.add("created", env.Created.String(), "")
+	d.done()
+	return nil
+}
+
+func inspectDRM(res *result) error {
+	k, err := drm.FromString(res.Input)
+	if err != nil {
+		return err
+	}
+
+	d := newDescriber(res, 6)
+	d.add("type", k.Code.String(), "")
+	d.add("id", "0x"+hex.EncodeToString(k.ID), "")
+	if !k.Hash.IsNil() {
+		d.add("hash", k.Hash.String(), "")
+	}
+	d.done()
+	return nil
+}
+
+func inspectLink(res *result) error {
+	l, err := link.FromString(res.Input)
+	if err != nil {
+		return err
+	}
+
+	d := newDescriber(res, 9)
+	if l.IsAbsolute() {
+		d.add("target", l.Target.String(), "")
+	} else {
+		d.add("target", nil, "(relative)")
+	}
+	if l.Selector != "" {
+		d.add("selector", string(l.Selector), "")
+	}
+	if len(l.Path) > 0 {
+		d.add("path", l.Path.String(), "")
+	}
+	if l.Len != 0 && (l.Off != 0 || l.Len != -1) {
+		d.add("offset", l.Off, "")
+		d.add("length", l.Len, "")
+	}
+	d.done()
+	return nil
+}
+
+// describer collects the fields of a decoded value and their description as aligned "key: value" lines.
+type describer struct {
+	res    *result
+	width  int
+	sb     strings.Builder
+	fields map[string]interface{}
+}
+
+func newDescriber(res *result, width int) *describer {
+	return &describer{
+		res:    res,
+		width:  width,
+		fields: map[string]interface{}{},
+	}
+}
+
+// add adds the given field. The field is described with the given text, or the value itself if the text is empty. Nil
+// values are described, but not added to the fields.
+func (d *describer) add(key string, val interface{}, text string) {
+	if val != nil {
+		d.fields[key] = val
+	}
+	if text == "" {
+		text = fmt.Sprint(val)
+	}
+	d.sb.WriteString(fmt.Sprintf("%-*s %s\n", d.width, key+":", text))
+}
+
+// done sets the description and the fields of the result.
+func (d *describer) done() {
+	d.res.Description = d.sb.String()
+	d.res.Fields = d.fields
+}
+
+// verifySignature verifies the signature of the given token against the given signer, which may be an ethereum address
+// (0x...), a user or Ed25519 ID (iusr..., ied2...) or a public key (kpec..., kped...).
+func verifySignature(tok *eat.Token, signer string) *signatureResult {
+	res := &signatureResult{Signer: signer}
+	err := tok.VerifySignature()
+	if err == nil {
+		err = verifySigner(tok, signer)
+	}
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Verified = true
+	}
+	return res
+}
+
+func verifySigner(tok *eat.Token, signer string) error {
+	switch {
+	case strings.HasPrefix(signer, "0x"):
+		addr, err := ethutil.HexToAddress(signer)
+		if err != nil {
+			return err
+		}
+		return tok.VerifySignatureFrom(addr)
+	case strings.HasPrefix(signer, "i"):
+		trusted, err := id.Parse(signer)
+		if err != nil {
+			return err
+		}
+		return tok.VerifySignatureFromID(trusted)
+	case strings.HasPrefix(signer, "k"):
+		trusted, err := keys.Parse(signer)
+		if err != nil {
+			return err
+		}
+		return tok.VerifySignatureFromKey(trusted)
+	}
+	return errors.E("verify signature", errors.K.Invalid,
+		"reason", "signer is not an address, ID or key",
+		"signer", signer)
+}
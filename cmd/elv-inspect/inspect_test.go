@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
+	"github.com/eluv-io/common-go/format/token"
+	"github.com/eluv-io/common-go/util/ethutil"
+	"github.com/eluv-io/utc-go"
+)
+
+const (
+	hashString = "hq__2w1SR2eY9LChsaY5f3EE2G4RhroKnmL7dsyB7Wm2qvbRG5UF9GoPVgFvD1nFqe9Pt4hF7"
+	drmString  = "drm_2qU32EeeHhVBxMF9vC8ABUsF5rmbfY1a6TvUGr3EYpYv1EvAc1FAM9tkACxiYmPoPxaEfbTNzHsAVh4TyKGJEFCd1gM"
+)
+
+func TestInspect(t *testing.T) {
+	qid := id.Generate(id.Q)
+	tqw := token.Generate(token.QWrite)
+	kp := keys.New(keys.Primary, []byte{1, 2, 3, 4})
+
+	tests := []struct {
+		s      string
+		kind   kind
+		fields map[string]interface{}
+	}{
+		{s: qid.String(), kind: kinds.ID, fields: map[string]interface{}{"type": "content"}},
+		{s: hashString, kind: kinds.Hash, fields: map[string]interface{}{"size": int64(1024), "algorithm": "SHA-256"}},
+		{s: tqw.String(), kind: kinds.Token, fields: map[string]interface{}{"qid": tqw.QID.String(), "nid": tqw.NID.String()}},
+		{s: kp.String(), kind: kinds.Key, fields: map[string]interface{}{"type": "kp__"}},
+		{s: drmString, kind: kinds.DRM, fields: map[string]interface{}{"hash": hashString}},
+		{s: "/qfab/" + hashString + "/meta/some/path", kind: kinds.Link, fields: map[string]interface{}{
+			"target":   hashString,
+			"selector": "meta",
+			"path":     "/some/path",
+		}},
+		{s: "./files/a/b#10-19", kind: kinds.Link, fields: map[string]interface{}{
+			"target": nil,
+			"offset": int64(10),
+			"length": int64(10),
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			res := inspect(test.s, "")
+			require.Equal(t, test.kind, res.Kind)
+			require.Empty(t, res.Error)
+			require.False(t, res.failed())
+			require.NotEmpty(t, res.Description)
+			for key, val := range test.fields {
+				require.Equal(t, val, res.Fields[key], key)
+			}
+		})
+	}
+
+	for _, s := range []string{"", "blub", "iq__invalid", "hq__", "/qfab/blub", "xyz_abcdefg"} {
+		res := inspect(s, "")
+		require.NotEmpty(t, res.Error, s)
+		require.True(t, res.failed(), s)
+	}
+}
+
+func TestInspectKey(t *testing.T) {
+	sk, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	kupk, uid := ethutil.ToPublicKeyAndID(sk, id.User)
+	kpec := keys.New(keys.ES256KPublicKey, crypto.CompressPubkey(&sk.PublicKey))
+
+	for _, kid := range []keys.Key{kpec, kupk} {
+		res := inspect(kid.String(), "")
+		require.Equal(t, kinds.Key, res.Kind)
+		require.Empty(t, res.Error)
+		require.Equal(t, kid.Code().String(), res.Fields["type"])
+		require.Equal(t, crypto.PubkeyToAddress(sk.PublicKey).Hex(), res.Fields["address"])
+		require.Equal(t, uid.String(), res.Fields["user"])
+	}
+}
+
+func TestInspectEAT(t *testing.T) {
+	sk, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	addr := crypto.PubkeyToAddress(sk.PublicKey)
+	_, uid := ethutil.ToPublicKeyAndID(sk, id.User)
+	kid := keys.New(keys.ES256KPublicKey, crypto.CompressPubkey(&sk.PublicKey))
+	otherSK, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	qid := id.Generate(id.Q)
+
+	tok, err := eat.NewClientSigned(id.Generate(id.QSpace)).WithQID(qid).Sign(sk).Encode()
+	require.NoError(t, err)
+
+	res := inspect(tok, "")
+	require.Equal(t, kinds.EAT, res.Kind)
+	require.Empty(t, res.Error)
+	require.Nil(t, res.Signature)
+	require.Contains(t, res.Description, "client-signed")
+	require.Equal(t, addr.Hex(), res.Fields["signer_address"])
+	require.Equal(t, qid.String(), res.Fields["data"].(map[string]interface{})["qid"])
+
+	for _, signer := range []string{addr.Hex(), uid.String(), kid.String()} {
+		res = inspect(tok, signer)
+		require.Empty(t, res.Error)
+		require.NotNil(t, res.Signature)
+		require.True(t, res.Signature.Verified, signer)
+		require.False(t, res.failed())
+	}
+
+	for _, signer := range []string{crypto.PubkeyToAddress(otherSK.PublicKey).Hex(), "iq__invalid", "blub"} {
+		res = inspect(tok, signer)
+		require.Empty(t, res.Error)
+		require.NotNil(t, res.Signature)
+		require.False(t, res.Signature.Verified, signer)
+		require.NotEmpty(t, res.Signature.Error, signer)
+		require.True(t, res.failed())
+	}
+
+	// signatures of other strings cannot be verified
+	res = inspect(qid.String(), addr.Hex())
+	require.NotNil(t, res.Signature)
+	require.False(t, res.Signature.Verified)
+	require.True(t, res.failed())
+
+	// invalid tokens are still explained as far as possible
+	res = inspect(tok[:len(tok)-10], "")
+	require.Equal(t, kinds.EAT, res.Kind)
+	require.NotEmpty(t, res.Error)
+	require.NotEmpty(t, res.Description)
+}
+
+func TestDetect(t *testing.T) {
+	sk, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tok, err := eat.NewClientSigned(id.Generate(id.QSpace)).Sign(sk).Encode()
+	require.NoError(t, err)
+	jws := jwsString(t, sk)
+	env := envelopeString(t, "")
+
+	tests := []struct {
+		s    string
+		kind kind
+	}{
+		{tok, kinds.EAT},
+		{"eyJhbGciOiJFUzI1NksifQ", kinds.EAT},
+		{jws, kinds.JWS},
+		{id.Generate(id.Q).String(), kinds.ID},
+		{id.Generate(id.Q).ChecksumString(), kinds.ID},
+		{hashString, kinds.Hash},
+		{token.Generate(token.QWrite).String(), kinds.Token},
+		{keys.New(keys.Primary, []byte{1, 2, 3, 4}).String(), kinds.Key},
+		{env, kinds.Envelope},
+		{drmString, kinds.DRM},
+		{"/qfab/" + hashString + "/meta", kinds.Link},
+		{"./files/a.b.c", kinds.Link},
+		{"blub", kinds.Unknown},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.kind, detect(test.s), test.s)
+	}
+}
+
+func TestInspectJWS(t *testing.T) {
+	sk, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	addr := crypto.PubkeyToAddress(sk.PublicKey)
+	jws := jwsString(t, sk)
+
+	res := inspect(jws, addr.Hex())
+	require.Equal(t, kinds.JWS, res.Kind)
+	require.Empty(t, res.Error)
+	require.NotEmpty(t, res.Description)
+	require.Equal(t, addr.Hex(), res.Fields["signer_address"])
+	require.NotNil(t, res.Signature)
+	require.True(t, res.Signature.Verified, res.Signature.Error)
+	require.False(t, res.failed())
+
+	res = inspect(jws[:len(jws)-10], "")
+	require.Equal(t, kinds.JWS, res.Kind)
+	require.NotEmpty(t, res.Error)
+}
+
+func TestInspectEnvelope(t *testing.T) {
+	for _, kid := range []string{"", "kek-1"} {
+		env := envelopeString(t, kid)
+		parsed, err := keys.ParseEnvelope(env)
+		require.NoError(t, err)
+
+		res := inspect(env, "")
+		require.Equal(t, kinds.Envelope, res.Kind)
+		require.Empty(t, res.Error)
+		require.Equal(t, string(keys.EA.AESGCM), res.Fields["algorithm"])
+		require.Equal(t, parsed.Created.String(), res.Fields["created"])
+		if kid == "" {
+			require.NotContains(t, res.Fields, "kid")
+		} else {
+			require.Equal(t, kid, res.Fields["kid"])
+		}
+		require.NotContains(t, res.Description, "0x")
+	}
+
+	res := inspect(keys.EnvelopePrefix+"blub", "")
+	require.Equal(t, kinds.Envelope, res.Kind)
+	require.NotEmpty(t, res.Error)
+}
+
+// jwsString returns an auth token signed with the given key as JWS.
+func jwsString(t *testing.T, sk *ecdsa.PrivateKey) string {
+	tok := eat.NewEditorSigned(id.Generate(id.QSpace), id.Generate(id.QLib), id.Generate(id.Q)).
+		WithSubject(id.Generate(id.User).String()).
+		WithIssuedAt(utc.Now()).
+		WithExpires(utc.Now().Add(time.Hour)).
+		Token()
+	jws, err := tok.EncodeJWS(eat.NewES256KSigner(sk))
+	require.NoError(t, err)
+	return jws
+}
+
+// envelopeString returns a key sealed in an envelope under a KEK with the given key ID.
+func envelopeString(t *testing.T, kid string) string {
+	kek, err := keys.RawKEK(kid, bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	env, err := keys.Seal(keys.New(keys.Primary, []byte{1, 2, 3, 4}), kek)
+	require.NoError(t, err)
+	return env.String()
+}
+
+func TestRun(t *testing.T) {
+	qid := id.Generate(id.Q)
+
+	out := &bytes.Buffer{}
+	code := run([]string{qid.String(), hashString}, nil, out, false, "")
+	require.Equal(t, 0, code)
+	require.Contains(t, out.String(), "kind:   id")
+	require.Contains(t, out.String(), "kind:   hash")
+
+	out.Reset()
+	code = run(nil, strings.NewReader(qid.String()+"\n\nblub\n"), out, true, "")
+	require.Equal(t, 1, code)
+	var results []*result
+	require.NoError(t, json.Unmarshal(out.Bytes(), &results))
+	require.Len(t, results, 2)
+	require.Equal(t, kinds.ID, results[0].Kind)
+	require.Equal(t, kinds.Unknown, results[1].Kind)
+
+	out.Reset()
+	code = run([]string{"-"}, strings.NewReader(hashString), out, true, "")
+	require.Equal(t, 0, code)
+	var res result
+	require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+	require.Equal(t, kinds.Hash, res.Kind)
+}
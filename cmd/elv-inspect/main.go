@@ -0,0 +1,92 @@
+// elv-inspect decodes fabric strings offline and prints their breakdown.
+//
+// The kind of each string is detected automatically: auth tokens (eat, including JWS), IDs (iq__, ilib, iusr, ...),
+// hashes (hq__, hqp_, ...), tokens (tqw_, tlro, ...), keys (kp__, kpec, kped, ...), key envelopes (kenv), DRM key IDs
+// (drm_) and links (/qfab/..., ./...).
+//
+// Usage:
+//
+//	elv-inspect [-json] [-signer <address|id|key>] [string ...]
+//
+// Strings are read from stdin (one per line) if none are given or if the only argument is "-". If a signer is given,
+// the signature of auth tokens is verified against it. The signer may be an ethereum address (0x...), a user or Ed25519
+// ID (iusr..., ied2...) or a public key (kpec..., kped...).
+//
+// The exit code is 1 if any of the strings cannot be decoded or fails signature verification.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the results as JSON")
+	signer := flag.String("signer", "", "verify the signature of auth tokens against the given address, ID or key")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "usage: elv-inspect [-json] [-signer <address|id|key>] [string ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	os.Exit(run(flag.Args(), os.Stdin, os.Stdout, *asJSON, *signer))
+}
+
+// run inspects the given strings - or the lines read from in if there are none - and writes the results to out.
+// Returns the process exit code.
+func run(args []string, in io.Reader, out io.Writer, asJSON bool, signer string) int {
+	if len(args) == 0 || (len(args) == 1 && args[0] == "-") {
+		args = nil
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" {
+				args = append(args, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "failed to read input:", err)
+			return 2
+		}
+	}
+
+	code := 0
+	results := make([]*result, 0, len(args))
+	for _, arg := range args {
+		res := inspect(arg, signer)
+		if res.failed() {
+			code = 1
+		}
+		results = append(results, res)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		var err error
+		if len(results) == 1 {
+			err = enc.Encode(results[0])
+		} else {
+			err = enc.Encode(results)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "failed to encode results:", err)
+			return 2
+		}
+		return code
+	}
+
+	for i, res := range results {
+		if i > 0 {
+			_, _ = fmt.Fprintln(out, strings.Repeat("-", 80))
+		}
+		_, _ = fmt.Fprint(out, res.text())
+	}
+	return code
+}
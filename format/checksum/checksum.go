@@ -0,0 +1,66 @@
+// Package checksum implements the optional checksummed text form of IDs, keys, tokens and DRM key IDs.
+//
+// The checksummed form of a string is the string itself, followed by the Separator and the base58 encoding of the
+// first 4 bytes of the SHA-256 digest of the string:
+//
+//	iq__2ZkPfmuv4RmBMn1SoXPzhjrkVMuD.5QNCED
+//
+// Since the base58 alphabet does not contain the separator, the checksum is unambiguous. A checksum detects mistyped
+// strings that would otherwise decode to a different but equally valid value.
+package checksum
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+
+	"github.com/mr-tron/base58/base58"
+
+	"github.com/eluv-io/errors-go"
+)
+
+// Separator separates the checksum from the checksummed string.
+const Separator = "."
+
+// Len is the length of the checksum in bytes, before base58 encoding.
+const Len = 4
+
+// Append returns the checksummed form of the given string. Returns the empty string if s is empty.
+func Append(s string) string {
+	if s == "" {
+		return ""
+	}
+	return s + Separator + base58.Encode(calc(s))
+}
+
+// Has returns true if the given string has a checksum. The checksum is not validated.
+func Has(s string) bool {
+	return strings.Contains(s, Separator)
+}
+
+// Strip validates and removes the checksum of the given string. Strings without checksum are returned unchanged.
+func Strip(s string) (string, error) {
+	idx := strings.LastIndex(s, Separator)
+	if idx < 0 {
+		return s, nil
+	}
+
+	e := errors.TemplateNoTrace("strip checksum", errors.K.Invalid, "string", s)
+	plain := s[:idx]
+	if plain == "" {
+		return "", e("reason", "empty string")
+	}
+	sum, err := base58.Decode(s[idx+len(Separator):])
+	if err != nil || len(sum) != Len {
+		return "", e(err, "reason", "invalid checksum")
+	}
+	if !bytes.Equal(sum, calc(plain)) {
+		return "", e("reason", "checksum mismatch")
+	}
+	return plain, nil
+}
+
+func calc(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:Len]
+}
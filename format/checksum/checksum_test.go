@@ -0,0 +1,41 @@
+package checksum_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/checksum"
+)
+
+func TestChecksum(t *testing.T) {
+	s := "iq__2ZkPfmuv4RmBMn1SoXPzhjrkVMuD"
+
+	cs := checksum.Append(s)
+	require.True(t, strings.HasPrefix(cs, s+checksum.Separator))
+	require.True(t, checksum.Has(cs))
+	require.False(t, checksum.Has(s))
+	require.Equal(t, "", checksum.Append(""))
+
+	stripped, err := checksum.Strip(cs)
+	require.NoError(t, err)
+	require.Equal(t, s, stripped)
+
+	stripped, err = checksum.Strip(s)
+	require.NoError(t, err)
+	require.Equal(t, s, stripped)
+
+	for _, invalid := range []string{
+		strings.Replace(cs, "Z", "z", 1), // typo in the string
+		cs[:len(cs)-1],                   // truncated checksum
+		s + checksum.Separator,           // empty checksum
+		s + checksum.Separator + "0OIl",  // invalid base58
+		cs[len(s):],                      // empty string
+	} {
+		t.Run(invalid, func(t *testing.T) {
+			_, err := checksum.Strip(invalid)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package codecs
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/eluv-io/common-go/format/structured"
+)
+
+// NewCborStreamDecoder creates a structured.StreamDecoder for CBOR documents read from the given reader. In contrast to
+// the default CBOR decoding of the StreamDecoder, the selected values are decoded with CborV2Codec, i.e. with support
+// for the CBOR tags of IDs, hashes, links, UTC timestamps and tokens.
+//
+// The documents are not expected to have a MultiCodec header.
+func NewCborStreamDecoder(r io.Reader) *structured.StreamDecoder {
+	return structured.
+		NewStreamDecoder(r, structured.StreamFormats.CBOR).
+		WithCborDecoder(decodeCborItem)
+}
+
+// decodeCborItem decodes a single CBOR data item with the CborV2Codec.
+func decodeCborItem(raw []byte) (interface{}, error) {
+	var res interface{}
+	err := CborV2Codec.Decoder(bytes.NewReader(raw)).Decode(&res)
+	return res, err
+}
@@ -0,0 +1,38 @@
+package codecs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/structured"
+)
+
+func TestCborStreamDecoder(t *testing.T) {
+	qid := id.Generate(id.Q)
+	data := map[string]interface{}{
+		"content": map[string]interface{}{
+			"id":   qid,
+			"name": "name",
+		},
+		"skipped": []interface{}{"a", "b", map[string]interface{}{"c": qid}},
+	}
+
+	for _, codec := range []Codec{CborV1Codec, CborV2Codec} {
+		buf := &bytes.Buffer{}
+		require.NoError(t, codec.Encoder(buf).Encode(data))
+		docLen := buf.Len()
+
+		dec := NewCborStreamDecoder(buf).WithSelect(structured.ParsePath("/content/id"))
+		res, err := dec.Decode()
+		require.NoError(t, err)
+		require.Equal(t, qid, structured.Wrap(res).Get("content", "id").Value())
+		require.Len(t, structured.Wrap(res).Map(), 1)
+
+		stats := dec.Stats()
+		require.EqualValues(t, docLen, stats.BytesRead)
+		require.EqualValues(t, 2, stats.ValuesSkipped)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/eluv-io/errors-go"
 	"github.com/eluv-io/log-go"
 
+	"github.com/eluv-io/common-go/format/checksum"
 	"github.com/eluv-io/common-go/format/hash"
 )
 
@@ -109,11 +110,16 @@ func NewFromStrings(c Code, idStr string, hStr string) (*KeyID, error) {
 	return New(c, id, h)
 }
 
-// FromString parses a DRM key from the given string representation.
+// FromString parses a DRM key from the given string representation, which may be in checksummed form - see
+// ChecksumString().
 func FromString(s string) (*KeyID, error) {
 	e := errors.TemplateNoTrace("parse drm key", errors.K.Invalid, "string", s)
 	if s == "" {
 		return nil, nil
+	}
+	s, err := checksum.Strip(s)
+	if err != nil {
+		return nil, e(err)
 	} else if len(s) < prefixLen {
 		return nil, e("reason", "invalid token string")
 	}
@@ -142,7 +148,7 @@ func FromString(s string) (*KeyID, error) {
 	id := b[n : n+m]
 	n += m
 	// Parse hash
-	h, err := hash.FromDecodedBytes(hash.Type{hash.Q, hash.Unencrypted}, b[n:])
+	h, err := hash.FromDecodedBytes(hash.Type{Code: hash.Q, Format: hash.Unencrypted}, b[n:])
 	if err != nil {
 		return nil, e(err, "reason", "invalid hash")
 	}
@@ -187,6 +193,12 @@ func (k *KeyID) String() string {
 	return k.s
 }
 
+// ChecksumString returns the checksummed string representation of this DRM key - see package checksum. FromString
+// accepts both the plain and the checksummed form.
+func (k *KeyID) ChecksumString() string {
+	return checksum.Append(k.String())
+}
+
 // MarshalText converts this DRM key to text.
 func (k KeyID) MarshalText() ([]byte, error) {
 	return []byte(k.String()), nil
@@ -139,6 +139,19 @@ type Wrapper struct {
 	Key *drm.KeyID
 }
 
+func TestChecksumString(t *testing.T) {
+	cs := key.ChecksumString()
+	require.NotEqual(t, keyString, cs)
+
+	k, err := drm.FromString(cs)
+	require.NoError(t, err)
+	require.True(t, key.Equal(k))
+	require.Equal(t, keyString, k.String())
+
+	_, err = drm.FromString(keyString + ".1111")
+	require.Error(t, err)
+}
+
 func TestMarshalUnmarshal(t *testing.T) {
 	b, err := json.Marshal(key)
 	assert.NoError(t, err)
@@ -0,0 +1,267 @@
+package eat
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/errors-go"
+)
+
+// maxAttenuationDepth is the maximum number of attenuated tokens in a chain.
+const maxAttenuationDepth = 8
+
+// Caveats restrict the use of an attenuated token. Unset caveats are inherited from the parent token, set caveats must
+// narrow the caveats of the parent token - see Token.EffectiveCaveats().
+type Caveats struct {
+	Paths   []structured.Path `json:"pth,omitempty"` // path prefixes of the accessible resources - all if empty
+	IPs     []string          `json:"ips,omitempty"` // IP addresses or CIDR prefixes of allowed clients - all if empty
+	Session string            `json:"ses,omitempty"` // the session the token is bound to - any if empty
+	MaxUses int64             `json:"mxu,omitempty"` // max number of uses of the token - unlimited if 0
+}
+
+// Validate validates the caveats.
+func (c *Caveats) Validate() error {
+	if c == nil {
+		return nil
+	}
+	e := errors.Template("validate caveats", errors.K.Invalid)
+	for _, ip := range c.IPs {
+		if _, err := parsePrefix(ip); err != nil {
+			return e(err, "ip", ip)
+		}
+	}
+	if c.MaxUses < 0 {
+		return e("reason", "negative max uses", "max_uses", c.MaxUses)
+	}
+	return nil
+}
+
+// AllowsPath returns true if the resource with the given path is at or below one of the caveats' path prefixes.
+func (c *Caveats) AllowsPath(path structured.Path) bool {
+	if c == nil || len(c.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range c.Paths {
+		if path.StartsWith(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsIP returns true if the given client IP address matches one of the caveats' IP addresses or prefixes.
+func (c *Caveats) AllowsIP(ip string) bool {
+	if c == nil || len(c.IPs) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, s := range c.IPs {
+		prefix, err := parsePrefix(s)
+		if err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsSession returns true if the token is not bound to a session or bound to the given session.
+func (c *Caveats) AllowsSession(session string) bool {
+	return c == nil || c.Session == "" || c.Session == session
+}
+
+// narrows returns an error if these caveats of a child token widen the given effective caveats of its parent.
+func (c *Caveats) narrows(parent *Caveats) error {
+	if c == nil || parent == nil {
+		return nil
+	}
+	e := errors.Template("narrows", errors.K.Invalid)
+	if len(c.Paths) > 0 && len(parent.Paths) > 0 {
+		for _, path := range c.Paths {
+			if !parent.AllowsPath(path) {
+				return e("reason", "path not within parent paths",
+					"path", path,
+					"parent_paths", parent.Paths)
+			}
+		}
+	}
+	if len(c.IPs) > 0 && len(parent.IPs) > 0 {
+		for _, ip := range c.IPs {
+			if !parent.containsPrefix(ip) {
+				return e("reason", "ip not within parent ips",
+					"ip", ip,
+					"parent_ips", parent.IPs)
+			}
+		}
+	}
+	if c.Session != "" && parent.Session != "" && c.Session != parent.Session {
+		return e("reason", "session differs from parent session",
+			"session", c.Session,
+			"parent_session", parent.Session)
+	}
+	if c.MaxUses > 0 && parent.MaxUses > 0 && c.MaxUses > parent.MaxUses {
+		return e("reason", "max uses exceed parent max uses",
+			"max_uses", c.MaxUses,
+			"parent_max_uses", parent.MaxUses)
+	}
+	return nil
+}
+
+// containsPrefix returns true if the given IP address or prefix is contained in one of the caveats' IP prefixes.
+func (c *Caveats) containsPrefix(ip string) bool {
+	child, err := parsePrefix(ip)
+	if err != nil {
+		return false
+	}
+	for _, s := range c.IPs {
+		prefix, err := parsePrefix(s)
+		if err == nil && prefix.Bits() <= child.Bits() && prefix.Contains(child.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+// merge returns the caveats resulting from applying the given child caveats to these caveats: caveats set in the child
+// replace the ones of the parent.
+func (c *Caveats) merge(child *Caveats) *Caveats {
+	if c == nil {
+		return child
+	} else if child == nil {
+		return c
+	}
+	res := *c
+	if len(child.Paths) > 0 {
+		res.Paths = child.Paths
+	}
+	if len(child.IPs) > 0 {
+		res.IPs = child.IPs
+	}
+	if child.Session != "" {
+		res.Session = child.Session
+	}
+	if child.MaxUses > 0 {
+		res.MaxUses = child.MaxUses
+	}
+	return &res
+}
+
+// parsePrefix parses the given IP address or CIDR prefix. IP addresses are converted to single-address prefixes.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return prefix, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// NewAttenuatedToken creates a new attenuated token that embeds and restricts the given parent token. The attenuated
+// token inherits the space and library IDs, the format and the validity period of the parent token. It must be signed
+// with the ephemeral key referenced in the parent token's client confirmation - see also NewAttenuated().
+func NewAttenuatedToken(parent *Token) (*Token, error) {
+	e := errors.Template("new attenuated token", errors.K.Invalid)
+	if parent.IsNil() {
+		return nil, e("reason", "parent token is nil")
+	}
+	if !parent.Type.MayRequireConfirmation || !parent.Confirmation.RequiresConfirmation() {
+		return nil, e("reason", "parent token cannot be attenuated", "type", parent.Type)
+	}
+
+	return &Token{
+		Type:     Types.Attenuated(),
+		Format:   parent.Format,
+		SigType:  SigTypes.Unsigned(),
+		Embedded: parent,
+		TokenData: TokenData{
+			SID:      parent.GetQSpaceID(),
+			LID:      parent.GetQLibID(),
+			IssuedAt: parent.IssuedAt,
+			Expires:  parent.Expires,
+		},
+	}, nil
+}
+
+// EffectiveCaveats returns the caveats in effect for this token: the caveats of all attenuated tokens in the chain,
+// where the caveats of a child token replace the (wider) caveats of its parent. Returns nil if there are no caveats.
+func (t *Token) EffectiveCaveats() *Caveats {
+	if t == nil || t.Type != Types.Attenuated() {
+		return nil
+	}
+	return t.Embedded.EffectiveCaveats().merge(t.Caveats)
+}
+
+// AttenuationRoot returns the token at the root of the attenuation chain of this token, i.e. the first embedded token
+// that is not an attenuated token. Returns the token itself if it is not an attenuated token.
+func (t *Token) AttenuationRoot() *Token {
+	for t != nil && t.Type == Types.Attenuated() {
+		t = t.Embedded
+	}
+	return t
+}
+
+// validateAttenuation validates the chain of this attenuated token: the parent token must be signed and may be
+// attenuated, this token must be signed with the parent's ephemeral key and must narrow the parent's validity period and
+// caveats.
+func (t *Token) validateAttenuation() error {
+	e := errors.Template("validate attenuation", errors.K.Invalid)
+
+	parent := t.Embedded
+	depth := 1
+	for p := parent; p != nil && p.Type == Types.Attenuated(); p = p.Embedded {
+		depth++
+	}
+	if depth > maxAttenuationDepth {
+		return e("reason", "attenuation chain too long", "depth", depth, "max", maxAttenuationDepth)
+	}
+
+	err := parent.Validate()
+	if err != nil {
+		return e(err, "reason", "invalid parent token")
+	}
+	if !parent.Type.MayRequireConfirmation || !parent.SigType.HasSig() {
+		return e("reason", "parent token cannot be attenuated", "parent_type", parent.Type)
+	}
+	if !parent.Confirmation.RequiresConfirmation() {
+		return e("reason", "parent token has no ephemeral key")
+	}
+	if t.SigType != SigTypes.ES256K() {
+		return e("reason", "attenuated tokens must be signed with ES256K", "sig_type", t.SigType)
+	}
+	ephemeral, err := parent.Confirmation.ConfirmationAddress()
+	if err != nil {
+		return e(err)
+	}
+	if t.EthAddr != ephemeral {
+		return e("reason", "not signed with the parent's ephemeral key",
+			"signer", t.EthAddr.Hex(),
+			"ephemeral", ephemeral.Hex())
+	}
+
+	if !t.SID.Equal(parent.GetQSpaceID()) {
+		return e("reason", "space differs from parent space", "spc", t.SID, "parent_spc", parent.GetQSpaceID())
+	}
+	if t.IssuedAt.Before(parent.IssuedAt) {
+		return e("reason", "issued before parent",
+			"issued_at", t.IssuedAt,
+			"parent_issued_at", parent.IssuedAt)
+	}
+	if !parent.Expires.IsZero() && t.Expires.After(parent.Expires) {
+		return e("reason", "expires after parent",
+			"expires", t.Expires,
+			"parent_expires", parent.Expires)
+	}
+
+	return e.IfNotNil(t.Caveats.narrows(parent.EffectiveCaveats()))
+}
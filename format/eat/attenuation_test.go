@@ -0,0 +1,232 @@
+package eat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/common-go/util/ethutil"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestAttenuation(t *testing.T) {
+	now := utc.Now().Truncate(time.Millisecond)
+	newVerifier := func(now utc.UTC) *eat.Verifier {
+		return eat.NewVerifier(func(tok *eat.Token) ([]id.ID, error) {
+			return []id.ID{clientID}, nil
+		}).
+			WithClock(func() utc.UTC { return now }).
+			WithDefaultPolicy(eat.Policy{TrustedSigner: true})
+	}
+	verifier := newVerifier(now)
+
+	// the front-end's ephemeral key referenced in the root token, and the player's ephemeral key
+	frontSK, _ := crypto.GenerateKey()
+	frontAddr := crypto.PubkeyToAddress(frontSK.PublicKey)
+	playerSK, _ := crypto.GenerateKey()
+	playerAddr := crypto.PubkeyToAddress(playerSK.PublicKey)
+
+	newRoot := func(format eat.TokenFormat) *eat.Token {
+		tok := eat.NewEditorSigned(sid, lid, qid).
+			WithSubject(clientID.String()).
+			WithIssuedAt(now).
+			WithExpires(now.Add(time.Hour)).
+			WithConfirmation(eat.ClientConfirmation{AddrOfEphemeralKey: frontAddr.String()}).
+			Token().
+			With(format)
+		require.NoError(t, tok.SignWith(clientSK))
+		tok, err := eat.Parse(tok.String())
+		require.NoError(t, err)
+		return tok
+	}
+	caveats := eat.Caveats{
+		Paths:   []structured.Path{{"meta", "public"}, {"rep", "playout"}},
+		IPs:     []string{"10.0.0.0/8"},
+		Session: "session-1",
+		MaxUses: 10,
+	}
+	newChild := func(root *eat.Token) *eat.Token {
+		return mustParse(eat.NewAttenuated(root).
+			WithIssuedAt(now).
+			WithExpires(now.Add(5 * time.Minute)).
+			WithCaveats(caveats).
+			WithConfirmation(eat.ClientConfirmation{AddrOfEphemeralKey: playerAddr.String()}).
+			Sign(frontSK))
+	}
+
+	for _, format := range []eat.TokenFormat{
+		eat.Formats.Json(),
+		eat.Formats.JsonCompressed(),
+		eat.Formats.Cbor(),
+		eat.Formats.CborCompressed(),
+		eat.Formats.Custom(),
+	} {
+		t.Run(format.String(), func(t *testing.T) {
+			root := newRoot(format)
+			child := newChild(root)
+			require.Equal(t, eat.Types.Attenuated(), child.Type)
+			require.Equal(t, format, child.Format)
+			require.Equal(t, frontAddr, child.EthAddr)
+			require.Equal(t, root.String(), child.Embedded.String())
+			require.Equal(t, root.String(), child.AttenuationRoot().String())
+			require.Equal(t, caveats, *child.Caveats)
+
+			grandchild := mustParse(eat.NewAttenuated(child).
+				WithIssuedAt(now).
+				WithExpires(now.Add(time.Minute)).
+				WithCaveats(eat.Caveats{
+					Paths:   []structured.Path{{"rep", "playout", "default"}},
+					MaxUses: 5,
+				}).
+				Sign(playerSK))
+			require.Equal(t, root.String(), grandchild.AttenuationRoot().String())
+			require.Equal(t, &eat.Caveats{
+				Paths:   []structured.Path{{"rep", "playout", "default"}},
+				IPs:     caveats.IPs,
+				Session: caveats.Session,
+				MaxUses: 5,
+			}, grandchild.EffectiveCaveats())
+
+			auth, err := verifier.Verify(grandchild)
+			require.NoError(t, err)
+			require.Equal(t, eat.Types.Attenuated(), auth.Type)
+			require.Equal(t, grandchild.String(), auth.Bearer)
+			require.Equal(t, qid, auth.QID)
+			require.Equal(t, eat.Grants.Read, auth.Grant)
+			require.Equal(t, clientID, auth.UserId())
+			require.True(t, grandchild.Expires.Equal(auth.Expires))
+			require.False(t, auth.Confirmation.Required)
+
+			require.True(t, auth.Allows(eat.Ops.Read, qid, structured.ParsePath("/rep/playout/default/hls")))
+			require.False(t, auth.Allows(eat.Ops.Read, qid, structured.ParsePath("/rep/playout/other")))
+			require.False(t, auth.Allows(eat.Ops.Read, qid, structured.ParsePath("/meta/public")))
+			require.True(t, auth.Caveats.AllowsIP("10.1.2.3"))
+			require.True(t, auth.Caveats.AllowsIP("::ffff:10.1.2.3"))
+			require.False(t, auth.Caveats.AllowsIP("192.168.1.1"))
+			require.False(t, auth.Caveats.AllowsIP("invalid"))
+			require.True(t, auth.Caveats.AllowsSession("session-1"))
+			require.False(t, auth.Caveats.AllowsSession("session-2"))
+
+			// the child requires the confirmation of the player's ephemeral key when used directly
+			_, err = verifier.Verify(child)
+			require.Error(t, err)
+			require.Equal(t, eat.RejectReasons.InvalidConfirmation, eat.RejectReasonOf(err))
+			confirmation := mustParse(eat.NewClientConfirmation(now, time.Minute).Sign(playerSK))
+			auth, err = verifier.Verify(child, confirmation)
+			require.NoError(t, err)
+			require.Equal(t, &caveats, auth.Caveats)
+
+			// stripping the caveats yields the root, which requires the confirmation of the front-end's ephemeral key
+			_, err = verifier.Verify(grandchild.AttenuationRoot())
+			require.Error(t, err)
+			require.Equal(t, eat.RejectReasons.InvalidConfirmation, eat.RejectReasonOf(err))
+
+			// the chain is verified up to the root
+			_, err = newVerifier(now.Add(2 * time.Minute)).Verify(grandchild)
+			require.Error(t, err)
+			require.Equal(t, eat.RejectReasons.InvalidTimes, eat.RejectReasonOf(err))
+		})
+	}
+
+	t.Run("narrowing only", func(t *testing.T) {
+		child := newChild(newRoot(eat.Formats.Cbor()))
+		attenuate := func(caveats eat.Caveats) *eat.AttenuatedBuilder {
+			return eat.NewAttenuated(child).
+				WithIssuedAt(now).
+				WithExpires(now.Add(time.Minute)).
+				WithCaveats(caveats)
+		}
+
+		for _, test := range []struct {
+			name string
+			enc  eat.Encoder
+		}{
+			{"narrower", attenuate(eat.Caveats{
+				Paths:   []structured.Path{{"meta", "public", "title"}},
+				IPs:     []string{"10.1.0.0/16", "10.2.3.4"},
+				Session: "session-1",
+				MaxUses: 10,
+			}).Sign(playerSK)},
+			{"inherited", attenuate(eat.Caveats{}).Sign(playerSK)},
+		} {
+			_, err := test.enc.Encode()
+			require.NoError(t, err, test.name)
+		}
+
+		for _, test := range []struct {
+			name string
+			enc  eat.Encoder
+		}{
+			{"expires after parent", attenuate(eat.Caveats{}).WithExpires(now.Add(time.Hour)).Sign(playerSK)},
+			{"issued before parent", attenuate(eat.Caveats{}).WithIssuedAt(now.Add(-time.Second)).Sign(playerSK)},
+			{"path", attenuate(eat.Caveats{Paths: []structured.Path{{"meta"}}}).Sign(playerSK)},
+			{"ips", attenuate(eat.Caveats{IPs: []string{"0.0.0.0/0"}}).Sign(playerSK)},
+			{"invalid ip", attenuate(eat.Caveats{IPs: []string{"10.0.0.0/33"}}).Sign(playerSK)},
+			{"session", attenuate(eat.Caveats{Session: "session-2"}).Sign(playerSK)},
+			{"max uses", attenuate(eat.Caveats{MaxUses: 11}).Sign(playerSK)},
+			{"wrong key", attenuate(eat.Caveats{}).Sign(frontSK)},
+			{"wrong sig type", attenuate(eat.Caveats{}).SignEIP912Personal(playerSK)},
+			{"no confirmation", eat.NewAttenuated(mustParse(eat.NewEditorSigned(sid, lid, qid).Sign(clientSK))).
+				Sign(playerSK)},
+			{"state channel", eat.NewAttenuated(mustParse(eat.NewStateChannel(sid, lid, qid, "sub").
+				Sign(clientSK))).
+				Sign(playerSK)},
+		} {
+			_, err := test.enc.Encode()
+			require.Error(t, err, test.name)
+		}
+
+		// caveats are refused in other tokens
+		tok := eat.NewEditorSigned(sid, lid, qid).Token()
+		tok.Caveats = &eat.Caveats{MaxUses: 1}
+		require.NoError(t, tok.SignWith(clientSK))
+		_, err := tok.Encode()
+		require.Error(t, err)
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		tok := newRoot(eat.Formats.Custom())
+		sk := frontSK
+		var err error
+		for depth := 1; depth <= 9; depth++ {
+			nextSK, _ := crypto.GenerateKey()
+			var encoded string
+			encoded, err = eat.NewAttenuated(tok).
+				WithIssuedAt(now).
+				WithConfirmation(eat.ClientConfirmation{
+					AddrOfEphemeralKey: crypto.PubkeyToAddress(nextSK.PublicKey).String(),
+				}).
+				Sign(sk).
+				Encode()
+			if err != nil {
+				require.Equal(t, 9, depth)
+				break
+			}
+			tok, err = eat.Parse(encoded)
+			require.NoError(t, err)
+			sk = nextSK
+		}
+		require.Error(t, err)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		// client-signed root tokens identify the user by the signer's address
+		root := mustParse(eat.NewClientSigned(sid).
+			WithQID(qid).
+			WithIssuedAt(now).
+			WithExpires(now.Add(time.Hour)).
+			WithConfirmation(eat.ClientConfirmation{AddrOfEphemeralKey: frontAddr.String()}).
+			Sign(clientSK))
+		tok := mustParse(eat.NewAttenuated(root).WithExpires(now.Add(time.Minute)).Sign(frontSK))
+		auth, err := eat.NewAuthorization(tok)
+		require.NoError(t, err)
+		require.Equal(t, ethutil.AddressToID(clientAddr, id.User), auth.UserId())
+		require.Nil(t, auth.Caveats)
+		require.True(t, auth.Allows(eat.Ops.Read, qid, structured.ParsePath("/any")))
+	})
+}
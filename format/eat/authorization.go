@@ -15,8 +15,16 @@ func NewAuthorization(tok *Token, conf ...*Token) (*Authorization, error) {
 		return nil, errors.E("NewAuthorization", errors.K.Invalid, "reason", "token is nil")
 	}
 	data := tok.TokenData.Copy()
-	// when an embedded exists this is a state channel, hence it has precedence
-	if tok.Embedded != nil {
+	if tok.Type == Types.Attenuated() {
+		// the root of the chain defines the authorization, restricted by the caveats and validity of the chain, while the
+		// confirmation of the root is satisfied by the chain itself
+		data = tok.AttenuationRoot().TokenData.Copy()
+		data.IssuedAt = tok.IssuedAt
+		data.Expires = tok.Expires
+		data.Confirmation = tok.TokenData.Copy().Confirmation
+		data.Caveats = tok.EffectiveCaveats()
+	} else if tok.Embedded != nil {
+		// when an embedded exists this is a state channel, hence it has precedence
 		data = tok.Embedded.TokenData
 		if len(data.AFGHPublicKey) == 0 {
 			// only take it if not specified by elv master
@@ -79,6 +87,13 @@ func (a *Authorization) UserId() types.UserID {
 		if a.EthAddr != zeroAddr {
 			uid = ethutil.AddressToID(a.EthAddr, id.User)
 		}
+	case Types.Attenuated():
+		// the token data is the one of the chain's root token: client-signed root tokens identify the user by the
+		// signer's address, editor-signed root tokens by the subject
+		uid, _ = id.User.FromString(a.Subject)
+		if uid == nil && a.EthAddr != zeroAddr {
+			uid = ethutil.AddressToID(a.EthAddr, id.User)
+		}
 	case Types.Anonymous():
 	default:
 	}
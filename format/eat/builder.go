@@ -30,6 +30,11 @@ type Signer interface {
 	Sign(pk *ecdsa.PrivateKey) Encoder
 	// SignEIP912Personal signs the token with the given key, producing a EIP191PersonalSign signature.
 	SignEIP912Personal(pk *ecdsa.PrivateKey) Encoder
+	// SignEIP712TypedData signs the token with the given key, producing a EIP712TypedData signature on the token's
+	// typed data.
+	SignEIP712TypedData(pk *ecdsa.PrivateKey) Encoder
+	// SignWithSigner signs the token with the given token signer, producing a signature of the signer's type.
+	SignWithSigner(s TokenSigner) Encoder
 }
 
 type TokenBuilder interface {
@@ -136,6 +141,22 @@ func (b *signer) SignEIP912Personal(pk *ecdsa.PrivateKey) Encoder {
 	return b.enc
 }
 
+func (b *signer) SignEIP712TypedData(pk *ecdsa.PrivateKey) Encoder {
+	if b.enc.err != nil {
+		return b.enc
+	}
+	b.enc.err = b.enc.token.SignWithT(pk, SigTypes.EIP712TypedData())
+	return b.enc
+}
+
+func (b *signer) SignWithSigner(s TokenSigner) Encoder {
+	if b.enc.err != nil {
+		return b.enc
+	}
+	b.enc.err = b.enc.token.SignWithSigner(s)
+	return b.enc
+}
+
 func (b *signer) Token() *Token {
 	return b.enc.token
 }
@@ -188,6 +209,11 @@ func (b *StateChannelBuilder) WithCtx(ctx map[string]interface{}) *StateChannelB
 	return b
 }
 
+func (b *StateChannelBuilder) WithCapabilities(caps ...Capability) *StateChannelBuilder {
+	b.enc.token.Capabilities = caps
+	return b
+}
+
 // -----------------------------------------------------------------------------
 
 type TxBuilder struct {
@@ -292,6 +318,11 @@ func (b *EditorSignedBuilder) WithCtx(ctx map[string]interface{}) *EditorSignedB
 	return b
 }
 
+func (b *EditorSignedBuilder) WithCapabilities(caps ...Capability) *EditorSignedBuilder {
+	b.enc.token.Capabilities = caps
+	return b
+}
+
 func (b *EditorSignedBuilder) WithSubject(s string) *EditorSignedBuilder {
 	b.enc.token.Subject = s
 	return b
@@ -316,6 +347,20 @@ func (b *EditorSignedBuilder) SignEIP912Personal(pk *ecdsa.PrivateKey) Encoder {
 	return b.signer.SignEIP912Personal(pk)
 }
 
+func (b *EditorSignedBuilder) SignEIP712TypedData(pk *ecdsa.PrivateKey) Encoder {
+	if len(b.enc.token.Subject) == 0 {
+		b.enc.token.Subject = ethutil.AddressToID(crypto.PubkeyToAddress(pk.PublicKey), id.User).String()
+	}
+	return b.signer.SignEIP712TypedData(pk)
+}
+
+func (b *EditorSignedBuilder) SignWithSigner(s TokenSigner) Encoder {
+	if len(b.enc.token.Subject) == 0 && s != nil {
+		b.enc.token.Subject = signerSubject(s)
+	}
+	return b.signer.SignWithSigner(s)
+}
+
 // -----------------------------------------------------------------------------
 
 type PlainBuilder struct {
@@ -430,6 +475,18 @@ func (b *SignedLinkBuilder) SignEIP912Personal(pk *ecdsa.PrivateKey) Encoder {
 	return b.signer.SignEIP912Personal(pk)
 }
 
+func (b *SignedLinkBuilder) SignEIP712TypedData(pk *ecdsa.PrivateKey) Encoder {
+	b.enc.token.Subject = ethutil.AddressToID(crypto.PubkeyToAddress(pk.PublicKey), id.User).String()
+	return b.signer.SignEIP712TypedData(pk)
+}
+
+func (b *SignedLinkBuilder) SignWithSigner(s TokenSigner) Encoder {
+	if s != nil {
+		b.enc.token.Subject = signerSubject(s)
+	}
+	return b.signer.SignWithSigner(s)
+}
+
 // -----------------------------------------------------------------------------
 
 // PENDING(LUK): review offered methods on ClientSignedBuilder
@@ -481,6 +538,11 @@ func (b *ClientSignedBuilder) WithCtx(ctx map[string]interface{}) *ClientSignedB
 	return b
 }
 
+func (b *ClientSignedBuilder) WithCapabilities(caps ...Capability) *ClientSignedBuilder {
+	b.enc.token.Capabilities = caps
+	return b
+}
+
 func (b *ClientSignedBuilder) WithSubject(s string) *ClientSignedBuilder {
 	b.enc.token.Subject = s
 	return b
@@ -505,6 +567,20 @@ func (b *ClientSignedBuilder) SignEIP912Personal(pk *ecdsa.PrivateKey) Encoder {
 	return b.signer.SignEIP912Personal(pk)
 }
 
+func (b *ClientSignedBuilder) SignEIP712TypedData(pk *ecdsa.PrivateKey) Encoder {
+	if len(b.enc.token.Subject) == 0 {
+		b.enc.token.Subject = ethutil.AddressToID(crypto.PubkeyToAddress(pk.PublicKey), id.User).String()
+	}
+	return b.signer.SignEIP712TypedData(pk)
+}
+
+func (b *ClientSignedBuilder) SignWithSigner(s TokenSigner) Encoder {
+	if len(b.enc.token.Subject) == 0 && s != nil {
+		b.enc.token.Subject = signerSubject(s)
+	}
+	return b.signer.SignWithSigner(s)
+}
+
 // -----------------------------------------------------------------------------
 
 type ClientConfirmationBuilder struct {
@@ -539,3 +615,56 @@ func (b *ClientConfirmationBuilder) Sign(pk *ecdsa.PrivateKey) Encoder {
 func (b *ClientConfirmationBuilder) SignEIP912Personal(pk *ecdsa.PrivateKey) Encoder {
 	return b.signer.SignEIP912Personal(pk)
 }
+
+func (b *ClientConfirmationBuilder) SignEIP712TypedData(pk *ecdsa.PrivateKey) Encoder {
+	return b.signer.SignEIP712TypedData(pk)
+}
+
+func (b *ClientConfirmationBuilder) SignWithSigner(s TokenSigner) Encoder {
+	return b.signer.SignWithSigner(s)
+}
+
+// -----------------------------------------------------------------------------
+
+type AttenuatedBuilder struct {
+	*signer
+}
+
+// NewAttenuated creates a builder for an attenuated token that embeds and restricts the given parent token - see
+// NewAttenuatedToken(). The token must be signed with the ephemeral key referenced in the parent token's client
+// confirmation.
+func NewAttenuated(parent *Token) *AttenuatedBuilder {
+	token, err := NewAttenuatedToken(parent)
+	if err != nil {
+		b := &AttenuatedBuilder{newSigner(New(Types.Attenuated(), defaultFormat))}
+		b.enc.err = err
+		return b
+	}
+	return &AttenuatedBuilder{newSigner(token)}
+}
+
+func (b *AttenuatedBuilder) WithIssuedAt(issuedAt utc.UTC) *AttenuatedBuilder {
+	b.enc.token.IssuedAt = issuedAt
+	return b
+}
+
+func (b *AttenuatedBuilder) WithExpires(expiresAt utc.UTC) *AttenuatedBuilder {
+	b.enc.token.Expires = expiresAt
+	return b
+}
+
+func (b *AttenuatedBuilder) WithCaveats(caveats Caveats) *AttenuatedBuilder {
+	b.enc.token.Caveats = &caveats
+	return b
+}
+
+// WithConfirmation references the ephemeral key that is required for using the token directly, and that is used for
+// signing further attenuated tokens derived from it.
+func (b *AttenuatedBuilder) WithConfirmation(s ClientConfirmation) *AttenuatedBuilder {
+	b.enc.token.Confirmation = s
+	return b
+}
+
+func (b *AttenuatedBuilder) Sign(pk *ecdsa.PrivateKey) Encoder {
+	return b.signer.Sign(pk)
+}
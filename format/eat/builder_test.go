@@ -32,7 +32,7 @@ func TestTokenBuilders(t *testing.T) {
 		"k2": "v2",
 	}
 
-	sigTypes := []*eat.TokenSigType{eat.SigTypes.ES256K(), eat.SigTypes.EIP191Personal()}
+	sigTypes := []*eat.TokenSigType{eat.SigTypes.ES256K(), eat.SigTypes.EIP191Personal(), eat.SigTypes.EIP712TypedData()}
 	for _, sigType := range sigTypes {
 		sign := func(signer eat.Signer, key *ecdsa.PrivateKey) eat.Encoder {
 			switch sigType {
@@ -40,6 +40,8 @@ func TestTokenBuilders(t *testing.T) {
 				return signer.Sign(key)
 			case eat.SigTypes.EIP191Personal():
 				return signer.SignEIP912Personal(key)
+			case eat.SigTypes.EIP712TypedData():
+				return signer.SignEIP712TypedData(key)
 			}
 			panic(errors.E("sign", errors.K.Invalid, "sig_type", sigType))
 		}
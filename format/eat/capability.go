@@ -0,0 +1,137 @@
+package eat
+
+import (
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/common-go/format/types"
+	"github.com/eluv-io/errors-go"
+)
+
+// Op is the type of operations granted by capabilities.
+type Op string
+
+// Ops defines the operations granted by capabilities.
+var Ops = struct {
+	All    Op
+	Read   Op
+	Write  Op
+	Delete Op
+}{
+	All:    "*",      // all operations
+	Read:   "read",   // read resources
+	Write:  "write",  // create or update resources
+	Delete: "delete", // delete resources
+}
+
+// Quotas limit the usage of the resources granted by a capability. Zero values mean unlimited. Quotas are conveyed in
+// the token and must be enforced by the service accepting the token.
+type Quotas struct {
+	EgressBytes int64 `json:"egr,omitempty"` // max number of bytes served
+	Requests    int64 `json:"req,omitempty"` // max number of requests
+}
+
+// Capability grants operations on resources of a content object. Resources are identified by path globs with the
+// semantics of structured.FilterGlob(): a glob grants access to the resources at the matching paths and all their
+// children, and may contain wildcards '*' in place of path segments. Excluded globs take precedence, e.g.
+//
+//	Paths:   /meta/public
+//	Exclude: /meta/public/*/secret
+type Capability struct {
+	QID     types.QID         `json:"qid,omitempty"` // the content - the token's content if nil
+	Paths   []structured.Path `json:"pth,omitempty"` // globs of granted resource paths - all resources if empty
+	Exclude []structured.Path `json:"exc,omitempty"` // globs of excluded resource paths
+	Ops     []Op              `json:"ops,omitempty"` // granted operations
+	Quotas  *Quotas           `json:"quo,omitempty"` // usage limits - unlimited if nil
+}
+
+// Validate validates the capability.
+func (c *Capability) Validate() error {
+	e := errors.Template("validate capability", errors.K.Invalid)
+	if len(c.Ops) == 0 {
+		return e("reason", "no operations")
+	}
+	for _, op := range c.Ops {
+		if op == "" {
+			return e("reason", "empty operation")
+		}
+	}
+	if c.Quotas != nil && (c.Quotas.EgressBytes < 0 || c.Quotas.Requests < 0) {
+		return e("reason", "negative quota", "quotas", *c.Quotas)
+	}
+	return nil
+}
+
+// Allows returns true if the capability grants the given operation on the resource with the given path. It does not
+// check the capability's content.
+func (c *Capability) Allows(op Op, path structured.Path) bool {
+	if !c.allowsOp(op) {
+		return false
+	}
+	for _, glob := range c.Exclude {
+		if structured.MatchGlob(glob, path) {
+			return false
+		}
+	}
+	if len(c.Paths) == 0 {
+		return true
+	}
+	for _, glob := range c.Paths {
+		if structured.MatchGlob(glob, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter filters the given target (e.g. content metadata) to the resources granted by the capability - see
+// structured.FilterGlob().
+func (c *Capability) Filter(target interface{}) interface{} {
+	return structured.FilterGlob(target, c.Paths, c.Exclude)
+}
+
+func (c *Capability) allowsOp(op Op) bool {
+	for _, o := range c.Ops {
+		if o == op || o == Ops.All {
+			return true
+		}
+	}
+	return false
+}
+
+// Capability returns the first capability of the authorization that grants the given operation on the resource with
+// the given path in the content with the given ID, or false if there is none. Capabilities without content ID apply to
+// the authorization's content.
+//
+// If the authorization has no capabilities, it is not restricted by capabilities: the result is nil and true for the
+// authorization's content (or any content if the authorization has no content ID).
+//
+// In any case, the path must be allowed by the caveats of an authorization from an attenuated token - see
+// Caveats.AllowsPath().
+func (a *Authorization) Capability(op Op, qid types.QID, path structured.Path) (*Capability, bool) {
+	if !a.Caveats.AllowsPath(path) {
+		return nil, false
+	}
+	if len(a.Capabilities) == 0 {
+		return nil, a.QID.IsNil() || a.QID.Equal(qid)
+	}
+	for i := range a.Capabilities {
+		c := &a.Capabilities[i]
+		capQID := c.QID
+		if capQID.IsNil() {
+			capQID = a.QID
+		}
+		if !capQID.IsNil() && !capQID.Equal(qid) {
+			continue
+		}
+		if c.Allows(op, path) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Allows returns true if the authorization allows the given operation on the resource with the given path in the
+// content with the given ID - see Capability().
+func (a *Authorization) Allows(op Op, qid types.QID, path structured.Path) bool {
+	_, ok := a.Capability(op, qid, path)
+	return ok
+}
@@ -0,0 +1,134 @@
+package eat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/utc-go"
+)
+
+var capabilities = []eat.Capability{
+	{
+		Paths:   []structured.Path{structured.ParsePath("/meta/public")},
+		Exclude: []structured.Path{structured.ParsePath("/meta/public/*/secret")},
+		Ops:     []eat.Op{eat.Ops.Read},
+	},
+	{
+		QID:    qid,
+		Paths:  []structured.Path{structured.ParsePath("/files/assets")},
+		Ops:    []eat.Op{eat.Ops.Read, eat.Ops.Write},
+		Quotas: &eat.Quotas{EgressBytes: 10 << 30},
+	},
+}
+
+func TestCapabilities(t *testing.T) {
+	otherQID := id.Generate(id.Q)
+	auth := &eat.Authorization{
+		TokenData: eat.TokenData{
+			QID: qid,
+			Capabilities: append(capabilities, eat.Capability{
+				QID: otherQID,
+				Ops: []eat.Op{eat.Ops.All},
+			}),
+		},
+	}
+
+	tests := []struct {
+		op      eat.Op
+		qid     id.ID
+		path    string
+		allowed bool
+	}{
+		{eat.Ops.Read, qid, "/meta/public", true},
+		{eat.Ops.Read, qid, "/meta/public/a/b", true},
+		{eat.Ops.Read, qid, "/meta/public/a/secret", false},
+		{eat.Ops.Read, qid, "/meta/public/a/secret/b", false},
+		{eat.Ops.Read, qid, "/meta", false},
+		{eat.Ops.Read, qid, "/meta/private", false},
+		{eat.Ops.Write, qid, "/meta/public", false},
+		{eat.Ops.Write, qid, "/files/assets/video.mp4", true},
+		{eat.Ops.Delete, qid, "/files/assets/video.mp4", false},
+		{eat.Ops.Delete, otherQID, "/files/assets/video.mp4", true},
+		{eat.Ops.Read, id.Generate(id.Q), "/meta/public", false},
+	}
+	for _, test := range tests {
+		t.Run(string(test.op)+" "+test.path, func(t *testing.T) {
+			require.Equal(t, test.allowed, auth.Allows(test.op, test.qid, structured.ParsePath(test.path)))
+		})
+	}
+
+	c, ok := auth.Capability(eat.Ops.Read, qid, structured.ParsePath("/files/assets/a"))
+	require.True(t, ok)
+	require.EqualValues(t, 10<<30, c.Quotas.EgressBytes)
+
+	filtered := capabilities[0].Filter(structured.WrapJson(`{
+		"public": {"a": {"secret": 1, "title": "A"}},
+		"private": {"b": 2}
+	}`).Data)
+	require.Nil(t, filtered) // paths are rooted at the content - the metadata is at /meta
+	meta := structured.WrapJson(`{"meta": {"public": {"a": {"secret": 1, "title": "A"}}, "private": {"b": 2}}}`).Data
+	require.Equal(t, structured.WrapJson(`{"meta": {"public": {"a": {"title": "A"}}}}`).Data, capabilities[0].Filter(meta))
+
+	// without capabilities, the authorization is unrestricted for its content
+	auth.Capabilities = nil
+	require.True(t, auth.Allows(eat.Ops.Delete, qid, structured.ParsePath("/meta")))
+	require.False(t, auth.Allows(eat.Ops.Read, otherQID, structured.ParsePath("/meta")))
+}
+
+func TestCapabilitiesEncoding(t *testing.T) {
+	now := utc.Now().Truncate(time.Millisecond)
+	for _, format := range []eat.TokenFormat{
+		eat.Formats.Json(),
+		eat.Formats.JsonCompressed(),
+		eat.Formats.Cbor(),
+		eat.Formats.CborCompressed(),
+		eat.Formats.Custom(),
+	} {
+		for _, sigType := range []*eat.TokenSigType{eat.SigTypes.ES256K(), eat.SigTypes.EIP712TypedData()} {
+			t.Run(format.Name+" "+sigType.Name, func(t *testing.T) {
+				tok := eat.New(eat.Types.EditorSigned(), format)
+				tok.SID = sid
+				tok.LID = lid
+				tok.QID = qid
+				tok.Subject = clientID.String()
+				tok.Grant = eat.Grants.Read
+				tok.IssuedAt = now
+				tok.Expires = now.Add(time.Hour)
+				tok.Capabilities = capabilities
+				require.NoError(t, tok.SignWithT(clientSK, sigType))
+
+				decoded := assertEncodeDecode(t, tok)
+				require.Equal(t, capabilities, decoded.Capabilities)
+
+				// the signature covers the capabilities
+				decoded.Capabilities[0].Ops = []eat.Op{eat.Ops.All}
+				require.Error(t, decoded.With(format).VerifySignatureFrom(clientAddr))
+			})
+		}
+	}
+
+	t.Run("jws", func(t *testing.T) {
+		es256k, _ := jwsSigners(t)
+		tok := jwsToken()
+		tok.Capabilities = capabilities
+		jws, err := tok.EncodeJWS(es256k)
+		require.NoError(t, err)
+		decoded, err := eat.ParseJWS(jws)
+		require.NoError(t, err)
+		require.Equal(t, capabilities, decoded.Capabilities)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tok := eat.New(eat.Types.ClientSigned(), eat.Formats.Cbor())
+		tok.SID = sid
+		tok.Capabilities = []eat.Capability{{Paths: []structured.Path{{"meta"}}}}
+		require.NoError(t, tok.SignWithT(clientSK, eat.SigTypes.ES256K()))
+		_, err := tok.Encode()
+		require.Error(t, err)
+	})
+}
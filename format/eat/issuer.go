@@ -0,0 +1,264 @@
+package eat
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
+	"github.com/eluv-io/common-go/util/ethutil"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// IssuerKey is a signing key in the keyring of an Issuer.
+type IssuerKey struct {
+	KID       keys.Key    // the key ID: the public key of the signer
+	Signer    TokenSigner // the signer
+	Activates utc.UTC     // the key is used for signing from this time on - immediately if zero
+	Retires   utc.UTC     // the key is neither used for signing nor trusted from this time on - never if zero
+}
+
+// IsActiveAt returns true if the key may be used for signing at the given time.
+func (k *IssuerKey) IsActiveAt(now utc.UTC) bool {
+	return !now.Before(k.Activates) && !k.IsRetiredAt(now)
+}
+
+// IsRetiredAt returns true if the key is retired at the given time.
+func (k *IssuerKey) IsRetiredAt(now utc.UTC) bool {
+	return !k.Retires.IsZero() && !now.Before(k.Retires)
+}
+
+// Issuer mints tokens with the signing keys of its keyring. Keys are identified by their key ID (the signer's public
+// key, see keys.KID) and have an activation and a retirement time. Tokens are signed with the active key that was
+// activated last, so that a rotation is performed by adding the new key with a future activation time, and retiring the
+// old key once all tokens it signed have expired. In the meantime, verifiers accept tokens signed with any of the
+// non-retired keys - see PublicKeys() and TrustResolver().
+//
+// The issuer stamps the issued-at and expiration times of issued tokens according to the lifetime configured for their
+// type - see Issue().
+//
+// An Issuer is safe for concurrent use. Its configuration (clock and lifetimes) must not be modified once it is in use,
+// while keys may be added and retired at any time.
+type Issuer struct {
+	mutex           sync.RWMutex
+	keys            map[string]*IssuerKey
+	now             func() utc.UTC
+	lifetimes       map[TokenType]time.Duration
+	defaultLifetime time.Duration
+}
+
+// NewIssuer creates a new issuer with an empty keyring. The default lifetime of tokens is 1 hour.
+func NewIssuer() *Issuer {
+	return &Issuer{
+		keys:            map[string]*IssuerKey{},
+		now:             utc.Now,
+		lifetimes:       map[TokenType]time.Duration{},
+		defaultLifetime: time.Hour,
+	}
+}
+
+// WithClock sets the function returning the current time.
+func (i *Issuer) WithClock(now func() utc.UTC) *Issuer {
+	i.now = now
+	return i
+}
+
+// WithLifetime sets the lifetime of tokens of the given type. A lifetime of 0 leaves the expiration time of issued
+// tokens untouched.
+func (i *Issuer) WithLifetime(typ TokenType, lifetime time.Duration) *Issuer {
+	i.lifetimes[typ] = lifetime
+	return i
+}
+
+// WithDefaultLifetime sets the lifetime of tokens of types without specific lifetime.
+func (i *Issuer) WithDefaultLifetime(lifetime time.Duration) *Issuer {
+	i.defaultLifetime = lifetime
+	return i
+}
+
+// Lifetime returns the lifetime of tokens of the given type.
+func (i *Issuer) Lifetime(typ TokenType) time.Duration {
+	if lifetime, ok := i.lifetimes[typ]; ok {
+		return lifetime
+	}
+	return i.defaultLifetime
+}
+
+// AddKey adds the given signer to the keyring and returns its key ID. The key is used for signing between its
+// activation and retirement times - a zero activation time activates the key immediately, a zero retirement time never
+// retires it.
+func (i *Issuer) AddKey(signer TokenSigner, activates, retires utc.UTC) (keys.Key, error) {
+	e := errors.Template("Issuer.AddKey", errors.K.Invalid)
+	if signer == nil {
+		return nil, e("reason", "signer is nil")
+	}
+	kid := signer.PublicKey()
+	if kid.IsNil() {
+		return nil, e("reason", "signer has no public key")
+	}
+	if !retires.IsZero() && !retires.After(activates) {
+		return nil, e("reason", "key retires before its activation",
+			"kid", kid,
+			"activates", activates,
+			"retires", retires)
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if _, ok := i.keys[kid.String()]; ok {
+		return nil, e(errors.K.Exist, "reason", "key exists", "kid", kid)
+	}
+	i.keys[kid.String()] = &IssuerKey{
+		KID:       kid,
+		Signer:    signer,
+		Activates: activates,
+		Retires:   retires,
+	}
+	return kid, nil
+}
+
+// RetireKey sets the retirement time of the key with the given ID.
+func (i *Issuer) RetireKey(kid keys.Key, retires utc.UTC) error {
+	e := errors.Template("Issuer.RetireKey", errors.K.Invalid, "kid", kid)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	key, ok := i.keys[kid.String()]
+	if !ok {
+		return e(errors.K.NotExist, "reason", "key not found")
+	}
+	if !retires.IsZero() && !retires.After(key.Activates) {
+		return e("reason", "key retires before its activation",
+			"activates", key.Activates,
+			"retires", retires)
+	}
+	key.Retires = retires
+	return nil
+}
+
+// RemoveKey removes the key with the given ID from the keyring. Tokens signed with the key are no longer trusted.
+func (i *Issuer) RemoveKey(kid keys.Key) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	delete(i.keys, kid.String())
+}
+
+// Keys returns a copy of all keys in the keyring, ordered by activation time.
+func (i *Issuer) Keys() []IssuerKey {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	return i.sortedKeys(func(*IssuerKey) bool { return true })
+}
+
+// ActiveKey returns the key used for signing at the current time: the active key that was activated last.
+func (i *Issuer) ActiveKey() (IssuerKey, error) {
+	return i.activeKeyAt(i.now())
+}
+
+func (i *Issuer) activeKeyAt(now utc.UTC) (IssuerKey, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	active := i.sortedKeys(func(key *IssuerKey) bool { return key.IsActiveAt(now) })
+	if len(active) == 0 {
+		return IssuerKey{}, errors.E("Issuer.ActiveKey", errors.K.NotExist, "reason", "no active key", "now", now)
+	}
+	return active[len(active)-1], nil
+}
+
+// PublicKeys returns the IDs of all keys that are not retired at the current time, including keys that are not yet
+// active. Verifiers should accept tokens signed with any of these keys.
+func (i *Issuer) PublicKeys() []keys.Key {
+	now := i.now()
+
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	var res []keys.Key
+	for _, key := range i.sortedKeys(func(key *IssuerKey) bool { return !key.IsRetiredAt(now) }) {
+		res = append(res, key.KID)
+	}
+	return res
+}
+
+// TrustResolver returns a trust resolver for a Verifier that trusts the signers of all keys returned by PublicKeys() at
+// the time of verification. Keys that cannot be represented as ID (see Token.VerifySignatureFromID()) are ignored.
+func (i *Issuer) TrustResolver() TrustResolver {
+	return func(*Token) ([]id.ID, error) {
+		var res []id.ID
+		for _, kid := range i.PublicKeys() {
+			switch kid.Code() {
+			case keys.ES256KPublicKey:
+				addr, err := publicKeyAddress(kid)
+				if err != nil {
+					return nil, errors.E("Issuer.TrustResolver", errors.K.Invalid, err, "kid", kid)
+				}
+				res = append(res, ethutil.AddressToID(addr, id.User))
+			case keys.ED25519PublicKey:
+				res = append(res, id.NewID(id.Ed25519, kid.Bytes()))
+			}
+		}
+		return res, nil
+	}
+}
+
+// Issue stamps the issued-at and expiration times of the given token, signs it with the active key and returns its
+// encoded form. The token is modified in place.
+//
+// The expiration time is limited to the lifetime of the token's type: an earlier expiration time set by the caller is
+// retained. Tokens of types that refuse validity times (anonymous, tx, plain and client tokens) are not stamped at all.
+func (i *Issuer) Issue(tok *Token) (string, error) {
+	e := errors.Template("Issuer.Issue", errors.K.Invalid)
+	if tok.IsNil() {
+		return "", e("reason", "token is nil")
+	}
+
+	now := i.now()
+	key, err := i.activeKeyAt(now)
+	if err != nil {
+		return "", e(err)
+	}
+
+	if allowsTimes(tok.Type) {
+		tok.IssuedAt = now
+		if lifetime := i.Lifetime(tok.Type); lifetime > 0 {
+			expires := now.Add(lifetime)
+			if tok.Expires.IsZero() || expires.Before(tok.Expires) {
+				tok.Expires = expires
+			}
+		}
+	}
+
+	err = tok.SignWithSigner(key.Signer)
+	if err != nil {
+		return "", e(err, "kid", key.KID)
+	}
+	encoded, err := tok.Encode()
+	if err != nil {
+		return "", e(err, "kid", key.KID)
+	}
+	return encoded, nil
+}
+
+// sortedKeys returns copies of the keys matching the given filter, ordered by activation time and key ID.
+func (i *Issuer) sortedKeys(filter func(key *IssuerKey) bool) []IssuerKey {
+	var res []IssuerKey
+	for _, key := range i.keys {
+		if filter(key) {
+			res = append(res, *key)
+		}
+	}
+	sort.Slice(res, func(a, b int) bool {
+		if !res[a].Activates.Equal(res[b].Activates) {
+			return res[a].Activates.Before(res[b].Activates)
+		}
+		return res[a].KID.String() < res[b].KID.String()
+	})
+	return res
+}
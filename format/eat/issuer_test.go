@@ -0,0 +1,135 @@
+package eat_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/format/keys"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+func TestIssuer(t *testing.T) {
+	now := utc.UnixMilli(1700000000000)
+	clock := func() utc.UTC { return now }
+
+	sk1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer1 := eat.NewES256KSigner(sk1)
+	signer2 := eat.NewED25519Signer(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{7}, ed25519.SeedSize)))
+
+	issuer := eat.NewIssuer().
+		WithClock(clock).
+		WithLifetime(eat.Types.SignedLink(), 0).
+		WithLifetime(eat.Types.Node(), time.Minute).
+		WithDefaultLifetime(10 * time.Minute)
+	verifier := eat.NewVerifier(issuer.TrustResolver()).
+		WithClock(clock).
+		WithDefaultPolicy(eat.Policy{TrustedSigner: true})
+
+	newToken := func() *eat.Token {
+		return eat.NewEditorSigned(sid, lid, qid).WithSubject(clientID.String()).Token()
+	}
+	issue := func(tok *eat.Token) *eat.Token {
+		encoded, err := issuer.Issue(tok)
+		require.NoError(t, err)
+		parsed, err := eat.Parse(encoded)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	_, err = issuer.Issue(newToken())
+	require.Error(t, err)
+	require.True(t, errors.IsKind(errors.K.NotExist, err))
+
+	kid1, err := issuer.AddKey(signer1, utc.UTC{}, utc.UTC{})
+	require.NoError(t, err)
+	require.Equal(t, signer1.PublicKey(), kid1)
+
+	_, err = issuer.AddKey(signer1, utc.UTC{}, utc.UTC{})
+	require.True(t, errors.IsKind(errors.K.Exist, err))
+	_, err = issuer.AddKey(signer2, now, now.Add(-time.Second))
+	require.Error(t, err)
+
+	tok1 := issue(newToken())
+	require.Equal(t, eat.SigTypes.ES256K(), tok1.SigType)
+	require.NoError(t, tok1.VerifySignatureFromKey(kid1))
+	require.True(t, now.Equal(tok1.IssuedAt))
+	require.True(t, now.Add(10*time.Minute).Equal(tok1.Expires))
+
+	// type specific lifetimes
+	node := issue(eat.NewNodeToken(sid, qph).Token())
+	require.True(t, now.Add(time.Minute).Equal(node.Expires))
+
+	// an earlier expiration time is retained
+	short := newToken()
+	short.Expires = now.Add(time.Minute)
+	short = issue(short)
+	require.True(t, now.Equal(short.IssuedAt))
+	require.True(t, now.Add(time.Minute).Equal(short.Expires))
+
+	// types that refuse validity times are not stamped
+	plain := issue(eat.NewPlain(sid, lid).WithQID(qid).Token())
+	require.True(t, plain.IssuedAt.IsZero())
+	require.True(t, plain.Expires.IsZero())
+	require.NoError(t, plain.Validate())
+
+	_, err = verifier.Verify(tok1)
+	require.NoError(t, err)
+
+	// rotation: add the new key with a future activation time - it is published but not used yet
+	kid2, err := issuer.AddKey(signer2, now.Add(time.Hour), utc.UTC{})
+	require.NoError(t, err)
+	require.Equal(t, []eat.IssuerKey{
+		{KID: kid1, Signer: signer1},
+		{KID: kid2, Signer: signer2, Activates: now.Add(time.Hour)},
+	}, issuer.Keys())
+	require.Equal(t, []keys.Key{kid1, kid2}, issuer.PublicKeys())
+	active, err := issuer.ActiveKey()
+	require.NoError(t, err)
+	require.Equal(t, kid1, active.KID)
+
+	// the new key is activated and used
+	now = now.Add(time.Hour)
+	tok2 := issue(newToken())
+	require.Equal(t, eat.SigTypes.ED25519(), tok2.SigType)
+	require.NoError(t, tok2.VerifySignatureFromKey(kid2))
+
+	// tokens signed with the old key are accepted until it is retired
+	require.NoError(t, issuer.RetireKey(kid1, now.Add(time.Minute)))
+	reissued := tok1.With(tok1.Format)
+	reissued.Expires = utc.UTC{} // the expired token is re-issued with the full lifetime
+	tok1 = issue(reissued)
+	require.NoError(t, tok1.VerifySignatureFromKey(kid2))
+
+	old := eat.NewEditorSigned(sid, lid, qid).
+		WithIssuedAt(now).
+		WithExpires(now.Add(time.Hour)).
+		SignWithSigner(signer1).
+		MustEncode()
+	oldTok, err := eat.Parse(old)
+	require.NoError(t, err)
+	_, err = verifier.Verify(oldTok)
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	require.Equal(t, []keys.Key{kid2}, issuer.PublicKeys())
+	_, err = verifier.Verify(oldTok)
+	require.Error(t, err)
+	require.Equal(t, eat.RejectReasons.UntrustedSigner, eat.RejectReasonOf(err))
+	_, err = verifier.Verify(tok2)
+	require.NoError(t, err)
+
+	require.Error(t, issuer.RetireKey(kid2, now.Add(-2*time.Hour)))
+	require.True(t, errors.IsKind(errors.K.NotExist, issuer.RetireKey(signer1.PublicKey()[:1], now)))
+
+	issuer.RemoveKey(kid2)
+	_, err = issuer.ActiveKey()
+	require.Error(t, err)
+}
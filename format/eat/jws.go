@@ -0,0 +1,322 @@
+package eat
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/eluv-io/common-go/format/keys"
+	"github.com/eluv-io/common-go/format/sign"
+	"github.com/eluv-io/common-go/format/types"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// JWS algorithms supported for the export and import of tokens as JSON Web Signatures (RFC 7515) in compact
+// serialization:
+//   - ES256K: ECDSA with secp256k1 and SHA-256 (RFC 8812), corresponding to signature type ES256K
+//   - EdDSA: ED25519 (RFC 8037), corresponding to signature type ED25519
+const (
+	JwsAlgES256K = "ES256K"
+	JwsAlgEdDSA  = "EdDSA"
+)
+
+// jwsTyp is the media type of JWS tokens.
+const jwsTyp = "JWT"
+
+// jwsHeader is the JOSE header of JWS tokens.
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"` // the ID of the signer: user ID for ES256K, ed25519 ID for EdDSA
+}
+
+// jwsClaims are the claims of JWS tokens. The subject, issued-at and expiration times are mapped to the registered
+// claims "sub", "iat" and "exp" (with millisecond fractions), all other token data to private claims with the names
+// of the JSON format. The token type is stored in the private claim "eat" as prefix, e.g. "acs".
+type jwsClaims struct {
+	Type          string                 `json:"eat,omitempty"`
+	EthTxHash     string                 `json:"txh,omitempty"`
+	EthAddr       string                 `json:"adr,omitempty"`
+	AFGHPublicKey string                 `json:"apk,omitempty"`
+	QPHash        types.QPHash           `json:"qph,omitempty"`
+	SignerKey     keys.Key               `json:"spk,omitempty"`
+	SID           types.QSpaceID         `json:"spc,omitempty"`
+	LID           types.QLibID           `json:"lib,omitempty"`
+	QID           types.QID              `json:"qid,omitempty"`
+	Subject       string                 `json:"sub,omitempty"`
+	Grant         Grant                  `json:"gra,omitempty"`
+	IssuedAt      numericDate            `json:"iat,omitempty"`
+	Expires       numericDate            `json:"exp,omitempty"`
+	Ctx           map[string]interface{} `json:"ctx,omitempty"`
+	Cnf           *serClientConfirmation `json:"cnf,omitempty"`
+	Cap           []Capability           `json:"cap,omitempty"`
+}
+
+func (c *jwsClaims) copyFrom(t *Token) *jwsClaims {
+	c.Type = t.Type.Prefix
+	if t.EthTxHash != zeroHash {
+		c.EthTxHash = t.EthTxHash.Hex()
+	}
+	if t.HasEthAddr() {
+		c.EthAddr = t.EthAddr.Hex()
+	}
+	c.AFGHPublicKey = t.AFGHPublicKey
+	c.QPHash = t.QPHash
+	c.SignerKey = t.SignerKey
+	c.SID = t.SID
+	c.LID = t.LID
+	c.QID = t.QID
+	c.Subject = t.Subject
+	c.Grant = t.Grant
+	c.IssuedAt = numericDateOf(t.IssuedAt)
+	c.Expires = numericDateOf(t.Expires)
+	c.Ctx = t.Ctx
+	if t.Confirmation != zeroCnf {
+		c.Cnf = &serClientConfirmation{
+			Aek: t.Confirmation.AddrOfEphemeralKey,
+			Pek: t.Confirmation.PublicEphemeralKey,
+			Ttl: t.Confirmation.TTL,
+		}
+	}
+	c.Cap = t.Capabilities
+	return c
+}
+
+func (c *jwsClaims) copyTo(t *Token) error {
+	e := errors.Template("decode claims", errors.K.Invalid)
+
+	t.Type = Types.ClientSigned()
+	if c.Type != "" {
+		typ, ok := prefixToType[c.Type]
+		if !ok {
+			return e("reason", "unknown token type", "eat", c.Type)
+		}
+		t.Type = typ
+	}
+	if c.EthTxHash != "" {
+		t.EthTxHash = common.HexToHash(c.EthTxHash)
+	}
+	if c.EthAddr != "" {
+		if !common.IsHexAddress(c.EthAddr) {
+			return e("reason", "invalid address", "adr", c.EthAddr)
+		}
+		t.EthAddr = common.HexToAddress(c.EthAddr)
+	}
+	t.AFGHPublicKey = c.AFGHPublicKey
+	t.QPHash = c.QPHash
+	t.SignerKey = c.SignerKey
+	t.SID = c.SID
+	t.LID = c.LID
+	t.QID = c.QID
+	t.Subject = c.Subject
+	t.Grant = c.Grant
+	t.IssuedAt = c.IssuedAt.UTC()
+	t.Expires = c.Expires.UTC()
+	t.Ctx = c.Ctx
+	t.Confirmation = c.Cnf.toClientConfirmation()
+	t.Capabilities = c.Cap
+	return nil
+}
+
+// numericDate is a JWT NumericDate (RFC 7519): the number of seconds since the epoch. It is stored in milliseconds and
+// marshalled with a fractional part if the milliseconds are not zero.
+type numericDate int64
+
+func numericDateOf(t utc.UTC) numericDate {
+	if t.IsZero() {
+		return 0
+	}
+	return numericDate(t.UnixMilli())
+}
+
+func (n numericDate) UTC() utc.UTC {
+	if n == 0 {
+		return utc.UTC{}
+	}
+	return utc.UnixMilli(int64(n))
+}
+
+func (n numericDate) MarshalJSON() ([]byte, error) {
+	if n%1000 == 0 {
+		return []byte(strconv.FormatInt(int64(n/1000), 10)), nil
+	}
+	return []byte(strconv.FormatFloat(float64(n)/1000, 'f', 3, 64)), nil
+}
+
+func (n *numericDate) UnmarshalJSON(bts []byte) error {
+	f, err := strconv.ParseFloat(string(bts), 64)
+	if err != nil {
+		return errors.E("numericDate.UnmarshalJSON", errors.K.Invalid, err, "date", string(bts))
+	}
+	*n = numericDate(math.Round(f * 1000))
+	return nil
+}
+
+// EncodeJWS exports this token as JWS in compact serialization, signed with the given signer. The signer must produce
+// signatures of type ES256K (JWS algorithm "ES256K") or ED25519 (JWS algorithm "EdDSA").
+//
+// As with the token's own formats, the signer is identified in the token data: for ES256K, the "adr" claim is set to
+// the signer's address, for EdDSA, the "spk" claim is set to the signer's public key. The token itself is not modified.
+// State channel tokens and tokens with embedded tokens cannot be exported.
+func (t *Token) EncodeJWS(signer TokenSigner) (string, error) {
+	e := errors.Template("encode JWS", errors.K.Invalid)
+	if t.IsNil() {
+		return "", e("reason", "token is nil")
+	}
+	if signer == nil {
+		return "", e("reason", "signer is nil")
+	}
+	if t.Type == Types.StateChannel() || t.Embedded != nil {
+		return "", e("reason", "token type not supported", "type", t.Type)
+	}
+
+	tok := *t
+	tok.Embedded = nil
+	tok.clearCaches()
+
+	header := jwsHeader{Typ: jwsTyp, Kid: signerSubject(signer)}
+	switch signer.SigType() {
+	case SigTypes.ES256K():
+		header.Alg = JwsAlgES256K
+		addr, err := signerAddress(signer)
+		if err != nil {
+			return "", e(err)
+		}
+		tok.EthAddr = addr
+		tok.SignerKey = nil
+		tok.SigType = SigTypes.ES256K()
+	case SigTypes.ED25519():
+		header.Alg = JwsAlgEdDSA
+		tok.EthAddr = zeroAddr
+		tok.SignerKey = signer.PublicKey()
+		tok.SigType = SigTypes.ED25519()
+	default:
+		return "", e("reason", "signature type not supported", "sig_type", signer.SigType())
+	}
+
+	hdr, err := json.Marshal(&header)
+	if err != nil {
+		return "", e(err)
+	}
+	claims, err := json.Marshal((&jwsClaims{}).copyFrom(&tok))
+	if err != nil {
+		return "", e(err)
+	}
+	signingInput := jwsEncoding.EncodeToString(hdr) + "." + jwsEncoding.EncodeToString(claims)
+
+	var sig []byte
+	if header.Alg == JwsAlgES256K {
+		hsh := sha256.Sum256([]byte(signingInput))
+		sig, err = signer.Sign(hsh[:])
+		if err == nil {
+			if len(sig) != 65 {
+				return "", e("reason", "invalid signature length", "len", len(sig))
+			}
+			// drop the recovery ID: JWS signatures are R || S
+			sig = sig[:64]
+		}
+	} else {
+		sig, err = signer.Sign([]byte(signingInput))
+	}
+	if err != nil {
+		return "", e(err)
+	}
+
+	return signingInput + "." + jwsEncoding.EncodeToString(sig), nil
+}
+
+// ParseJWS imports a token from the given JWS in compact serialization, as produced by Token.EncodeJWS(). The JWS
+// algorithm must be "ES256K" or "EdDSA".
+//
+// The token is validated like a token in any other format, including the verification of its signature against the
+// signer identified in the token data ("adr" for ES256K, "spk" for EdDSA). Use Token.VerifySignatureFrom(),
+// Token.VerifySignatureFromKey() or a Verifier with a trust resolver in order to verify that the signer is trusted.
+//
+// The returned token reports the JSON format. Its string form is the original JWS, which is therefore also the bearer
+// of an authorization created from the token.
+func ParseJWS(jws string) (*Token, error) {
+	e := errors.Template("parse JWS", errors.K.Invalid)
+
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		return nil, e("reason", "invalid JWS compact serialization")
+	}
+
+	var header jwsHeader
+	err := jwsDecode(parts[0], &header)
+	if err != nil {
+		return nil, e(err, "reason", "invalid header")
+	}
+	if header.Typ != "" && !strings.EqualFold(header.Typ, jwsTyp) {
+		return nil, e("reason", "invalid type", "typ", header.Typ)
+	}
+
+	var claims jwsClaims
+	err = jwsDecode(parts[1], &claims)
+	if err != nil {
+		return nil, e(err, "reason", "invalid claims")
+	}
+
+	sig, err := jwsEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, e(err, "reason", "invalid signature")
+	}
+
+	t := &Token{Format: Formats.Json()}
+	err = claims.copyTo(t)
+	if err != nil {
+		return nil, e(err)
+	}
+	if t.Type == Types.StateChannel() || t.Type.embedsToken() {
+		return nil, e("reason", "token type not supported", "type", t.Type)
+	}
+
+	signingInput := []byte(parts[0] + "." + parts[1])
+	switch header.Alg {
+	case JwsAlgES256K:
+		if len(sig) != 64 {
+			return nil, e("reason", "invalid signature length", "len", len(sig))
+		}
+		// restore the recovery ID from the signer's address - if it doesn't match, signature validation fails below
+		hsh := sha256.Sum256(signingInput)
+		sig = append(sig, 0)
+		if pub, err := crypto.SigToPub(hsh[:], sig); err != nil || crypto.PubkeyToAddress(*pub) != t.EthAddr {
+			sig[64] = 1
+		}
+		t.SigType = SigTypes.ES256K()
+		t.Signature = sign.NewSig(t.SigType.Code, sig)
+	case JwsAlgEdDSA:
+		t.SigType = SigTypes.ED25519()
+		t.Signature = sign.NewSig(t.SigType.Code, sig)
+	default:
+		return nil, e("reason", "unsupported algorithm", "alg", header.Alg)
+	}
+
+	t.jws = true
+	t.payload = signingInput
+	t.encoded = jws
+
+	err = t.Validate()
+	if err != nil {
+		return nil, e(err)
+	}
+	return t, nil
+}
+
+// jwsEncoding is the base64url encoding without padding used by JWS.
+var jwsEncoding = base64.RawURLEncoding
+
+func jwsDecode(s string, v interface{}) error {
+	bts, err := jwsEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bts, v)
+}
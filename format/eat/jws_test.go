@@ -0,0 +1,183 @@
+package eat_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
+	"github.com/eluv-io/common-go/util/ethutil"
+	"github.com/eluv-io/utc-go"
+)
+
+// JWS of jwsToken() signed with the signers of jwsSigners()
+const (
+	jwsES256K = "eyJhbGciOiJFUzI1NksiLCJ0eXAiOiJKV1QiLCJraWQiOiJpdXNyMjd1NExnS0JLVDhCd3hxY0FDdm52UUJVdnAzTSJ9." +
+		"eyJlYXQiOiJhZXMiLCJhZHIiOiIweDUwNTBBNEY0YjNmOTMzOEMzNDcyZGNDMDFBODdDNzZBMTQ0YjNjOWMiLCJzcGMiOiJpc3BjMmdmenVXeGkya3JadjJTcWtOejNmNlVwTWJKZSIsImxpYiI6ImlsaWIzUml3aVA3VUpKaUh4Rkxia0w0NkJvVmZLV3JCIiwicWlkIjoiaXFfXzNSaXdpUDdVSkppSHhGTGJrTDQ2Qm9WZktXckIiLCJzdWIiOiJpdXNyMkxzZHU3Y3BtS29STHZ0VE4xcWVDcE5CZDJUdCIsImdyYSI6InJlYWQiLCJpYXQiOjE3MDAwMDAwMDAuMTIzLCJleHAiOjE3MDAwMDM2MDAuMTIzLCJjdHgiOnsia2V5IjoidmFsIn19." +
+		"0kqcoC6yG2lcYiE9j-RPaMoTJzfIm5oM3bBgOU3wLTsin--F_K9Y8jJwR4Qi9EXeHKwXwoshI_b342CGT83tHQ"
+	jwsEdDSA = "eyJhbGciOiJFZERTQSIsInR5cCI6IkpXVCIsImtpZCI6ImllZDJBS25MNE5OZjNER1daSlM2Y1BrbkJ1RUduVnNWNEE0bTV0Z2ViTEhhUlNaOSJ9." +
+		"eyJlYXQiOiJhZXMiLCJzcGsiOiJrcGVkQUtuTDROTmYzREdXWkpTNmNQa25CdUVHblZzVjRBNG01dGdlYkxIYVJTWjkiLCJzcGMiOiJpc3BjMmdmenVXeGkya3JadjJTcWtOejNmNlVwTWJKZSIsImxpYiI6ImlsaWIzUml3aVA3VUpKaUh4Rkxia0w0NkJvVmZLV3JCIiwicWlkIjoiaXFfXzNSaXdpUDdVSkppSHhGTGJrTDQ2Qm9WZktXckIiLCJzdWIiOiJpdXNyMkxzZHU3Y3BtS29STHZ0VE4xcWVDcE5CZDJUdCIsImdyYSI6InJlYWQiLCJpYXQiOjE3MDAwMDAwMDAuMTIzLCJleHAiOjE3MDAwMDM2MDAuMTIzLCJjdHgiOnsia2V5IjoidmFsIn19." +
+		"LEuMei8eMsVARN9mN0ztFZDHU9fZ3jOqss1WoMmQjS_bEhyPjaaC2KTIu7e6Mxq-M5ilOIJ99cA-WXrUfpd4AQ"
+)
+
+func jwsSigners(t *testing.T) (es256k, eddsa eat.TokenSigner) {
+	sk, err := crypto.ToECDSA(bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, err)
+	es256k = eat.NewES256KSigner(sk)
+	eddsa = eat.NewED25519Signer(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize)))
+	return es256k, eddsa
+}
+
+func jwsToken() *eat.Token {
+	iat := utc.UnixMilli(1700000000123)
+	return eat.NewEditorSigned(sid, lid, qid).
+		WithSubject("iusr2Lsdu7cpmKoRLvtTN1qeCpNBd2Tt").
+		WithGrant(eat.Grants.Read).
+		WithIssuedAt(iat).
+		WithExpires(iat.Add(time.Hour)).
+		WithCtx(map[string]interface{}{"key": "val"}).
+		Token()
+}
+
+func TestJWS(t *testing.T) {
+	es256k, eddsa := jwsSigners(t)
+
+	for _, test := range []struct {
+		signer eat.TokenSigner
+		vector string
+	}{
+		{es256k, jwsES256K},
+		{eddsa, jwsEdDSA},
+	} {
+		t.Run(test.signer.SigType().Name, func(t *testing.T) {
+			tok := jwsToken()
+			jws, err := tok.EncodeJWS(test.signer)
+			require.NoError(t, err)
+			require.Equal(t, test.vector, jws)
+
+			// the exported token is not modified
+			require.Equal(t, eat.SigTypes.Unsigned(), tok.SigType)
+
+			parsed, err := eat.ParseJWS(test.vector)
+			require.NoError(t, err)
+			require.Equal(t, eat.Types.EditorSigned(), parsed.Type)
+			require.Equal(t, test.signer.SigType(), parsed.SigType)
+			require.Equal(t, test.vector, parsed.String())
+			require.Equal(t, tok.SID, parsed.SID)
+			require.Equal(t, tok.LID, parsed.LID)
+			require.Equal(t, tok.QID, parsed.QID)
+			require.Equal(t, tok.Subject, parsed.Subject)
+			require.Equal(t, tok.Grant, parsed.Grant)
+			require.Equal(t, tok.Ctx, parsed.Ctx)
+			require.True(t, tok.IssuedAt.Equal(parsed.IssuedAt))
+			require.True(t, tok.Expires.Equal(parsed.Expires))
+
+			require.NoError(t, parsed.VerifySignature())
+			require.NoError(t, parsed.VerifySignatureFromKey(test.signer.PublicKey()))
+			other := eddsa
+			if test.signer == eddsa {
+				other = es256k
+			}
+			require.Error(t, parsed.VerifySignatureFromKey(other.PublicKey()))
+
+			// the JWS is the bearer of the authorization
+			auth, err := eat.NewAuthorization(parsed)
+			require.NoError(t, err)
+			require.Equal(t, test.vector, auth.Bearer)
+
+			// re-encoding yields the same JWS
+			jws, err = parsed.EncodeJWS(test.signer)
+			require.NoError(t, err)
+			require.Equal(t, test.vector, jws)
+		})
+	}
+
+	t.Run("standard claims", func(t *testing.T) {
+		parts := strings.Split(jwsES256K, ".")
+		header, err := base64.RawURLEncoding.DecodeString(parts[0])
+		require.NoError(t, err)
+		require.Equal(t, `{"alg":"ES256K","typ":"JWT","kid":"`+signerID(es256k)+`"}`, string(header))
+
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		require.Contains(t, string(claims), `"sub":"iusr2Lsdu7cpmKoRLvtTN1qeCpNBd2Tt"`)
+		require.Contains(t, string(claims), `"iat":1700000000.123`)
+		require.Contains(t, string(claims), `"exp":1700003600.123`)
+		require.Contains(t, string(claims), `"spc":"`+sid.String()+`"`)
+
+		// the signature is a plain ES256K signature (R || S) over the SHA-256 hash of the signing input
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		require.Len(t, sig, 64)
+		hsh := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		require.True(t, crypto.VerifySignature(es256k.PublicKey().Bytes(), hsh[:], sig))
+
+		parts = strings.Split(jwsEdDSA, ".")
+		sig, err = base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		require.True(t, ed25519.Verify(eddsa.PublicKey().Bytes(), []byte(parts[0]+"."+parts[1]), sig))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		parts := strings.Split(jwsES256K, ".")
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		tampered := parts[0] + "." +
+			base64.RawURLEncoding.EncodeToString(bytes.Replace(claims, []byte(`"read"`), []byte(`"edit"`), 1)) + "." +
+			parts[2]
+		unsupported := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","typ":"JWT"}`)) +
+			jwsEdDSA[strings.Index(jwsEdDSA, "."):]
+
+		for _, jws := range []string{
+			"",
+			"a.b",
+			parts[0] + "." + parts[1] + ".",
+			tampered,
+			unsupported,
+		} {
+			_, err := eat.ParseJWS(jws)
+			require.Error(t, err, jws)
+		}
+
+		sr, err := eat.NewTokenSigner(keys.New(keys.SR25519SecretKey, bytes.Repeat([]byte{3}, 32)))
+		require.NoError(t, err)
+		_, err = jwsToken().EncodeJWS(sr)
+		require.Error(t, err)
+	})
+
+	t.Run("verifier", func(t *testing.T) {
+		trusted := id.MustParse(signerID(es256k))
+		verifier := eat.NewVerifier(func(tok *eat.Token) ([]id.ID, error) {
+			return []id.ID{trusted}, nil
+		}).
+			WithClock(func() utc.UTC { return utc.UnixMilli(1700000060000) }).
+			WithDefaultPolicy(eat.Policy{TrustedSigner: true})
+
+		tok, err := eat.ParseJWS(jwsES256K)
+		require.NoError(t, err)
+		auth, err := verifier.Verify(tok)
+		require.NoError(t, err)
+		require.Equal(t, jwsES256K, auth.Bearer)
+		require.Equal(t, qid, auth.QID)
+
+		tok, err = eat.ParseJWS(jwsEdDSA)
+		require.NoError(t, err)
+		_, err = verifier.Verify(tok)
+		require.Error(t, err)
+		require.Equal(t, eat.RejectReasons.UntrustedSigner, eat.RejectReasonOf(err))
+	})
+}
+
+func signerID(signer eat.TokenSigner) string {
+	pub, _ := crypto.DecompressPubkey(signer.PublicKey().Bytes())
+	return ethutil.AddressToID(crypto.PubkeyToAddress(*pub), id.User).String()
+}
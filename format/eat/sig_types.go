@@ -26,6 +26,16 @@ func (s *TokenSigType) HasSig() bool {
 	return true
 }
 
+// RecoversSigner returns true if the address of the signer can be recovered from a signature of this type (i.e. for
+// Ethereum signatures). Signatures of other types are verified with the signer's public key instead.
+func (s *TokenSigType) RecoversSigner() bool {
+	switch s {
+	case SigTypes.ES256K(), SigTypes.EIP191Personal(), SigTypes.EIP712TypedData():
+		return true
+	}
+	return false
+}
+
 func (s *TokenSigType) Validate() error {
 	e := errors.Template("validate token signature type", errors.K.Invalid)
 	if s == nil {
@@ -41,18 +51,21 @@ var allSignatures = []*TokenSigType{
 	{"_", "unknown", sign.UNKNOWN},
 	{"u", "unsigned", sign.UNKNOWN},
 	{"s", "ES256K", sign.ES256K},
-	{"p", "EIP191Personal", sign.EIP191Personal}, // https://eips.ethereum.org/EIPS/eip-191
-	// {"t", "EIP712TypedData", sign.EIP712TypedData}, // https://eips.ethereum.org/EIPS/eip-712
+	{"p", "EIP191Personal", sign.EIP191Personal},   // https://eips.ethereum.org/EIPS/eip-191
+	{"t", "EIP712TypedData", sign.EIP712TypedData}, // https://eips.ethereum.org/EIPS/eip-712
+	{"e", "ED25519", sign.ED25519},                 // https://www.rfc-editor.org/rfc/rfc8032
+	{"r", "SR25519", sign.SR25519},                 // https://github.com/w3f/schnorrkel
 }
 
 type enumSigType int
 
-func (enumSigType) Unknown() *TokenSigType        { return allSignatures[0] }
-func (enumSigType) Unsigned() *TokenSigType       { return allSignatures[1] }
-func (enumSigType) ES256K() *TokenSigType         { return allSignatures[2] }
-func (enumSigType) EIP191Personal() *TokenSigType { return allSignatures[3] }
-
-// func (enumSigType) EIP712TypedData() *TokenSigType { return allSignatures[4] }
+func (enumSigType) Unknown() *TokenSigType         { return allSignatures[0] }
+func (enumSigType) Unsigned() *TokenSigType        { return allSignatures[1] }
+func (enumSigType) ES256K() *TokenSigType          { return allSignatures[2] }
+func (enumSigType) EIP191Personal() *TokenSigType  { return allSignatures[3] }
+func (enumSigType) EIP712TypedData() *TokenSigType { return allSignatures[4] }
+func (enumSigType) ED25519() *TokenSigType         { return allSignatures[5] }
+func (enumSigType) SR25519() *TokenSigType         { return allSignatures[6] }
 
 var prefixToSignature = map[string]*TokenSigType{}
 
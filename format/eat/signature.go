@@ -2,10 +2,13 @@ package eat
 
 import (
 	"bytes"
+	"crypto/sha256"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
 	"github.com/eluv-io/common-go/format/sign"
 	"github.com/eluv-io/errors-go"
 )
@@ -28,6 +31,9 @@ func (t *Token) VerifySignature() error {
 // Returns nil if the signature matches, an error otherwise.
 func (t *Token) VerifySignatureFrom(trusted common.Address) error {
 	e := errors.Template("verify token signature", errors.K.Permission)
+	if t.SigType.HasSig() && !t.SigType.RecoversSigner() {
+		return e("reason", "signature type does not allow signer recovery", "sig_type", t.SigType)
+	}
 	signerAddress, err := t.SignerAddress()
 	if err != nil {
 		return e(err)
@@ -43,9 +49,64 @@ func (t *Token) VerifySignatureFrom(trusted common.Address) error {
 	return nil
 }
 
-// VerifySignature verifies that the token was signed by the private key belonging to the the token's EthAddr. Returns
-// nil if the signature matches, an error otherwise.
+// VerifySignatureFromKey verifies that the token was signed by the private key belonging to the given public key. For
+// signature types that allow signer recovery, the key must be a keys.ES256KPublicKey. Returns nil if the signature
+// matches, an error otherwise.
+func (t *Token) VerifySignatureFromKey(trusted keys.Key) error {
+	e := errors.Template("verify token signature", errors.K.Permission)
+
+	if t.SigType.RecoversSigner() {
+		err := trusted.AssertCode(keys.ES256KPublicKey)
+		if err != nil {
+			return e(err)
+		}
+		pub, err := crypto.DecompressPubkey(trusted.Bytes())
+		if err != nil {
+			return e(err)
+		}
+		return t.VerifySignatureFrom(crypto.PubkeyToAddress(*pub))
+	}
+
+	if !t.SigType.HasSig() {
+		return e("reason", "token is not signed")
+	}
+
+	if !t.SignerKey.IsNil() && !bytes.Equal(t.SignerKey, trusted) {
+		return e("reason", "invalid trust key or auth token tampered with",
+			"expect_key", trusted.String(),
+			"signer_key", t.SignerKey.String())
+	}
+
+	payload, err := t.getPayload()
+	if err != nil {
+		return e(err)
+	}
+
+	return e.IfNotNil(t.Signature.Verify(trusted, payload))
+}
+
+// VerifySignatureFromID verifies that the token was signed by the private key belonging to the given ID. Supported are
+// user IDs (the ethereum address of the signer) and ed25519 IDs (the ed25519 public key of the signer). Returns nil if
+// the signature matches, an error otherwise.
+func (t *Token) VerifySignatureFromID(trusted id.ID) error {
+	switch trusted.Code() {
+	case id.Ed25519:
+		return t.VerifySignatureFromKey(keys.New(keys.ED25519PublicKey, trusted.Bytes()))
+	case id.User:
+		return t.VerifySignatureFrom(common.BytesToAddress(trusted.Bytes()))
+	}
+	return errors.E("verify token signature", errors.K.Permission,
+		"reason", "unsupported id type",
+		"id", trusted)
+}
+
+// VerifySignature verifies that the token was signed by the private key belonging to the the token's EthAddr or - for
+// signature types that don't allow signer recovery - the token's SignerKey. Returns nil if the signature matches, an
+// error otherwise.
 func (t *Token) verifySignature() error {
+	if t.SigType.HasSig() && !t.SigType.RecoversSigner() {
+		return t.VerifySignatureFromKey(t.SignerKey)
+	}
 	return t.VerifySignatureFrom(t.EthAddr)
 }
 
@@ -57,7 +118,7 @@ func (t *Token) SignerAddress() (addr common.Address, err error) {
 	e := errors.Template("signerAddress")
 
 	switch t.SigType {
-	case SigTypes.ES256K(), SigTypes.EIP191Personal():
+	case SigTypes.ES256K(), SigTypes.EIP191Personal(), SigTypes.EIP712TypedData():
 		// continue
 	default:
 		return zeroAddr, errors.E("signerAddress", "reason", "token is not signed")
@@ -72,6 +133,27 @@ func (t *Token) SignerAddress() (addr common.Address, err error) {
 	return addr, e.IfNotNil(err)
 }
 
+// SignedHash returns the hash of the data covered by the token's signature: the hash that is signed for signature
+// types that allow signer recovery, the SHA-256 digest of the payload otherwise. Unlike the signature, which may have
+// several valid encodings for the same data (e.g. the recovery ID of ES256K signatures), the hash is the same for all
+// encodings of a signed token and is therefore suitable for identifying the token, e.g. in revocation lists.
+func (t *Token) SignedHash() ([]byte, error) {
+	e := errors.Template("SignedHash", errors.K.Invalid)
+	if !t.SigType.HasSig() {
+		return nil, e("reason", "token is not signed", "sig_type", t.SigType)
+	}
+	if t.SigType.RecoversSigner() {
+		hsh, err := t.hashToken(t.SigType)
+		return hsh, e.IfNotNil(err)
+	}
+	payload, err := t.getPayload()
+	if err != nil {
+		return nil, e(err)
+	}
+	sum := sha256.Sum256(payload)
+	return sum[:], nil
+}
+
 // sign signs the token with the provided signing function and according to the given signature type. The signAddr
 // must correspond to the private key that is used in the signing function.
 func (t *Token) sign(
@@ -111,19 +193,75 @@ func (t *Token) sign(
 	return nil
 }
 
+// signWithKey signs the token's payload with the provided signing function and according to the given signature type,
+// which must not allow signer recovery. The public key must correspond to the private key used in the signing function
+// and is stored in the token as SignerKey.
+func (t *Token) signWithKey(
+	publicKey keys.Key,
+	signFunc func(msg []byte) ([]byte, error),
+	sigType *TokenSigType) (err error) {
+
+	e := errors.Template("sign", errors.K.Invalid)
+	if t == nil {
+		return e("reason", "token is nil")
+	}
+
+	e = e.Add("sig_type", sigType)
+	if !sigType.HasSig() || sigType.RecoversSigner() {
+		return e("reason", "invalid signature type")
+	}
+	if publicKey.IsNil() {
+		return e("reason", "public key is nil")
+	}
+
+	t.clearCaches()
+	t.SignerKey = publicKey
+
+	payload, err := t.getPayload()
+	if err != nil {
+		return e(err)
+	}
+
+	sig, err := signFunc(payload)
+	if err != nil {
+		return e(err)
+	}
+	if len(sig) != sigType.Code.SigLen() {
+		return e("reason", "invalid signature length",
+			"len", len(sig),
+			"expected", sigType.Code.SigLen())
+	}
+
+	t.Signature = sign.NewSig(sigType.Code, sig)
+	t.SigType = sigType
+	return nil
+}
+
 func (t *Token) hashToken(sigType *TokenSigType) (hsh []byte, err error) {
 	var encoded []byte
 	switch sigType {
 	case SigTypes.ES256K():
 		encoded, err = t.getPayload()
 		if err == nil {
-			hsh = crypto.Keccak256(encoded)
+			if t.jws {
+				// JWS algorithm ES256K uses SHA-256
+				sum := sha256.Sum256(encoded)
+				hsh = sum[:]
+			} else {
+				hsh = crypto.Keccak256(encoded)
+			}
 		}
 	case SigTypes.EIP191Personal():
 		encoded, err = t.getUncompressedTokenData()
 		if err == nil {
 			hsh = sign.HashEIP191Personal(append([]byte("Eluvio Content Fabric Access Token 1.0\n"), encoded...))
 		}
+	case SigTypes.EIP712TypedData():
+		// the signature is calculated on the typed data, but the payload is needed for encoding the token
+		_, err = t.getPayload()
+		if err == nil {
+			hsh, err = t.hashTypedData()
+		}
 	default:
 		return nil, errors.E("hashToken", "reason", "invalid signature type", "sig_type", t.SigType)
 	}
@@ -12,6 +12,7 @@ import (
 	"unicode"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/mattn/go-runewidth"
 	"github.com/mr-tron/base58"
@@ -59,6 +60,8 @@ type Token struct {
 	uncompressedTokenData []byte
 	// Cache of validation result.
 	validationResult *validationResult
+	// True if the token was imported from a JWS: the payload is the JWS signing input and the encoded form is the JWS.
+	jws bool
 
 	Embedded       *Token
 	EmbeddedLength int // the length of the embedded token within the payload
@@ -188,9 +191,11 @@ func (t *Token) Encode() (s string, err error) {
 		return "", e(err)
 	}
 
-	switch t.Format {
-	case Formats.Legacy():
+	switch {
+	case t.Format == Formats.Legacy():
 		return t.encodeLegacy()
+	case t.jws:
+		return t.encoded, nil
 	}
 
 	var data []byte
@@ -328,6 +333,14 @@ func (t *Token) Validate() (err error) {
 			// the token itself. Hence, the signature cannot be checked here and must be checked downstream.
 			case Types.StateChannel():
 			default:
+				if !t.SigType.RecoversSigner() {
+					// the signer is identified by its public key rather than an address
+					if validator.require("spk", t.SignerKey) {
+						validator.error(t.verifySignature())
+					}
+					break
+				}
+				validator.refuse("spk", t.SignerKey)
 				if !t.HasEthAddr() &&
 					// cases where we extract it from the signature itself:
 					// - legacy tokens may have a missing eth addr,
@@ -348,6 +361,7 @@ func (t *Token) Validate() (err error) {
 		}
 		validator.refuse("signature", t.Signature)
 		validator.refuse("address", t.EthAddr)
+		validator.refuse("spk", t.SignerKey)
 	}
 
 	if t.Type != Types.ClientConfirmation() {
@@ -357,6 +371,15 @@ func (t *Token) Validate() (err error) {
 	// lib seems optional... (because it can be specified in the URL?)
 	// valid.require("lib id", t.LID)
 
+	for i := range t.Capabilities {
+		validator.error(t.Capabilities[i].Validate())
+	}
+	if t.Type == Types.Attenuated() {
+		validator.error(t.Caveats.Validate())
+	} else {
+		validator.refuse("caveats", t.Caveats)
+	}
+
 	switch t.Type {
 	case Types.StateChannel():
 		// require
@@ -468,6 +491,7 @@ func (t *Token) Validate() (err error) {
 		validator.refuse("qp-hash", t.QPHash)
 		validator.refuse("afgh", t.AFGHPublicKey)
 		validator.refuse("confirmation", t.Confirmation)
+		validator.refuse("capabilities", t.Capabilities)
 
 		validator.require("issued at", t.IssuedAt)
 		validator.require("expires", t.Expires)
@@ -477,11 +501,36 @@ func (t *Token) Validate() (err error) {
 		validator.refuse("subject", t.Subject)
 		validator.refuse("grant", t.Grant)
 		validator.refuse("ctx", t.Ctx)
+		validator.refuse("capabilities", t.Capabilities)
 		validator.refuse("tx-hash", t.EthTxHash)
 		if t.QPHash.IsNil() && t.Expires.IsZero() {
 			validator.errorReason("one of 'qp-hash' or 'expires' is required")
 		}
 		return e.IfNotNil(validator.err)
+
+	case Types.Attenuated():
+		// require
+		validator.require("embedded token", t.Embedded)
+		validator.require("issued at", t.IssuedAt)
+		validator.require("expires", t.Expires)
+		if t.Expires.Before(t.IssuedAt) {
+			validator.errorReason("expires before issued at",
+				"expires", t.Expires,
+				"issued_at", t.IssuedAt)
+		}
+		// refuse: the authorization is defined by the root token of the chain and only restricted by caveats
+		validator.refuse("qid", t.QID)
+		validator.refuse("subject", t.Subject)
+		validator.refuse("grant", t.Grant)
+		validator.refuse("ctx", t.Ctx)
+		validator.refuse("capabilities", t.Capabilities)
+		validator.refuse("tx-hash", t.EthTxHash)
+		validator.refuse("qp-hash", t.QPHash)
+		validator.refuse("afgh", t.AFGHPublicKey)
+		if t.Embedded != nil {
+			validator.error(t.validateAttenuation())
+		}
+		return e.IfNotNil(validator.err)
 	}
 
 	// refused for all other types
@@ -490,6 +539,7 @@ func (t *Token) Validate() (err error) {
 	validator.refuse("issued at", t.IssuedAt)
 	validator.refuse("expires", t.Expires)
 	validator.refuse("ctx", t.Ctx)
+	validator.refuse("capabilities", t.Capabilities)
 
 	switch t.Type {
 	case Types.Client():
@@ -521,6 +571,16 @@ func (t *Token) Validate() (err error) {
 	return e.IfNotNil(validator.err)
 }
 
+// allowsTimes returns whether tokens of the given type may carry validity times - they are refused by Validate() for
+// anonymous, tx, plain and client tokens.
+func allowsTimes(typ TokenType) bool {
+	switch typ {
+	case Types.Anonymous(), Types.Tx(), Types.Plain(), Types.Client():
+		return false
+	}
+	return true
+}
+
 // ValidateConfirmation validates the 'confirmation' token.
 // The function returns an error if:
 // - the token requires a confirmation but is not of the right type
@@ -530,6 +590,10 @@ func (t *Token) Validate() (err error) {
 // confirmation token validity period is verified with the given max validity
 // and time skew.
 func (t *Token) ValidateConfirmation(confirmation *Token, maxValidity, timeSkew time.Duration) error {
+	return t.validateConfirmationAt(utc.Now(), confirmation, maxValidity, timeSkew)
+}
+
+func (t *Token) validateConfirmationAt(now utc.UTC, confirmation *Token, maxValidity, timeSkew time.Duration) error {
 	e := errors.Template("ValidateConfirmation", errors.K.Invalid,
 		"type", t.Type,
 		"sig_type", t.SigType,
@@ -569,7 +633,7 @@ func (t *Token) ValidateConfirmation(confirmation *Token, maxValidity, timeSkew
 		}
 
 		// verify conditions in 'confirmation'
-		err = confirmation.VerifyTimes(maxValidity, timeSkew)
+		err = confirmation.verifyTimesAt(now, maxValidity, timeSkew, true)
 		if err != nil {
 			return e("reason", "confirmation time invalid or expired", err)
 		}
@@ -754,6 +818,27 @@ func (t *Token) explain(indent string, isEmbedded bool) (res string) {
 			return sub
 		}, string(bts))
 	}
+	writeTypedData := func(tok *Token) {
+		typedData, err := tok.TypedData()
+		if err != nil {
+			writeErr(err)
+			return
+		}
+		hsh, err := sign.HashTypedData(typedData)
+		if err != nil {
+			writeErr(err)
+			return
+		}
+		write("EIP712 HASH", len(hsh), hexutil.Encode(hsh))
+		bts, err := json.MarshalIndent(typedData.Message, indent, "  ")
+		if err != nil {
+			writeErr(err)
+			return
+		}
+		sb.WriteString(indent)
+		sb.WriteString(string(bts))
+		sb.WriteString("\n")
+	}
 	prefix := func(tok *Token) string {
 		return tok.Type.Prefix + "=" + tok.Type.Name + " " +
 			tok.SigType.Prefix + "=" + tok.SigType.Name + " " +
@@ -834,6 +919,10 @@ func (t *Token) explain(indent string, isEmbedded bool) (res string) {
 		write("BODY", bodyLen, "base58(SIGNATURE + PAYLOAD)")
 	}
 
+	if t.SigType == SigTypes.EIP712TypedData() {
+		writeTypedData(t)
+	}
+
 	if t.Embedded == nil {
 		sigPlusBodyLen := sigLen + t.encDetails.compressedTokenDataLen
 		write("SIGNATURE + PAYLOAD", sigPlusBodyLen, fmt.Sprintf("%db * 138 / 100 + 1 = %db (>= %db)", sigPlusBodyLen, sigPlusBodyLen*138/100+1, bodyLen))
@@ -959,7 +1048,7 @@ func (t *Token) encodeBytesNoCompression() (data []byte, err error) {
 }
 
 func (t *Token) encodeEmbedded() ([]byte, error) {
-	if t.Type != Types.Client() {
+	if !t.Type.embedsToken() {
 		return nil, nil
 	}
 
@@ -1031,12 +1120,19 @@ func (t *Token) decodeTokenAndSigBytes(bts []byte) (err error) {
 	e := errors.Template("decode auth token", errors.K.Invalid)
 
 	switch t.SigType {
-	case SigTypes.ES256K(), SigTypes.EIP191Personal():
+	case SigTypes.ES256K(), SigTypes.EIP191Personal(), SigTypes.EIP712TypedData():
 		if len(bts) <= 65 {
 			return e("reason", "token too short")
 		}
 		t.Signature = sign.NewSig(t.SigType.Code, bts[:65])
 		bts = bts[65:]
+	case SigTypes.ED25519(), SigTypes.SR25519():
+		sigLen := t.SigType.Code.SigLen()
+		if len(bts) <= sigLen {
+			return e("reason", "token too short")
+		}
+		t.Signature = sign.NewSig(t.SigType.Code, bts[:sigLen])
+		bts = bts[sigLen:]
 	}
 
 	err = t.decodeBytes(bts)
@@ -1095,7 +1191,7 @@ func (t *Token) decodeBytes(bts []byte) error {
 }
 
 func (t *Token) decodeEmbedded(bts []byte) (n int, err error) {
-	if t.Type != Types.Client() {
+	if !t.Type.embedsToken() {
 		return 0, nil
 	}
 
@@ -1132,9 +1228,13 @@ func (t *Token) decodeEmbedded(bts []byte) (n int, err error) {
 }
 
 func (t *Token) VerifyTimes(maxValidity, timeSkew time.Duration) error {
-	e := errors.Template("verify validity times", errors.K.Permission)
+	return t.verifyTimesAt(utc.Now(), maxValidity, timeSkew, true)
+}
 
-	now := utc.Now()
+// verifyTimesAt verifies the validity times at the given time. If requireExpires is false, a token without expiration
+// time does not expire.
+func (t *Token) verifyTimesAt(now utc.UTC, maxValidity, timeSkew time.Duration, requireExpires bool) error {
+	e := errors.Template("verify validity times", errors.K.Permission)
 
 	if now.Before(t.IssuedAt.Add(-timeSkew)) {
 		return e("reason", "token not yet valid",
@@ -1142,7 +1242,7 @@ func (t *Token) VerifyTimes(maxValidity, timeSkew time.Duration) error {
 			"now", now)
 	}
 
-	if now.After(t.Expires) {
+	if (requireExpires || !t.Expires.IsZero()) && now.After(t.Expires) {
 		return e("reason", "token expired",
 			"expired_at", t.Expires,
 			"now", now)
@@ -1245,6 +1345,7 @@ func (t *Token) clearCaches() {
 	t.payload = nil
 	t.uncompressedTokenData = nil
 	t.validationResult = nil
+	t.jws = false
 }
 
 func Describe(tok string) (res string) {
@@ -2,16 +2,14 @@ package eat
 
 import (
 	"bytes"
-	"encoding/binary"
 	"encoding/json"
-	"io"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 
 	"github.com/eluv-io/common-go/format/codecs"
-	"github.com/eluv-io/common-go/format/hash"
 	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
 	"github.com/eluv-io/common-go/format/types"
 	"github.com/eluv-io/common-go/util/ethutil"
 	"github.com/eluv-io/errors-go"
@@ -67,6 +65,7 @@ type TokenData struct {
 	EthAddr       common.Address `json:"adr,omitempty"` // ethereum address of the user who signed the token - stored as []byte to enable 'nil'
 	AFGHPublicKey string         `json:"apk,omitempty"` // AFGH public key
 	QPHash        types.QPHash   `json:"qph,omitempty"` // qpart hash for node 2 node
+	SignerKey     keys.Key       `json:"spk,omitempty"` // public key of the signer for signatures without signer recovery (ED25519, SR25519)
 
 	// Common
 	SID types.QSpaceID `json:"spc,omitempty"` // space ID
@@ -80,6 +79,8 @@ type TokenData struct {
 	Expires      utc.UTC                `json:"exp,omitempty"` // Expiration Time
 	Ctx          map[string]interface{} `json:"ctx,omitempty"` // additional, arbitrary information conveyed in the token
 	Confirmation ClientConfirmation     `json:"cnf,omitempty"` // auxiliary confirmation for DPOP (Demonstrating Proof-of-Possession)
+	Capabilities []Capability           `json:"cap,omitempty"` // fine-grained capabilities - see Authorization.Allows()
+	Caveats      *Caveats               `json:"cav,omitempty"` // restrictions of attenuated tokens
 }
 
 // Copy returns a copy of this TokenData.
@@ -130,109 +131,6 @@ func (t *TokenData) DecodeCBOR(bts []byte) error {
 var zeroHash common.Hash
 var zeroAddr common.Address
 
-func (t *TokenData) Encode() ([]byte, error) {
-	e := errors.Template("Encode")
-	enc := newTokenEncoder()
-
-	sd := (&serData{}).copyFrom(t)
-	enc.writeBytes(sd.EthTxHash)
-	enc.writeBytes(sd.EthAddr)
-	enc.writeString(t.AFGHPublicKey)
-	enc.writeString(t.QPHash.String())
-	enc.writeBytes(t.SID)
-	enc.writeBytes(t.LID)
-	enc.writeBytes(t.QID)
-	enc.writeString(string(t.Grant))
-	b, err := t.IssuedAt.MarshalBinary()
-	if err != nil {
-		return nil, e(err)
-	}
-	enc.writeBytes(b)
-	b, err = t.Expires.MarshalBinary()
-	if err != nil {
-		return nil, e(err)
-	}
-	enc.writeBytes(b)
-
-	err = enc.writeCbor(t.Ctx)
-	if err != nil {
-		return nil, e(err)
-	}
-
-	err = enc.writeCbor(sd.Cnf)
-	if err != nil {
-		return nil, e(err)
-	}
-
-	return enc.buf.Bytes(), nil
-}
-
-func (t *TokenData) Decode(bts []byte) error {
-	e := errors.Template("decode token data")
-	dec := newDecoder(bts)
-
-	var b []byte
-	var s string
-
-	td := serData{}
-	err := dec.readBytes(&td.EthTxHash)
-	if err == nil {
-		t.EthTxHash = common.BytesToHash(td.EthTxHash)
-		err = dec.readBytes(&td.EthAddr)
-	}
-	if err == nil {
-		t.EthAddr = common.BytesToAddress(td.EthAddr)
-		err = dec.readString(&t.AFGHPublicKey)
-	}
-	if err == nil {
-		err = dec.readString(&s)
-		if err == nil {
-			t.QPHash, err = hash.FromString(s)
-		}
-	}
-	if err == nil {
-		err = dec.readBytes((*[]byte)(&t.SID))
-	}
-	if err == nil {
-		err = dec.readBytes((*[]byte)(&t.LID))
-	}
-	if err == nil {
-		err = dec.readBytes((*[]byte)(&t.QID))
-	}
-	if err == nil {
-		err = dec.readString(&s)
-		if err == nil {
-			t.Grant = Grant(s)
-		}
-	}
-	if err == nil {
-		err = dec.readBytes(&b)
-		if err == nil {
-			err = t.IssuedAt.UnmarshalBinary(b)
-		}
-	}
-	if err == nil {
-		err = dec.readBytes(&b)
-		if err == nil {
-			err = t.Expires.UnmarshalBinary(b)
-		}
-	}
-	if err == nil {
-		err = dec.readCbor(&t.Ctx)
-	}
-	if err == nil {
-		err = dec.readCbor(&td.Cnf)
-		if err == nil {
-			t.Confirmation = td.Cnf.toClientConfirmation()
-		}
-		if err == io.EOF {
-			err = nil
-		}
-	}
-
-	return e.IfNotNil(err)
-}
-
 func (t *TokenData) IPGeo() string {
 	if obj, ok := t.Ctx[ElvIPGeo]; ok {
 		return obj.(string)
@@ -242,6 +140,9 @@ func (t *TokenData) IPGeo() string {
 
 func (t *TokenData) Signer() types.UserID {
 	if t.EthAddr == zeroAddr {
+		if t.SignerKey.Code() == keys.ED25519PublicKey {
+			return id.NewID(id.Ed25519, t.SignerKey.Bytes())
+		}
 		return nil
 	}
 	return ethutil.AddressToID(t.EthAddr, id.User)
@@ -274,6 +175,7 @@ type serData struct {
 	EthAddr       []byte       `json:"adr,omitempty"` // ethereum address of the user - stored as []byte to enable 'nil'
 	AFGHPublicKey string       `json:"apk,omitempty"` // AFGH public key
 	QPHash        types.QPHash `json:"qph,omitempty"` // qpart hash for node 2 node
+	SignerKey     keys.Key     `json:"spk,omitempty"` // public key of the signer
 
 	// Common
 	SID types.QSpaceID `json:"spc,omitempty"` // space ID
@@ -287,6 +189,8 @@ type serData struct {
 	Expires  int64                  `json:"exp,omitempty"` // Expiration Time
 	Ctx      map[string]interface{} `json:"ctx,omitempty"` // additional, arbitrary information conveyed in the token
 	Cnf      *serClientConfirmation `json:"cnf,omitempty"` // auxiliary 'confirmation'
+	Cap      []Capability           `json:"cap,omitempty"` // capabilities
+	Cav      *Caveats               `json:"cav,omitempty"` // caveats
 }
 
 func (d *serData) copyTo(t *TokenData) *TokenData {
@@ -294,6 +198,7 @@ func (d *serData) copyTo(t *TokenData) *TokenData {
 	t.EthAddr = common.BytesToAddress(d.EthAddr)
 	t.AFGHPublicKey = d.AFGHPublicKey
 	t.QPHash = d.QPHash
+	t.SignerKey = d.SignerKey
 	t.SID = d.SID
 	t.LID = d.LID
 	t.QID = d.QID
@@ -309,6 +214,8 @@ func (d *serData) copyTo(t *TokenData) *TokenData {
 	if d.Cnf != nil {
 		t.Confirmation = d.Cnf.toClientConfirmation()
 	}
+	t.Capabilities = d.Cap
+	t.Caveats = d.Cav
 	return t
 }
 
@@ -321,6 +228,7 @@ func (d *serData) copyFrom(t *TokenData) *serData {
 	}
 	d.AFGHPublicKey = t.AFGHPublicKey
 	d.QPHash = t.QPHash
+	d.SignerKey = t.SignerKey
 	d.SID = t.SID
 	d.LID = t.LID
 	d.QID = t.QID
@@ -340,76 +248,7 @@ func (d *serData) copyFrom(t *TokenData) *serData {
 			Ttl: t.Confirmation.TTL,
 		}
 	}
+	d.Cap = t.Capabilities
+	d.Cav = t.Caveats
 	return d
 }
-
-// -----------------------------------------------------------------------------
-
-func newTokenEncoder() *tokenEncoder {
-	return &tokenEncoder{
-		vbuf: make([]byte, binary.MaxVarintLen64),
-	}
-}
-
-type tokenEncoder struct {
-	buf  bytes.Buffer
-	vbuf []byte
-}
-
-func (e *tokenEncoder) writeString(s string) {
-	e.writeBytes([]byte(s))
-}
-
-var vi0 = []byte{0}
-
-func (e *tokenEncoder) writeBytes(b []byte) {
-	if len(b) == 0 {
-		_, _ = e.buf.Write(vi0)
-	} else {
-		n := binary.PutUvarint(e.vbuf, uint64(len(b)))
-		_, _ = e.buf.Write(e.vbuf[:n])
-		_, _ = e.buf.Write(b)
-	}
-}
-
-func (e *tokenEncoder) writeCbor(v interface{}) error {
-	return codecs.CborEncode(&e.buf, v)
-}
-func newDecoder(b []byte) *tokenDecoder {
-	return &tokenDecoder{buf: bytes.NewBuffer(b)}
-}
-
-type tokenDecoder struct {
-	buf *bytes.Buffer
-}
-
-func (e *tokenDecoder) readString(s *string) error {
-	var b []byte
-	err := e.readBytes(&b)
-	if err != nil {
-		return err
-	}
-	if b == nil {
-		return nil
-	}
-	*s = string(b)
-	return nil
-}
-
-func (e *tokenDecoder) readBytes(b *[]byte) error {
-	n, err := binary.ReadUvarint(e.buf)
-	if err != nil {
-		return err
-	}
-	if n == 0 {
-		return nil
-	}
-	bts := make([]byte, n)
-	_, err = e.buf.Read(bts)
-	*b = bts
-	return err
-}
-
-func (e *tokenDecoder) readCbor(v interface{}) error {
-	return codecs.CborDecode(e.buf, v)
-}
@@ -0,0 +1,506 @@
+package eat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/eluv-io/common-go/format/codecs"
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// The custom format is a compact binary encoding of the token data:
+//
+//	CUSTOM:  VERSION FIELD*
+//	VERSION: 1 byte - customVersion
+//	FIELD:   TAG VALUE
+//	TAG:     1 byte - the field (see tagXXX constants), possibly combined with the tagVarLen flag
+//
+// Only fields with non-zero values are encoded, in the order of their tags. The encoding of a field's value depends on
+// its type:
+//
+//	hash, address: fixed width - 32 and 20 bytes
+//	ID:            code byte + 20 bytes. IDs of a different length are tagged with tagVarLen and their length is
+//	               stored as varint between code and ID bytes.
+//	time:          signed varint of the unix time in milliseconds
+//	int:           unsigned varint
+//	string, key:   varint length + bytes
+//	part hash:     hash code byte + hash format byte + varint length + base58-decoded hash bytes
+//	               Part hashes with a digest algorithm other than SHA256 are stored with tagQPHashAlg instead of
+//	               tagQPHash and have an additional algorithm byte after the format byte.
+//	ctx, cap, cav: varint length + CBOR
+//
+// A subject that is an ID is encoded as ID (tagSubjectID) rather than string.
+//
+// Tokens encoded with the original, untagged custom layout (starting with the varint length of the tx hash, i.e. 0 or
+// 32) are still decoded.
+const customVersion = 1
+
+const (
+	tagEthTxHash byte = iota + 1
+	tagEthAddr
+	tagAFGHPublicKey
+	tagQPHash
+	tagSignerKey
+	tagSID
+	tagLID
+	tagQID
+	tagSubject
+	tagSubjectID
+	tagGrant
+	tagIssuedAt
+	tagExpires
+	tagCtx
+	tagCnfAek
+	tagCnfPek
+	tagCnfTtl
+	tagCapabilities
+	tagCaveats
+	tagQPHashAlg
+	tagMax = tagQPHashAlg
+
+	tagVarLen byte = 0x80 // flag for IDs of non-standard length
+)
+
+// customIDLen is the standard length of an ID (without its code) in the custom format.
+const customIDLen = 20
+
+// Encode encodes the token data in the custom binary format.
+func (t *TokenData) Encode() ([]byte, error) {
+	e := errors.Template("encode token data", errors.K.Invalid)
+	enc := newTokenEncoder()
+
+	enc.buf.WriteByte(customVersion)
+	if t.EthTxHash != zeroHash {
+		enc.writeTag(tagEthTxHash)
+		enc.buf.Write(t.EthTxHash.Bytes())
+	}
+	if t.EthAddr != zeroAddr {
+		enc.writeTag(tagEthAddr)
+		enc.buf.Write(t.EthAddr.Bytes())
+	}
+	if t.AFGHPublicKey != "" {
+		enc.writeTag(tagAFGHPublicKey)
+		enc.writeString(t.AFGHPublicKey)
+	}
+	if !t.QPHash.IsNil() && t.QPHash.Type.Algorithm == hash.SHA256 {
+		// SHA256 part hashes keep the original layout, so that their tokens remain readable by older decoders
+		enc.writeTag(tagQPHash)
+		enc.writeQPHash(t.QPHash, false)
+	}
+	if !t.SignerKey.IsNil() {
+		enc.writeTag(tagSignerKey)
+		enc.writeBytes(t.SignerKey)
+	}
+	enc.writeID(tagSID, t.SID)
+	enc.writeID(tagLID, t.LID)
+	enc.writeID(tagQID, t.QID)
+	if t.Subject != "" {
+		subjectID, err := id.FromString(t.Subject)
+		if err == nil && subjectID.String() == t.Subject {
+			enc.writeID(tagSubjectID, subjectID)
+		} else {
+			enc.writeTag(tagSubject)
+			enc.writeString(t.Subject)
+		}
+	}
+	if t.Grant != "" {
+		enc.writeTag(tagGrant)
+		enc.writeString(string(t.Grant))
+	}
+	if !t.IssuedAt.IsZero() {
+		enc.writeTag(tagIssuedAt)
+		enc.writeVarint(t.IssuedAt.UnixMilli())
+	}
+	if !t.Expires.IsZero() {
+		enc.writeTag(tagExpires)
+		enc.writeVarint(t.Expires.UnixMilli())
+	}
+	if len(t.Ctx) > 0 {
+		buf := &bytes.Buffer{}
+		err := codecs.CborEncode(buf, t.Ctx)
+		if err != nil {
+			return nil, e(err, "reason", "failed to encode ctx")
+		}
+		enc.writeTag(tagCtx)
+		enc.writeBytes(buf.Bytes())
+	}
+	if t.Confirmation.AddrOfEphemeralKey != "" {
+		enc.writeTag(tagCnfAek)
+		enc.writeString(t.Confirmation.AddrOfEphemeralKey)
+	}
+	if t.Confirmation.PublicEphemeralKey != "" {
+		enc.writeTag(tagCnfPek)
+		enc.writeString(t.Confirmation.PublicEphemeralKey)
+	}
+	if t.Confirmation.TTL > 0 {
+		enc.writeTag(tagCnfTtl)
+		enc.writeUvarint(uint64(t.Confirmation.TTL))
+	}
+	if len(t.Capabilities) > 0 {
+		buf := &bytes.Buffer{}
+		err := codecs.CborEncode(buf, t.Capabilities)
+		if err != nil {
+			return nil, e(err, "reason", "failed to encode capabilities")
+		}
+		enc.writeTag(tagCapabilities)
+		enc.writeBytes(buf.Bytes())
+	}
+	if t.Caveats != nil {
+		buf := &bytes.Buffer{}
+		err := codecs.CborEncode(buf, t.Caveats)
+		if err != nil {
+			return nil, e(err, "reason", "failed to encode caveats")
+		}
+		enc.writeTag(tagCaveats)
+		enc.writeBytes(buf.Bytes())
+	}
+	if !t.QPHash.IsNil() && t.QPHash.Type.Algorithm != hash.SHA256 {
+		enc.writeTag(tagQPHashAlg)
+		enc.writeQPHash(t.QPHash, true)
+	}
+
+	return enc.buf.Bytes(), nil
+}
+
+// Decode decodes the token data from the custom binary format.
+func (t *TokenData) Decode(bts []byte) error {
+	if len(bts) == 0 || bts[0] != customVersion {
+		return t.decodeV0(bts)
+	}
+
+	e := errors.Template("decode token data", errors.K.Invalid)
+	dec := newDecoder(bts[1:])
+
+	var seen uint32
+	for dec.buf.Len() > 0 {
+		b, _ := dec.buf.ReadByte()
+		tag := b &^ tagVarLen
+		if tag == 0 || tag > tagMax {
+			return e("reason", "invalid tag", "tag", b)
+		}
+		if seen&(1<<tag) != 0 {
+			return e("reason", "duplicate tag", "tag", tag)
+		}
+		seen |= 1 << tag
+		if b&tagVarLen != 0 && !isIDTag(tag) {
+			return e("reason", "invalid var-len tag", "tag", b)
+		}
+
+		var err error
+		var s string
+		switch tag {
+		case tagEthTxHash:
+			var h []byte
+			h, err = dec.readFixed(common.HashLength)
+			t.EthTxHash = common.BytesToHash(h)
+		case tagEthAddr:
+			var a []byte
+			a, err = dec.readFixed(common.AddressLength)
+			t.EthAddr = common.BytesToAddress(a)
+		case tagAFGHPublicKey:
+			err = dec.readString(&t.AFGHPublicKey)
+		case tagQPHash, tagQPHashAlg:
+			if seen&(1<<tagQPHash) != 0 && seen&(1<<tagQPHashAlg) != 0 {
+				return e("reason", "duplicate part hash", "tag", tag)
+			}
+			t.QPHash, err = dec.readQPHash(tag == tagQPHashAlg)
+		case tagSignerKey:
+			err = dec.readBytes((*[]byte)(&t.SignerKey))
+		case tagSID:
+			t.SID, err = dec.readID(b)
+		case tagLID:
+			t.LID, err = dec.readID(b)
+		case tagQID:
+			t.QID, err = dec.readID(b)
+		case tagSubject:
+			err = dec.readString(&t.Subject)
+		case tagSubjectID:
+			var subjectID id.ID
+			subjectID, err = dec.readID(b)
+			t.Subject = subjectID.String()
+		case tagGrant:
+			err = dec.readString(&s)
+			t.Grant = Grant(s)
+		case tagIssuedAt:
+			var ms int64
+			ms, err = binary.ReadVarint(dec.buf)
+			t.IssuedAt = utc.UnixMilli(ms)
+		case tagExpires:
+			var ms int64
+			ms, err = binary.ReadVarint(dec.buf)
+			t.Expires = utc.UnixMilli(ms)
+		case tagCtx:
+			var ctx []byte
+			err = dec.readBytes(&ctx)
+			if err == nil {
+				err = codecs.CborDecode(bytes.NewReader(ctx), &t.Ctx)
+			}
+		case tagCnfAek:
+			err = dec.readString(&t.Confirmation.AddrOfEphemeralKey)
+		case tagCnfPek:
+			err = dec.readString(&t.Confirmation.PublicEphemeralKey)
+		case tagCnfTtl:
+			var ttl uint64
+			ttl, err = binary.ReadUvarint(dec.buf)
+			t.Confirmation.TTL = int(ttl)
+		case tagCapabilities:
+			var caps []byte
+			err = dec.readBytes(&caps)
+			if err == nil {
+				err = codecs.CborDecode(bytes.NewReader(caps), &t.Capabilities)
+			}
+		case tagCaveats:
+			var cav []byte
+			err = dec.readBytes(&cav)
+			if err == nil {
+				t.Caveats = &Caveats{}
+				err = codecs.CborDecode(bytes.NewReader(cav), t.Caveats)
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return e(err, "tag", tag)
+		}
+	}
+
+	return nil
+}
+
+// decodeV0 decodes the token data from the original, untagged custom layout.
+func (t *TokenData) decodeV0(bts []byte) error {
+	e := errors.Template("decode token data")
+	dec := newDecoder(bts)
+
+	var b []byte
+	var s string
+
+	td := serData{}
+	err := dec.readBytes(&td.EthTxHash)
+	if err == nil {
+		t.EthTxHash = common.BytesToHash(td.EthTxHash)
+		err = dec.readBytes(&td.EthAddr)
+	}
+	if err == nil {
+		t.EthAddr = common.BytesToAddress(td.EthAddr)
+		err = dec.readString(&t.AFGHPublicKey)
+	}
+	if err == nil {
+		err = dec.readString(&s)
+		if err == nil {
+			t.QPHash, err = hash.FromString(s)
+		}
+	}
+	if err == nil {
+		err = dec.readBytes((*[]byte)(&t.SID))
+	}
+	if err == nil {
+		err = dec.readBytes((*[]byte)(&t.LID))
+	}
+	if err == nil {
+		err = dec.readBytes((*[]byte)(&t.QID))
+	}
+	if err == nil {
+		err = dec.readString(&s)
+		if err == nil {
+			t.Grant = Grant(s)
+		}
+	}
+	if err == nil {
+		err = dec.readBytes(&b)
+		if err == nil {
+			err = t.IssuedAt.UnmarshalBinary(b)
+		}
+	}
+	if err == nil {
+		err = dec.readBytes(&b)
+		if err == nil {
+			err = t.Expires.UnmarshalBinary(b)
+		}
+	}
+	if err == nil {
+		err = dec.readCbor(&t.Ctx)
+	}
+	if err == nil {
+		err = dec.readCbor(&td.Cnf)
+		if err == nil {
+			t.Confirmation = td.Cnf.toClientConfirmation()
+			err = dec.readBytes((*[]byte)(&t.SignerKey))
+		}
+		if err == io.EOF {
+			err = nil
+		}
+	}
+
+	return e.IfNotNil(err)
+}
+
+func isIDTag(tag byte) bool {
+	switch tag {
+	case tagSID, tagLID, tagQID, tagSubjectID:
+		return true
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+func newTokenEncoder() *tokenEncoder {
+	return &tokenEncoder{
+		vbuf: make([]byte, binary.MaxVarintLen64),
+	}
+}
+
+type tokenEncoder struct {
+	buf  bytes.Buffer
+	vbuf []byte
+}
+
+func (e *tokenEncoder) writeTag(tag byte) {
+	_ = e.buf.WriteByte(tag)
+}
+
+func (e *tokenEncoder) writeString(s string) {
+	e.writeBytes([]byte(s))
+}
+
+func (e *tokenEncoder) writeBytes(b []byte) {
+	e.writeUvarint(uint64(len(b)))
+	_, _ = e.buf.Write(b)
+}
+
+func (e *tokenEncoder) writeUvarint(v uint64) {
+	n := binary.PutUvarint(e.vbuf, v)
+	_, _ = e.buf.Write(e.vbuf[:n])
+}
+
+func (e *tokenEncoder) writeVarint(v int64) {
+	n := binary.PutVarint(e.vbuf, v)
+	_, _ = e.buf.Write(e.vbuf[:n])
+}
+
+// writeQPHash writes the given part hash, including its algorithm if withAlg is true.
+func (e *tokenEncoder) writeQPHash(h *hash.Hash, withAlg bool) {
+	e.buf.WriteByte(byte(h.Type.Code))
+	e.buf.WriteByte(byte(h.Type.Format))
+	if withAlg {
+		e.buf.WriteByte(byte(h.Type.Algorithm))
+	}
+	e.writeBytes(h.DecodedBytes())
+}
+
+// writeID writes the given ID with the given tag if it is not nil.
+func (e *tokenEncoder) writeID(tag byte, i id.ID) {
+	if i.IsNil() {
+		return
+	}
+	bts := i.Bytes()
+	if len(bts) == customIDLen {
+		e.writeTag(tag)
+		_ = e.buf.WriteByte(byte(i.Code()))
+		_, _ = e.buf.Write(bts)
+		return
+	}
+	e.writeTag(tag | tagVarLen)
+	_ = e.buf.WriteByte(byte(i.Code()))
+	e.writeBytes(bts)
+}
+
+func newDecoder(b []byte) *tokenDecoder {
+	return &tokenDecoder{buf: bytes.NewBuffer(b)}
+}
+
+type tokenDecoder struct {
+	buf *bytes.Buffer
+}
+
+func (e *tokenDecoder) readString(s *string) error {
+	var b []byte
+	err := e.readBytes(&b)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return nil
+	}
+	*s = string(b)
+	return nil
+}
+
+func (e *tokenDecoder) readBytes(b *[]byte) error {
+	n, err := binary.ReadUvarint(e.buf)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	if n > uint64(e.buf.Len()) {
+		return io.ErrUnexpectedEOF
+	}
+	bts := make([]byte, n)
+	_, err = e.buf.Read(bts)
+	*b = bts
+	return err
+}
+
+func (e *tokenDecoder) readFixed(n int) ([]byte, error) {
+	if n > e.buf.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	bts := make([]byte, n)
+	_, err := e.buf.Read(bts)
+	return bts, err
+}
+
+// readID reads an ID written with the given tag.
+func (e *tokenDecoder) readID(tag byte) (id.ID, error) {
+	code, err := e.buf.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var bts []byte
+	if tag&tagVarLen != 0 {
+		err = e.readBytes(&bts)
+	} else {
+		bts, err = e.readFixed(customIDLen)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return id.NewID(id.Code(code), bts), nil
+}
+
+// readQPHash reads a part hash. The algorithm byte is only present if withAlg is true, otherwise the algorithm is
+// SHA256.
+func (e *tokenDecoder) readQPHash(withAlg bool) (*hash.Hash, error) {
+	n := 2
+	if withAlg {
+		n = 3
+	}
+	tc, err := e.readFixed(n)
+	if err != nil {
+		return nil, err
+	}
+	htype := hash.Type{Code: hash.Code(tc[0]), Format: hash.Format(tc[1])}
+	if withAlg {
+		htype.Algorithm = hash.Algorithm(tc[2])
+	}
+	var bts []byte
+	err = e.readBytes(&bts)
+	if err != nil {
+		return nil, err
+	}
+	return hash.FromDecodedBytes(htype, bts)
+}
+
+func (e *tokenDecoder) readCbor(v interface{}) error {
+	return codecs.CborDecode(e.buf, v)
+}
@@ -1,17 +1,21 @@
 package eat_test
 
 import (
+	"encoding/hex"
 	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/eluv-io/utc-go"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/require"
 
 	"github.com/eluv-io/common-go/format"
 	"github.com/eluv-io/common-go/format/eat"
 	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
 	"github.com/eluv-io/common-go/format/types"
 	"github.com/eluv-io/common-go/util/byteutil"
 )
@@ -22,6 +26,12 @@ var qph = func() types.QPHash {
 	return digest.AsHash()
 }()
 
+func qphWith(alg hash.Algorithm) types.QPHash {
+	digest := hash.NewTypeDigest(hash.Type{Code: hash.QPart, Format: hash.Unencrypted, Algorithm: alg})
+	_, _ = digest.Write(byteutil.RandomBytes(10))
+	return digest.AsHash()
+}
+
 func TestTokenDataJSON(t *testing.T) {
 	zero := eat.TokenData{}
 	tokens := []eat.TokenData{
@@ -63,3 +73,117 @@ func TestTokenDataJSON(t *testing.T) {
 		require.Equal(t, td, unmarshalled)
 	}
 }
+
+func TestTokenDataCustom(t *testing.T) {
+	now := utc.Now().Truncate(time.Millisecond)
+	tokens := map[string]eat.TokenData{
+		"zero": {},
+		"full": {
+			EthTxHash:     txh,
+			EthAddr:       clientAddr,
+			AFGHPublicKey: "afgh",
+			QPHash:        qph,
+			SignerKey:     keys.Key(byteutil.RandomBytes(33)),
+			SID:           sid,
+			LID:           lid,
+			QID:           qid,
+			Subject:       "a subject",
+			Grant:         "read",
+			IssuedAt:      now,
+			Expires:       now.Add(time.Hour),
+			Ctx: map[string]interface{}{
+				"key1":       "val1",
+				eat.ElvIPGeo: "eu-west",
+			},
+			Confirmation: eat.ClientConfirmation{
+				AddrOfEphemeralKey: "0x195ee4d4b7bd5e8a1d8b56ac31e4cd8bdc1c13a6",
+				TTL:                300,
+			},
+		},
+		"blake3 part hash": {
+			QPHash: qphWith(hash.BLAKE3),
+			QID:    qid,
+		},
+		"sha512/256 part hash": {
+			QPHash: qphWith(hash.SHA512_256),
+		},
+		"id subject": {
+			QID:      id.Generate(id.Q), // 16 bytes
+			Subject:  id.NewID(id.User, clientAddr.Bytes()).String(),
+			IssuedAt: utc.UnixMilli(-1000),
+		},
+	}
+
+	for name, td := range tokens {
+		t.Run(name, func(t *testing.T) {
+			bts, err := td.Encode()
+			require.NoError(t, err)
+
+			var decoded eat.TokenData
+			require.NoError(t, decoded.Decode(bts))
+			// also caches the string form of the hashes for the comparison below
+			require.Equal(t, td.QPHash.String(), decoded.QPHash.String())
+			if !td.QPHash.IsNil() {
+				require.Equal(t, td.QPHash.Type, decoded.QPHash.Type)
+			}
+			require.Equal(t, td, decoded)
+
+			cbor, err := td.EncodeCBOR()
+			require.NoError(t, err)
+			fmt.Println(name, "custom", len(bts), "cbor", len(cbor))
+			require.LessOrEqual(t, len(bts), len(cbor))
+
+			if len(bts) > 1 {
+				require.Error(t, new(eat.TokenData).Decode(bts[:len(bts)-1]))
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		var td eat.TokenData
+		require.Error(t, td.Decode([]byte{1, 0}))   // invalid tag
+		require.Error(t, td.Decode([]byte{1, 100})) // invalid tag
+		require.Error(t, td.Decode([]byte{1, 11, 1, 'r', 11, 1, 'w'}))
+		require.Error(t, td.Decode([]byte{1, 11 | 0x80, 1, 'r'}))
+
+		// part hash with and without algorithm
+		bts, err := (&eat.TokenData{QPHash: qph}).Encode()
+		require.NoError(t, err)
+		alg, err := (&eat.TokenData{QPHash: qphWith(hash.BLAKE3)}).Encode()
+		require.NoError(t, err)
+		require.Error(t, td.Decode(append(bts, alg[1:]...)))
+	})
+}
+
+func TestTokenDataCustomLegacy(t *testing.T) {
+	// custom encoding of the untagged layout used before version 1
+	legacy, err := hex.DecodeString("207a3b1c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f80914195ee4d4b7bd5e8a1d8b56ac31e4cd8bdc1c13a60461666768326871705f516d59745563346954436262665653444e4b76745171726679657a50506e467645333377466d757477395042426b150678e045519e273a98fb8fb7e1b3a3b56dff48c1f71503ae277cd410f255c4e940fdedea39a782e369ac681504ae277cd410f255c4e940fdedea39a782e369ac680472656164090edec86d0000000000090edec87b1000000000062f63626f720aa1646b6579316476616c31062f63626f720af600")
+	require.NoError(t, err)
+
+	expected := eat.TokenData{
+		EthTxHash:     common.HexToHash("0x7a3b1c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f809"),
+		EthAddr:       common.HexToAddress("0x195ee4d4b7bd5e8a1d8b56ac31e4cd8bdc1c13a6"),
+		AFGHPublicKey: "afgh",
+		QPHash:        hash.MustParse("hqp_QmYtUc4iTCbbfVSDNKvtQqrfyezPPnFvE33wFmutw9PBBk"),
+		SID:           sid,
+		LID:           lid,
+		QID:           qid,
+		Grant:         "read",
+		IssuedAt:      utc.UnixMilli(1700000000000),
+		Expires:       utc.UnixMilli(1700003600000),
+		Ctx:           map[string]interface{}{"key1": "val1"},
+	}
+
+	var td eat.TokenData
+	require.NoError(t, td.Decode(legacy))
+	require.Equal(t, expected.QPHash.String(), td.QPHash.String())
+	require.True(t, expected.IssuedAt.Equal(td.IssuedAt))
+	require.True(t, expected.Expires.Equal(td.Expires))
+	expected.IssuedAt, expected.Expires = td.IssuedAt, td.Expires
+	require.Equal(t, expected, td)
+
+	// re-encoded with the current layout
+	bts, err := td.Encode()
+	require.NoError(t, err)
+	require.Less(t, len(bts), len(legacy))
+}
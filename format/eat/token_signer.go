@@ -0,0 +1,232 @@
+package eat
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+
+	"github.com/ChainSafe/go-schnorrkel"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
+	"github.com/eluv-io/common-go/format/sign"
+	"github.com/eluv-io/common-go/util/ethutil"
+	"github.com/eluv-io/errors-go"
+)
+
+// TokenSigner is a key-agnostic signer of tokens.
+type TokenSigner interface {
+	// SigType returns the type of signatures produced by this signer.
+	SigType() *TokenSigType
+	// PublicKey returns the public key corresponding to the signer's secret key.
+	PublicKey() keys.Key
+	// Sign signs the given data. For signature types that allow signer recovery (see TokenSigType.RecoversSigner),
+	// the data is the hash of the token according to the signature type and the signature must be 65 bytes long
+	// (R || S || V). For all other types, the data is the token's payload.
+	Sign(data []byte) (sig []byte, err error)
+}
+
+// NewTokenSigner creates a token signer for the given secret key. The following key types are supported:
+//   - keys.ES256KSecretKey: signature type ES256K (default), EIP191Personal or EIP712TypedData
+//   - keys.ED25519SecretKey: signature type ED25519
+//   - keys.SR25519SecretKey: signature type SR25519 - the key is the 32 byte "mini secret key"
+//
+// The optional sigType overrides the default signature type of the key.
+func NewTokenSigner(secretKey keys.Key, sigType ...*TokenSigType) (TokenSigner, error) {
+	e := errors.Template("NewTokenSigner", errors.K.Invalid, "key_type", secretKey.Code())
+
+	err := secretKey.Validate()
+	if err != nil {
+		return nil, e(err)
+	}
+
+	var st *TokenSigType
+	if len(sigType) > 0 {
+		st = sigType[0]
+	}
+
+	switch secretKey.Code() {
+	case keys.ES256KSecretKey:
+		sk, err := crypto.ToECDSA(secretKey.Bytes())
+		if err != nil {
+			return nil, e(err)
+		}
+		if st == nil {
+			st = SigTypes.ES256K()
+		}
+		if !st.RecoversSigner() {
+			return nil, e("reason", "invalid signature type for key", "sig_type", st)
+		}
+		return NewES256KSigner(sk, st), nil
+	case keys.ED25519SecretKey:
+		if st != nil && st != SigTypes.ED25519() {
+			return nil, e("reason", "invalid signature type for key", "sig_type", st)
+		}
+		return NewED25519Signer(secretKey.Bytes()), nil
+	case keys.SR25519SecretKey:
+		if st != nil && st != SigTypes.SR25519() {
+			return nil, e("reason", "invalid signature type for key", "sig_type", st)
+		}
+		var raw [schnorrkel.MiniSecretKeySize]byte
+		copy(raw[:], secretKey.Bytes())
+		msk, err := schnorrkel.NewMiniSecretKeyFromRaw(raw)
+		if err != nil {
+			return nil, e(err)
+		}
+		return NewSR25519Signer(msk), nil
+	}
+
+	return nil, e("reason", "unsupported key type")
+}
+
+// NewES256KSigner creates a token signer for the given secp256k1 private key. The signature type defaults to ES256K.
+func NewES256KSigner(sk *ecdsa.PrivateKey, sigType ...*TokenSigType) TokenSigner {
+	st := SigTypes.ES256K()
+	if len(sigType) > 0 && sigType[0] != nil {
+		st = sigType[0]
+	}
+	return &es256kSigner{sk: sk, sigType: st}
+}
+
+// NewED25519Signer creates a token signer for the given ed25519 private key.
+func NewED25519Signer(sk ed25519.PrivateKey) TokenSigner {
+	return &ed25519Signer{sk: sk}
+}
+
+// NewSR25519Signer creates a token signer for the given sr25519 mini secret key. The key is expanded to a secret key in
+// ed25519 mode, the way substrate does it.
+func NewSR25519Signer(msk *schnorrkel.MiniSecretKey) TokenSigner {
+	return &sr25519Signer{sk: msk.ExpandEd25519(), pk: msk.Public()}
+}
+
+// SignWithSigner signs this token with the given signer, producing a signature of the signer's type. For signature
+// types that allow signer recovery, the token's EthAddr is set to the signer's address. Otherwise, the token's SignerKey
+// is set to the signer's public key.
+func (t *Token) SignWithSigner(signer TokenSigner) error {
+	e := errors.Template("sign", errors.K.Invalid)
+	if signer == nil {
+		return e("reason", "signer is nil")
+	}
+
+	sigType := signer.SigType()
+	if sigType.RecoversSigner() {
+		signAddr := common.Address{}
+		if t.Type != Types.ClientConfirmation() {
+			var err error
+			signAddr, err = signerAddress(signer)
+			if err != nil {
+				return e(err)
+			}
+		}
+		return t.sign(signAddr, signer.Sign, sigType)
+	}
+
+	return t.signWithKey(signer.PublicKey(), signer.Sign, sigType)
+}
+
+// signerAddress returns the ethereum address of the given signer's public key.
+func signerAddress(signer TokenSigner) (common.Address, error) {
+	return publicKeyAddress(signer.PublicKey())
+}
+
+// publicKeyAddress returns the ethereum address of the given ES256K public key.
+func publicKeyAddress(pk keys.Key) (common.Address, error) {
+	err := pk.AssertCode(keys.ES256KPublicKey)
+	if err != nil {
+		return zeroAddr, err
+	}
+	pub, err := crypto.DecompressPubkey(pk.Bytes())
+	if err != nil {
+		return zeroAddr, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// signerSubject returns the default subject for tokens signed by the given signer - see publicKeySubject().
+func signerSubject(signer TokenSigner) string {
+	return publicKeySubject(signer.PublicKey())
+}
+
+// publicKeySubject returns the subject for the given public key: the user ID for Ethereum keys, the ed25519 ID for
+// ED25519 keys and the public key otherwise.
+func publicKeySubject(pk keys.Key) string {
+	switch pk.Code() {
+	case keys.ES256KPublicKey:
+		addr, err := publicKeyAddress(pk)
+		if err != nil {
+			return ""
+		}
+		return ethutil.AddressToID(addr, id.User).String()
+	case keys.ED25519PublicKey:
+		return id.NewID(id.Ed25519, pk.Bytes()).String()
+	}
+	return pk.String()
+}
+
+// -----------------------------------------------------------------------------
+
+type es256kSigner struct {
+	sk      *ecdsa.PrivateKey
+	sigType *TokenSigType
+}
+
+func (s *es256kSigner) SigType() *TokenSigType {
+	return s.sigType
+}
+
+func (s *es256kSigner) PublicKey() keys.Key {
+	return keys.New(keys.ES256KPublicKey, crypto.CompressPubkey(&s.sk.PublicKey))
+}
+
+func (s *es256kSigner) Sign(hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, s.sk)
+}
+
+// -----------------------------------------------------------------------------
+
+type ed25519Signer struct {
+	sk ed25519.PrivateKey
+}
+
+func (s *ed25519Signer) SigType() *TokenSigType {
+	return SigTypes.ED25519()
+}
+
+func (s *ed25519Signer) PublicKey() keys.Key {
+	return keys.New(keys.ED25519PublicKey, s.sk.Public().(ed25519.PublicKey))
+}
+
+func (s *ed25519Signer) Sign(msg []byte) ([]byte, error) {
+	if len(s.sk) != ed25519.PrivateKeySize {
+		return nil, errors.E("ed25519Signer.Sign", errors.K.Invalid,
+			"reason", "invalid private key size",
+			"size", len(s.sk))
+	}
+	return ed25519.Sign(s.sk, msg), nil
+}
+
+// -----------------------------------------------------------------------------
+
+type sr25519Signer struct {
+	sk *schnorrkel.SecretKey
+	pk *schnorrkel.PublicKey
+}
+
+func (s *sr25519Signer) SigType() *TokenSigType {
+	return SigTypes.SR25519()
+}
+
+func (s *sr25519Signer) PublicKey() keys.Key {
+	pk := s.pk.Encode()
+	return keys.New(keys.SR25519PublicKey, pk[:])
+}
+
+func (s *sr25519Signer) Sign(msg []byte) ([]byte, error) {
+	sig, err := s.sk.Sign(schnorrkel.NewSigningContext(sign.SR25519SigningContext, msg))
+	if err != nil {
+		return nil, errors.E("sr25519Signer.Sign", errors.K.Invalid, err)
+	}
+	bts := sig.Encode()
+	return bts[:], nil
+}
@@ -3,6 +3,7 @@ package eat_test
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/eluv-io/common-go/format/eat"
 	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/keys"
 	"github.com/eluv-io/common-go/format/structured"
 	"github.com/eluv-io/common-go/util/ethutil"
 	"github.com/eluv-io/common-go/util/jsonutil"
@@ -388,6 +390,173 @@ func TestSignatures(t *testing.T) {
 	assertEncodeDecode(t, tok)
 }
 
+func TestEIP712TypedDataSignature(t *testing.T) {
+	formats := []eat.TokenFormat{
+		eat.Formats.Json(),
+		eat.Formats.JsonCompressed(),
+		eat.Formats.Cbor(),
+		eat.Formats.CborCompressed(),
+	}
+	for _, format := range formats {
+		t.Run(format.Name, func(t *testing.T) {
+			tok := eat.New(eat.Types.EditorSigned(), format)
+			tok.SID = sid
+			tok.LID = lid
+			tok.QID = qid
+			tok.Subject = "me"
+			tok.Grant = eat.Grants.Read
+			tok.IssuedAt = utc.Now()
+			tok.Expires = tok.IssuedAt.Add(time.Hour)
+			tok.Ctx = map[string]interface{}{"k1": "v1", "k2": []interface{}{"a", "b"}}
+			err := tok.SignWithT(clientSK, eat.SigTypes.EIP712TypedData())
+			require.NoError(t, err)
+			require.Equal(t, eat.SigTypes.EIP712TypedData(), tok.SigType)
+
+			decoded := assertEncodeDecode(t, tok)
+			require.Equal(t, eat.SigTypes.EIP712TypedData(), decoded.SigType)
+			require.NoError(t, decoded.VerifySignatureFrom(clientAddr))
+			require.Contains(t, decoded.Explain(), "EIP712 HASH")
+
+			// tampering with the token data invalidates the signature
+			decoded.Subject = "someone else"
+			require.Error(t, decoded.VerifySignatureFrom(clientAddr))
+		})
+	}
+
+	t.Run("wallet signature", func(t *testing.T) {
+		tok := eat.New(eat.Types.ClientSigned(), eat.Formats.CborCompressed())
+		tok.SID = sid
+		tok.IssuedAt = utc.Now()
+		tok.Expires = tok.IssuedAt.Add(time.Hour)
+		tok.Grant = eat.Grants.Read
+
+		// wallets return signatures with V in {27, 28}
+		err := tok.SignWithFuncT(clientAddr, func(hash []byte) ([]byte, error) {
+			sig, err := crypto.Sign(hash, clientSK)
+			if err == nil {
+				sig[64] += 27
+			}
+			return sig, err
+		}, eat.SigTypes.EIP712TypedData())
+		require.NoError(t, err)
+
+		typedData, err := tok.TypedData()
+		require.NoError(t, err)
+		require.Equal(t, "TokenData", typedData.PrimaryType)
+		require.Equal(t, clientAddr.Hex(), typedData.Message["address"])
+
+		decoded := assertEncodeDecode(t, tok)
+		require.NoError(t, decoded.VerifySignatureFrom(clientAddr))
+	})
+}
+
+func TestKeySignatures(t *testing.T) {
+	edSK := keys.New(keys.ED25519SecretKey, ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize)))
+	srSK := keys.New(keys.SR25519SecretKey, bytes.Repeat([]byte{2}, 32))
+
+	formats := []eat.TokenFormat{
+		eat.Formats.Json(),
+		eat.Formats.JsonCompressed(),
+		eat.Formats.Cbor(),
+		eat.Formats.CborCompressed(),
+		eat.Formats.Custom(),
+	}
+
+	for _, sk := range []keys.Key{edSK, srSK} {
+		signer, err := eat.NewTokenSigner(sk)
+		require.NoError(t, err)
+
+		for _, format := range formats {
+			t.Run(signer.SigType().Name+"-"+format.Name, func(t *testing.T) {
+				tok := eat.New(eat.Types.ClientSigned(), format)
+				tok.SID = sid
+				tok.Grant = eat.Grants.Read
+				tok.IssuedAt = utc.Now()
+				tok.Expires = tok.IssuedAt.Add(time.Hour)
+				tok.Ctx = map[string]interface{}{"k1": "v1"}
+				err := tok.SignWithSigner(signer)
+				require.NoError(t, err)
+				require.Equal(t, signer.SigType(), tok.SigType)
+				require.Equal(t, signer.PublicKey(), tok.SignerKey)
+				require.Equal(t, common.Address{}, tok.EthAddr)
+
+				decoded := assertEncodeDecode(t, tok)
+				require.Equal(t, signer.SigType(), decoded.SigType)
+				require.Equal(t, signer.PublicKey(), decoded.SignerKey)
+				require.NoError(t, decoded.VerifySignatureFromKey(signer.PublicKey()))
+
+				// recovery of a signer address is not possible
+				require.Error(t, decoded.VerifySignatureFrom(clientAddr))
+
+				// a different key of the same type is rejected
+				otherSK := keys.New(keys.SR25519SecretKey, bytes.Repeat([]byte{3}, 32))
+				if sk.Code() == keys.ED25519SecretKey {
+					otherSK = keys.New(keys.ED25519SecretKey, ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize)))
+				}
+				other, err := eat.NewTokenSigner(otherSK)
+				require.NoError(t, err)
+				require.Equal(t, signer.SigType(), other.SigType())
+				require.Error(t, decoded.VerifySignatureFromKey(other.PublicKey()))
+
+				// a key of a different type is rejected
+				for _, otherSK := range []keys.Key{edSK, srSK} {
+					if otherSK.Code() == sk.Code() {
+						continue
+					}
+					other, err := eat.NewTokenSigner(otherSK)
+					require.NoError(t, err)
+					require.Error(t, decoded.VerifySignatureFromKey(other.PublicKey()))
+				}
+			})
+		}
+	}
+
+	t.Run("ed25519 id", func(t *testing.T) {
+		signer, err := eat.NewTokenSigner(edSK)
+		require.NoError(t, err)
+
+		tok, err := eat.NewEditorSigned(sid, lid, qid).
+			WithGrant(eat.Grants.Read).
+			SignWithSigner(signer).
+			Token()
+		require.NoError(t, err)
+
+		signerID := id.NewID(id.Ed25519, signer.PublicKey().Bytes())
+		require.Equal(t, signerID.String(), tok.Subject)
+		require.Equal(t, signerID, tok.Signer())
+
+		decoded := assertEncodeDecode(t, tok)
+		require.NoError(t, decoded.VerifySignatureFromID(signerID))
+		require.Error(t, decoded.VerifySignatureFromID(clientID))
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		signer, err := eat.NewTokenSigner(srSK)
+		require.NoError(t, err)
+
+		tok, err := eat.NewClientSigned(sid).
+			WithGrant(eat.Grants.Read).
+			SignWithSigner(signer).
+			Token()
+		require.NoError(t, err)
+		require.Equal(t, signer.PublicKey().String(), tok.Subject)
+
+		// replacing the signer key invalidates the signature
+		other, err := eat.NewTokenSigner(keys.New(keys.SR25519SecretKey, bytes.Repeat([]byte{3}, 32)))
+		require.NoError(t, err)
+		tampered := tok.With(tok.Format)
+		tampered.SignerKey = other.PublicKey()
+		require.Error(t, tampered.VerifySignature())
+	})
+
+	t.Run("invalid signature type", func(t *testing.T) {
+		_, err := eat.NewTokenSigner(edSK, eat.SigTypes.ES256K())
+		require.Error(t, err)
+		_, err = eat.NewTokenSigner(keys.New(keys.ES256KSecretKey, crypto.FromECDSA(clientSK)), eat.SigTypes.SR25519())
+		require.Error(t, err)
+	})
+}
+
 func assertEncodeDecode(t *testing.T, tok *eat.Token) *eat.Token {
 	encoded, err := tok.Encode()
 	require.NoError(t, err)
@@ -711,6 +880,12 @@ func TestClientSigned(t *testing.T) {
 			WithExpires(now.Add(time.Hour)).
 			WithGrant(eat.Grants.Read).
 			SignEIP912Personal(clientSK),
+		eat.NewClientSigned(sid).
+			WithIssuedAt(now).
+			WithExpires(now.Add(time.Hour)).
+			WithGrant(eat.Grants.Read).
+			WithCtx(map[string]any{"bla": "xyz"}).
+			SignEIP712TypedData(clientSK),
 	}
 
 	for _, encoder := range encoders {
@@ -0,0 +1,148 @@
+package eat
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/eluv-io/common-go/format/sign"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// typedDataPrimaryType is the EIP-712 primary type of the token data.
+const typedDataPrimaryType = "TokenData"
+
+// typedDataDomain is the EIP-712 domain of auth tokens. It intentionally has no chain ID or verifying contract, since
+// tokens are not bound to a particular chain.
+var typedDataDomain = apitypes.TypedDataDomain{
+	Name:    "Eluvio Content Fabric Access Token",
+	Version: "1.0",
+}
+
+// typedDataTypes is the canonical EIP-712 schema of the token data. All fields are always part of the message (with
+// their zero value if not set in the token), so the schema and hence the type hash never change for a given version of
+// the domain.
+var typedDataTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+	},
+	typedDataPrimaryType: {
+		{Name: "tokenType", Type: "string"},
+		{Name: "txHash", Type: "string"},
+		{Name: "address", Type: "address"},
+		{Name: "afghPublicKey", Type: "string"},
+		{Name: "partHash", Type: "string"},
+		{Name: "space", Type: "string"},
+		{Name: "library", Type: "string"},
+		{Name: "content", Type: "string"},
+		{Name: "subject", Type: "string"},
+		{Name: "grant", Type: "string"},
+		{Name: "issuedAt", Type: "string"},
+		{Name: "expires", Type: "string"},
+		{Name: "context", Type: "string"},
+		{Name: "confirmation", Type: "Confirmation"},
+		{Name: "capabilities", Type: "string"},
+		{Name: "embedded", Type: "string"},
+	},
+	"Confirmation": {
+		{Name: "ephemeralKeyAddress", Type: "string"},
+		{Name: "ephemeralPublicKey", Type: "string"},
+		{Name: "ttl", Type: "uint256"},
+	},
+}
+
+// TypedData returns the EIP-712 typed data representation of the token that is signed with the
+// SigTypes.EIP712TypedData() signature type. The result may be passed to a wallet (e.g. with eth_signTypedData_v4) in
+// order to produce the token signature.
+//
+// The typed data is independent of the token's encoding format. Times are truncated to millisecond precision like in
+// the binary token encodings, and the context and capabilities are represented as JSON strings. Client tokens include
+// their embedded token in encoded form.
+func (t *Token) TypedData() (*apitypes.TypedData, error) {
+	e := errors.Template("TypedData", errors.K.Invalid)
+	if t == nil {
+		return nil, e("reason", "token is nil")
+	}
+
+	ctx := ""
+	if len(t.Ctx) > 0 {
+		bts, err := json.Marshal(t.Ctx)
+		if err != nil {
+			return nil, e(err, "reason", "failed to marshal ctx")
+		}
+		ctx = string(bts)
+	}
+
+	caps := ""
+	if len(t.Capabilities) > 0 {
+		bts, err := json.Marshal(t.Capabilities)
+		if err != nil {
+			return nil, e(err, "reason", "failed to marshal capabilities")
+		}
+		caps = string(bts)
+	}
+
+	txh := ""
+	if t.HasEthTxHash() {
+		txh = t.EthTxHash.Hex()
+	}
+
+	qph := ""
+	if !t.QPHash.IsNil() {
+		qph = t.QPHash.String()
+	}
+
+	embedded := ""
+	if t.Embedded != nil {
+		embedded = t.Embedded.String()
+	}
+
+	return &apitypes.TypedData{
+		Types:       typedDataTypes,
+		PrimaryType: typedDataPrimaryType,
+		Domain:      typedDataDomain,
+		Message: apitypes.TypedDataMessage{
+			"tokenType":     t.Type.Name,
+			"txHash":        txh,
+			"address":       t.EthAddr.Hex(),
+			"afghPublicKey": t.AFGHPublicKey,
+			"partHash":      qph,
+			"space":         t.SID.String(),
+			"library":       t.LID.String(),
+			"content":       t.QID.String(),
+			"subject":       t.Subject,
+			"grant":         string(t.Grant),
+			"issuedAt":      typedDataTime(t.IssuedAt),
+			"expires":       typedDataTime(t.Expires),
+			"context":       ctx,
+			"confirmation": apitypes.TypedDataMessage{
+				"ephemeralKeyAddress": t.Confirmation.AddrOfEphemeralKey,
+				"ephemeralPublicKey":  t.Confirmation.PublicEphemeralKey,
+				"ttl":                 math.NewHexOrDecimal256(int64(t.Confirmation.TTL)),
+			},
+			"capabilities": caps,
+			"embedded":     embedded,
+		},
+	}, nil
+}
+
+// typedDataTime formats the given time with millisecond precision (the precision of encoded tokens) or returns an
+// empty string for the zero time.
+func typedDataTime(t utc.UTC) string {
+	if t.IsZero() {
+		return ""
+	}
+	return utc.UnixMilli(t.UnixMilli()).String()
+}
+
+// hashTypedData returns the EIP-712 hash of the token's typed data.
+func (t *Token) hashTypedData() ([]byte, error) {
+	typedData, err := t.TypedData()
+	if err != nil {
+		return nil, err
+	}
+	return sign.HashTypedData(typedData)
+}
@@ -43,6 +43,7 @@ var allTypes = []TokenType{
 	{"asl", "signed-link", true, false},   // 8
 	{"acs", "client-signed", true, true},  // 9
 	{"acc", "confirmation", true, false},  // 10
+	{"aat", "attenuated", true, true},     // 11
 }
 
 type enumType int
@@ -62,6 +63,12 @@ func (enumType) Node() TokenType               { return allTypes[7] }  // token
 func (enumType) SignedLink() TokenType         { return allTypes[8] }  // token for signed-links (https://github.com/qluvio/proj-mgm/issues/14#issuecomment-724867064)
 func (enumType) ClientSigned() TokenType       { return allTypes[9] }  // client-signed token
 func (enumType) ClientConfirmation() TokenType { return allTypes[10] } // client-confirmation token (can be required by client-signed or editor-signed)
+func (enumType) Attenuated() TokenType         { return allTypes[11] } // a token embedding a parent token and restricting it with caveats
+
+// embedsToken returns true if tokens of this type embed another token.
+func (t *tokenType) embedsToken() bool {
+	return t == Types.Client() || t == Types.Attenuated()
+}
 
 var prefixToType = map[string]*tokenType{}
 
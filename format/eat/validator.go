@@ -88,6 +88,10 @@ func isEmpty(field interface{}) bool {
 		return len(t) == 0
 	case ClientConfirmation:
 		return t == zeroCnf
+	case []Capability:
+		return len(t) == 0
+	case *Caveats:
+		return t == nil
 	default:
 		return ifutil.IsEmpty(field)
 	}
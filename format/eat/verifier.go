@@ -0,0 +1,332 @@
+package eat
+
+import (
+	"time"
+
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// TrustResolver returns the IDs of the signers that are trusted to sign the given token. Supported IDs are user IDs
+// (the ethereum address of the signer) and ed25519 IDs (the ed25519 public key of the signer) - see
+// Token.VerifySignatureFromID().
+type TrustResolver func(tok *Token) ([]id.ID, error)
+
+// RevocationChecker checks whether a token has been revoked.
+type RevocationChecker interface {
+	// IsRevoked returns true if the given token has been revoked, false otherwise.
+	IsRevoked(tok *Token) (bool, error)
+}
+
+// Policy is the verification policy for a given token type.
+type Policy struct {
+	// MaxValidity is the maximum validity period of tokens counted from their issued-at time. Unlimited if 0.
+	MaxValidity time.Duration
+	// TimeSkew is the accepted clock skew for the issued-at time of tokens.
+	TimeSkew time.Duration
+	// ConfirmationMaxValidity is the maximum validity period of client confirmation tokens. Unlimited if 0.
+	ConfirmationMaxValidity time.Duration
+	// TrustedSigner requires the token to be signed by one of the signers returned by the verifier's trust resolver.
+	// Always enforced for state channel tokens, since their signer is not stored in the token itself. Not applicable to
+	// attenuated tokens, since they are signed with the ephemeral key designated by their parent token.
+	TrustedSigner bool
+}
+
+// RejectReason is the reason for rejecting a token in Verifier.Verify(). It is stored in the "rejected" field of the
+// returned error - see RejectReasonOf().
+type RejectReason string
+
+// RejectReasons defines the reasons for rejecting a token.
+var RejectReasons = struct {
+	None                RejectReason
+	InvalidToken        RejectReason
+	TypeNotAllowed      RejectReason
+	FormatNotAllowed    RejectReason
+	SigTypeNotAllowed   RejectReason
+	UntrustedSigner     RejectReason
+	InvalidTimes        RejectReason
+	Revoked             RejectReason
+	InvalidConfirmation RejectReason
+	InvalidSignedLink   RejectReason
+	InvalidEmbedded     RejectReason
+}{
+	None:                "",
+	InvalidToken:        "invalid-token",        // nil token, invalid token data or signature
+	TypeNotAllowed:      "type-not-allowed",     // token type not allowed by the verifier
+	FormatNotAllowed:    "format-not-allowed",   // token format not allowed by the verifier
+	SigTypeNotAllowed:   "sig-type-not-allowed", // signature type not allowed by the verifier
+	UntrustedSigner:     "untrusted-signer",     // token not signed by a trusted signer
+	InvalidTimes:        "invalid-times",        // token expired, not yet valid or max validity exceeded
+	Revoked:             "revoked",              // token revoked
+	InvalidConfirmation: "invalid-confirmation", // missing, unexpected or invalid client confirmation token
+	InvalidSignedLink:   "invalid-signed-link",  // signed link does not match the link source or path
+	InvalidEmbedded:     "invalid-embedded",     // embedded token rejected
+}
+
+// RejectReasonOf returns the reason why a token was rejected by Verifier.Verify() if err is the error returned by
+// Verify(), RejectReasons.None otherwise.
+func RejectReasonOf(err error) RejectReason {
+	if reason, ok := errors.Field(err, "rejected").(RejectReason); ok {
+		return reason
+	}
+	return RejectReasons.None
+}
+
+// Verifier verifies tokens according to a configuration that is set up once and shared by all verifications:
+//   - the trust resolver provides the trusted signers of tokens whose policy requires a trusted signer
+//   - the clock is used for verifying validity times
+//   - the allowed token types, formats and signature types restrict the accepted tokens (including embedded tokens).
+//     All are allowed if not configured.
+//   - the optional revocation checker is consulted for each token (including embedded tokens)
+//   - the per-type policies define validity periods and trust requirements
+//
+// A Verifier must not be modified once it is in use.
+type Verifier struct {
+	trust         TrustResolver
+	now           func() utc.UTC
+	types         map[TokenType]bool
+	formats       map[TokenFormat]bool
+	sigTypes      map[*TokenSigType]bool
+	revocation    RevocationChecker
+	policies      map[TokenType]*Policy
+	defaultPolicy Policy
+}
+
+// NewVerifier creates a new verifier with the given trust resolver.
+func NewVerifier(trust TrustResolver) *Verifier {
+	return &Verifier{
+		trust:    trust,
+		now:      utc.Now,
+		policies: map[TokenType]*Policy{},
+	}
+}
+
+// WithClock sets the function returning the current time.
+func (v *Verifier) WithClock(now func() utc.UTC) *Verifier {
+	v.now = now
+	return v
+}
+
+// WithTypes restricts the accepted token types to the given types.
+func (v *Verifier) WithTypes(types ...TokenType) *Verifier {
+	v.types = map[TokenType]bool{}
+	for _, typ := range types {
+		v.types[typ] = true
+	}
+	return v
+}
+
+// WithFormats restricts the accepted token formats to the given formats.
+func (v *Verifier) WithFormats(formats ...TokenFormat) *Verifier {
+	v.formats = map[TokenFormat]bool{}
+	for _, format := range formats {
+		v.formats[format] = true
+	}
+	return v
+}
+
+// WithSigTypes restricts the accepted signature types to the given types.
+func (v *Verifier) WithSigTypes(sigTypes ...*TokenSigType) *Verifier {
+	v.sigTypes = map[*TokenSigType]bool{}
+	for _, sigType := range sigTypes {
+		v.sigTypes[sigType] = true
+	}
+	return v
+}
+
+// WithRevocationChecker sets the revocation checker.
+func (v *Verifier) WithRevocationChecker(checker RevocationChecker) *Verifier {
+	v.revocation = checker
+	return v
+}
+
+// WithPolicy sets the policy for the given token type.
+func (v *Verifier) WithPolicy(typ TokenType, policy Policy) *Verifier {
+	v.policies[typ] = &policy
+	return v
+}
+
+// WithDefaultPolicy sets the policy for token types without specific policy.
+func (v *Verifier) WithDefaultPolicy(policy Policy) *Verifier {
+	v.defaultPolicy = policy
+	return v
+}
+
+// Policy returns the policy for the given token type.
+func (v *Verifier) Policy(typ TokenType) Policy {
+	if policy, ok := v.policies[typ]; ok {
+		return *policy
+	}
+	return v.defaultPolicy
+}
+
+// Verify verifies the given token and the optional client confirmation token and returns the resulting authorization.
+// If the token is rejected, the returned error is of kind Permission and contains the reason - see RejectReasonOf().
+//
+// The token must have been parsed from its encoded form, since the authorization includes the original bearer.
+//
+// Signed link tokens are verified like any other token, but without checking the link they were issued for - use
+// VerifySignedLink() for that.
+//
+// Attenuated tokens are verified along their entire chain, each token according to the policy of its type. Only the
+// client confirmation of the outermost token is verified, since the confirmations of its parent tokens are satisfied by
+// the chain of signatures.
+func (v *Verifier) Verify(tok *Token, confirmation ...*Token) (*Authorization, error) {
+	e := errors.Template("verify token", errors.K.Permission)
+
+	var cnf *Token
+	if len(confirmation) > 0 {
+		cnf = confirmation[0]
+	}
+
+	err := v.verifyToken(tok)
+	if err != nil {
+		return nil, e(err)
+	}
+
+	if cnf != nil {
+		err = cnf.VerifySignature()
+		if err != nil {
+			return nil, e(err, "rejected", RejectReasons.InvalidConfirmation)
+		}
+	}
+	policy := v.Policy(tok.Type)
+	err = tok.validateConfirmationAt(v.now(), cnf, policy.ConfirmationMaxValidity, policy.TimeSkew)
+	if err != nil {
+		return nil, e(err, "rejected", RejectReasons.InvalidConfirmation)
+	}
+
+	auth, err := NewAuthorization(tok, cnf)
+	if err != nil {
+		return nil, e(err, "rejected", RejectReasons.InvalidToken)
+	}
+	return auth, nil
+}
+
+// VerifySignedLink verifies the given signed link token like Verify() and additionally checks that it was issued for
+// the link with the given path in the content with the given ID.
+func (v *Verifier) VerifySignedLink(tok *Token, srcQID, linkPath string) (*Authorization, error) {
+	e := errors.Template("verify signed link", errors.K.Permission)
+
+	if tok != nil && tok.Type != Types.SignedLink() {
+		return nil, e("reason", "not a signed link token",
+			"type", tok.Type,
+			"rejected", RejectReasons.TypeNotAllowed)
+	}
+
+	auth, err := v.Verify(tok)
+	if err != nil {
+		return nil, e(err)
+	}
+
+	err = tok.VerifySignedLink(srcQID, linkPath)
+	if err != nil {
+		return nil, e(err, "rejected", RejectReasons.InvalidSignedLink)
+	}
+
+	return auth, nil
+}
+
+// verifyToken verifies the given token without its confirmation.
+func (v *Verifier) verifyToken(tok *Token) error {
+	e := errors.Template("verifyToken", errors.K.Permission)
+
+	if tok.IsNil() {
+		return e("reason", "token is nil or unknown", "rejected", RejectReasons.InvalidToken)
+	}
+
+	e = e.Add("type", tok.Type, "format", tok.Format, "sig_type", tok.SigType)
+
+	if v.types != nil && !v.types[tok.Type] {
+		return e("rejected", RejectReasons.TypeNotAllowed)
+	}
+	if v.formats != nil && !v.formats[tok.Format] {
+		return e("rejected", RejectReasons.FormatNotAllowed)
+	}
+	if v.sigTypes != nil && !v.sigTypes[tok.SigType] {
+		return e("rejected", RejectReasons.SigTypeNotAllowed)
+	}
+
+	// validates the token and verifies its signature for all types except state channel tokens
+	err := tok.VerifySignature()
+	if err != nil {
+		return e(err, "rejected", RejectReasons.InvalidToken)
+	}
+
+	policy := v.Policy(tok.Type)
+	if (policy.TrustedSigner && tok.Type != Types.Attenuated()) || tok.Type == Types.StateChannel() {
+		err = v.verifyTrustedSigner(tok)
+		if err != nil {
+			return e(err, "rejected", RejectReasons.UntrustedSigner)
+		}
+	}
+
+	if check, requireExpires := timesPolicy(tok); check {
+		err = tok.verifyTimesAt(v.now(), policy.MaxValidity, policy.TimeSkew, requireExpires)
+		if err != nil {
+			return e(err, "rejected", RejectReasons.InvalidTimes)
+		}
+	}
+
+	if v.revocation != nil {
+		revoked, err := v.revocation.IsRevoked(tok)
+		if err != nil {
+			return e(err, "rejected", RejectReasons.Revoked)
+		}
+		if revoked {
+			return e("reason", "token revoked", "rejected", RejectReasons.Revoked)
+		}
+	}
+
+	if tok.Embedded != nil {
+		err = v.verifyToken(tok.Embedded)
+		if err != nil {
+			return e(err, "rejected", RejectReasons.InvalidEmbedded)
+		}
+	}
+
+	return nil
+}
+
+// timesPolicy returns whether the validity times of the given token are verified and whether the token must have an
+// expiration time.
+func timesPolicy(tok *Token) (check bool, requireExpires bool) {
+	if !allowsTimes(tok.Type) {
+		return false, false
+	}
+	switch tok.Type {
+	case Types.SignedLink():
+		// signed links have no expiration per default
+		return true, false
+	case Types.Node():
+		// node tokens bound to a content part may omit validity times
+		if !tok.QPHash.IsNil() {
+			return !tok.IssuedAt.IsZero() || !tok.Expires.IsZero(), false
+		}
+	}
+	return true, true
+}
+
+// verifyTrustedSigner verifies that the token is signed by one of the signers returned by the trust resolver.
+func (v *Verifier) verifyTrustedSigner(tok *Token) error {
+	e := errors.Template("verifyTrustedSigner", errors.K.Permission)
+
+	if v.trust == nil {
+		return e("reason", "no trust resolver")
+	}
+
+	trusted, err := v.trust(tok)
+	if err != nil {
+		return e(err)
+	}
+
+	for _, signer := range trusted {
+		err = tok.VerifySignatureFromID(signer)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return e("reason", "token not signed by a trusted signer", "trusted", trusted)
+}
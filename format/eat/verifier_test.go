@@ -0,0 +1,216 @@
+package eat_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/util/ethutil"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+type revokedSubjects map[string]bool
+
+func (r revokedSubjects) IsRevoked(tok *eat.Token) (bool, error) {
+	return r[tok.Subject], nil
+}
+
+// mustParse encodes and parses the token produced by the given encoder, since authorizations are made from parsed
+// tokens only.
+func mustParse(enc eat.Encoder) *eat.Token {
+	tok, err := eat.Parse(enc.MustEncode())
+	if err != nil {
+		panic(err)
+	}
+	return tok
+}
+
+func TestVerifier(t *testing.T) {
+	now := utc.Now().Truncate(time.Millisecond)
+	serverID := ethutil.AddressToID(serverAddr, id.User)
+
+	trust := func(tok *eat.Token) ([]id.ID, error) {
+		return []id.ID{serverID}, nil
+	}
+	newVerifier := func() *eat.Verifier {
+		return eat.NewVerifier(trust).WithClock(func() utc.UTC { return now })
+	}
+
+	stateChannel := func() *eat.Token {
+		return mustParse(eat.NewStateChannel(sid, lid, qid, clientID.String()).
+			WithIssuedAt(now).
+			Sign(serverSK))
+	}
+
+	sk1, _ := crypto.GenerateKey()
+	adr1 := crypto.PubkeyToAddress(sk1.PublicKey)
+
+	editorSigned := mustParse(eat.NewEditorSigned(sid, lid, qid).
+		WithIssuedAt(now).
+		WithExpires(now.Add(time.Minute * 5)).
+		WithGrant(eat.Grants.Read).
+		WithConfirmation(eat.ClientConfirmation{
+			AddrOfEphemeralKey: adr1.String(),
+		}).
+		Sign(clientSK))
+	confirmation := mustParse(eat.NewClientConfirmation(now, time.Minute).Sign(sk1))
+
+	srcQID := id.Generate(id.Q)
+	signedLink := mustParse(eat.NewSignedLink(sid, lid, qid, "/meta/some/link", srcQID).
+		WithIssuedAt(now).
+		Sign(clientSK))
+
+	t.Run("state channel", func(t *testing.T) {
+		auth, err := newVerifier().Verify(stateChannel())
+		require.NoError(t, err)
+		require.Equal(t, eat.Types.StateChannel(), auth.Type)
+		require.Equal(t, clientID.String(), auth.User())
+	})
+
+	t.Run("state channel untrusted", func(t *testing.T) {
+		tok := mustParse(eat.NewStateChannel(sid, lid, qid, clientID.String()).
+			WithIssuedAt(now).
+			Sign(clientSK))
+		_, err := newVerifier().Verify(tok)
+		require.Error(t, err)
+		require.True(t, errors.IsKind(errors.K.Permission, err))
+		require.Equal(t, eat.RejectReasons.UntrustedSigner, eat.RejectReasonOf(err))
+	})
+
+	t.Run("client token with embedded state channel", func(t *testing.T) {
+		tok, err := eat.NewClientToken(stateChannel())
+		require.NoError(t, err)
+		require.NoError(t, tok.SignWith(clientSK))
+		tok, err = eat.Parse(tok.String())
+		require.NoError(t, err)
+
+		auth, err := newVerifier().Verify(tok)
+		require.NoError(t, err)
+		require.Equal(t, eat.Types.Client(), auth.Type)
+		require.Equal(t, qid, auth.QID)
+
+		// embedded state channel signed by untrusted key
+		embedded := mustParse(eat.NewStateChannel(sid, lid, qid, clientID.String()).
+			WithIssuedAt(now).
+			Sign(clientSK))
+		tok, err = eat.NewClientToken(embedded)
+		require.NoError(t, err)
+		require.NoError(t, tok.SignWith(clientSK))
+		tok, err = eat.Parse(tok.String())
+		require.NoError(t, err)
+
+		_, err = newVerifier().Verify(tok)
+		require.Error(t, err)
+		require.Equal(t, eat.RejectReasons.InvalidEmbedded, eat.RejectReasonOf(err))
+	})
+
+	t.Run("allowed types formats and sig types", func(t *testing.T) {
+		_, err := newVerifier().WithTypes(eat.Types.EditorSigned()).Verify(stateChannel())
+		require.Equal(t, eat.RejectReasons.TypeNotAllowed, eat.RejectReasonOf(err))
+
+		_, err = newVerifier().WithFormats(eat.Formats.Cbor()).Verify(stateChannel())
+		require.Equal(t, eat.RejectReasons.FormatNotAllowed, eat.RejectReasonOf(err))
+
+		_, err = newVerifier().WithSigTypes(eat.SigTypes.ED25519()).Verify(stateChannel())
+		require.Equal(t, eat.RejectReasons.SigTypeNotAllowed, eat.RejectReasonOf(err))
+
+		_, err = newVerifier().
+			WithTypes(eat.Types.StateChannel()).
+			WithFormats(eat.Formats.JsonCompressed()).
+			WithSigTypes(eat.SigTypes.ES256K()).
+			Verify(stateChannel())
+		require.NoError(t, err)
+	})
+
+	t.Run("times", func(t *testing.T) {
+		tok := stateChannel()
+
+		_, err := newVerifier().WithClock(func() utc.UTC { return now.Add(2 * time.Hour) }).Verify(tok)
+		require.Equal(t, eat.RejectReasons.InvalidTimes, eat.RejectReasonOf(err))
+
+		_, err = newVerifier().
+			WithClock(func() utc.UTC { return now.Add(20 * time.Minute) }).
+			WithPolicy(eat.Types.StateChannel(), eat.Policy{MaxValidity: 10 * time.Minute}).
+			Verify(tok)
+		require.Equal(t, eat.RejectReasons.InvalidTimes, eat.RejectReasonOf(err))
+
+		_, err = newVerifier().
+			WithClock(func() utc.UTC { return now.Add(-time.Minute) }).
+			WithPolicy(eat.Types.StateChannel(), eat.Policy{TimeSkew: 2 * time.Minute}).
+			Verify(tok)
+		require.NoError(t, err)
+	})
+
+	t.Run("missing expiration", func(t *testing.T) {
+		tok := eat.New(eat.Types.ClientSigned(), eat.Formats.Json())
+		tok.SID = sid
+		tok.Grant = eat.Grants.Read
+		require.NoError(t, tok.SignWith(clientSK))
+		tok, err := eat.Parse(tok.String())
+		require.NoError(t, err)
+		require.True(t, tok.Expires.IsZero())
+
+		_, err = newVerifier().Verify(tok)
+		require.Equal(t, eat.RejectReasons.InvalidTimes, eat.RejectReasonOf(err))
+
+		// signed links have no expiration per default
+		require.True(t, signedLink.Expires.IsZero())
+		_, err = newVerifier().Verify(signedLink)
+		require.NoError(t, err)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		verifier := newVerifier().WithRevocationChecker(revokedSubjects{clientID.String(): true})
+		_, err := verifier.Verify(stateChannel())
+		require.Equal(t, eat.RejectReasons.Revoked, eat.RejectReasonOf(err))
+	})
+
+	t.Run("trusted signer policy", func(t *testing.T) {
+		_, err := newVerifier().Verify(editorSigned, confirmation)
+		require.NoError(t, err)
+
+		_, err = newVerifier().
+			WithPolicy(eat.Types.EditorSigned(), eat.Policy{TrustedSigner: true}).
+			Verify(editorSigned, confirmation)
+		require.Equal(t, eat.RejectReasons.UntrustedSigner, eat.RejectReasonOf(err))
+	})
+
+	t.Run("confirmation", func(t *testing.T) {
+		auth, err := newVerifier().Verify(editorSigned, confirmation)
+		require.NoError(t, err)
+		require.Equal(t, clientID, auth.Signer())
+
+		_, err = newVerifier().Verify(editorSigned)
+		require.Equal(t, eat.RejectReasons.InvalidConfirmation, eat.RejectReasonOf(err))
+
+		_, err = newVerifier().
+			WithClock(func() utc.UTC { return now.Add(2 * time.Minute) }).
+			Verify(editorSigned, confirmation)
+		require.Equal(t, eat.RejectReasons.InvalidConfirmation, eat.RejectReasonOf(err))
+
+		_, err = newVerifier().Verify(stateChannel(), confirmation)
+		require.Equal(t, eat.RejectReasons.InvalidConfirmation, eat.RejectReasonOf(err))
+	})
+
+	t.Run("signed link", func(t *testing.T) {
+		auth, err := newVerifier().VerifySignedLink(signedLink, srcQID.String(), "/meta/some/link")
+		require.NoError(t, err)
+		require.Equal(t, clientID.String(), auth.User())
+
+		_, err = newVerifier().VerifySignedLink(signedLink, srcQID.String(), "/meta/other/link")
+		require.Equal(t, eat.RejectReasons.InvalidSignedLink, eat.RejectReasonOf(err))
+
+		_, err = newVerifier().VerifySignedLink(stateChannel(), srcQID.String(), "/meta/some/link")
+		require.Equal(t, eat.RejectReasons.TypeNotAllowed, eat.RejectReasonOf(err))
+	})
+
+	t.Run("nil token", func(t *testing.T) {
+		_, err := newVerifier().Verify(nil)
+		require.Equal(t, eat.RejectReasons.InvalidToken, eat.RejectReasonOf(err))
+	})
+}
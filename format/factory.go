@@ -101,12 +101,12 @@ type factory struct{}
 
 // NewContentDigest returns a digest object for calculating content hashes.
 func (f *factory) NewContentDigest(format hash.Format, id QID) *hash.Digest {
-	return hash.NewDigest(sha256.New(), hash.Type{hash.Q, format}).WithID(id)
+	return hash.NewDigest(sha256.New(), hash.Type{Code: hash.Q, Format: format}).WithID(id)
 }
 
 // NewContentPartDigest returns a digest object for calculating content hashes.
 func (f *factory) NewContentPartDigest(format hash.Format) *hash.Digest {
-	return hash.NewDigest(sha256.New(), hash.Type{hash.QPart, format})
+	return hash.NewDigest(sha256.New(), hash.Type{Code: hash.QPart, Format: format})
 }
 
 // GenerateAccountID generates a new account ID
@@ -0,0 +1,86 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+
+	"github.com/zeebo/blake3"
+
+	"github.com/eluv-io/errors-go"
+)
+
+// Algorithm is the digest algorithm of a hash
+type Algorithm uint8
+
+// lint disable
+const (
+	SHA256     Algorithm = iota // SHA-256 - the original algorithm of all hashes
+	BLAKE3                      // BLAKE3 with 256-bit output
+	SHA512_256                  // SHA-512/256
+)
+
+// algorithm holds the definition of a digest algorithm.
+type algorithm struct {
+	name string
+	size int
+	new  func() hash.Hash
+}
+
+var algorithms = map[Algorithm]*algorithm{}
+
+func init() {
+	RegisterAlgorithm(SHA256, "SHA-256", sha256.Size, sha256.New)
+	RegisterAlgorithm(BLAKE3, "BLAKE3", 32, func() hash.Hash { return blake3.New() })
+	RegisterAlgorithm(SHA512_256, "SHA-512/256", sha512.Size256, sha512.New512_256)
+}
+
+// RegisterAlgorithm registers the digest algorithm with the given name, digest size and constructor. Hashes using the
+// algorithm additionally need a type prefix - see RegisterType(). Registering an algorithm again replaces its previous
+// definition.
+func RegisterAlgorithm(a Algorithm, name string, size int, fn func() hash.Hash) {
+	algorithms[a] = &algorithm{name: name, size: size, new: fn}
+}
+
+// AlgorithmFromString returns the algorithm with the given name.
+func AlgorithmFromString(s string) (Algorithm, error) {
+	for a, alg := range algorithms {
+		if alg.name == s {
+			return a, nil
+		}
+	}
+	return SHA256, errors.NoTrace("parse algorithm", errors.K.Invalid, "string", s)
+}
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	alg, ok := algorithms[a]
+	if !ok {
+		return "unknown"
+	}
+	return alg.name
+}
+
+// IsValid returns true if the algorithm is registered.
+func (a Algorithm) IsValid() bool {
+	_, ok := algorithms[a]
+	return ok
+}
+
+// Size returns the size of the digests produced by the algorithm in bytes, or -1 if the algorithm is unknown.
+func (a Algorithm) Size() int {
+	alg, ok := algorithms[a]
+	if !ok {
+		return -1
+	}
+	return alg.size
+}
+
+// New returns a new hash.Hash calculating digests with the algorithm, or nil if the algorithm is unknown.
+func (a Algorithm) New() hash.Hash {
+	alg, ok := algorithms[a]
+	if !ok {
+		return nil
+	}
+	return alg.new()
+}
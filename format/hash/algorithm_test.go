@@ -0,0 +1,140 @@
+package hash_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/blake3"
+
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/id"
+)
+
+func TestAlgorithms(t *testing.T) {
+	data := make([]byte, 300*1024)
+	rand.New(rand.NewSource(0)).Read(data)
+
+	sha := sha256.Sum256(data)
+	b3 := blake3.Sum256(data)
+	s512 := sha512.Sum512_256(data)
+
+	tests := []struct {
+		alg        hash.Algorithm
+		name       string
+		partPrefix string
+		qPrefix    string
+		digest     []byte
+	}{
+		{hash.SHA256, "SHA-256", "hqp_", "hq__", sha[:]},
+		{hash.BLAKE3, "BLAKE3", "hqpb", "hqb_", b3[:]},
+		{hash.SHA512_256, "SHA-512/256", "hqps", "hqs_", s512[:]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.True(t, test.alg.IsValid())
+			require.Equal(t, test.name, test.alg.String())
+			require.Equal(t, 32, test.alg.Size())
+			alg, err := hash.AlgorithmFromString(test.name)
+			require.NoError(t, err)
+			require.Equal(t, test.alg, alg)
+
+			h, err := hash.CalcHashWith(test.alg, bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, hash.Type{Code: hash.QPart, Format: hash.Unencrypted, Algorithm: test.alg}, h.Type)
+			require.Equal(t, test.digest, h.Digest)
+			require.Equal(t, int64(len(data)), h.Size)
+			require.Equal(t, test.partPrefix, h.String()[:4])
+
+			parsed, err := hash.FromString(h.String())
+			require.NoError(t, err)
+			require.Equal(t, h.Type, parsed.Type)
+			require.True(t, h.Equal(parsed))
+			require.NoError(t, h.AssertEqual(parsed))
+
+			bts, err := cbor.Marshal(h)
+			require.NoError(t, err)
+			var decoded *hash.Hash
+			require.NoError(t, cbor.Unmarshal(bts, &decoded))
+			require.True(t, h.Equal(decoded))
+
+			bts, err = json.Marshal(h)
+			require.NoError(t, err)
+			decoded = nil
+			require.NoError(t, json.Unmarshal(bts, &decoded))
+			require.True(t, h.Equal(decoded))
+
+			qid := id.Generate(id.Q)
+			qhash, err := h.As(hash.Q, qid)
+			require.NoError(t, err)
+			require.Equal(t, test.qPrefix, qhash.String()[:4])
+			require.Equal(t, test.alg, qhash.Type.Algorithm)
+			qparsed, err := hash.FromString(qhash.String())
+			require.NoError(t, err)
+			require.True(t, qhash.Equal(qparsed))
+			require.Equal(t, qid, qparsed.ID)
+
+			d := hash.NewTypeDigest(hash.Type{Code: hash.Q, Format: hash.Unencrypted, Algorithm: test.alg}).WithID(qid)
+			_, err = d.Write(data)
+			require.NoError(t, err)
+			require.True(t, qhash.Equal(d.AsHash()))
+		})
+	}
+
+	// hashes with the same digest but different algorithms differ
+	h1, err := hash.NewPart(hash.Type{Code: hash.QPart, Format: hash.Unencrypted}, sha[:], 10, 0)
+	require.NoError(t, err)
+	h2, err := hash.NewPart(hash.Type{Code: hash.QPart, Format: hash.Unencrypted, Algorithm: hash.BLAKE3}, sha[:], 10, 0)
+	require.NoError(t, err)
+	require.False(t, h1.Equal(h2))
+	require.Error(t, h1.AssertEqual(h2))
+	require.Contains(t, h2.Describe(), "BLAKE3")
+	require.NotContains(t, h1.Describe(), "SHA-256")
+
+	_, err = hash.AlgorithmFromString("MD5")
+	require.Error(t, err)
+	require.False(t, hash.Algorithm(99).IsValid())
+	require.Equal(t, -1, hash.Algorithm(99).Size())
+	_, err = hash.CalcHashWith(hash.Algorithm(99), bytes.NewReader(data))
+	require.Error(t, err)
+}
+
+func TestRegisterType(t *testing.T) {
+	const sha224 = hash.Algorithm(42)
+	hash.RegisterAlgorithm(sha224, "SHA-224", sha256.Size224, sha256.New224)
+
+	htype := hash.Type{Code: hash.QPart, Format: hash.Unencrypted, Algorithm: sha224}
+	require.Error(t, hash.RegisterType("hqp_", htype))                                      // prefix exists
+	require.Error(t, hash.RegisterType("xqpx", htype))                                      // invalid prefix
+	require.Error(t, hash.RegisterType("hqp", htype))                                       // invalid prefix
+	require.Error(t, hash.RegisterType("hqpx", hash.Type{Code: hash.QPart, Algorithm: 43})) // unknown algorithm
+	require.Error(t, hash.RegisterType("hqlx", hash.Type{Code: hash.QPartLive, Algorithm: sha224}))
+	require.Error(t, hash.RegisterType("hqpx", hash.Type{Code: hash.QPart})) // type exists
+	require.NoError(t, hash.RegisterType("hqpx", htype))
+
+	h, err := hash.CalcHashWith(sha224, bytes.NewReader([]byte("some data")))
+	require.NoError(t, err)
+	require.Len(t, h.Digest, sha256.Size224)
+	require.Equal(t, "hqpx", h.String()[:4])
+	parsed, err := hash.FromString(h.String())
+	require.NoError(t, err)
+	require.True(t, h.Equal(parsed))
+}
+
+func TestLegacyHashesUnchanged(t *testing.T) {
+	qphash, err := hsh.As(hash.QPart, nil)
+	require.NoError(t, err)
+	for _, s := range []string{hashString, qphash.String()} {
+		h, err := hash.FromString(s)
+		require.NoError(t, err)
+		require.Equal(t, hash.SHA256, h.Type.Algorithm)
+		reencoded, err := hash.FromDecodedBytes(h.Type, h.DecodedBytes())
+		require.NoError(t, err)
+		require.Equal(t, s, reencoded.String())
+	}
+}
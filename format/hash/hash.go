@@ -2,7 +2,6 @@ package hash
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"hash"
@@ -63,8 +62,9 @@ func (c Code) MustParse(s string) *Hash {
 type Format uint8
 
 const (
-	Unencrypted Format = iota // SHA256, No encryption
-	AES128AFGH                // SHA256, AES-128, AFGHG BLS12-381, 1 MB block size
+	Unencrypted Format = iota // No encryption
+	AES128AFGH                // AES-128, AFGHG BLS12-381, 1 MB block size
+	Merkle                    // No encryption, digest is the root of a Merkle tree over the part data - see MerkleTree
 )
 
 // FromString parses the given string and returns the hash.
@@ -79,10 +79,11 @@ func (f Format) FromString(s string) (*Hash, error) {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-// Type, the composition of Code and Format, is the type of a hash
+// Type, the composition of Code, Format and Algorithm, is the type of a hash
 type Type struct {
-	Code   Code
-	Format Format
+	Code      Code
+	Format    Format
+	Algorithm Algorithm // the digest algorithm - the zero value is SHA256
 }
 
 func TypeFromString(s string) (Type, error) {
@@ -116,6 +117,11 @@ func (t Type) Describe() string {
 		f = "unencrypted"
 	case AES128AFGH:
 		f = "encrypted with AES-128, AFGHG BLS12-381, 1 MB block size"
+	case Merkle:
+		f = "unencrypted, Merkle tree over 64 KB chunks"
+	}
+	if t.Algorithm != SHA256 {
+		f += ", " + t.Algorithm.String()
 	}
 	return c + ", " + f
 }
@@ -124,14 +130,24 @@ const prefixLen = 4
 
 var typeToPrefix = map[Type]string{}
 var prefixToType = map[string]Type{
-	"hunk": Type{UNKNOWN, Unencrypted},
-	"hq__": Type{Q, Unencrypted},
-	"hqp_": Type{QPart, Unencrypted},
-	"hqpe": Type{QPart, AES128AFGH},
-	"hql_": Type{QPartLive, Unencrypted},
-	"hqle": Type{QPartLive, AES128AFGH},
-	"hqt_": Type{QPartLiveTransient, Unencrypted},
-	"hqte": Type{QPartLiveTransient, AES128AFGH},
+	"hunk": Type{UNKNOWN, Unencrypted, SHA256},
+	"hq__": Type{Q, Unencrypted, SHA256},
+	"hqp_": Type{QPart, Unencrypted, SHA256},
+	"hqpe": Type{QPart, AES128AFGH, SHA256},
+	"hql_": Type{QPartLive, Unencrypted, SHA256},
+	"hqle": Type{QPartLive, AES128AFGH, SHA256},
+	"hqt_": Type{QPartLiveTransient, Unencrypted, SHA256},
+	"hqte": Type{QPartLiveTransient, AES128AFGH, SHA256},
+	"hqpm": Type{QPart, Merkle, SHA256},
+
+	"hqb_": Type{Q, Unencrypted, BLAKE3},
+	"hqpb": Type{QPart, Unencrypted, BLAKE3},
+	"hqeb": Type{QPart, AES128AFGH, BLAKE3},
+	"hqmb": Type{QPart, Merkle, BLAKE3},
+	"hqs_": Type{Q, Unencrypted, SHA512_256},
+	"hqps": Type{QPart, Unencrypted, SHA512_256},
+	"hqes": Type{QPart, AES128AFGH, SHA512_256},
+	"hqms": Type{QPart, Merkle, SHA512_256},
 }
 
 func init() {
@@ -139,10 +155,34 @@ func init() {
 		if len(p) != prefixLen {
 			log.Fatal("invalid hash prefix definition", "prefix", p)
 		}
+		if !t.Algorithm.IsValid() {
+			log.Fatal("invalid hash prefix definition", "prefix", p, "algorithm", t.Algorithm)
+		}
 		typeToPrefix[t] = p
 	}
 }
 
+// RegisterType registers the given prefix for the given hash type. This enables hashes with additional digest
+// algorithms, which need to be registered first with RegisterAlgorithm(). Live part hashes only support SHA256.
+// Registration is not thread-safe and should be performed during initialization.
+func RegisterType(prefix string, t Type) error {
+	e := errors.Template("register hash type", errors.K.Invalid, "prefix", prefix)
+	if len(prefix) != prefixLen || prefix[0] != 'h' {
+		return e("reason", "invalid prefix")
+	} else if t.Code == UNKNOWN || (t.Code.IsLive() && t.Algorithm != SHA256) {
+		return e("reason", "invalid code", "code", t.Code)
+	} else if !t.Algorithm.IsValid() {
+		return e("reason", "unknown algorithm", "algorithm", t.Algorithm)
+	} else if _, ok := prefixToType[prefix]; ok {
+		return e(errors.K.Exist, "reason", "prefix already registered")
+	} else if p, ok := typeToPrefix[t]; ok {
+		return e(errors.K.Exist, "reason", "type already registered", "registered_prefix", p)
+	}
+	prefixToType[prefix] = t
+	typeToPrefix[t] = prefix
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // Hash is the output of a cryptographic hash function and associated metadata, identifying a particular instance of an
@@ -155,6 +195,8 @@ func init() {
 //	QPartLive:          type (1 byte) | expiration (var bytes) | digest (var bytes)
 //	QPartLiveTransient: type (1 byte) | expiration (var bytes) | digest (var bytes)
 //	(Old) QPartLive:    type (1 byte) | digest (24-25 bytes)
+//
+// The size of the digest of content and part hashes is determined by the digest algorithm of the type - see Algorithm.
 type Hash struct {
 	Type         Type
 	Digest       []byte
@@ -176,8 +218,8 @@ func NewObject(htype Type, digest []byte, size int64, id ei.ID) (*Hash, error) {
 		return nil, e("reason", "code not supported", "code", htype.Code)
 	}
 
-	if len(digest) != sha256.Size {
-		return nil, e("reason", "invalid digest", "digest", digest)
+	if len(digest) != htype.Algorithm.Size() {
+		return nil, e("reason", "invalid digest", "digest", digest, "algorithm", htype.Algorithm)
 	}
 
 	if size < 0 {
@@ -205,8 +247,8 @@ func NewPart(htype Type, digest []byte, size int64, preambleSize int64) (*Hash,
 		return nil, e("reason", "code not supported", "code", htype.Code)
 	}
 
-	if len(digest) != sha256.Size {
-		return nil, e("reason", "invalid digest", "digest", digest)
+	if len(digest) != htype.Algorithm.Size() {
+		return nil, e("reason", "invalid digest", "digest", digest, "algorithm", htype.Algorithm)
 	}
 
 	if size < 0 {
@@ -302,8 +344,8 @@ func FromDecodedBytes(htype Type, b []byte) (*Hash, error) {
 	var expiration utc.UTC
 	if !htype.Code.IsLive() {
 		// Parse digest
-		m := sha256.Size
-		if n+m > len(b) {
+		m := htype.Algorithm.Size()
+		if m <= 0 || n+m > len(b) {
 			return nil, e("reason", "invalid digest")
 		}
 		digest = b[n : n+m]
@@ -447,7 +489,7 @@ func (h *Hash) prefix() string {
 		p, found = typeToPrefix[h.Type]
 	}
 	if !found {
-		return typeToPrefix[Type{UNKNOWN, Unencrypted}]
+		return typeToPrefix[Type{UNKNOWN, Unencrypted, SHA256}]
 	}
 	return p
 }
@@ -501,7 +543,7 @@ func (h *Hash) As(c Code, id ei.ID) (*Hash, error) {
 		return nil, errors.NoTrace("convert hash", errors.K.Invalid, "reason", "no conversion for parts with preamble", "hash", h)
 	} else if h.IsLive() || c.IsLive() {
 		return nil, errors.NoTrace("convert hash", errors.K.Invalid, "reason", "no conversion for live parts", "hash", h, "code", c)
-	} else if _, ok := typeToPrefix[Type{c, h.Type.Format}]; !ok {
+	} else if _, ok := typeToPrefix[Type{c, h.Type.Format, h.Type.Algorithm}]; !ok {
 		return nil, errors.NoTrace("convert hash", errors.K.Invalid, "reason", "invaid type", "code", c, "format", h.Type.Format)
 	}
 	var res Hash = *h
@@ -611,6 +653,12 @@ func NewDigest(h hash.Hash, t Type) *Digest {
 	return &Digest{Hash: h, preamble: preamble.NewSizer(), htype: t}
 }
 
+// NewTypeDigest creates a new digest that calculates the hash with the digest algorithm of the given type. Does not
+// support live and Merkle part hashes - see MerkleDigest for the latter.
+func NewTypeDigest(t Type) *Digest {
+	return NewDigest(t.Algorithm.New(), t)
+}
+
 func (d *Digest) WithPreamble(preambleSize int64) *Digest {
 	if d.htype.Code == QPart {
 		if preambleSize > 0 {
@@ -671,20 +719,16 @@ func (d *Digest) AsHash() *Hash {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+// CalcHash calculates the SHA256 part hash of the data read from the given reader - see CalcHashWith().
 func CalcHash(reader io.ReadSeeker, size ...int64) (*Hash, error) {
-	digest := NewDigest(sha256.New(), Type{QPart, Unencrypted})
+	return CalcHashWith(SHA256, reader, size...)
+}
 
-	// Check for preamble
-	var preambleSize int64
-	var err error
-	if len(size) > 0 {
-		_, _, preambleSize, err = preamble.Read(reader, false, size[0])
-	} else {
-		_, _, preambleSize, err = preamble.Read(reader, false)
-	}
-	if errors.IsNotExist(err) {
-		preambleSize = 0
-	} else if err != nil {
+// CalcHashWith calculates the unencrypted part hash of the data read from the given reader with the given digest
+// algorithm. The optional size is the size of the data and is used for reading the preamble, if any.
+func CalcHashWith(alg Algorithm, reader io.ReadSeeker, size ...int64) (*Hash, error) {
+	digest, preambleSize, err := newPartDigest(alg, reader, size...)
+	if err != nil {
 		return nil, err
 	}
 
@@ -711,3 +755,29 @@ func CalcHash(reader io.ReadSeeker, size ...int64) (*Hash, error) {
 
 	return digest.AsHash(), nil
 }
+
+// newPartDigest creates the digest for calculating the unencrypted part hash with the given algorithm and reads the
+// size of the part's preamble (if any) from the given reader.
+func newPartDigest(alg Algorithm, reader io.ReadSeeker, size ...int64) (*Digest, int64, error) {
+	htype := Type{QPart, Unencrypted, alg}
+	if _, ok := typeToPrefix[htype]; !ok {
+		return nil, 0, errors.E("calc hash", errors.K.Invalid, "reason", "unsupported algorithm", "algorithm", alg)
+	}
+	digest := NewTypeDigest(htype)
+
+	// Check for preamble
+	var preambleSize int64
+	var err error
+	if len(size) > 0 {
+		_, _, preambleSize, err = preamble.Read(reader, false, size[0])
+	} else {
+		_, _, preambleSize, err = preamble.Read(reader, false)
+	}
+	if errors.IsNotExist(err) {
+		preambleSize = 0
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	return digest, preambleSize, nil
+}
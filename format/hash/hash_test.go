@@ -327,7 +327,7 @@ func TestWrappedJSON(t *testing.T) {
 func TestCreation(t *testing.T) {
 	digest, _ := hex.DecodeString("9cbc07c3f991725836a3aa2a581ca2029198aa420b9d99bc0e131d9f3e2cbe47")
 	idObj, _ := id.FromString("iq__WxoChT9EZU2PRdTdNU7Ldf")
-	h, err := hash.NewObject(hash.Type{hash.Q, hash.Unencrypted}, digest, 1024, idObj)
+	h, err := hash.NewObject(hash.Type{Code: hash.Q, Format: hash.Unencrypted}, digest, 1024, idObj)
 	assert.NoError(t, err)
 	assertHash(t, h, "hq_")
 	//assertHash(t, GenerateAccountHash(), "acc")
@@ -447,7 +447,7 @@ func TestOldLivePart(t *testing.T) {
 	require.True(t, h.Expiration.IsZero())
 	require.True(t, h.IsLive())
 
-	h = &hash.Hash{Type: hash.Type{hash.QPartLive, hash.Unencrypted}, Digest: h.Digest}
+	h = &hash.Hash{Type: hash.Type{Code: hash.QPartLive, Format: hash.Unencrypted}, Digest: h.Digest}
 	require.Equal(t, oldLivePart, h.String())
 
 	h, err = hash.FromString(oldLivePart2)
@@ -458,10 +458,10 @@ func TestOldLivePart(t *testing.T) {
 	require.True(t, h.Expiration.IsZero())
 	require.True(t, h.IsLive())
 
-	h = &hash.Hash{Type: hash.Type{hash.QPartLive, hash.Unencrypted}, Digest: h.Digest}
+	h = &hash.Hash{Type: hash.Type{Code: hash.QPartLive, Format: hash.Unencrypted}, Digest: h.Digest}
 	require.Equal(t, oldLivePart2, h.String())
 
-	h, err = hash.NewLive(hash.Type{hash.QPartLive, hash.Unencrypted}, h.Digest, utc.Zero)
+	h, err = hash.NewLive(hash.Type{Code: hash.QPartLive, Format: hash.Unencrypted}, h.Digest, utc.Zero)
 	require.Error(t, err)
 	require.Nil(t, h)
 }
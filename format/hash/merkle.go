@@ -0,0 +1,467 @@
+package hash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math/bits"
+
+	"github.com/eluv-io/common-go/format/preamble"
+	"github.com/eluv-io/errors-go"
+)
+
+// MerkleChunkSize is the size of the chunks covered by the leaves of the Merkle tree of Merkle part hashes.
+const MerkleChunkSize = 64 * 1024
+
+// MerklePreambleFormat is the preamble format of Merkle parts. The preamble data is the encoded Merkle tree - see
+// MerkleTree.Encode().
+const MerklePreambleFormat = "/merkle"
+
+// merkleTreeVersion is the version of the Merkle tree encoding.
+const merkleTreeVersion = 1
+
+// Domain separation prefixes of leaf and interior node hashes as defined in RFC 6962, section 2.1.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleTree is the Merkle tree over the data of a Merkle part, i.e. the part data following the preamble. The leaves
+// are the digests of consecutive chunks of MerkleChunkSize bytes (the last chunk may be shorter), the root is the digest
+// of the part's Merkle hash. The tree is stored in the part preamble and serves as proof for verifying individual
+// chunks while streaming arbitrary ranges of the part - see NewMerkleReader().
+//
+// The tree is computed as specified in RFC 6962, section 2.1 with the part's digest algorithm: leaf digests are
+// H(0x00 | chunk), interior nodes H(0x01 | left | right). The data of empty parts is treated as a single empty chunk.
+//
+// Encoding:
+//
+//	version (1 byte) | algorithm (1 byte) | chunk size (uvarint) | data size (uvarint) | leaf digests (n * digest size)
+type MerkleTree struct {
+	Algorithm Algorithm
+	ChunkSize int64
+	Size      int64
+	Leaves    [][]byte
+}
+
+// CalcMerkleTree calculates the Merkle tree with the given digest algorithm over the data read from the given reader.
+func CalcMerkleTree(alg Algorithm, reader io.Reader) (*MerkleTree, error) {
+	d, err := NewMerkleDigest(alg)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(d, reader)
+	if err != nil {
+		return nil, errors.E("calc merkle tree", errors.K.IO, err)
+	}
+	return d.Tree(), nil
+}
+
+// DecodeMerkleTree decodes a Merkle tree from the given bytes - see MerkleTree.Encode().
+func DecodeMerkleTree(b []byte) (*MerkleTree, error) {
+	e := errors.Template("decode merkle tree", errors.K.Invalid)
+	if len(b) < 2 {
+		return nil, e("reason", "invalid encoding")
+	} else if b[0] != merkleTreeVersion {
+		return nil, e("reason", "unsupported version", "version", b[0])
+	}
+	t := &MerkleTree{Algorithm: Algorithm(b[1])}
+	if !t.Algorithm.IsValid() {
+		return nil, e("reason", "unknown algorithm", "algorithm", t.Algorithm)
+	}
+	n := 2
+
+	chunkSize, m := binary.Uvarint(b[n:])
+	if m <= 0 || chunkSize == 0 || chunkSize > 1<<30 {
+		return nil, e("reason", "invalid chunk size")
+	}
+	n += m
+	t.ChunkSize = int64(chunkSize)
+
+	size, m := binary.Uvarint(b[n:])
+	if m <= 0 || size > 1<<62 {
+		return nil, e("reason", "invalid size")
+	}
+	n += m
+	t.Size = int64(size)
+
+	digestSize := t.Algorithm.Size()
+	count := t.leafCount()
+	if count > int64(len(b)) || int64(len(b)-n) != count*int64(digestSize) {
+		return nil, e("reason", "invalid leaf digests", "expected", count, "size", len(b)-n)
+	}
+	t.Leaves = make([][]byte, count)
+	for i := range t.Leaves {
+		t.Leaves[i] = b[n : n+digestSize : n+digestSize]
+		n += digestSize
+	}
+	return t, nil
+}
+
+// Encode encodes the Merkle tree. Only the leaves are encoded, since the rest of the tree can be computed from them.
+func (t *MerkleTree) Encode() []byte {
+	digestSize := t.Algorithm.Size()
+	b := make([]byte, 2, 2+2*binary.MaxVarintLen64+len(t.Leaves)*digestSize)
+	b[0] = merkleTreeVersion
+	b[1] = byte(t.Algorithm)
+	b = binary.AppendUvarint(b, uint64(t.ChunkSize))
+	b = binary.AppendUvarint(b, uint64(t.Size))
+	for _, leaf := range t.Leaves {
+		b = append(b, leaf...)
+	}
+	return b
+}
+
+// WritePreamble writes the encoded Merkle tree as preamble to the given writer. Returns the size of the preamble.
+func (t *MerkleTree) WritePreamble(w io.Writer) (int64, error) {
+	return preamble.Write(w, t.Encode(), MerklePreambleFormat)
+}
+
+// Root computes the root digest of the Merkle tree.
+func (t *MerkleTree) Root() []byte {
+	if len(t.Leaves) == 0 {
+		return nil
+	}
+	return merkleRoot(t.Algorithm.New(), t.Leaves)
+}
+
+// Hash returns the Merkle part hash of a part consisting of a preamble of the given size followed by the data covered
+// by this tree.
+func (t *MerkleTree) Hash(preambleSize int64) (*Hash, error) {
+	return NewPart(Type{QPart, Merkle, t.Algorithm}, t.Root(), preambleSize+t.Size, preambleSize)
+}
+
+// Verify verifies that this tree is the Merkle tree of the given Merkle part hash.
+func (t *MerkleTree) Verify(h *Hash) error {
+	e := errors.Template("verify merkle tree", errors.K.Invalid, "hash", h)
+	switch {
+	case h.IsNil() || h.Type.Code != QPart || h.Type.Format != Merkle:
+		return e("reason", "not a merkle part hash")
+	case t.Algorithm != h.Type.Algorithm:
+		return e("reason", "algorithm mismatch", "algorithm", t.Algorithm)
+	case t.ChunkSize != MerkleChunkSize:
+		return e("reason", "invalid chunk size", "chunk_size", t.ChunkSize)
+	case t.Size != h.Size-h.PreambleSize:
+		return e("reason", "size mismatch", "size", t.Size)
+	case int64(len(t.Leaves)) != t.leafCount():
+		return e("reason", "invalid leaf count", "leaves", len(t.Leaves))
+	case !bytes.Equal(t.Root(), h.Digest):
+		return e("reason", "root digest mismatch")
+	}
+	return nil
+}
+
+// VerifyChunk verifies the chunk with the given index against its leaf digest.
+func (t *MerkleTree) VerifyChunk(index int, chunk []byte) error {
+	e := errors.Template("verify merkle chunk", errors.K.Invalid, "index", index)
+	if index < 0 || index >= len(t.Leaves) {
+		return e("reason", "invalid chunk index", "chunks", len(t.Leaves))
+	}
+	if int64(len(chunk)) != t.chunkLen(index) {
+		return e("reason", "invalid chunk size", "size", len(chunk), "expected", t.chunkLen(index))
+	}
+	if !bytes.Equal(merkleLeaf(t.Algorithm.New(), chunk), t.Leaves[index]) {
+		return e("reason", "chunk digest mismatch")
+	}
+	return nil
+}
+
+// leafCount returns the number of leaves of the tree as determined by the chunk and data size.
+func (t *MerkleTree) leafCount() int64 {
+	if t.Size == 0 {
+		return 1
+	}
+	return (t.Size + t.ChunkSize - 1) / t.ChunkSize
+}
+
+// chunkLen returns the length of the chunk with the given index.
+func (t *MerkleTree) chunkLen(index int) int64 {
+	return min(t.ChunkSize, t.Size-int64(index)*t.ChunkSize)
+}
+
+func merkleLeaf(h hash.Hash, chunk []byte) []byte {
+	h.Reset()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(chunk)
+	return h.Sum(nil)
+}
+
+func merkleRoot(h hash.Hash, nodes [][]byte) []byte {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	// split at the largest power of two smaller than the number of nodes
+	k := 1 << (bits.Len(uint(len(nodes)-1)) - 1)
+	left := merkleRoot(h, nodes[:k])
+	right := merkleRoot(h, nodes[k:])
+	h.Reset()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// MerkleDigest calculates the Merkle tree over the data written to it.
+type MerkleDigest struct {
+	alg    Algorithm
+	h      hash.Hash
+	n      int64 // bytes written to the current chunk
+	size   int64
+	leaves [][]byte
+}
+
+// make sure MerkleDigest implements the io.Writer interface
+var _ io.Writer = (*MerkleDigest)(nil)
+
+// NewMerkleDigest creates a new Merkle digest with the given digest algorithm.
+func NewMerkleDigest(alg Algorithm) (*MerkleDigest, error) {
+	if !alg.IsValid() {
+		return nil, errors.E("new merkle digest", errors.K.Invalid, "reason", "unknown algorithm", "algorithm", alg)
+	}
+	d := &MerkleDigest{alg: alg, h: alg.New()}
+	d.h.Write([]byte{merkleLeafPrefix})
+	return d, nil
+}
+
+func (d *MerkleDigest) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(int64(len(p)), MerkleChunkSize-d.n)
+		d.h.Write(p[:n])
+		d.n += n
+		d.size += n
+		p = p[n:]
+		if d.n == MerkleChunkSize {
+			d.finishChunk()
+		}
+	}
+	return written, nil
+}
+
+// Tree finalizes the calculation and returns the Merkle tree of all the bytes previously written to this digest.
+func (d *MerkleDigest) Tree() *MerkleTree {
+	if d.n > 0 || len(d.leaves) == 0 {
+		d.finishChunk()
+	}
+	return &MerkleTree{
+		Algorithm: d.alg,
+		ChunkSize: MerkleChunkSize,
+		Size:      d.size,
+		Leaves:    d.leaves,
+	}
+}
+
+func (d *MerkleDigest) finishChunk() {
+	d.leaves = append(d.leaves, d.h.Sum(nil))
+	d.h.Reset()
+	d.h.Write([]byte{merkleLeafPrefix})
+	d.n = 0
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// CalcMerkleHash reads the Merkle tree from the preamble of the part read from the given reader, verifies it against
+// the part data and returns the part's Merkle hash. The optional size is the size of the part and is used for reading
+// the preamble.
+func CalcMerkleHash(reader io.ReadSeeker, size ...int64) (*Hash, error) {
+	e := errors.Template("calc merkle hash", errors.K.Invalid)
+
+	tree, preambleSize, err := readMerklePreamble(reader, size...)
+	if err != nil {
+		return nil, e(err)
+	}
+	_, err = reader.Seek(preambleSize, io.SeekStart)
+	if err != nil {
+		return nil, e(errors.K.IO, err)
+	}
+	calculated, err := CalcMerkleTree(tree.Algorithm, reader)
+	if err != nil {
+		return nil, e(err)
+	}
+	return tree.verifyCalculated(calculated, preambleSize)
+}
+
+// verifyCalculated verifies this tree read from the preamble of a part against the tree calculated from the part data
+// and returns the part's Merkle hash.
+func (t *MerkleTree) verifyCalculated(calculated *MerkleTree, preambleSize int64) (*Hash, error) {
+	e := errors.Template("calc merkle hash", errors.K.Invalid)
+	h, err := calculated.Hash(preambleSize)
+	if err != nil {
+		return nil, e(err)
+	}
+	err = t.Verify(h)
+	if err != nil {
+		return nil, e(err, "reason", "preamble does not match part data")
+	}
+	return h, nil
+}
+
+func readMerklePreamble(reader io.ReadSeeker, size ...int64) (*MerkleTree, int64, error) {
+	data, format, preambleSize, err := preamble.Read(reader, false, size...)
+	if err != nil {
+		return nil, 0, err
+	} else if format != MerklePreambleFormat {
+		return nil, 0, errors.E("read merkle preamble", errors.K.Invalid, "reason", "invalid preamble format",
+			"format", format)
+	}
+	tree, err := DecodeMerkleTree(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tree, preambleSize, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// MerkleReader is an io.ReadSeeker over the data of a Merkle part that verifies each chunk against the part's Merkle
+// tree as it is read. Reads always fetch and verify entire chunks from the underlying reader, hence reading any range
+// of the part only requires reading the chunks overlapping the range. Reading a corrupted chunk fails with an error of
+// kind errors.K.Invalid, and so do all subsequent reads.
+//
+// Offsets are relative to the start of the part data, i.e. excluding the preamble.
+type MerkleReader struct {
+	tree   *MerkleTree
+	src    io.ReadSeeker
+	base   int64 // offset of the part data in src
+	srcOff int64 // current offset in src, or -1 if unknown
+	off    int64 // current read offset
+	chunk  []byte
+	idx    int // index of the verified chunk held in chunk, or -1
+	err    error
+}
+
+// make sure MerkleReader implements the io.ReadSeekCloser interface
+var _ io.ReadSeekCloser = (*MerkleReader)(nil)
+
+// NewMerkleReader creates a verifying reader for the data of the part with the given Merkle hash. The part - including
+// its preamble - is read from the given reader. The Merkle tree is read from the preamble and verified against the
+// hash before returning the reader.
+func NewMerkleReader(h *Hash, part io.ReadSeeker) (*MerkleReader, error) {
+	e := errors.Template("new merkle reader", errors.K.Invalid)
+	if h.IsNil() {
+		return nil, e("reason", "hash is nil")
+	}
+	tree, preambleSize, err := readMerklePreamble(part, h.Size)
+	if err != nil {
+		return nil, e(err)
+	}
+	if preambleSize != h.PreambleSize {
+		return nil, e("reason", "preamble size mismatch", "preamble_size", preambleSize, "hash", h)
+	}
+	return newMerkleReader(h, tree, part, preambleSize)
+}
+
+// NewMerkleDataReader creates a verifying reader for the data of the part with the given Merkle hash and Merkle tree.
+// The part data - excluding the preamble - is read from the given reader. The tree is verified against the hash before
+// returning the reader.
+func NewMerkleDataReader(h *Hash, tree *MerkleTree, data io.ReadSeeker) (*MerkleReader, error) {
+	return newMerkleReader(h, tree, data, 0)
+}
+
+func newMerkleReader(h *Hash, tree *MerkleTree, src io.ReadSeeker, base int64) (*MerkleReader, error) {
+	err := tree.Verify(h)
+	if err != nil {
+		return nil, errors.E("new merkle reader", errors.K.Invalid, err)
+	}
+	return &MerkleReader{
+		tree:   tree,
+		src:    src,
+		base:   base,
+		srcOff: -1,
+		idx:    -1,
+	}, nil
+}
+
+// Size returns the size of the part data.
+func (r *MerkleReader) Size() int64 {
+	return r.tree.Size
+}
+
+func (r *MerkleReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.off >= r.tree.Size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	idx := int(r.off / r.tree.ChunkSize)
+	if idx != r.idx {
+		err := r.loadChunk(idx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk[r.off-int64(idx)*r.tree.ChunkSize:])
+	r.off += int64(n)
+	return n, nil
+}
+
+// loadChunk reads the chunk with the given index from the underlying reader and verifies it.
+func (r *MerkleReader) loadChunk(idx int) error {
+	e := errors.Template("merkle read", errors.K.IO, "chunk", idx)
+
+	start := r.base + int64(idx)*r.tree.ChunkSize
+	if r.srcOff != start {
+		_, err := r.src.Seek(start, io.SeekStart)
+		if err != nil {
+			r.srcOff = -1
+			return e(err)
+		}
+	}
+
+	size := r.tree.chunkLen(idx)
+	if int64(cap(r.chunk)) < size {
+		r.chunk = make([]byte, size)
+	}
+	r.chunk = r.chunk[:size]
+	r.idx = -1
+	n, err := io.ReadFull(r.src, r.chunk)
+	r.srcOff = start + int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.err = e(errors.K.Invalid, err, "reason", "part data truncated")
+		return r.err
+	} else if err != nil {
+		r.srcOff = -1
+		return e(err)
+	}
+
+	err = r.tree.VerifyChunk(idx, r.chunk)
+	if err != nil {
+		r.err = e(errors.K.Invalid, err)
+		return r.err
+	}
+	r.idx = idx
+	return nil
+}
+
+func (r *MerkleReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.tree.Size
+	default:
+		return 0, errors.E("merkle seek", errors.K.Invalid, "reason", "invalid whence", "whence", whence)
+	}
+	if offset < 0 {
+		return 0, errors.E("merkle seek", errors.K.Invalid, "reason", "negative offset", "offset", offset)
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// Close closes the underlying reader if it implements io.Closer.
+func (r *MerkleReader) Close() error {
+	if c, ok := r.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
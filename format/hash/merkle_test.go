@@ -0,0 +1,245 @@
+package hash_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/util/httputil"
+	"github.com/eluv-io/errors-go"
+)
+
+// newMerklePart creates a Merkle part with the given data and returns the part bytes and its hash.
+func newMerklePart(t *testing.T, data []byte) ([]byte, *hash.Hash) {
+	tree, err := hash.CalcMerkleTree(hash.SHA256, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	preambleSize, err := tree.WritePreamble(buf)
+	require.NoError(t, err)
+	buf.Write(data)
+
+	h, err := tree.Hash(preambleSize)
+	require.NoError(t, err)
+	return buf.Bytes(), h
+}
+
+func TestMerkleTree(t *testing.T) {
+	leaf := func(chunk []byte) []byte {
+		sum := sha256.Sum256(append([]byte{0}, chunk...))
+		return sum[:]
+	}
+	node := func(left, right []byte) []byte {
+		sum := sha256.Sum256(append(append([]byte{1}, left...), right...))
+		return sum[:]
+	}
+
+	data := make([]byte, 2*hash.MerkleChunkSize+10)
+	rand.New(rand.NewSource(1)).Read(data)
+	l0 := leaf(data[:hash.MerkleChunkSize])
+	l1 := leaf(data[hash.MerkleChunkSize : 2*hash.MerkleChunkSize])
+	l2 := leaf(data[2*hash.MerkleChunkSize:])
+
+	tree, err := hash.CalcMerkleTree(hash.SHA256, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{l0, l1, l2}, tree.Leaves)
+	require.Equal(t, node(node(l0, l1), l2), tree.Root())
+	require.Equal(t, int64(len(data)), tree.Size)
+
+	decoded, err := hash.DecodeMerkleTree(tree.Encode())
+	require.NoError(t, err)
+	require.Equal(t, tree, decoded)
+
+	empty, err := hash.CalcMerkleTree(hash.SHA256, bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.Equal(t, leaf(nil), empty.Root())
+
+	for _, b := range [][]byte{
+		nil,
+		{2, 0},
+		{1, 99, 1, 1},
+		tree.Encode()[:20],
+		append(tree.Encode(), 0),
+		{1, 0, 1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x3f}, // huge leaf count
+	} {
+		_, err = hash.DecodeMerkleTree(b)
+		require.Error(t, err)
+	}
+}
+
+func TestMerkleHash(t *testing.T) {
+	data := make([]byte, 5*hash.MerkleChunkSize+1234)
+	rand.New(rand.NewSource(2)).Read(data)
+	part, h := newMerklePart(t, data)
+
+	require.Equal(t, hash.Type{Code: hash.QPart, Format: hash.Merkle}, h.Type)
+	require.Equal(t, "hqpm", h.String()[:4])
+	require.Equal(t, int64(len(part)), h.Size)
+	require.Contains(t, h.Describe(), "Merkle tree")
+
+	parsed, err := hash.FromString(h.String())
+	require.NoError(t, err)
+	require.True(t, h.Equal(parsed))
+
+	calculated, err := hash.CalcMerkleHash(bytes.NewReader(part))
+	require.NoError(t, err)
+	require.True(t, h.Equal(calculated))
+
+	// a regular part hash of the same part differs
+	regular, err := hash.CalcHash(bytes.NewReader(part))
+	require.NoError(t, err)
+	require.False(t, h.Equal(regular))
+
+	// corrupted data is detected
+	corrupted := bytes.Clone(part)
+	corrupted[len(corrupted)-1]++
+	_, err = hash.CalcMerkleHash(bytes.NewReader(corrupted))
+	require.Error(t, err)
+
+	// parts without merkle preamble are rejected
+	_, err = hash.CalcMerkleHash(bytes.NewReader(data))
+	require.Error(t, err)
+}
+
+func TestMerkleHashAlgorithms(t *testing.T) {
+	data := make([]byte, 3*hash.MerkleChunkSize+10)
+	rand.New(rand.NewSource(3)).Read(data)
+
+	for _, test := range []struct {
+		alg    hash.Algorithm
+		prefix string
+	}{
+		{hash.SHA256, "hqpm"},
+		{hash.BLAKE3, "hqmb"},
+		{hash.SHA512_256, "hqms"},
+	} {
+		t.Run(test.alg.String(), func(t *testing.T) {
+			tree, err := hash.CalcMerkleTree(test.alg, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			buf := &bytes.Buffer{}
+			preambleSize, err := tree.WritePreamble(buf)
+			require.NoError(t, err)
+			buf.Write(data)
+
+			h, err := tree.Hash(preambleSize)
+			require.NoError(t, err)
+			require.Equal(t, hash.Type{Code: hash.QPart, Format: hash.Merkle, Algorithm: test.alg}, h.Type)
+			require.Equal(t, test.prefix, h.String()[:4])
+
+			parsed, err := hash.FromString(h.String())
+			require.NoError(t, err)
+			require.True(t, h.Equal(parsed))
+			require.NoError(t, tree.Verify(parsed))
+
+			calculated, err := hash.CalcMerkleHash(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			require.True(t, h.Equal(calculated))
+		})
+	}
+}
+
+func TestMerkleReader(t *testing.T) {
+	data := make([]byte, 5*hash.MerkleChunkSize+1234)
+	rand.New(rand.NewSource(3)).Read(data)
+	part, h := newMerklePart(t, data)
+
+	t.Run("full read", func(t *testing.T) {
+		r, err := hash.NewMerkleReader(h, bytes.NewReader(part))
+		require.NoError(t, err)
+		require.Equal(t, int64(len(data)), r.Size())
+		read, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, data, read)
+	})
+
+	t.Run("ranges", func(t *testing.T) {
+		rnd := rand.New(rand.NewSource(4))
+		for i := 0; i < 50; i++ {
+			off := rnd.Int63n(int64(len(data)))
+			size := rnd.Int63n(int64(len(data))-off) + 1
+			r, err := hash.NewMerkleReader(h, bytes.NewReader(part))
+			require.NoError(t, err)
+			rr, cr, err := httputil.ToRangeReader(r, off, size, r.Size(), nil)
+			require.NoError(t, err)
+			read, err := io.ReadAll(io.LimitReader(rr, cr.GetAdaptedLen()))
+			require.NoError(t, err)
+			require.Equal(t, data[off:off+size], read)
+			require.NoError(t, rr.Close())
+		}
+	})
+
+	t.Run("data reader", func(t *testing.T) {
+		tree, err := hash.CalcMerkleTree(hash.SHA256, bytes.NewReader(data))
+		require.NoError(t, err)
+		r, err := hash.NewMerkleDataReader(h, tree, bytes.NewReader(data))
+		require.NoError(t, err)
+		off, err := r.Seek(-100, io.SeekEnd)
+		require.NoError(t, err)
+		read, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, data[off:], read)
+
+		_, err = r.Seek(-1, io.SeekStart)
+		require.Error(t, err)
+	})
+
+	t.Run("corrupted chunk", func(t *testing.T) {
+		corrupted := bytes.Clone(part)
+		corrupted[int(h.PreambleSize)+3*hash.MerkleChunkSize+7]++
+
+		r, err := hash.NewMerkleReader(h, bytes.NewReader(corrupted))
+		require.NoError(t, err)
+
+		// ranges before the corrupted chunk can be read
+		buf := make([]byte, 2*hash.MerkleChunkSize)
+		_, err = io.ReadFull(r, buf)
+		require.NoError(t, err)
+		require.Equal(t, data[:len(buf)], buf)
+
+		// reading the corrupted chunk fails without returning any of its data
+		_, err = r.Seek(3*hash.MerkleChunkSize-10, io.SeekStart)
+		require.NoError(t, err)
+		n, err := io.ReadFull(r, buf[:20])
+		require.Equal(t, 10, n)
+		require.Error(t, err)
+		require.True(t, errors.IsKind(errors.K.Invalid, err))
+
+		// and so do all subsequent reads
+		_, err = r.Seek(0, io.SeekStart)
+		require.NoError(t, err)
+		_, err = r.Read(buf)
+		require.Error(t, err)
+	})
+
+	t.Run("truncated part", func(t *testing.T) {
+		r, err := hash.NewMerkleReader(h, bytes.NewReader(part[:len(part)-1]))
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		require.Error(t, err)
+		require.True(t, errors.IsKind(errors.K.Invalid, err))
+	})
+
+	t.Run("corrupted tree", func(t *testing.T) {
+		corrupted := bytes.Clone(part)
+		corrupted[int(h.PreambleSize)-1]++
+		_, err := hash.NewMerkleReader(h, bytes.NewReader(corrupted))
+		require.Error(t, err)
+
+		other, otherHash := newMerklePart(t, data[1:])
+		_, err = hash.NewMerkleReader(h, bytes.NewReader(other))
+		require.Error(t, err)
+		_, err = hash.NewMerkleReader(otherHash, bytes.NewReader(part))
+		require.Error(t, err)
+
+		regular, err := hash.CalcHash(bytes.NewReader(part))
+		require.NoError(t, err)
+		_, err = hash.NewMerkleReader(regular, bytes.NewReader(part))
+		require.Error(t, err)
+	})
+}
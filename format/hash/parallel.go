@@ -0,0 +1,216 @@
+package hash
+
+import (
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/eluv-io/common-go/util/syncutil"
+	"github.com/eluv-io/errors-go"
+)
+
+const (
+	// pipelineBufferSize is the size of the buffers used by CalcHashPipelined()
+	pipelineBufferSize = 1024 * 1024
+	// pipelineBuffers is the number of buffers used by CalcHashPipelined()
+	pipelineBuffers = 4
+	// merkleSegmentChunks is the number of chunks hashed by a single task of CalcMerkleTreeParallel()
+	merkleSegmentChunks = 64
+)
+
+var (
+	defaultPool     syncutil.WorkerPool
+	defaultPoolOnce sync.Once
+
+	segmentBuffers = sync.Pool{
+		New: func() any {
+			buf := make([]byte, merkleSegmentChunks*MerkleChunkSize)
+			return &buf
+		},
+	}
+)
+
+// DefaultWorkerPool returns the worker pool used for parallel hash calculations if no pool is specified. The pool uses
+// up to runtime.NumCPU() workers.
+func DefaultWorkerPool() syncutil.WorkerPool {
+	defaultPoolOnce.Do(func() {
+		defaultPool = syncutil.NewWorkerPool(runtime.NumCPU(), time.Minute, 0)
+	})
+	return defaultPool
+}
+
+// CalcHashPipelined calculates the same SHA256 part hash as CalcHash() - see CalcHashPipelinedWith().
+func CalcHashPipelined(reader io.ReadSeeker, size ...int64) (*Hash, error) {
+	return CalcHashPipelinedWith(SHA256, reader, size...)
+}
+
+// CalcHashPipelinedWith calculates the same part hash as CalcHashWith(), but overlaps reading from the given reader
+// with the digest calculation: the data is read on a separate goroutine into a small set of rotating buffers, while the
+// calling goroutine hashes the buffers that have been filled previously.
+func CalcHashPipelinedWith(alg Algorithm, reader io.ReadSeeker, size ...int64) (*Hash, error) {
+	digest, preambleSize, err := newPartDigest(alg, reader, size...)
+	if err != nil {
+		return nil, err
+	}
+
+	type block struct {
+		buf []byte
+		n   int
+		err error
+	}
+
+	free := make(chan []byte, pipelineBuffers)
+	for i := 0; i < pipelineBuffers; i++ {
+		free <- make([]byte, pipelineBufferSize)
+	}
+	full := make(chan block, pipelineBuffers)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(full)
+		for {
+			var buf []byte
+			select {
+			case buf = <-free:
+			case <-done:
+				return
+			}
+			n, err := io.ReadFull(reader, buf)
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			select {
+			case full <- block{buf: buf, n: n, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for blk := range full {
+		if blk.n > 0 {
+			_, err = digest.Write(blk.buf[:blk.n])
+			if err != nil {
+				return nil, err
+			}
+		}
+		if blk.err == io.EOF {
+			break
+		} else if blk.err != nil {
+			return nil, blk.err
+		}
+		free <- blk.buf
+	}
+
+	if preambleSize > 0 {
+		digest = digest.WithPreamble(preambleSize)
+	}
+
+	return digest.AsHash(), nil
+}
+
+// CalcMerkleTreeParallel calculates the same Merkle tree as CalcMerkleTree() over the given number of bytes read from
+// the given reader. The data is split into segments of multiple chunks that are read and hashed concurrently on the
+// given worker pool, or the DefaultWorkerPool() if nil.
+func CalcMerkleTreeParallel(
+	alg Algorithm,
+	reader io.ReaderAt,
+	size int64,
+	pool syncutil.WorkerPool,
+) (*MerkleTree, error) {
+	e := errors.Template("calc merkle tree", errors.K.Invalid)
+	if !alg.IsValid() {
+		return nil, e("reason", "unknown algorithm", "algorithm", alg)
+	} else if size < 0 {
+		return nil, e("reason", "invalid size", "size", size)
+	}
+	if pool == nil {
+		pool = DefaultWorkerPool()
+	}
+
+	tree := &MerkleTree{
+		Algorithm: alg,
+		ChunkSize: MerkleChunkSize,
+		Size:      size,
+	}
+	tree.Leaves = make([][]byte, tree.leafCount())
+
+	queue := pool.NewTaskQueue()
+	defer queue.Close()
+
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	var once sync.Once
+	var err error
+
+	for first := 0; first < len(tree.Leaves) && !failed.Load(); first += merkleSegmentChunks {
+		last := min(first+merkleSegmentChunks, len(tree.Leaves))
+		wg.Add(1)
+		queue.Submit(func() {
+			defer wg.Done()
+			if failed.Load() {
+				return
+			}
+			segErr := tree.hashSegment(reader, first, last)
+			if segErr != nil {
+				once.Do(func() { err = segErr })
+				failed.Store(true)
+			}
+		})
+	}
+	wg.Wait()
+
+	if err != nil {
+		return nil, e(err)
+	}
+	return tree, nil
+}
+
+// hashSegment reads the chunks with indices [first, last) from the given reader and calculates their leaf digests.
+func (t *MerkleTree) hashSegment(reader io.ReaderAt, first, last int) error {
+	bufp := segmentBuffers.Get().(*[]byte)
+	defer segmentBuffers.Put(bufp)
+
+	off := int64(first) * t.ChunkSize
+	buf := (*bufp)[:min(int64(last-first)*t.ChunkSize, t.Size-off)]
+	n, err := reader.ReadAt(buf, off)
+	if n < len(buf) {
+		if err == nil || err == io.EOF {
+			return errors.E("hash segment", errors.K.Invalid, err, "reason", "data truncated", "offset", off+int64(n))
+		}
+		return errors.E("hash segment", errors.K.IO, err, "offset", off)
+	}
+
+	h := t.Algorithm.New()
+	for i := first; i < last; i++ {
+		chunk := buf[:t.chunkLen(i)]
+		t.Leaves[i] = merkleLeaf(h, chunk)
+		buf = buf[len(chunk):]
+	}
+	return nil
+}
+
+// CalcMerkleHashParallel calculates the same hash as CalcMerkleHash() for the part of the given size read from the
+// given reader, but hashes the part data concurrently on the given worker pool - see CalcMerkleTreeParallel().
+func CalcMerkleHashParallel(reader io.ReaderAt, size int64, pool syncutil.WorkerPool) (*Hash, error) {
+	e := errors.Template("calc merkle hash", errors.K.Invalid)
+
+	tree, preambleSize, err := readMerklePreamble(io.NewSectionReader(reader, 0, size), size)
+	if err != nil {
+		return nil, e(err)
+	}
+	dataSize := size - preambleSize
+	calculated, err := CalcMerkleTreeParallel(tree.Algorithm, io.NewSectionReader(reader, preambleSize, dataSize),
+		dataSize, pool)
+	if err != nil {
+		return nil, e(err)
+	}
+	return tree.verifyCalculated(calculated, preambleSize)
+}
@@ -0,0 +1,219 @@
+package hash_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/preamble"
+	"github.com/eluv-io/common-go/util/syncutil"
+)
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func TestCalcHashPipelined(t *testing.T) {
+	withPreamble := &bytes.Buffer{}
+	_, err := preamble.Write(withPreamble, []byte(`{"some":"preamble"}`), "json")
+	require.NoError(t, err)
+	withPreamble.Write(randomData(3*1024*1024 + 17))
+
+	for _, data := range [][]byte{
+		nil,
+		randomData(1),
+		randomData(1024 * 1024),
+		randomData(5*1024*1024 + 3),
+		withPreamble.Bytes(),
+	} {
+		for _, alg := range []hash.Algorithm{hash.SHA256, hash.BLAKE3} {
+			t.Run(fmt.Sprint(len(data), alg), func(t *testing.T) {
+				expected, err := hash.CalcHashWith(alg, bytes.NewReader(data))
+				require.NoError(t, err)
+				actual, err := hash.CalcHashPipelinedWith(alg, bytes.NewReader(data))
+				require.NoError(t, err)
+				require.Equal(t, expected, actual)
+			})
+		}
+	}
+
+	expected, err := hash.CalcHash(bytes.NewReader(withPreamble.Bytes()))
+	require.NoError(t, err)
+	require.NotZero(t, expected.PreambleSize)
+	actual, err := hash.CalcHashPipelined(bytes.NewReader(withPreamble.Bytes()), int64(withPreamble.Len()))
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	// read errors are returned
+	_, err = hash.CalcHashPipelined(failingReadSeeker{bytes.NewReader(randomData(3 * 1024 * 1024))})
+	require.Error(t, err)
+}
+
+func TestCalcMerkleParallel(t *testing.T) {
+	pool := syncutil.NewWorkerPool(4, time.Second, 0)
+	for _, size := range []int{
+		0,
+		1,
+		hash.MerkleChunkSize,
+		64 * hash.MerkleChunkSize,
+		64*hash.MerkleChunkSize + 1,
+		200*hash.MerkleChunkSize + 12345,
+	} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			data := randomData(size)
+			expected, err := hash.CalcMerkleTree(hash.SHA256, bytes.NewReader(data))
+			require.NoError(t, err)
+			actual, err := hash.CalcMerkleTreeParallel(hash.SHA256, bytes.NewReader(data), int64(size), pool)
+			require.NoError(t, err)
+			require.Equal(t, expected, actual)
+
+			part, h := newMerklePart(t, data)
+			expectedHash, err := hash.CalcMerkleHash(bytes.NewReader(part))
+			require.NoError(t, err)
+			require.Equal(t, h, expectedHash)
+			actualHash, err := hash.CalcMerkleHashParallel(bytes.NewReader(part), int64(len(part)), nil)
+			require.NoError(t, err)
+			require.Equal(t, expectedHash, actualHash)
+		})
+	}
+
+	data := randomData(100 * hash.MerkleChunkSize)
+	_, err := hash.CalcMerkleTreeParallel(hash.SHA256, bytes.NewReader(data), int64(len(data))+1, pool)
+	require.Error(t, err)
+	_, err = hash.CalcMerkleTreeParallel(hash.Algorithm(99), bytes.NewReader(data), int64(len(data)), pool)
+	require.Error(t, err)
+
+	part, _ := newMerklePart(t, data)
+	part[len(part)-1]++
+	_, err = hash.CalcMerkleHashParallel(bytes.NewReader(part), int64(len(part)), pool)
+	require.Error(t, err)
+}
+
+type failingReadSeeker struct {
+	*bytes.Reader
+}
+
+func (f failingReadSeeker) Read(p []byte) (int, error) {
+	if f.Reader.Len() < 1024*1024 {
+		return 0, io.ErrClosedPipe
+	}
+	return f.Reader.Read(p)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+const benchmarkSize = 64 * 1024 * 1024
+
+// slowReader simulates storage latency by delaying every read of the wrapped reader.
+type slowReader struct {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(200 * time.Microsecond)
+	return s.ReadSeeker.Read(p)
+}
+
+func (s slowReader) ReadAt(p []byte, off int64) (int, error) {
+	time.Sleep(200 * time.Microsecond)
+	return s.ReaderAt.ReadAt(p, off)
+}
+
+type readSeekerAt interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+func newBenchmarkReader(data []byte, slow bool) readSeekerAt {
+	r := bytes.NewReader(data)
+	if slow {
+		return slowReader{ReadSeeker: r, ReaderAt: r}
+	}
+	return r
+}
+
+func benchmarkCalc(b *testing.B, slow bool, calc func(r readSeekerAt, size int64) (*hash.Hash, error)) {
+	data := randomData(benchmarkSize)
+	b.SetBytes(benchmarkSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := calc(newBenchmarkReader(data, slow), benchmarkSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkCalcMerkle(b *testing.B, slow bool, parallel bool) {
+	data := randomData(benchmarkSize)
+	tree, err := hash.CalcMerkleTree(hash.SHA256, bytes.NewReader(data))
+	require.NoError(b, err)
+	buf := &bytes.Buffer{}
+	_, err = tree.WritePreamble(buf)
+	require.NoError(b, err)
+	buf.Write(data)
+	part := buf.Bytes()
+
+	b.SetBytes(benchmarkSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := newBenchmarkReader(part, slow)
+		if parallel {
+			_, err = hash.CalcMerkleHashParallel(r, int64(len(part)), nil)
+		} else {
+			_, err = hash.CalcMerkleHash(r)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCalcHash(b *testing.B) {
+	benchmarkCalc(b, false, func(r readSeekerAt, size int64) (*hash.Hash, error) {
+		return hash.CalcHash(r, size)
+	})
+}
+
+func BenchmarkCalcHashPipelined(b *testing.B) {
+	benchmarkCalc(b, false, func(r readSeekerAt, size int64) (*hash.Hash, error) {
+		return hash.CalcHashPipelined(r, size)
+	})
+}
+
+func BenchmarkCalcHashSlowReader(b *testing.B) {
+	benchmarkCalc(b, true, func(r readSeekerAt, size int64) (*hash.Hash, error) {
+		return hash.CalcHash(r, size)
+	})
+}
+
+func BenchmarkCalcHashPipelinedSlowReader(b *testing.B) {
+	benchmarkCalc(b, true, func(r readSeekerAt, size int64) (*hash.Hash, error) {
+		return hash.CalcHashPipelined(r, size)
+	})
+}
+
+func BenchmarkCalcMerkleHash(b *testing.B) {
+	benchmarkCalcMerkle(b, false, false)
+}
+
+func BenchmarkCalcMerkleHashParallel(b *testing.B) {
+	benchmarkCalcMerkle(b, false, true)
+}
+
+func BenchmarkCalcMerkleHashSlowReader(b *testing.B) {
+	benchmarkCalcMerkle(b, true, false)
+}
+
+func BenchmarkCalcMerkleHashParallelSlowReader(b *testing.B) {
+	benchmarkCalcMerkle(b, true, true)
+}
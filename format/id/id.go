@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/mr-tron/base58/base58"
@@ -11,6 +12,8 @@ import (
 
 	"github.com/eluv-io/errors-go"
 	"github.com/eluv-io/log-go"
+
+	"github.com/eluv-io/common-go/format/checksum"
 )
 
 // Code is the type of an ID
@@ -40,6 +43,80 @@ func (c Code) MustParse(s string) ID {
 	return res
 }
 
+// Describe returns the name of the ID type.
+func (c Code) Describe() string {
+	return codeToName[c]
+}
+
+// Info returns the metadata of the ID type.
+func (c Code) Info() *CodeInfo {
+	return &CodeInfo{
+		Code:       c,
+		Name:       codeToName[c],
+		Prefix:     codeToPrefix[c],
+		Generators: codeToGenerators[c],
+	}
+}
+
+// Generator describes how the payload of an ID is generated.
+type Generator struct {
+	Name string // the name of the generator
+	Len  int    // the length of the generated payload in bytes
+}
+
+// G defines the ID generators.
+var G = struct {
+	Random   *Generator // random UUID
+	Sortable *Generator // time-sortable UUID - see SortableBytes
+	Address  *Generator // ethereum address, i.e. the last 20 bytes of the keccak256 digest of a public key
+	Ed25519  *Generator // ed25519 public key
+}{
+	Random:   &Generator{Name: "random", Len: 16},
+	Sortable: &Generator{Name: "sortable", Len: SortableLen},
+	Address:  &Generator{Name: "address", Len: 20},
+	Ed25519:  &Generator{Name: "ed25519", Len: 32},
+}
+
+// CodeInfo is the metadata of an ID type. It is enforced by ID.Validate(), but not by ID.IsValid() or when parsing IDs.
+//
+// Only IDs carry code metadata: the length of keys.Key is defined by keys.Code.KeyLen() and the ID of drm.KeyID has a
+// fixed length, while the bytes of token.Token are chosen by the creator of the token and have no fixed length.
+type CodeInfo struct {
+	Code       Code
+	Name       string
+	Prefix     string
+	Generators []*Generator // the allowed generators, the preferred first - any payload is allowed if empty
+}
+
+// PayloadLens returns the allowed payload lengths of IDs of this type, or nil if any length is allowed.
+func (i *CodeInfo) PayloadLens() []int {
+	var res []int
+	for _, g := range i.Generators {
+		if !slices.Contains(res, g.Len) {
+			res = append(res, g.Len)
+		}
+	}
+	return res
+}
+
+// Allows returns true if IDs of this type may be created with the given generator.
+func (i *CodeInfo) Allows(g *Generator) bool {
+	return len(i.Generators) == 0 || slices.Contains(i.Generators, g)
+}
+
+// ValidatePayload validates the given payload of an ID of this type.
+func (i *CodeInfo) ValidatePayload(payload []byte) error {
+	lens := i.PayloadLens()
+	if len(lens) > 0 && !slices.Contains(lens, len(payload)) {
+		return errors.NoTrace("validate ID payload", errors.K.Invalid,
+			"reason", "invalid payload length",
+			"type", i.Name,
+			"expected", lens,
+			"actual", len(payload))
+	}
+	return nil
+}
+
 // lint disable
 const (
 	UNKNOWN Code = iota
@@ -106,6 +183,25 @@ var codeToName = map[Code]string{
 	Ed25519:         "ed25519 public key",
 	Allocation:      "allocation",
 }
+var codeToGenerators = map[Code][]*Generator{
+	Account:         {G.Random, G.Address},
+	User:            {G.Random, G.Address},
+	QLib:            {G.Random, G.Address},
+	Q:               {G.Random, G.Address},
+	QStateStore:     {G.Random},
+	QSpace:          {G.Random, G.Address},
+	QFileUpload:     {G.Random, G.Sortable},
+	QFilesJob:       {G.Random, G.Sortable},
+	QNode:           {G.Random, G.Address},
+	Network:         {G.Random, G.Address},
+	KMS:             {G.Random, G.Address},
+	CachedResultSet: {G.Random, G.Address},
+	Tenant:          {G.Random, G.Address},
+	Group:           {G.Random, G.Address},
+	Key:             {G.Random, G.Address},
+	Ed25519:         {G.Random, G.Ed25519},
+	Allocation:      {G.Random, G.Sortable},
+}
 
 func init() {
 	for prefix, code := range prefixToCode {
@@ -130,6 +226,12 @@ func (id ID) String() string {
 	return id.prefix() + base58.Encode(id[codeLen:])
 }
 
+// ChecksumString returns the checksummed string representation of this ID - see package checksum. Parse accepts both
+// the plain and the checksummed form.
+func (id ID) ChecksumString() string {
+	return checksum.Append(id.String())
+}
+
 // AssertCode checks whether the ID's code equals the provided code
 func (id ID) AssertCode(c Code) error {
 	if id == nil || id.Code() != c {
@@ -160,6 +262,20 @@ func (id ID) MarshalText() ([]byte, error) {
 	return []byte(id.String()), nil
 }
 
+// Describe returns a textual description of this ID.
+func (id ID) Describe() string {
+	sb := strings.Builder{}
+
+	add := func(s string) {
+		sb.WriteString(s)
+		sb.WriteString("\n")
+	}
+
+	add("type:  " + id.Code().Describe())
+	add("bytes: 0x" + hex.EncodeToString(id.Bytes()))
+	return sb.String()
+}
+
 func (id ID) Bytes() []byte {
 	if id.IsNil() {
 		return nil
@@ -192,6 +308,21 @@ func (id ID) IsNil() bool {
 	return len(id) == 0
 }
 
+// Validate checks that this ID has a payload that conforms to the metadata of its type - see Code.Info().
+func (id ID) Validate() error {
+	e := errors.TemplateNoTrace("validate ID", errors.K.Invalid)
+	if len(id) <= codeLen {
+		return e("reason", "ID empty")
+	}
+	err := id.Code().Info().ValidatePayload(id.Bytes())
+	if err != nil {
+		return e(err)
+	}
+	return nil
+}
+
+// IsValid returns true if this ID is not empty. Unlike Validate(), it does not check the payload against the metadata
+// of the ID type, so that IDs with payloads of non-standard length remain usable.
 func (id ID) IsValid() bool {
 	return len(id) > codeLen
 }
@@ -214,7 +345,8 @@ func (id ID) Equivalent(other ID) bool {
 	return bytes.Equal(id.Bytes(), other.Bytes())
 }
 
-// Generate creates a random ID for the given ID type.
+// Generate creates a new ID with a random UUID for the given ID type. Use GenerateSortable to create a time-sortable
+// ID.
 func Generate(code Code) ID {
 	return ID(append([]byte{byte(code)}, uuid.NewV4().Bytes()...))
 }
@@ -256,9 +388,13 @@ func MustParse(s string) ID {
 	return res
 }
 
-// Parse parses an ID from the given string representation.
+// Parse parses an ID from the given string representation, which may be in checksummed form - see ChecksumString().
 func Parse(s string) (ID, error) {
 	e := errors.TemplateNoTrace("parse ID", errors.K.Invalid, "string", s)
+	s, err := checksum.Strip(s)
+	if err != nil {
+		return nil, e(err)
+	}
 	if len(s) <= prefixLen {
 		if len(s) == 0 {
 			return nil, e("reason", "empty string")
@@ -155,3 +155,82 @@ func TestEquivalent(t *testing.T) {
 	require.False(t, id2.Equivalent(id3))
 	require.False(t, id3.Equivalent(id2))
 }
+
+func TestDescribe(t *testing.T) {
+	id := NewID(Q, []byte{1, 2, 3, 4})
+	require.Equal(t, "content", Q.Describe())
+	require.Equal(t, "type:  content\nbytes: 0x01020304\n", id.Describe())
+}
+
+func TestChecksumString(t *testing.T) {
+	for code := range codeToPrefix {
+		generated := Generate(code)
+		require.NoError(t, generated.Validate(), code.Describe())
+
+		cs := generated.ChecksumString()
+		require.True(t, strings.HasPrefix(cs, generated.String()+"."))
+
+		parsed, err := FromString(cs)
+		require.NoError(t, err)
+		require.Equal(t, generated, parsed)
+		require.True(t, parsed.Is(cs))
+	}
+
+	cs := NewID(Q, []byte{1, 2, 3, 4}).ChecksumString()
+	_, err := Q.FromString(cs)
+	require.NoError(t, err)
+	_, err = Q.FromString(strings.Replace(cs, "iq__", "ilib", 1)) // wrong prefix
+	require.Error(t, err)
+	_, err = Q.FromString(cs[:4] + "3" + cs[5:]) // typo
+	require.Error(t, err)
+}
+
+func TestCodeInfo(t *testing.T) {
+	for code := range codeToPrefix {
+		info := code.Info()
+		require.Equal(t, code, info.Code)
+		require.Equal(t, code.Describe(), info.Name)
+		require.Equal(t, codeToPrefix[code], info.Prefix)
+	}
+
+	info := Q.Info()
+	require.Equal(t, []int{16, 20}, info.PayloadLens())
+	require.True(t, info.Allows(G.Random))
+	require.False(t, info.Allows(G.Ed25519))
+	require.NoError(t, info.ValidatePayload(make([]byte, 20)))
+	require.Error(t, info.ValidatePayload(make([]byte, 32)))
+
+	require.Equal(t, []int{16, 32}, Ed25519.Info().PayloadLens())
+	require.Len(t, Generate(Ed25519).Bytes(), 16)
+	require.Len(t, Generate(Key).Bytes(), 16)
+	require.Nil(t, UNKNOWN.Info().PayloadLens())
+
+	tests := []struct {
+		id    ID
+		valid bool
+	}{
+		{nil, false},
+		{ID{byte(Q)}, false},
+		{ID{byte(Q), 1}, false},
+		{NewID(Q, make([]byte, 16)), true},
+		{NewID(Q, make([]byte, 20)), true},
+		{NewID(Q, make([]byte, 4)), false},
+		{NewID(QStateStore, make([]byte, 20)), false},
+		{NewID(Ed25519, make([]byte, 32)), true},
+		{NewID(Ed25519, make([]byte, 20)), false},
+		{NewID(Key, make([]byte, 16)), true},
+		{NewID(Key, make([]byte, 20)), true},
+		{NewID(Key, make([]byte, 32)), false},
+		{NewID(UNKNOWN, make([]byte, 7)), true},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.id.Code(), len(test.id)), func(t *testing.T) {
+			require.Equal(t, test.valid, test.id.Validate() == nil)
+			// IsValid only requires a payload
+			require.Equal(t, len(test.id) > 1, test.id.IsValid())
+		})
+	}
+
+	// As only requires a payload
+	require.Equal(t, ID{byte(QLib), 1, 2}, NewID(Q, []byte{1, 2}).As(QLib))
+}
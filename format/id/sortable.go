@@ -0,0 +1,78 @@
+package id
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// SortableLen is the length of sortable ID payloads - see SortableBytes.
+const SortableLen = 17
+
+// sortableVersion is the first byte of sortable payloads. Together with the payload length, it distinguishes sortable
+// payloads from random 16-byte UUIDs, which may happen to carry the version and variant bits of a version 7 UUID.
+const sortableVersion = 0x01
+
+var sortable = struct {
+	mutex sync.Mutex
+	ms    int64  // the timestamp of the last generated payload
+	seq   uint16 // the sequence number of the last generated payload within ms
+}{}
+
+// SortableBytes returns a new time-sortable payload for the given timestamp. The payload consists of a version byte
+// followed by a version 7 UUID (RFC 9562): a 48-bit big-endian unix timestamp in milliseconds, followed by a 12-bit
+// sequence number and 62 random bits (separated by version and variant bits). Payloads generated in the same
+// millisecond by the same process are ordered by their sequence number, so that payloads sort in the order of their
+// creation when compared bytewise - and so do their base58 encodings if they have the same prefix.
+func SortableBytes(ts time.Time) []byte {
+	ms := ts.UnixMilli()
+
+	sortable.mutex.Lock()
+	if ms <= sortable.ms && sortable.seq < 0xfff {
+		// same millisecond (or clock moved backwards): keep the last timestamp and increment the sequence number
+		ms = sortable.ms
+		sortable.seq++
+	} else {
+		if ms <= sortable.ms {
+			// sequence exhausted: advance to the next millisecond
+			ms = sortable.ms + 1
+		}
+		sortable.ms = ms
+		sortable.seq = 0
+	}
+	seq := sortable.seq
+	sortable.mutex.Unlock()
+
+	b := make([]byte, SortableLen)
+	b[0] = sortableVersion
+	u := b[1:]
+	_, _ = rand.Read(u[8:])
+	binary.BigEndian.PutUint64(u, uint64(ms)<<16)
+	binary.BigEndian.PutUint16(u[6:], 0x7000|seq)
+	u[8] = u[8]&0x3f | 0x80
+	return b
+}
+
+// SortableTime returns the creation time embedded in the given payload and true if it is a time-sortable payload as
+// created by SortableBytes, the zero time and false otherwise.
+func SortableTime(b []byte) (time.Time, bool) {
+	if len(b) != SortableLen || b[0] != sortableVersion {
+		return time.Time{}, false
+	}
+	u := b[1:]
+	if u[6]>>4 != 7 || u[8]>>6 != 2 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(u) >> 16)), true
+}
+
+// GenerateSortable creates a time-sortable ID for the given ID type - see SortableBytes.
+func GenerateSortable(code Code) ID {
+	return NewID(code, SortableBytes(time.Now()))
+}
+
+// Time returns the creation time of this ID and true if it is a time-sortable ID, the zero time and false otherwise.
+func (id ID) Time() (time.Time, bool) {
+	return SortableTime(id.Bytes())
+}
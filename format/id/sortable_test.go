@@ -0,0 +1,66 @@
+package id
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortable(t *testing.T) {
+	ts := time.UnixMilli(time.Now().UnixMilli())
+	sortable.mutex.Lock()
+	sortable.ms = 0 // reset the state of previous runs
+	sortable.mutex.Unlock()
+
+	var ids []ID
+	for i := 0; i < 10000; i++ {
+		ids = append(ids, NewID(QFileUpload, SortableBytes(ts)))
+	}
+	later := NewID(QFileUpload, SortableBytes(ts.Add(time.Second)))
+	ids = append(ids, later)
+
+	require.True(t, sort.SliceIsSorted(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i], ids[j]) < 0
+	}))
+	require.True(t, sort.SliceIsSorted(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	}))
+
+	// more than 4096 IDs within a millisecond advance the timestamp
+	created, ok := ids[0].Time()
+	require.True(t, ok)
+	require.Equal(t, ts, created)
+	created, ok = ids[len(ids)-2].Time()
+	require.True(t, ok)
+	require.Equal(t, ts.Add(2*time.Millisecond), created)
+	created, ok = later.Time()
+	require.True(t, ok)
+	require.Equal(t, ts.Add(time.Second), created)
+
+	// sortable IDs are regular IDs
+	parsed, err := Parse(later.String())
+	require.NoError(t, err)
+	require.Equal(t, later, parsed)
+	require.True(t, later.IsValid())
+	require.True(t, later.Equivalent(later.As(Q)))
+
+	// Generate creates random IDs, GenerateSortable sortable IDs
+	_, ok = Generate(QFileUpload).Time()
+	require.False(t, ok)
+	_, ok = GenerateSortable(QFileUpload).Time()
+	require.True(t, ok)
+	_, ok = NewID(Q, make([]byte, 20)).Time()
+	require.False(t, ok)
+
+	// random UUIDs with the version and variant bits of a version 7 UUID are not sortable
+	uuid := later.Bytes()[1:]
+	_, ok = NewID(Q, uuid).Time()
+	require.False(t, ok)
+	_, ok = NewID(Q, append([]byte{0x02}, uuid...)).Time()
+	require.False(t, ok)
+	_, ok = ID(nil).Time()
+	require.False(t, ok)
+}
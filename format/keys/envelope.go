@@ -0,0 +1,320 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/mr-tron/base58/base58"
+
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// EnvelopePrefix is the prefix of the text representation of an Envelope.
+const EnvelopePrefix = "kenv"
+
+const (
+	envelopeVersion = 1
+	kekLen          = 32 // AES-256
+	saltLen         = 16
+
+	// DefaultIterations is the default number of PBKDF2 iterations used to derive a KEK from a password.
+	DefaultIterations = 600_000
+	// MaxIterations is the maximum number of PBKDF2 iterations accepted when opening an envelope.
+	MaxIterations = 10_000_000
+)
+
+// EnvelopeAlgorithm is the algorithm used to seal a key in an Envelope.
+type EnvelopeAlgorithm string
+
+// EA defines the envelope algorithms.
+var EA = struct {
+	AESGCM       EnvelopeAlgorithm // AES-256-GCM with a raw KEK
+	PBKDF2AESGCM EnvelopeAlgorithm // AES-256-GCM with a KEK derived from a password with PBKDF2-SHA256
+}{
+	AESGCM:       "A256GCM",
+	PBKDF2AESGCM: "PBKDF2-SHA256-A256GCM",
+}
+
+// KEK is a key encryption key used to seal keys in envelopes and to open them. A KEK is either a raw 32-byte AES-256
+// key or derived from a password.
+type KEK struct {
+	kid        string
+	key        []byte
+	password   []byte
+	iterations int
+}
+
+// RawKEK creates a KEK from the given raw 32-byte AES-256 key. The optional key ID is recorded in sealed envelopes.
+func RawKEK(kid string, key []byte) (*KEK, error) {
+	if len(key) != kekLen {
+		return nil, errors.E("RawKEK", errors.K.Invalid,
+			"reason", "invalid key length",
+			"expected", kekLen,
+			"actual", len(key))
+	}
+	return &KEK{kid: kid, key: key}, nil
+}
+
+// PasswordKEK creates a KEK that is derived from the given password with PBKDF2-SHA256 and a random salt for each sealed
+// envelope. The optional key ID is recorded in sealed envelopes.
+func PasswordKEK(kid string, password string) *KEK {
+	return &KEK{kid: kid, password: []byte(password), iterations: DefaultIterations}
+}
+
+// WithIterations sets the number of PBKDF2 iterations used for sealing with a password-derived KEK.
+func (k *KEK) WithIterations(iterations int) *KEK {
+	k.iterations = iterations
+	return k
+}
+
+// KID returns the key ID of this KEK.
+func (k *KEK) KID() string {
+	return k.kid
+}
+
+func (k *KEK) algorithm() EnvelopeAlgorithm {
+	if k.password != nil {
+		return EA.PBKDF2AESGCM
+	}
+	return EA.AESGCM
+}
+
+// aead returns the AEAD cipher for the given envelope algorithm and PBKDF2 parameters.
+func (k *KEK) aead(alg EnvelopeAlgorithm, salt []byte, iterations int) (cipher.AEAD, error) {
+	e := errors.Template("kek.aead", errors.K.Invalid)
+	if alg != k.algorithm() {
+		return nil, e("reason", "algorithm mismatch", "expected", alg, "actual", k.algorithm())
+	}
+
+	key := k.key
+	if alg == EA.PBKDF2AESGCM {
+		if iterations <= 0 || iterations > MaxIterations {
+			return nil, e("reason", "invalid iterations", "iterations", iterations)
+		}
+		var err error
+		key, err = pbkdf2.Key(sha256.New, string(k.password), salt, iterations, kekLen)
+		if err != nil {
+			return nil, e(err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, e(err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Envelope is a Key sealed under a key encryption key (KEK) with AES-256-GCM. It records the algorithm, the ID of the
+// KEK and its creation time, which are all authenticated along with the sealed key. Envelopes are meant for storing key
+// material in config files, caches, etc. and are serialized as text with the EnvelopePrefix followed by the base58
+// encoding of their CBOR representation:
+//
+//	kenv7GJz8hXm...
+type Envelope struct {
+	Algorithm  EnvelopeAlgorithm // the algorithm used to seal the key
+	KID        string            // the ID of the KEK, optional
+	Created    utc.UTC           // the creation time of the envelope
+	Salt       []byte            // the PBKDF2 salt of password-derived KEKs
+	Iterations int               // the PBKDF2 iterations of password-derived KEKs
+	Nonce      []byte            // the AES-GCM nonce
+	Ciphertext []byte            // the sealed key
+}
+
+// envelopeHeader is the part of an envelope that is authenticated as additional data.
+type envelopeHeader struct {
+	_          struct{} `cbor:",toarray"` // encode struct as array
+	Version    uint8
+	Algorithm  EnvelopeAlgorithm
+	KID        string
+	Created    int64 // unix time in milliseconds
+	Salt       []byte
+	Iterations int
+}
+
+// encodedEnvelope is the CBOR representation of an envelope.
+type encodedEnvelope struct {
+	_          struct{} `cbor:",toarray"` // encode struct as array
+	Version    uint8
+	Algorithm  EnvelopeAlgorithm
+	KID        string
+	Created    int64 // unix time in milliseconds
+	Salt       []byte
+	Iterations int
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// Seal seals the given key in a new envelope under the given KEK.
+func Seal(key Key, kek *KEK) (*Envelope, error) {
+	e := errors.Template("Seal", errors.K.Invalid)
+	if kek == nil {
+		return nil, e("reason", "no kek")
+	}
+	if err := key.Validate(); err != nil {
+		return nil, e(err)
+	}
+
+	env := &Envelope{
+		Algorithm: kek.algorithm(),
+		KID:       kek.kid,
+		Created:   utc.UnixMilli(utc.Now().UnixMilli()),
+	}
+	if env.Algorithm == EA.PBKDF2AESGCM {
+		env.Salt = make([]byte, saltLen)
+		_, _ = rand.Read(env.Salt)
+		env.Iterations = kek.iterations
+	}
+
+	aead, err := kek.aead(env.Algorithm, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, e(err)
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	_, _ = rand.Read(env.Nonce)
+	aad, err := env.header()
+	if err != nil {
+		return nil, e(err)
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, key, aad)
+	return env, nil
+}
+
+// Open opens this envelope with the given KEK and returns the sealed key. Fails if the KEK does not match the KEK that
+// was used to seal the envelope or if the envelope was tampered with.
+func (env *Envelope) Open(kek *KEK) (Key, error) {
+	e := errors.Template("Envelope.Open", errors.K.Invalid)
+	if env == nil {
+		return nil, e("reason", "no envelope")
+	}
+	if kek == nil {
+		return nil, e("reason", "no kek")
+	}
+	if kek.kid != "" && env.KID != "" && kek.kid != env.KID {
+		return nil, e("reason", "kid mismatch", "expected", env.KID, "actual", kek.kid)
+	}
+
+	aead, err := kek.aead(env.Algorithm, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, e(err)
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, e("reason", "invalid nonce")
+	}
+	aad, err := env.header()
+	if err != nil {
+		return nil, e(err)
+	}
+	plain, err := aead.Open(nil, env.Nonce, env.Ciphertext, aad)
+	if err != nil {
+		return nil, e(err, "reason", "authentication failed")
+	}
+
+	key := Key(plain)
+	if err = key.Validate(); err != nil {
+		return nil, e(err)
+	}
+	return key, nil
+}
+
+func (env *Envelope) header() ([]byte, error) {
+	return cbor.Marshal(&envelopeHeader{
+		Version:    envelopeVersion,
+		Algorithm:  env.Algorithm,
+		KID:        env.KID,
+		Created:    env.Created.UnixMilli(),
+		Salt:       env.Salt,
+		Iterations: env.Iterations,
+	})
+}
+
+// String returns the text representation of this envelope.
+func (env *Envelope) String() string {
+	if env == nil {
+		return ""
+	}
+	bts, err := env.MarshalCBOR()
+	if err != nil {
+		return ""
+	}
+	return EnvelopePrefix + base58.Encode(bts)
+}
+
+// ParseEnvelope parses an envelope from the given text representation.
+func ParseEnvelope(s string) (*Envelope, error) {
+	e := errors.Template("ParseEnvelope", errors.K.Invalid)
+	if !strings.HasPrefix(s, EnvelopePrefix) || len(s) == len(EnvelopePrefix) {
+		return nil, e("reason", "invalid prefix")
+	}
+	bts, err := base58.Decode(s[len(EnvelopePrefix):])
+	if err != nil {
+		return nil, e(err, "reason", "invalid encoding")
+	}
+	env := &Envelope{}
+	err = env.UnmarshalCBOR(bts)
+	if err != nil {
+		return nil, e(err)
+	}
+	return env, nil
+}
+
+// MarshalText implements custom marshaling using the string representation.
+func (env *Envelope) MarshalText() ([]byte, error) {
+	return []byte(env.String()), nil
+}
+
+// UnmarshalText implements custom unmarshaling from the string representation.
+func (env *Envelope) UnmarshalText(text []byte) error {
+	parsed, err := ParseEnvelope(string(text))
+	if err != nil {
+		return errors.E("Envelope.UnmarshalText", err)
+	}
+	*env = *parsed
+	return nil
+}
+
+func (env *Envelope) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(&encodedEnvelope{
+		Version:    envelopeVersion,
+		Algorithm:  env.Algorithm,
+		KID:        env.KID,
+		Created:    env.Created.UnixMilli(),
+		Salt:       env.Salt,
+		Iterations: env.Iterations,
+		Nonce:      env.Nonce,
+		Ciphertext: env.Ciphertext,
+	})
+}
+
+func (env *Envelope) UnmarshalCBOR(bts []byte) error {
+	e := errors.Template("Envelope.UnmarshalCBOR", errors.K.Invalid)
+	var enc encodedEnvelope
+	err := cbor.Unmarshal(bts, &enc)
+	if err != nil {
+		return e(err)
+	}
+	if enc.Version != envelopeVersion {
+		return e("reason", "unsupported version", "version", enc.Version)
+	}
+	switch enc.Algorithm {
+	case EA.AESGCM, EA.PBKDF2AESGCM:
+	default:
+		return e("reason", "unknown algorithm", "algorithm", enc.Algorithm)
+	}
+	*env = Envelope{
+		Algorithm:  enc.Algorithm,
+		KID:        enc.KID,
+		Created:    utc.UnixMilli(enc.Created),
+		Salt:       enc.Salt,
+		Iterations: enc.Iterations,
+		Nonce:      enc.Nonce,
+		Ciphertext: enc.Ciphertext,
+	}
+	return nil
+}
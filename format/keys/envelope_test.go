@@ -0,0 +1,113 @@
+package keys
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/util/byteutil"
+)
+
+func TestEnvelope(t *testing.T) {
+	key := New(ED25519SecretKey, byteutil.RandomBytes(64))
+
+	raw, err := RawKEK("kek1", byteutil.RandomBytes(32))
+	require.NoError(t, err)
+	password := PasswordKEK("kek2", "secret").WithIterations(1000)
+
+	for _, kek := range []*KEK{raw, password} {
+		t.Run(string(kek.algorithm()), func(t *testing.T) {
+			env, err := Seal(key, kek)
+			require.NoError(t, err)
+			require.Equal(t, kek.algorithm(), env.Algorithm)
+			require.Equal(t, kek.KID(), env.KID)
+			require.False(t, env.Created.IsZero())
+			require.NotContains(t, string(env.Ciphertext), string(key))
+
+			opened, err := env.Open(kek)
+			require.NoError(t, err)
+			require.Equal(t, key, opened)
+
+			// text
+			s := env.String()
+			require.Contains(t, s, EnvelopePrefix)
+			parsed, err := ParseEnvelope(s)
+			require.NoError(t, err)
+			require.Equal(t, env, parsed)
+
+			// json
+			bts, err := json.Marshal(map[string]*Envelope{"key": env})
+			require.NoError(t, err)
+			require.Equal(t, `{"key":"`+s+`"}`, string(bts))
+			var unmarshalled map[string]*Envelope
+			require.NoError(t, json.Unmarshal(bts, &unmarshalled))
+			require.Equal(t, env, unmarshalled["key"])
+
+			// cbor
+			bts, err = cbor.Marshal(env)
+			require.NoError(t, err)
+			var decoded Envelope
+			require.NoError(t, cbor.Unmarshal(bts, &decoded))
+			require.Equal(t, *env, decoded)
+
+			opened, err = decoded.Open(kek)
+			require.NoError(t, err)
+			require.Equal(t, key, opened)
+		})
+	}
+
+	env, err := Seal(key, password)
+	require.NoError(t, err)
+
+	t.Run("wrong kek", func(t *testing.T) {
+		_, err := env.Open(PasswordKEK("kek2", "wrong"))
+		require.Error(t, err)
+		_, err = env.Open(PasswordKEK("other", "secret"))
+		require.Error(t, err)
+		_, err = env.Open(raw)
+		require.Error(t, err)
+		// the kid is optional
+		_, err = env.Open(PasswordKEK("", "secret"))
+		require.NoError(t, err)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := *env
+		tampered.KID = "kek3"
+		_, err := tampered.Open(PasswordKEK("", "secret"))
+		require.Error(t, err)
+
+		tampered = *env
+		tampered.Created = tampered.Created.Add(time.Second)
+		_, err = tampered.Open(password)
+		require.Error(t, err)
+
+		tampered = *env
+		tampered.Ciphertext = append([]byte{}, env.Ciphertext...)
+		tampered.Ciphertext[0] ^= 1
+		_, err = tampered.Open(password)
+		require.Error(t, err)
+
+		tampered = *env
+		tampered.Iterations = MaxIterations + 1
+		_, err = tampered.Open(password)
+		require.Error(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := RawKEK("", byteutil.RandomBytes(16))
+		require.Error(t, err)
+		_, err = Seal(Key{byte(ED25519SecretKey), 1, 2}, raw)
+		require.Error(t, err)
+		_, err = Seal(key, nil)
+		require.Error(t, err)
+
+		for _, s := range []string{"", "kenv", "kped1234", "kenv0OIl", "kenv1234"} {
+			_, err = ParseEnvelope(s)
+			require.Error(t, err, s)
+		}
+	})
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/eluv-io/errors-go"
 	"github.com/eluv-io/log-go"
+
+	"github.com/eluv-io/common-go/format/checksum"
 )
 
 // Code is the type of a Key
@@ -144,6 +146,12 @@ func (k Key) String() string {
 	return k.prefix() + base58.Encode(k[codeLen:])
 }
 
+// ChecksumString returns the checksummed string representation of this Key - see package checksum. FromString accepts
+// both the plain and the checksummed form.
+func (k Key) ChecksumString() string {
+	return checksum.Append(k.String())
+}
+
 // AssertCode checks whether the Key's Code equals the provided Code
 func (k Key) AssertCode(c Code) error {
 	if len(k) < codeLen || k.Code() != c {
@@ -240,13 +248,18 @@ func KFromString(s string) (Key, error) {
 	return FromString(s)
 }
 
-// FromString parses a Key from the given string representation.
+// FromString parses a Key from the given string representation, which may be in checksummed form - see
+// ChecksumString().
 func FromString(s string) (Key, error) {
 	if len(s) == 0 {
 		return nil, nil
 	}
 
 	e := errors.Template("parse key", errors.K.Invalid.Default(), "key", s)
+	s, err := checksum.Strip(s)
+	if err != nil {
+		return nil, e(err)
+	}
 	if len(s) <= prefixLen {
 		return nil, e("reason", "empty key")
 	}
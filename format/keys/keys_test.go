@@ -3,6 +3,7 @@ package keys
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -65,6 +66,24 @@ func TestInvalidStringConversions(t *testing.T) {
 	}
 }
 
+func TestChecksumString(t *testing.T) {
+	key := New(ED25519PublicKey, byteutil.RandomBytes(32))
+	cs := key.ChecksumString()
+	require.NotEqual(t, key.String(), cs)
+
+	parsed, err := Parse(cs)
+	require.NoError(t, err)
+	require.Equal(t, key, parsed)
+
+	_, err = ED25519PublicKey.FromString(cs)
+	require.NoError(t, err)
+
+	_, err = Parse(cs[:len(cs)-1])
+	require.Error(t, err)
+	_, err = Parse(strings.Replace(cs, "kped", "kpsr", 1))
+	require.Error(t, err)
+}
+
 func TestJSON(t *testing.T) {
 	key := New(ED25519PublicKey, byteutil.RandomBytes(32))
 	b, err := json.Marshal(key)
@@ -1,7 +1,12 @@
 package link
 
 import (
+	"bytes"
+	"compress/flate"
 	"encoding/base64"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
 
 	"github.com/eluv-io/common-go/format/encryption"
 	"github.com/eluv-io/common-go/format/structured"
@@ -9,6 +14,22 @@ import (
 	"github.com/eluv-io/errors-go"
 )
 
+// Compression is the compression applied to the data of a blob link when it is marshaled.
+type Compression string
+
+const (
+	CompressionNone    Compression = ""
+	CompressionDeflate Compression = "deflate"
+)
+
+const (
+	// MaxBlobSize is the maximum size of the original data of compressed or chunked blobs.
+	MaxBlobSize = 64 * 1024 * 1024
+	// MaxBlobCompressionRatio is the maximum ratio between the size of the original and the compressed data of a blob.
+	// Data that compresses better is marshaled without compression.
+	MaxBlobCompressionRatio = 1000
+)
+
 // Blob represents the specific data of a blob link. A blob link is a relative link with the "blob" selector, a "data"
 // property that contains base64-encoded bytes, and an optional "encryption" property as defined in encryption.Scheme
 // (defaults to "none"):
@@ -21,19 +42,100 @@ import (
 //
 // Blob links are used to include arbitrary binary - and optionally encrypted - data in a data structure that supports
 // links. Actual data encryption/decryption is out of scope of this struct and must be managed externally.
+//
+// The data of large blobs may be compressed and split into multiple chunks when marshaled. In that case, the
+// "compression" property records the compression, the "size" property the size of the original data, "data" contains
+// the first chunk and "chunks" the remaining chunks:
+//
+//	{
+//	  "/": "./blob",
+//	  "data": "...",
+//	  "compression": "deflate",
+//	  "size": 4000000,
+//	  "chunks": ["...", "..."]
+//	}
+//
+// The chunks are stored as a flat list in the blob link itself, not as a chain of links to further blobs. They are
+// reassembled and decompressed transparently when unmarshaling, so Data always contains the original data. Since the
+// "size" property is not trusted, blobs with a size above MaxBlobSize or a compression ratio above
+// MaxBlobCompressionRatio are rejected before decompression.
 type Blob struct {
 	// NOTE: DO NOT CHANGE FIELD TYPES, THEIR ORDER OR REMOVE ANY FIELDS SINCE STRUCT IS CBOR-ENCODED AS ARRAY!
-	_struct          struct{}          `cbor:",toarray"`             // encode struct as array
-	Data             []byte            `json:"data"`                 // data encrypted according to encryption scheme
-	EncryptionScheme encryption.Scheme `json:"encryption,omitempty"` // the encryption scheme of the data
-	KID              string            `json:"kid,omitempty"`        // optional key ID. Empty means "default key".
+	//       Blob implements custom CBOR marshaling for compressed and chunked blobs - see encodedBlob.
+	_struct          struct{}          `cbor:",toarray"`              // encode struct as array
+	Data             []byte            `json:"data"`                  // data encrypted according to encryption scheme
+	EncryptionScheme encryption.Scheme `json:"encryption,omitempty"`  // the encryption scheme of the data
+	KID              string            `json:"kid,omitempty"`         // optional key ID. Empty means "default key".
+	Compression      Compression       `json:"compression,omitempty"` // the compression applied when marshaling
+	ChunkSize        int               `json:"-"`                     // the max size of marshaled chunks, 0 for no limit
+	// NOTE: Compression and ChunkSize are restored when unmarshaling, ChunkSize however only for chunked blobs!
+}
+
+// encodedBlob is the CBOR encoding of a Blob. Like the original Blob struct, it is encoded as a map with the keys of
+// the json tags. Blobs without compression and chunks therefore retain their original encoding.
+type encodedBlob struct {
+	Data             []byte            `json:"data"`
+	EncryptionScheme encryption.Scheme `json:"encryption,omitempty"`
+	KID              string            `json:"kid,omitempty"`
+	Compression      Compression       `json:"compression,omitempty"`
+	Size             int64             `json:"size,omitempty"`
+	Chunks           [][]byte          `json:"chunks,omitempty"`
+}
+
+func (b *Blob) MarshalCBOR() ([]byte, error) {
+	chunks, compression := b.encode()
+	enc := &encodedBlob{
+		Data:             chunks[0],
+		EncryptionScheme: b.EncryptionScheme,
+		KID:              b.KID,
+		Compression:      compression,
+		Chunks:           chunks[1:],
+	}
+	if compression != CompressionNone || len(chunks) > 1 {
+		enc.Size = int64(len(b.Data))
+	}
+	return cbor.Marshal(enc)
+}
+
+func (b *Blob) UnmarshalCBOR(bts []byte) error {
+	var enc encodedBlob
+	err := cbor.Unmarshal(bts, &enc)
+	if err != nil {
+		return errors.E("blob.UnmarshalCBOR", errors.K.Invalid, err)
+	}
+	b.Data = enc.Data
+	b.EncryptionScheme = enc.EncryptionScheme
+	b.KID = enc.KID
+	b.Compression = enc.Compression
+	size := enc.Size
+	if enc.Compression == CompressionNone && len(enc.Chunks) == 0 {
+		size = -1 // original encoding without size
+	}
+	return b.reassemble(enc.Chunks, size)
 }
 
 func (b *Blob) UnmarshalValue(val *structured.Value) error {
+	e := errors.TemplateNoTrace("blob.UnmarshalValue")
 	b.EncryptionScheme = encryption.None
 	err := val.Decode(b)
 	if err != nil {
-		return errors.NoTrace("blob.unmarshalValue", err)
+		return e(err)
+	}
+
+	var chunks [][]byte
+	if chunksVal := val.Get("chunks"); !chunksVal.IsError() {
+		for _, chunk := range chunksVal.Slice() {
+			decoded, err := decodeBlobData(chunk)
+			if err != nil {
+				return e(err)
+			}
+			chunks = append(chunks, decoded)
+		}
+	}
+
+	err = b.reassemble(chunks, val.Get("size").Int64(-1))
+	if err != nil {
+		return e(err)
 	}
 	return nil
 }
@@ -43,17 +145,10 @@ func (b *Blob) UnmarshalValueAndRemove(val *structured.Value) error {
 	// Therefore copy the "data" manually...
 	data := val.Get("data")
 	if !data.IsError() {
-		switch t := data.Data.(type) {
-		case []byte:
-			b.Data = sliceutil.Copy(t)
-		case string:
-			var err error
-			b.Data, err = base64.StdEncoding.DecodeString(t)
-			if err != nil {
-				return errors.NoTrace("blob.UnmarshalValueAndRemove", errors.K.Invalid, err)
-			}
-		default:
-			return errors.NoTrace("blob.UnmarshalValueAndRemove: invalid type for data field", errors.K.Invalid, "type", errors.TypeOf(data.Data))
+		var err error
+		b.Data, err = decodeBlobData(data.Data)
+		if err != nil {
+			return errors.NoTrace("blob.UnmarshalValueAndRemove", err)
 		}
 		val.Delete("data")
 	}
@@ -64,18 +159,51 @@ func (b *Blob) UnmarshalValueAndRemove(val *structured.Value) error {
 	}
 	val.Delete("encryption")
 	val.Delete("kid")
+	val.Delete("compression")
+	val.Delete("size")
+	val.Delete("chunks")
 	return nil
 }
 
+// decodeBlobData decodes the given blob data, which is either a byte slice or a base64-encoded string.
+func decodeBlobData(data interface{}) ([]byte, error) {
+	switch t := data.(type) {
+	case []byte:
+		return sliceutil.Copy(t), nil
+	case string:
+		decoded, err := base64.StdEncoding.DecodeString(t)
+		if err != nil {
+			return nil, errors.NoTrace("decodeBlobData", errors.K.Invalid, err)
+		}
+		return decoded, nil
+	default:
+		return nil, errors.NoTrace("decodeBlobData: invalid type for data field", errors.K.Invalid, "type", errors.TypeOf(data))
+	}
+}
+
 func (b *Blob) MarshalMap() map[string]interface{} {
 	m := map[string]interface{}{}
-	m["data"] = base64.StdEncoding.EncodeToString(b.Data)
+	chunks, compression := b.encode()
+	m["data"] = base64.StdEncoding.EncodeToString(chunks[0])
 	if b.EncryptionScheme != encryption.None {
 		m["encryption"] = b.EncryptionScheme.String()
 	}
 	if b.KID != "" {
 		m["kid"] = b.KID
 	}
+	if compression != CompressionNone || len(chunks) > 1 {
+		if compression != CompressionNone {
+			m["compression"] = string(compression)
+		}
+		m["size"] = int64(len(b.Data))
+	}
+	if len(chunks) > 1 {
+		encoded := make([]interface{}, len(chunks)-1)
+		for idx, chunk := range chunks[1:] {
+			encoded[idx] = base64.StdEncoding.EncodeToString(chunk)
+		}
+		m["chunks"] = encoded
+	}
 	return m
 }
 
@@ -90,5 +218,101 @@ func (b *Blob) Validate() error {
 	if b.EncryptionScheme == encryption.UNKNOWN {
 		return e("reason", "encryption scheme unknown")
 	}
+	switch b.Compression {
+	case CompressionNone, CompressionDeflate:
+	default:
+		return e("reason", "compression unknown", "compression", b.Compression)
+	}
+	if b.ChunkSize < 0 {
+		return e("reason", "invalid chunk size", "chunk_size", b.ChunkSize)
+	}
+	if (b.Compression != CompressionNone || b.ChunkSize > 0) && len(b.Data) > MaxBlobSize {
+		return e("reason", "blob too large for compression or chunking", "size", len(b.Data), "max", MaxBlobSize)
+	}
 	return nil
 }
+
+// encode returns the data of this blob as marshaled and the compression applied to it: compressed according to the
+// blob's compression - unless the compression ratio exceeds MaxBlobCompressionRatio - and split into chunks of at most
+// ChunkSize bytes. Always returns at least one chunk.
+func (b *Blob) encode() ([][]byte, Compression) {
+	data := b.Data
+	compression := b.Compression
+	if compression == CompressionDeflate {
+		buf := &bytes.Buffer{}
+		// errors are impossible: the compression level is valid and writing to a bytes.Buffer does not fail
+		w, _ := flate.NewWriter(buf, flate.DefaultCompression)
+		_, _ = w.Write(data)
+		_ = w.Close()
+		if exceedsCompressionRatio(int64(len(data)), buf.Len()) {
+			compression = CompressionNone
+		} else {
+			data = buf.Bytes()
+		}
+	}
+	if b.ChunkSize <= 0 || len(data) <= b.ChunkSize {
+		return [][]byte{data}, compression
+	}
+	chunks := make([][]byte, 0, (len(data)+b.ChunkSize-1)/b.ChunkSize)
+	for len(data) > b.ChunkSize {
+		chunks = append(chunks, data[:b.ChunkSize])
+		data = data[b.ChunkSize:]
+	}
+	return append(chunks, data), compression
+}
+
+// reassemble appends the given additional chunks to the blob's data and decompresses the result. size is the size of
+// the original data, or -1 if unknown.
+func (b *Blob) reassemble(chunks [][]byte, size int64) error {
+	e := errors.Template("reassemble blob", errors.K.Invalid)
+	if len(chunks) > 0 {
+		b.ChunkSize = len(b.Data)
+		total := len(b.Data)
+		for _, chunk := range chunks {
+			total += len(chunk)
+		}
+		data := make([]byte, 0, total)
+		data = append(data, b.Data...)
+		for _, chunk := range chunks {
+			data = append(data, chunk...)
+		}
+		b.Data = data
+	}
+
+	if size > MaxBlobSize {
+		return e("reason", "blob too large", "size", size, "max", MaxBlobSize)
+	}
+
+	switch b.Compression {
+	case CompressionNone:
+	case CompressionDeflate:
+		if size < 0 {
+			return e("reason", "size of compressed blob missing")
+		}
+		if exceedsCompressionRatio(size, len(b.Data)) {
+			return e("reason", "compression ratio too high",
+				"size", size,
+				"compressed_size", len(b.Data),
+				"max_ratio", MaxBlobCompressionRatio)
+		}
+		r := flate.NewReader(bytes.NewReader(b.Data))
+		data, err := io.ReadAll(io.LimitReader(r, size+1))
+		if err != nil {
+			return e(err, "reason", "decompression failed")
+		}
+		b.Data = data
+	default:
+		return e("reason", "compression unknown", "compression", b.Compression)
+	}
+
+	if size >= 0 && int64(len(b.Data)) != size {
+		return e("reason", "size mismatch", "expected", size, "actual", len(b.Data))
+	}
+	return nil
+}
+
+// exceedsCompressionRatio returns true if the ratio between the given original and compressed sizes exceeds
+// MaxBlobCompressionRatio.
+func exceedsCompressionRatio(size int64, compressedSize int) bool {
+	return size > int64(compressedSize)*MaxBlobCompressionRatio
+}
@@ -20,7 +20,9 @@ func NewBlobBuilder() *BlobBuilder {
 }
 
 type BlobBuilder struct {
-	b *Builder
+	b                    *Builder
+	compression          Compression
+	compressionThreshold int
 }
 
 func (b *BlobBuilder) EncryptionScheme(scheme encryption.Scheme) *BlobBuilder {
@@ -38,6 +40,21 @@ func (b *BlobBuilder) KID(kid string) *BlobBuilder {
 	return b
 }
 
+// Compression sets the compression that is applied to the blob data when the link is marshaled, provided the data
+// size is at least the given threshold in bytes.
+func (b *BlobBuilder) Compression(compression Compression, threshold int) *BlobBuilder {
+	b.compression = compression
+	b.compressionThreshold = threshold
+	return b
+}
+
+// ChunkSize sets the maximum size of a chunk of (potentially compressed) blob data. Larger data is split into multiple
+// chunks when the link is marshaled. 0 means no limit.
+func (b *BlobBuilder) ChunkSize(size int) *BlobBuilder {
+	b.b.l.Blob.ChunkSize = size
+	return b
+}
+
 func (b *BlobBuilder) ReplaceProps(p map[string]interface{}) *BlobBuilder {
 	b.b.ReplaceProps(p)
 	return b
@@ -54,9 +71,19 @@ func (b *BlobBuilder) AddProp(key string, val interface{}) *BlobBuilder {
 }
 
 func (b *BlobBuilder) Build() (*Link, error) {
+	if blob := b.b.l.Blob; blob != nil {
+		blob.Compression = CompressionNone
+		if len(blob.Data) >= b.compressionThreshold {
+			blob.Compression = b.compression
+		}
+	}
 	return b.b.Build()
 }
 
 func (b *BlobBuilder) MustBuild() *Link {
-	return b.b.MustBuild()
+	res, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return res
 }
@@ -2,17 +2,20 @@ package link_test
 
 import (
 	"bytes"
+	"compress/flate"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"testing"
 
+	fxcbor "github.com/fxamacker/cbor/v2"
 	"github.com/stretchr/testify/require"
 
 	"github.com/eluv-io/common-go/format/codecs"
 	"github.com/eluv-io/common-go/format/encryption"
 	"github.com/eluv-io/common-go/format/link"
+	"github.com/eluv-io/common-go/format/structured"
 	"github.com/eluv-io/common-go/util/timeutil"
 	"github.com/eluv-io/log-go"
 	"github.com/eluv-io/mapstructure"
@@ -177,3 +180,173 @@ func xTestDecodeSlice(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, data, target)
 }
+
+func TestBlobLinkCompressionAndChunks(t *testing.T) {
+	data := bytes.Repeat([]byte("compressible blob data "), 10000)
+	random := make([]byte, 100000)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		lnk         *link.Link
+		compression link.Compression
+		chunks      int
+	}{
+		{
+			name:        "compressed",
+			lnk:         link.NewBlobBuilder().Data(data).Compression(link.CompressionDeflate, 1000).MustBuild(),
+			compression: link.CompressionDeflate,
+		},
+		{
+			name: "below threshold",
+			lnk:  link.NewBlobBuilder().Data(data[:999]).Compression(link.CompressionDeflate, 1000).MustBuild(),
+		},
+		{
+			name:   "chunked",
+			lnk:    link.NewBlobBuilder().Data(random).ChunkSize(30000).MustBuild(),
+			chunks: 3,
+		},
+		{
+			name:        "compressed and chunked",
+			lnk:         link.NewBlobBuilder().Data(data).Compression(link.CompressionDeflate, 0).ChunkSize(100).MustBuild(),
+			compression: link.CompressionDeflate,
+			chunks:      -1, // more than one
+		},
+		{
+			name: "encrypted and chunked",
+			lnk: link.NewBlobBuilder().
+				Data(random).
+				EncryptionScheme(encryption.ClientGen).
+				KID("kid").
+				ChunkSize(len(random) - 1).
+				MustBuild(),
+			chunks: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.compression, test.lnk.Blob.Compression)
+
+			m := test.lnk.MarshalMap()
+			require.Equal(t, string(test.compression), jsonString(m["compression"]))
+			chunks, _ := m["chunks"].([]interface{})
+			switch {
+			case test.chunks < 0:
+				require.Greater(t, len(chunks), 1)
+			default:
+				require.Len(t, chunks, test.chunks)
+			}
+			if test.compression != link.CompressionNone || test.chunks != 0 {
+				require.Equal(t, int64(len(test.lnk.Blob.Data)), m["size"])
+			} else {
+				require.NotContains(t, m, "size")
+			}
+
+			testJSON(t, test.lnk, "")
+
+			converted, err := link.ConvertLinks(map[string]interface{}{"blob": m})
+			require.NoError(t, err)
+			require.Equal(t, test.lnk.Blob.Data, converted.(map[string]interface{})["blob"].(*link.Link).Blob.Data)
+		})
+	}
+
+	// compression reduces the size of compressible data
+	compressed, err := json.Marshal(tests[0].lnk)
+	require.NoError(t, err)
+	require.Less(t, len(compressed), len(data)/10)
+}
+
+func TestBlobLinkCborCompatibility(t *testing.T) {
+	// the original Blob struct
+	type blobV1 struct {
+		_struct          struct{}          `cbor:",toarray"`
+		Data             []byte            `json:"data"`
+		EncryptionScheme encryption.Scheme `json:"encryption,omitempty"`
+		KID              string            `json:"kid,omitempty"`
+	}
+	data := []byte("###blob bytes###")
+	legacy, err := fxcbor.Marshal(&blobV1{Data: data, EncryptionScheme: encryption.ClientGen, KID: "kid"})
+	require.NoError(t, err)
+
+	blob := link.NewBlobBuilder().Data(data).EncryptionScheme(encryption.ClientGen).KID("kid").MustBuild().Blob
+	encoded, err := fxcbor.Marshal(blob)
+	require.NoError(t, err)
+	require.Equal(t, legacy, encoded)
+
+	var decoded link.Blob
+	require.NoError(t, fxcbor.Unmarshal(legacy, &decoded))
+	require.Equal(t, *blob, decoded)
+
+	// compressed and chunked blobs are encoded with additional properties
+	blob = link.NewBlobBuilder().Data(data).Compression(link.CompressionDeflate, 0).ChunkSize(5).MustBuild().Blob
+	encoded, err = fxcbor.Marshal(blob)
+	require.NoError(t, err)
+	decoded = link.Blob{}
+	require.NoError(t, fxcbor.Unmarshal(encoded, &decoded))
+	require.Equal(t, data, decoded.Data)
+	require.Equal(t, link.CompressionDeflate, decoded.Compression)
+	require.Equal(t, 5, decoded.ChunkSize)
+}
+
+func TestBlobLinkChunksInvalid(t *testing.T) {
+	data := bytes.Repeat([]byte("blob data "), 100)
+	valid := link.NewBlobBuilder().Data(data).Compression(link.CompressionDeflate, 0).ChunkSize(5).MustBuild().MarshalMap()
+	valid["/"] = "./blob"
+
+	tests := []struct {
+		name   string
+		modify func(m map[string]interface{})
+	}{
+		{"size mismatch", func(m map[string]interface{}) { m["size"] = 10 }},
+		{"size missing", func(m map[string]interface{}) { delete(m, "size") }},
+		{"chunk missing", func(m map[string]interface{}) { m["chunks"] = m["chunks"].([]interface{})[1:] }},
+		{"invalid chunk", func(m map[string]interface{}) { m["chunks"] = []interface{}{"not base64!"} }},
+		{"unknown compression", func(m map[string]interface{}) { m["compression"] = "zip" }},
+		{"size too large", func(m map[string]interface{}) { m["size"] = link.MaxBlobSize + 1 }},
+		{"compression ratio too high", func(m map[string]interface{}) { m["size"] = link.MaxBlobSize }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := structured.Copy(valid).(map[string]interface{})
+			test.modify(m)
+			var lnk link.Link
+			require.Error(t, lnk.UnmarshalMap(m))
+		})
+	}
+
+	var lnk link.Link
+	require.NoError(t, lnk.UnmarshalMap(structured.Copy(valid).(map[string]interface{})))
+	require.Equal(t, data, lnk.Blob.Data)
+}
+
+func TestBlobLinkDecompressionBomb(t *testing.T) {
+	// zeros compress with a ratio above MaxBlobCompressionRatio at the best compression level
+	data := make([]byte, 16*1024*1024)
+	buf := &bytes.Buffer{}
+	w, err := flate.NewWriter(buf, flate.BestCompression)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Greater(t, len(data)/buf.Len(), link.MaxBlobCompressionRatio)
+
+	var lnk link.Link
+	err = lnk.UnmarshalMap(map[string]interface{}{
+		"/":           "./blob",
+		"data":        base64.StdEncoding.EncodeToString(buf.Bytes()),
+		"compression": "deflate",
+		"size":        len(data),
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "compression ratio too high")
+
+	// blobs that were too large to be read back are refused
+	_, err = link.NewBlobBuilder().Data(make([]byte, link.MaxBlobSize+1)).ChunkSize(1000).Build()
+	require.Error(t, err)
+}
+
+func jsonString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
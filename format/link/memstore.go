@@ -0,0 +1,97 @@
+package link
+
+import (
+	"sync"
+
+	"github.com/eluv-io/errors-go"
+
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/common-go/util/sliceutil"
+)
+
+// MemoryStore is a simple in-memory implementation of the ContentStore interface, mainly intended for tests.
+type MemoryStore struct {
+	mutex sync.RWMutex
+	meta  map[string]interface{}           // qhot -> metadata
+	files map[string]map[string][]byte     // qhot -> file path -> data
+	tags  map[string]map[string]*hash.Hash // qid -> auto-update tag -> content hash
+}
+
+// NewMemoryStore creates a new, empty in-memory content store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		meta:  map[string]interface{}{},
+		files: map[string]map[string][]byte{},
+		tags:  map[string]map[string]*hash.Hash{},
+	}
+}
+
+// SetMeta sets the metadata of the content object with the given hash or write token. Links in the metadata may be
+// specified as Link objects or in their JSON map representation.
+func (s *MemoryStore) SetMeta(qhot string, meta interface{}) *MemoryStore {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.meta[qhot] = meta
+	return s
+}
+
+// SetFile sets the data of the file at the given path of the content object with the given hash or write token.
+func (s *MemoryStore) SetFile(qhot string, path structured.Path, data []byte) *MemoryStore {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	files := s.files[qhot]
+	if files == nil {
+		files = map[string][]byte{}
+		s.files[qhot] = files
+	}
+	files[path.String()] = data
+	return s
+}
+
+// SetTag sets the given auto-update tag of the content object identified by the given hash to the given hash.
+func (s *MemoryStore) SetTag(qhash *hash.Hash, tag string, target *hash.Hash) *MemoryStore {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tags := s.tags[qhash.ID.String()]
+	if tags == nil {
+		tags = map[string]*hash.Hash{}
+		s.tags[qhash.ID.String()] = tags
+	}
+	tags[tag] = target
+	return s
+}
+
+func (s *MemoryStore) Meta(qhot string, path structured.Path) (interface{}, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	meta, found := s.meta[qhot]
+	if !found {
+		return nil, errors.E("memstore.Meta", errors.K.NotExist, "reason", "content not found", "qhot", qhot)
+	}
+	val, err := structured.Resolve(path, meta)
+	if err != nil {
+		return nil, errors.E("memstore.Meta", err, "qhot", qhot)
+	}
+	return structured.Copy(val), nil
+}
+
+func (s *MemoryStore) File(qhot string, path structured.Path) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	data, found := s.files[qhot][path.String()]
+	if !found {
+		return nil, errors.E("memstore.File", errors.K.NotExist, "qhot", qhot, "path", path)
+	}
+	return sliceutil.Copy(data), nil
+}
+
+func (s *MemoryStore) ResolveAutoUpdate(qhash *hash.Hash, tag string) (*hash.Hash, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	target, found := s.tags[qhash.ID.String()][tag]
+	if !found {
+		return nil, errors.E("memstore.ResolveAutoUpdate", errors.K.NotExist, "qhash", qhash, "tag", tag)
+	}
+	return target, nil
+}
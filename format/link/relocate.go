@@ -0,0 +1,247 @@
+package link
+
+import (
+	"github.com/eluv-io/errors-go"
+
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/structured"
+)
+
+// Class is the classification of a link with respect to the content object in which it is defined.
+type Class string
+
+// C defines the link classes.
+var C = struct {
+	Relative Class // a relative link "./..."
+	Self     Class // an absolute link to (any version of) the content object in which it is defined
+	External Class // an absolute link to another content object or a content part
+}{
+	Relative: "relative",
+	Self:     "self",
+	External: "external",
+}
+
+// Classify returns the class of the given link, defined in the content object with the given hash.
+func Classify(l *Link, self *hash.Hash) Class {
+	switch {
+	case l.IsRelative():
+		return C.Relative
+	case !self.IsNil() && l.Target.Type.Code == hash.Q && self.ID.Equal(l.Target.ID):
+		return C.Self
+	default:
+		return C.External
+	}
+}
+
+// AsRelative returns a relative copy of this link. The link must be relative or an absolute link to a content object.
+func (l *Link) AsRelative() (*Link, error) {
+	if l.IsAbsolute() && l.Target.Type.Code == hash.QPart {
+		return nil, errors.E("link.AsRelative", errors.K.Invalid, "reason", "content part link", "link", l.String())
+	}
+	res := l.Clone()
+	res.Target = nil
+	return &res, nil
+}
+
+// AsAbsolute returns a copy of this link with the given target.
+func (l *Link) AsAbsolute(target *hash.Hash) (*Link, error) {
+	if target.IsNil() {
+		return nil, errors.E("link.AsAbsolute", errors.K.Invalid, "reason", "target required", "link", l.String())
+	}
+	res := l.Clone()
+	res.Target = target
+	err := res.Validate()
+	if err != nil {
+		return nil, errors.E("link.AsAbsolute", err)
+	}
+	return &res, nil
+}
+
+// Form is the form of links produced by a Relocator.
+type Form string
+
+// F defines the link forms.
+var F = struct {
+	Keep     Form // links keep their form
+	Relative Form // self links are converted to relative links
+	Absolute Form // relative links (except blob links) are converted to absolute self links
+}{
+	Keep:     "",
+	Relative: "relative",
+	Absolute: "absolute",
+}
+
+// HashMapping maps the target hash of an external link to a new target. It returns the new target and true if the hash
+// is mapped, nil and false otherwise.
+type HashMapping func(h *hash.Hash) (*hash.Hash, bool)
+
+// MapHashes returns a HashMapping for the given map of hash strings to new hashes.
+func MapHashes(m map[string]*hash.Hash) HashMapping {
+	return func(h *hash.Hash) (*hash.Hash, bool) {
+		res, ok := m[h.String()]
+		return res, ok
+	}
+}
+
+// LinkRef is a link encountered during relocation.
+type LinkRef struct {
+	Path     structured.Path // the path of the link in the metadata
+	Class    Class           // the class of the original link
+	Original *Link           // the original link
+	Link     *Link           // the relocated link
+}
+
+// RelocationReport is the report of a relocation.
+type RelocationReport struct {
+	Links    []*LinkRef // all links in the metadata
+	Dangling []*LinkRef // the links whose target does not exist
+}
+
+// Relocator rewrites the links in the metadata of a content object that is copied or migrated to a new content object:
+//
+//   - relative links remain relative, unless converted to absolute links
+//   - self links are retargeted to the new content object, or converted to relative links if the hash of the new
+//     content object is unknown
+//   - external links are retargeted through the hash mapping and remain unchanged if the mapping does not cover them
+type Relocator struct {
+	source  *hash.Hash
+	target  *hash.Hash
+	mapping HashMapping
+	form    Form
+	exists  func(h *hash.Hash) bool
+}
+
+// NewRelocator creates a relocator for metadata copied from the content object with the given source hash to the
+// content object with the given target hash. The target hash may be nil if it is not known yet.
+func NewRelocator(source, target *hash.Hash) *Relocator {
+	return &Relocator{
+		source: source,
+		target: target,
+		mapping: func(*hash.Hash) (*hash.Hash, bool) {
+			return nil, false
+		},
+	}
+}
+
+// WithMapping sets the mapping used to retarget external links.
+func (r *Relocator) WithMapping(mapping HashMapping) *Relocator {
+	r.mapping = mapping
+	return r
+}
+
+// WithForm sets the form of the relocated relative and self links.
+func (r *Relocator) WithForm(form Form) *Relocator {
+	r.form = form
+	return r
+}
+
+// WithExists sets the function used to check whether the target of an external link that is not covered by the hash
+// mapping exists. If not set, such targets are assumed to exist.
+func (r *Relocator) WithExists(exists func(h *hash.Hash) bool) *Relocator {
+	r.exists = exists
+	return r
+}
+
+// Relocate rewrites all links in the given metadata, which is modified in place and returned along with a report of all
+// links. Links are rewritten in the representation in which they are found: either as Link objects or in their map
+// representation.
+func (r *Relocator) Relocate(meta interface{}) (interface{}, *RelocationReport, error) {
+	e := errors.Template("relocate links", errors.K.Invalid)
+	if r.form == F.Absolute && r.target.IsNil() {
+		return nil, nil, e("reason", "target hash required for absolute links")
+	}
+
+	report := &RelocationReport{}
+	res, err := structured.Replace(meta, func(path structured.Path, val interface{}) (bool, interface{}, error) {
+		l, ok := ToLink(val)
+		if !ok {
+			return false, nil, nil
+		}
+		ref := &LinkRef{
+			Path:     path.Clone(),
+			Class:    Classify(l, r.source),
+			Original: l,
+		}
+		relocated, err := r.relocate(l, ref.Class)
+		if err != nil {
+			return false, nil, errors.E(err, "path", path)
+		}
+		ref.Link = relocated
+		report.Links = append(report.Links, ref)
+
+		if _, isMap := val.(map[string]interface{}); isMap {
+			return true, relocated.MarshalMap(), nil
+		}
+		return true, relocated, nil
+	})
+	if err != nil {
+		return nil, nil, e(err)
+	}
+
+	for _, ref := range report.Links {
+		if r.isDangling(res, ref) {
+			report.Dangling = append(report.Dangling, ref)
+		}
+	}
+	return res, report, nil
+}
+
+// relocate returns the relocated copy of the given link.
+func (r *Relocator) relocate(l *Link, class Class) (*Link, error) {
+	switch class {
+	case C.Relative:
+		if r.form == F.Absolute && l.Selector != S.Blob {
+			return l.AsAbsolute(r.target)
+		}
+	case C.Self:
+		if r.form == F.Relative || r.target.IsNil() {
+			return l.AsRelative()
+		}
+		return l.AsAbsolute(r.target)
+	case C.External:
+		if target, ok := r.mapping(l.Target); ok {
+			return l.AsAbsolute(target)
+		}
+	}
+	res := l.Clone()
+	return &res, nil
+}
+
+// isDangling checks whether the target of the given link exists. The targets of relative and self meta links are
+// looked up in the given (relocated) metadata.
+func (r *Relocator) isDangling(meta interface{}, ref *LinkRef) bool {
+	switch ref.Class {
+	case C.Relative, C.Self:
+		return ref.Original.Selector == S.Meta && !metaExists(meta, ref.Original.Path)
+	default:
+		if _, ok := r.mapping(ref.Original.Target); ok || r.exists == nil {
+			return false
+		}
+		return !r.exists(ref.Original.Target)
+	}
+}
+
+// metaExists returns true if the given path exists in the given metadata. Paths that traverse a link are assumed to
+// exist.
+func metaExists(meta interface{}, path structured.Path) bool {
+	for idx := 0; idx <= len(path); idx++ {
+		val, err := structured.Resolve(path[:idx], meta)
+		if err != nil {
+			return false
+		}
+		if IsLink(val) || isLinkMap(val) {
+			return true
+		}
+	}
+	return true
+}
+
+// isLinkMap returns true if the given value is a link in its map representation.
+func isLinkMap(val interface{}) bool {
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = m["/"].(string)
+	return ok
+}
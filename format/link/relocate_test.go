@@ -0,0 +1,142 @@
+package link_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/link"
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/common-go/util/jsonutil"
+)
+
+func TestClassify(t *testing.T) {
+	self := newVersion(t, id.Generate(id.Q), "v1")
+	selfV2 := newVersion(t, self.ID, "v2")
+	other := newVersion(t, id.Generate(id.Q), "other")
+
+	require.Equal(t, link.C.Relative, link.Classify(create(nil, link.S.Meta, structured.ParsePath("/a")), self))
+	require.Equal(t, link.C.Self, link.Classify(create(self, link.S.Meta, structured.ParsePath("/a")), self))
+	require.Equal(t, link.C.Self, link.Classify(create(selfV2, link.S.Meta, structured.ParsePath("/a")), self))
+	require.Equal(t, link.C.External, link.Classify(create(other, link.S.Meta, structured.ParsePath("/a")), self))
+	require.Equal(t, link.C.External, link.Classify(create(qpHash(), "", nil), self))
+	require.Equal(t, link.C.External, link.Classify(create(self, link.S.Meta, structured.ParsePath("/a")), nil))
+}
+
+func TestAsRelativeAbsolute(t *testing.T) {
+	self := newVersion(t, id.Generate(id.Q), "v1")
+
+	rel := create(nil, link.S.File, structured.ParsePath("/a/b"), 10, 20)
+	abs, err := rel.AsAbsolute(self)
+	require.NoError(t, err)
+	require.Equal(t, "/qfab/"+self.String()+"/files/a/b#10-29", abs.String())
+	require.True(t, rel.IsRelative())
+
+	rel2, err := abs.AsRelative()
+	require.NoError(t, err)
+	require.Equal(t, rel.String(), rel2.String())
+
+	_, err = rel.AsAbsolute(nil)
+	require.Error(t, err)
+	_, err = create(qpHash(), "", nil).AsRelative()
+	require.Error(t, err)
+}
+
+func TestRelocate(t *testing.T) {
+	src := newVersion(t, id.Generate(id.Q), "src")
+	srcV0 := newVersion(t, src.ID, "src v0")
+	dst := newVersion(t, id.Generate(id.Q), "dst")
+	ext := newVersion(t, id.Generate(id.Q), "ext")
+	extMigrated := newVersion(t, id.Generate(id.Q), "ext migrated")
+	unknown := newVersion(t, id.Generate(id.Q), "unknown")
+
+	newMeta := func() interface{} {
+		meta := jsonutil.UnmarshalStringToMap(`{
+			"info": {"title": "src"},
+			"rel": {"/": "./meta/info/title", "prop": "val"},
+			"rel_file": {"/": "./files/video.mp4#0-99"},
+			"self": {"/": "/qfab/` + src.String() + `/meta/info"},
+			"self_old": {"/": "/qfab/` + srcV0.String() + `/meta/info", ".": {"auto_update": {"tag": "latest"}}},
+			"ext": {"/": "/qfab/` + ext.String() + `/meta/public"},
+			"unknown": {"/": "/qfab/` + unknown.String() + `/files/x"},
+			"dangling": {"/": "./meta/does/not/exist"},
+			"through_link": {"/": "./meta/rel/sub"}
+		}`)
+		meta["objects"] = []interface{}{create(src, link.S.Rep, structured.ParsePath("/player"))}
+		return meta
+	}
+	mapping := link.MapHashes(map[string]*hash.Hash{ext.String(): extMigrated})
+	exists := func(h *hash.Hash) bool { return h.Equal(ext) }
+
+	t.Run("keep form", func(t *testing.T) {
+		res, report, err := link.NewRelocator(src, dst).WithMapping(mapping).WithExists(exists).Relocate(newMeta())
+		require.NoError(t, err)
+		val := structured.Wrap(res)
+
+		require.Equal(t, map[string]interface{}{"/": "./meta/info/title", "prop": "val"}, val.Get("rel").Value())
+		require.Equal(t, "./files/video.mp4#0-99", val.Get("rel_file", "/").String())
+		require.Equal(t, "/qfab/"+dst.String()+"/meta/info", val.Get("self", "/").String())
+		require.Equal(t, "/qfab/"+dst.String()+"/meta/info", val.Get("self_old", "/").String())
+		require.Equal(t, "latest", val.Get("self_old", ".", "auto_update", "tag").String())
+		require.Equal(t, "/qfab/"+extMigrated.String()+"/meta/public", val.Get("ext", "/").String())
+		require.Equal(t, "/qfab/"+unknown.String()+"/files/x", val.Get("unknown", "/").String())
+
+		rep, ok := val.Get("objects", "0").Value().(*link.Link)
+		require.True(t, ok)
+		require.Equal(t, "/qfab/"+dst.String()+"/rep/player", rep.String())
+
+		classes := map[string]link.Class{}
+		for _, ref := range report.Links {
+			classes[ref.Path.String()] = ref.Class
+		}
+		require.Equal(t, map[string]link.Class{
+			"/rel":          link.C.Relative,
+			"/rel_file":     link.C.Relative,
+			"/self":         link.C.Self,
+			"/self_old":     link.C.Self,
+			"/ext":          link.C.External,
+			"/unknown":      link.C.External,
+			"/dangling":     link.C.Relative,
+			"/through_link": link.C.Relative,
+			"/objects/0":    link.C.Self,
+		}, classes)
+
+		var dangling []string
+		for _, ref := range report.Dangling {
+			dangling = append(dangling, ref.Path.String())
+		}
+		require.ElementsMatch(t, []string{"/dangling", "/unknown"}, dangling)
+	})
+
+	t.Run("relative form", func(t *testing.T) {
+		res, report, err := link.NewRelocator(src, dst).WithForm(link.F.Relative).Relocate(newMeta())
+		require.NoError(t, err)
+		val := structured.Wrap(res)
+		require.Equal(t, "./meta/info", val.Get("self", "/").String())
+		require.Equal(t, "./rep/player", val.Get("objects", "0").Value().(*link.Link).String())
+		require.Equal(t, "/qfab/"+ext.String()+"/meta/public", val.Get("ext", "/").String())
+		require.Len(t, report.Dangling, 1)
+
+		// self links are made relative if the target hash is unknown
+		res, _, err = link.NewRelocator(src, nil).Relocate(newMeta())
+		require.NoError(t, err)
+		require.Equal(t, "./meta/info", structured.Wrap(res).Get("self", "/").String())
+	})
+
+	t.Run("absolute form", func(t *testing.T) {
+		meta := newMeta().(map[string]interface{})
+		meta["blob"] = link.NewBlobBuilder().Data([]byte("blob")).MustBuild()
+		res, _, err := link.NewRelocator(src, dst).WithForm(link.F.Absolute).Relocate(meta)
+		require.NoError(t, err)
+		val := structured.Wrap(res)
+		require.Equal(t, "/qfab/"+dst.String()+"/meta/info/title", val.Get("rel", "/").String())
+		require.Equal(t, "val", val.Get("rel", "prop").String())
+		require.Equal(t, "/qfab/"+dst.String()+"/files/video.mp4#0-99", val.Get("rel_file", "/").String())
+		require.Equal(t, "./blob", val.Get("blob").Value().(*link.Link).String())
+
+		_, _, err = link.NewRelocator(src, nil).WithForm(link.F.Absolute).Relocate(newMeta())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,269 @@
+package link
+
+import (
+	"github.com/eluv-io/errors-go"
+
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/structured"
+)
+
+// DefaultResolverDepth is the default maximum number of nested links followed by a Resolver.
+const DefaultResolverDepth = 10
+
+// ContentStore is the interface to the content objects used by a Resolver to resolve links. Content objects are
+// identified by their content hash or write token ("qhot").
+type ContentStore interface {
+	// Meta returns the metadata at the given path of the content object with the given hash or write token. The
+	// returned structure is owned by the caller and may be modified.
+	Meta(qhot string, path structured.Path) (interface{}, error)
+	// File returns the data of the file at the given path of the content object with the given hash or write token.
+	File(qhot string, path structured.Path) ([]byte, error)
+	// ResolveAutoUpdate returns the hash of the content version that the given auto-update tag of the content object
+	// with the given hash currently refers to.
+	ResolveAutoUpdate(qhash *hash.Hash, tag string) (*hash.Hash, error)
+}
+
+// Resolver resolves links in metadata structures with the help of a ContentStore:
+//
+//   - meta links are replaced with the metadata they point to, recursively up to the configured depth
+//   - links that cannot be resolved remain in the structure and record the reason in Extra.ResolutionError
+//   - relative links that remain in the structure record the content in which they are defined in Extra.Container
+//
+// File and blob links are not replaced, but their data can be retrieved with ResolveData().
+type Resolver struct {
+	store      ContentStore
+	depth      int
+	autoUpdate bool
+}
+
+// NewResolver creates a new resolver for the given content store.
+func NewResolver(store ContentStore) *Resolver {
+	return &Resolver{
+		store: store,
+		depth: DefaultResolverDepth,
+	}
+}
+
+// WithDepth sets the maximum number of nested links that are followed. Links beyond that depth are left unresolved.
+// A depth of 0 disables link resolution.
+func (r *Resolver) WithDepth(depth int) *Resolver {
+	r.depth = depth
+	return r
+}
+
+// WithAutoUpdate enables or disables auto-updates: if enabled, absolute links with an auto-update tag are resolved
+// against the content version that the tag currently refers to instead of the version in the link.
+func (r *Resolver) WithAutoUpdate(autoUpdate bool) *Resolver {
+	r.autoUpdate = autoUpdate
+	return r
+}
+
+// ResolveMeta retrieves the metadata at the given path of the content object with the given hash or write token and
+// resolves all links it contains. Links encountered along the path are followed.
+func (r *Resolver) ResolveMeta(qhot string, path structured.Path) (interface{}, error) {
+	ctx := r.newContext()
+	val, err := ctx.meta(qhot, path, r.depth)
+	if err != nil {
+		return nil, errors.E("resolve meta", err, "qhot", qhot, "path", path)
+	}
+	return ctx.resolveTree(qhot, val, r.depth), nil
+}
+
+// Resolve resolves all links in the given metadata structure, which is defined in the content object with the given
+// hash or write token. The structure is modified in place and returned. Errors that occur during the resolution of
+// individual links are recorded in the corresponding link's Extra.ResolutionError.
+func (r *Resolver) Resolve(qhot string, target interface{}) interface{} {
+	return r.newContext().resolveTree(qhot, target, r.depth)
+}
+
+// ResolveLink resolves a single meta link defined in the content object with the given hash or write token and
+// returns the (resolved) metadata it points to.
+func (r *Resolver) ResolveLink(qhot string, l *Link) (interface{}, error) {
+	return r.newContext().resolveLink(qhot, l, r.depth)
+}
+
+// ResolveData returns the data referenced by the given file or blob link defined in the content object with the given
+// hash or write token, limited to the link's byte range. Blob data is returned as is - decryption is the caller's
+// responsibility.
+func (r *Resolver) ResolveData(qhot string, l *Link) ([]byte, error) {
+	e := errors.Template("resolve data", "link", l.String())
+
+	var data []byte
+	switch l.Selector {
+	case S.Blob:
+		if l.Blob == nil {
+			return nil, e(errors.K.Invalid, "reason", "blob link without blob")
+		}
+		data = l.Blob.Data
+	case S.File:
+		container, err := r.container(qhot, l)
+		if err != nil {
+			return nil, e(err)
+		}
+		data, err = r.store.File(container, l.Path)
+		if err != nil {
+			return nil, e(err, "qhot", container)
+		}
+	default:
+		return nil, e(errors.K.Invalid, "reason", "not a file or blob link")
+	}
+
+	start, end, err := byteRange(l.Off, l.Len, int64(len(data)))
+	if err != nil {
+		return nil, e(err)
+	}
+	return data[start:end], nil
+}
+
+// container returns the hash or write token of the content object that the given link points to.
+func (r *Resolver) container(qhot string, l *Link) (string, error) {
+	if l.IsRelative() {
+		if qhot == "" {
+			return "", errors.E("resolve container", errors.K.Invalid, "reason", "relative link without container")
+		}
+		return qhot, nil
+	}
+	target := l.Target
+	if r.autoUpdate && l.Extra.AutoUpdate != nil && l.Extra.AutoUpdate.Tag != "" {
+		updated, err := r.store.ResolveAutoUpdate(target, l.Extra.AutoUpdate.Tag)
+		if err != nil {
+			return "", errors.E("resolve container", err, "tag", l.Extra.AutoUpdate.Tag)
+		}
+		target = updated
+	}
+	return target.String(), nil
+}
+
+func (r *Resolver) newContext() *resolveCtx {
+	return &resolveCtx{
+		Resolver: r,
+		visiting: map[string]bool{},
+	}
+}
+
+// resolveCtx holds the state of a single resolution.
+type resolveCtx struct {
+	*Resolver
+	visiting map[string]bool // the meta locations of the links currently being resolved
+}
+
+// resolveTree resolves the links in the given structure. depth is the remaining number of links that may be followed.
+func (c *resolveCtx) resolveTree(qhot string, target interface{}, depth int) interface{} {
+	res, _ := structured.Replace(target, func(path structured.Path, val interface{}) (bool, interface{}, error) {
+		l, ok := ToLink(val)
+		if !ok {
+			return false, nil, nil
+		}
+		if l == val {
+			// don't modify links that may be shared with other structures
+			clone := l.Clone()
+			l = &clone
+		}
+		if l.IsRelative() {
+			l.Extra.Container = qhot
+		}
+		if depth <= 0 || !l.isMetaLink() {
+			return true, l, nil
+		}
+		resolved, err := c.resolveLink(qhot, l, depth)
+		if err != nil {
+			l.Extra.ResolutionError = err
+			return true, l, nil
+		}
+		return true, resolved, nil
+	})
+	return res
+}
+
+// resolveLink resolves the given meta link and the links contained in the metadata it points to.
+func (c *resolveCtx) resolveLink(qhot string, l *Link, depth int) (interface{}, error) {
+	e := errors.Template("resolve link", "link", l.String())
+	if !l.isMetaLink() {
+		return nil, e(errors.K.Invalid, "reason", "not a meta link")
+	}
+	if depth <= 0 {
+		return nil, e(errors.K.Invalid, "reason", "maximum link depth exceeded")
+	}
+
+	qhot, err := c.container(qhot, l)
+	if err != nil {
+		return nil, e(err)
+	}
+
+	leave, err := c.enter(qhot, l.Path)
+	if err != nil {
+		return nil, e(err)
+	}
+	defer leave()
+
+	val, err := c.meta(qhot, l.Path, depth-1)
+	if err != nil {
+		return nil, e(err)
+	}
+	return c.resolveTree(qhot, val, depth-1), nil
+}
+
+// meta retrieves the metadata at the given path. If the path cannot be found, the path's prefixes are searched for
+// meta links, which are then followed with the rest of the path.
+func (c *resolveCtx) meta(qhot string, path structured.Path, depth int) (interface{}, error) {
+	val, err := c.store.Meta(qhot, path)
+	if err == nil {
+		return val, nil
+	}
+	for idx := len(path) - 1; idx >= 0; idx-- {
+		prefixVal, prefixErr := c.store.Meta(qhot, path[:idx])
+		if prefixErr != nil {
+			continue
+		}
+		l, ok := ToLink(prefixVal)
+		if !ok || !l.isMetaLink() {
+			break
+		}
+		leave, enterErr := c.enter(qhot, path[:idx])
+		if enterErr != nil {
+			return nil, enterErr
+		}
+		defer leave()
+		clone := l.Clone()
+		clone.Path = l.Path.CopyAppend(path[idx:]...)
+		return c.resolveLink(qhot, &clone, depth)
+	}
+	return nil, err
+}
+
+// enter marks the given meta location as being resolved and returns the function to call once resolution is complete.
+// Returns an error if the location is already being resolved, i.e. if a link cycle was detected.
+func (c *resolveCtx) enter(qhot string, path structured.Path) (leave func(), err error) {
+	location := qhot + "/" + string(S.Meta) + path.String()
+	if c.visiting[location] {
+		return nil, errors.E("resolve link", errors.K.Invalid, "reason", "link cycle detected", "location", location)
+	}
+	c.visiting[location] = true
+	return func() { delete(c.visiting, location) }, nil
+}
+
+func (l *Link) isMetaLink() bool {
+	return l.Selector == S.Meta && (l.IsRelative() || l.Target.Type.Code != hash.QPart)
+}
+
+// byteRange converts the given offset and length of a link's byte range to start and end indices of data with the
+// given size.
+func byteRange(off, len, size int64) (start, end int64, err error) {
+	e := errors.Template("byte range", errors.K.Invalid, "offset", off, "length", len, "size", size)
+	switch {
+	case off == -1 && len < 0:
+		return 0, 0, e("reason", "invalid byte range")
+	case off == -1:
+		start = max(size-len, 0)
+		end = size
+	case off < 0 || off > size:
+		return 0, 0, e("reason", "offset out of range")
+	case len <= 0:
+		start = off
+		end = size
+	default:
+		start = off
+		end = min(off+len, size)
+	}
+	return start, end, nil
+}
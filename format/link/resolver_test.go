@@ -0,0 +1,195 @@
+package link_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/errors-go"
+
+	"github.com/eluv-io/common-go/format/hash"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/link"
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/common-go/util/jsonutil"
+)
+
+// newVersion creates a new content hash for the content object with the given ID.
+func newVersion(t *testing.T, qid id.ID, content string) *hash.Hash {
+	part, err := hash.CalcHash(bytes.NewReader([]byte(content)))
+	require.NoError(t, err)
+	qhash, err := part.As(hash.Q, qid)
+	require.NoError(t, err)
+	return qhash
+}
+
+func TestResolver(t *testing.T) {
+	q1 := newVersion(t, id.Generate(id.Q), "q1")
+	q2v1 := newVersion(t, id.Generate(id.Q), "q2v1")
+	q2v2 := newVersion(t, q2v1.ID, "q2v2")
+
+	store := link.NewMemoryStore().
+		SetMeta(q1.String(), jsonutil.UnmarshalStringToAny(`{
+			"title": "q1",
+			"rel": {"/": "./meta/data"},
+			"nested": {"/": "./meta/alias"},
+			"alias": {"/": "./meta/data/info"},
+			"data": {"info": {"size": 42}, "file": {"/": "./files/video.mp4#10-19"}},
+			"abs": {"/": "/qfab/`+q2v1.String()+`/meta/public"},
+			"auto": {"/": "/qfab/`+q2v1.String()+`/meta/public/name", ".": {"auto_update": {"tag": "latest"}}},
+			"missing": {"/": "./meta/does/not/exist"},
+			"cycle1": {"/": "./meta/cycle2"},
+			"cycle2": {"/": "./meta/cycle1"},
+			"self": {"/": "./meta/self/sub"}
+		}`)).
+		SetMeta(q2v1.String(), jsonutil.UnmarshalStringToAny(`{
+			"public": {"name": "v1", "ref": {"/": "./meta/other"}},
+			"other": "q2v1 other"
+		}`)).
+		SetMeta(q2v2.String(), jsonutil.UnmarshalStringToAny(`{
+			"public": {"name": "v2"}
+		}`)).
+		SetTag(q2v1, "latest", q2v2).
+		SetFile(q1.String(), structured.ParsePath("/video.mp4"), []byte("0123456789abcdefghijklmnopqrstuvwxyz"))
+
+	resolve := func(r *link.Resolver, path string) interface{} {
+		res, err := r.ResolveMeta(q1.String(), structured.ParsePath(path))
+		require.NoError(t, err)
+		return res
+	}
+	resolutionError := func(val interface{}) error {
+		l, ok := link.ToLink(val)
+		require.True(t, ok)
+		require.Error(t, l.Extra.ResolutionError)
+		require.Equal(t, q1.String(), l.Extra.Container)
+		return l.Extra.ResolutionError
+	}
+
+	resolver := link.NewResolver(store)
+
+	t.Run("relative", func(t *testing.T) {
+		res := resolve(resolver, "/rel")
+		require.Equal(t, 42.0, structured.Wrap(res).Get("info", "size").Float64())
+		// file links remain links, but record their container
+		fileLink, ok := structured.Wrap(res).Get("file").Value().(*link.Link)
+		require.True(t, ok)
+		require.Equal(t, q1.String(), fileLink.Extra.Container)
+
+		require.Equal(t, map[string]interface{}{"size": 42.0}, resolve(resolver, "/nested"))
+	})
+
+	t.Run("path through link", func(t *testing.T) {
+		require.Equal(t, 42.0, resolve(resolver, "/rel/info/size"))
+		require.Equal(t, 42.0, resolve(resolver, "/nested/size"))
+
+		_, err := resolver.ResolveMeta(q1.String(), structured.ParsePath("/rel/info/unknown"))
+		require.Error(t, err)
+		require.True(t, errors.IsKind(errors.K.NotExist, err))
+	})
+
+	t.Run("absolute", func(t *testing.T) {
+		// relative links in the target content are resolved against the target content
+		require.Equal(t, map[string]interface{}{"name": "v1", "ref": "q2v1 other"}, resolve(resolver, "/abs"))
+	})
+
+	t.Run("auto update", func(t *testing.T) {
+		require.Equal(t, "v1", resolve(link.NewResolver(store), "/auto"))
+		require.Equal(t, "v2", resolve(link.NewResolver(store).WithAutoUpdate(true), "/auto"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		res := resolve(resolver, "/")
+		err := resolutionError(structured.Wrap(res).Get("missing").Value())
+		require.True(t, errors.IsKind(errors.K.NotExist, err))
+		require.Contains(t, resolutionError(structured.Wrap(res).Get("cycle1").Value()).Error(), "link cycle")
+		require.Contains(t, resolutionError(structured.Wrap(res).Get("cycle2").Value()).Error(), "link cycle")
+		require.Contains(t, resolutionError(structured.Wrap(res).Get("self").Value()).Error(), "link cycle")
+		// the other links are resolved nonetheless
+		require.Equal(t, "q1", structured.Wrap(res).Get("title").String())
+		require.Equal(t, 42.0, structured.Wrap(res).Get("nested", "size").Float64())
+
+		// resolution errors are marshaled
+		l := structured.Wrap(res).Get("missing").Value().(*link.Link)
+		require.Contains(t, jsonutil.MarshalString(l), "resolution_error")
+
+		// errors of nested links are recorded in the result
+		val, err := resolver.ResolveLink(q1.String(), link.NewBuilder().Selector(link.S.Meta).P("cycle1").MustBuild())
+		require.NoError(t, err)
+		require.Contains(t, resolutionError(val).Error(), "link cycle")
+
+		_, err = resolver.ResolveLink(q1.String(), link.NewBuilder().Selector(link.S.Meta).P("missing", "x").MustBuild())
+		require.Error(t, err)
+		_, err = resolver.ResolveLink("", link.NewBuilder().Selector(link.S.Meta).P("title").MustBuild())
+		require.Error(t, err)
+		_, err = link.NewResolver(store).WithAutoUpdate(true).ResolveLink(q1.String(),
+			link.NewBuilder().Target(q1).Selector(link.S.Meta).P("title").AutoUpdate("unknown").MustBuild())
+		require.Error(t, err)
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		res := resolve(link.NewResolver(store).WithDepth(0), "/rel")
+		require.Equal(t, map[string]interface{}{"/": "./meta/data", ".": map[string]interface{}{"container": q1.String()}},
+			jsonutil.UnmarshalStringToAny(jsonutil.MarshalString(res)))
+
+		// the first link is resolved, the second one not
+		res = resolve(link.NewResolver(store).WithDepth(1), "/nested")
+		l, ok := link.ToLink(res)
+		require.True(t, ok)
+		require.Equal(t, "./meta/data/info", l.String())
+		require.NoError(t, l.Extra.ResolutionError)
+		require.Equal(t, q1.String(), l.Extra.Container)
+
+		require.Equal(t, map[string]interface{}{"size": 42.0}, resolve(link.NewResolver(store).WithDepth(2), "/nested"))
+	})
+
+	t.Run("store unmodified", func(t *testing.T) {
+		_ = resolve(resolver, "/")
+		meta, err := store.Meta(q1.String(), structured.ParsePath("/rel"))
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"/": "./meta/data"}, meta)
+	})
+
+	t.Run("resolve in place", func(t *testing.T) {
+		target := map[string]interface{}{
+			"a": link.NewBuilder().Selector(link.S.Meta).P("title").MustBuild(),
+			"b": []interface{}{link.NewBuilder().Target(q2v1).Selector(link.S.Meta).P("other").MustBuild()},
+		}
+		res := resolver.Resolve(q1.String(), target)
+		require.Equal(t, map[string]interface{}{"a": "q1", "b": []interface{}{"q2v1 other"}}, res)
+	})
+
+	t.Run("data", func(t *testing.T) {
+		blobRange := link.NewBlobBuilder().Data([]byte("blob data")).MustBuild()
+		blobRange.Off = 5
+		tests := []struct {
+			link *link.Link
+			want string
+		}{
+			{link.NewBuilder().Selector(link.S.File).P("video.mp4").MustBuild(), "0123456789abcdefghijklmnopqrstuvwxyz"},
+			{link.NewBuilder().Selector(link.S.File).P("video.mp4").Off(10).Len(10).MustBuild(), "abcdefghij"},
+			{link.NewBuilder().Selector(link.S.File).P("video.mp4").Off(30).MustBuild(), "uvwxyz"},
+			{link.NewBuilder().Selector(link.S.File).P("video.mp4").Off(-1).Len(3).MustBuild(), "xyz"},
+			{link.NewBuilder().Selector(link.S.File).P("video.mp4").Off(30).Len(100).MustBuild(), "uvwxyz"},
+			{link.NewBuilder().Target(q1).Selector(link.S.File).P("video.mp4").Off(1).Len(2).MustBuild(), "12"},
+			{link.NewBlobBuilder().Data([]byte("blob data")).MustBuild(), "blob data"},
+			{blobRange, "data"},
+		}
+		for _, test := range tests {
+			t.Run(test.link.String(), func(t *testing.T) {
+				data, err := resolver.ResolveData(q1.String(), test.link)
+				require.NoError(t, err)
+				require.Equal(t, test.want, string(data))
+			})
+		}
+
+		for _, l := range []*link.Link{
+			link.NewBuilder().Selector(link.S.File).P("video.mp4").Off(100).MustBuild(),
+			link.NewBuilder().Selector(link.S.File).P("unknown").MustBuild(),
+			link.NewBuilder().Selector(link.S.Meta).P("title").MustBuild(),
+		} {
+			_, err := resolver.ResolveData(q1.String(), l)
+			require.Error(t, err)
+		}
+	})
+}
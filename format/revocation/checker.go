@@ -0,0 +1,159 @@
+package revocation
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/eluv-io/common-go/format/duration"
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/util/lru"
+	"github.com/eluv-io/utc-go"
+)
+
+var _ eat.RevocationChecker = (*Checker)(nil)
+
+// Checker is an in-memory revocation checker that can be passed to eat.Verifier. The entries are held in an
+// lru.TypedExpiringCache without size bound, since a revocation evicted for lack of space would silently accept a
+// revoked token. Likewise, the cache's max age is unlimited, since entries without expiration must be kept forever.
+// Instead, the expiration of each entry acts as its TTL: entries are ignored on lookup and evicted when applying a list
+// once they cannot revoke any valid token anymore:
+//   - entries with an expiration time after that time
+//   - entries with an issued-before cutoff when tokens issued before the cutoff have exceeded the max validity period
+//     of accepted tokens
+//
+// Entries without expiration time and cutoff - or with cutoff if the max validity period is unlimited - are kept
+// forever.
+type Checker struct {
+	mutex       sync.RWMutex
+	cache       *lru.TypedExpiringCache[string, checkerEntry]
+	sequence    uint64
+	maxValidity time.Duration
+	now         func() utc.UTC
+}
+
+// checkerEntry is a revocation entry held by the checker together with its cache key.
+type checkerEntry struct {
+	key   string
+	entry Entry
+}
+
+// NewChecker creates a new revocation checker. The max validity is the longest validity period of accepted tokens,
+// counted from their issued-at time - 0 if unlimited.
+func NewChecker(maxValidity duration.Spec) *Checker {
+	return &Checker{
+		cache: lru.NewTypedExpiringCache[string, checkerEntry](math.MaxInt, duration.Spec(math.MaxInt64)).
+			WithName("revocation-checker"),
+		maxValidity: maxValidity.Duration(),
+		now:         utc.Now,
+	}
+}
+
+// WithClock sets the function returning the current time, used for expiring entries.
+func (c *Checker) WithClock(now func() utc.UTC) *Checker {
+	c.now = now
+	return c
+}
+
+// Apply merges the entries of the given list into the checker's entries and evicts the entries that cannot revoke any
+// valid token anymore.
+func (c *Checker) Apply(l *List) {
+	if l == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if l.Sequence > c.sequence {
+		c.sequence = l.Sequence
+	}
+	for digest, entry := range l.Digests {
+		c.add(digestKey(digest), entry)
+	}
+	for subject, entry := range l.Subjects {
+		c.add(subjectKey(subject), entry)
+	}
+	for addr, entry := range l.Addresses {
+		c.add(addressKey(addr.Bytes()), entry)
+	}
+
+	now := c.now()
+	for _, e := range c.cache.Entries() {
+		if ce := e.Value(); c.isObsolete(ce.entry, now) {
+			c.cache.Remove(ce.key)
+		}
+	}
+}
+
+// Sequence returns the highest sequence number of all lists applied so far.
+func (c *Checker) Sequence() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.sequence
+}
+
+// Len returns the number of entries held by the checker.
+func (c *Checker) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cache.Len()
+}
+
+// IsRevoked returns true if the given token is revoked. Implements eat.RevocationChecker.
+func (c *Checker) IsRevoked(tok *eat.Token) (bool, error) {
+	if tok == nil {
+		return false, nil
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	now := c.now()
+	if digest, ok := TokenDigest(tok); ok && c.revokes(digestKey(digest), tok, now) {
+		return true, nil
+	}
+	if tok.Subject != "" && c.revokes(subjectKey(tok.Subject), tok, now) {
+		return true, nil
+	}
+	if tok.HasEthAddr() && c.revokes(addressKey(tok.EthAddr.Bytes()), tok, now) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (c *Checker) add(key string, entry Entry) {
+	if existing, ok := c.cache.Get(key); ok {
+		entry = existing.entry.Merge(entry)
+	}
+	c.cache.Update(key, checkerEntry{key: key, entry: entry})
+}
+
+// revokes returns true if the entry with the given key revokes the given token. Obsolete entries are ignored - they are
+// evicted with the next call to Apply().
+func (c *Checker) revokes(key string, tok *eat.Token, now utc.UTC) bool {
+	ce, ok := c.cache.Get(key)
+	return ok && !c.isObsolete(ce.entry, now) && ce.entry.Revokes(tok.IssuedAt)
+}
+
+// isObsolete returns true if the given entry cannot revoke any valid token at the given time anymore.
+func (c *Checker) isObsolete(entry Entry, now utc.UTC) bool {
+	if isExpired(entry, now) {
+		return true
+	}
+	return c.maxValidity > 0 &&
+		!entry.IssuedBefore.IsZero() &&
+		entry.IssuedBefore.Add(c.maxValidity).Before(now)
+}
+
+func digestKey(digest Digest) string {
+	return "d" + string(digest[:])
+}
+
+func subjectKey(subject string) string {
+	return "s" + subject
+}
+
+func addressKey(addr []byte) string {
+	return "a" + string(addr)
+}
@@ -0,0 +1,297 @@
+// Package revocation provides a compact, versioned format for lists of revoked auth tokens (see package format/eat) and
+// an in-memory checker that can be consulted when verifying tokens.
+//
+// Tokens are revoked by
+//   - the digest of their signed data: revokes exactly one token
+//   - their subject: revokes all tokens granted to a given entity
+//   - their signer address (EthAddr): revokes all tokens signed by a given key
+//
+// Each entry carries an optional issued-before cutoff: only tokens issued before the cutoff are revoked, tokens issued
+// at or after the cutoff remain valid. Without cutoff, all matching tokens are revoked.
+package revocation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/eluv-io/common-go/format/codecs"
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/errors-go"
+	"github.com/eluv-io/utc-go"
+)
+
+// formatVersion is the version of the encoded format of revocation lists.
+const formatVersion = 1
+
+// Digest is the SHA-256 digest of the data covered by a token's signature - see eat.Token.SignedHash().
+type Digest [sha256.Size]byte
+
+func (d Digest) String() string {
+	return hex.EncodeToString(d[:])
+}
+
+// TokenDigest returns the digest of the given token's signed data and true, or false if the token is not signed. The
+// digest does not depend on the signature itself, since ES256K signatures may be re-encoded in different but equally
+// valid forms.
+func TokenDigest(tok *eat.Token) (Digest, bool) {
+	if tok == nil || !tok.SigType.HasSig() || tok.Signature.IsNil() {
+		return Digest{}, false
+	}
+	hsh, err := tok.SignedHash()
+	if err != nil {
+		return Digest{}, false
+	}
+	return sha256.Sum256(hsh), true
+}
+
+// Entry is a revocation entry.
+type Entry struct {
+	IssuedBefore utc.UTC // only tokens issued before this time are revoked - all tokens if zero
+	Expires      utc.UTC // the entry may be removed after this time - never if zero
+}
+
+// Revokes returns true if the entry revokes a token issued at the given time.
+func (e Entry) Revokes(issuedAt utc.UTC) bool {
+	return e.IssuedBefore.IsZero() || issuedAt.Before(e.IssuedBefore)
+}
+
+// Merge merges the given entry into this entry and returns the result. The merged entry revokes any token revoked by
+// either of the two entries: the later cutoff wins (no cutoff winning over any cutoff), and so does the later
+// expiration (no expiration winning over any expiration).
+func (e Entry) Merge(o Entry) Entry {
+	return Entry{
+		IssuedBefore: laterOrZero(e.IssuedBefore, o.IssuedBefore),
+		Expires:      laterOrZero(e.Expires, o.Expires),
+	}
+}
+
+func laterOrZero(a, b utc.UTC) utc.UTC {
+	if a.IsZero() || b.IsZero() {
+		return utc.UTC{}
+	}
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// List is a list of token revocations. The sequence number identifies the version of the list and increases with
+// every update by the list's issuer.
+type List struct {
+	Sequence  uint64
+	Digests   map[Digest]Entry
+	Subjects  map[string]Entry
+	Addresses map[common.Address]Entry
+}
+
+// NewList creates a new, empty revocation list.
+func NewList() *List {
+	return &List{
+		Digests:   map[Digest]Entry{},
+		Subjects:  map[string]Entry{},
+		Addresses: map[common.Address]Entry{},
+	}
+}
+
+// Len returns the number of entries in the list.
+func (l *List) Len() int {
+	return len(l.Digests) + len(l.Subjects) + len(l.Addresses)
+}
+
+// RevokeToken revokes the given token by its digest - see TokenDigest(). The entry expires with the token.
+func (l *List) RevokeToken(tok *eat.Token) error {
+	digest, ok := TokenDigest(tok)
+	if !ok {
+		return errors.E("RevokeToken", errors.K.Invalid, "reason", "token is not signed")
+	}
+	l.RevokeDigest(digest, Entry{Expires: tok.Expires})
+	return nil
+}
+
+// RevokeDigest revokes the token with the given digest.
+func (l *List) RevokeDigest(digest Digest, entry Entry) {
+	revoke(l.Digests, digest, entry)
+}
+
+// RevokeSubject revokes the tokens granted to the given subject.
+func (l *List) RevokeSubject(subject string, entry Entry) {
+	revoke(l.Subjects, subject, entry)
+}
+
+// RevokeAddress revokes the tokens signed by the given address.
+func (l *List) RevokeAddress(addr common.Address, entry Entry) {
+	revoke(l.Addresses, addr, entry)
+}
+
+// Merge merges the given list into this list. Entries for the same key are merged with Entry.Merge(), the sequence
+// number is the higher of the two. Merging is commutative and idempotent.
+func (l *List) Merge(o *List) {
+	if o == nil {
+		return
+	}
+	if o.Sequence > l.Sequence {
+		l.Sequence = o.Sequence
+	}
+	for digest, entry := range o.Digests {
+		l.RevokeDigest(digest, entry)
+	}
+	for subject, entry := range o.Subjects {
+		l.RevokeSubject(subject, entry)
+	}
+	for addr, entry := range o.Addresses {
+		l.RevokeAddress(addr, entry)
+	}
+}
+
+// Prune removes all entries that expired before the given time.
+func (l *List) Prune(now utc.UTC) {
+	for digest, entry := range l.Digests {
+		if isExpired(entry, now) {
+			delete(l.Digests, digest)
+		}
+	}
+	for subject, entry := range l.Subjects {
+		if isExpired(entry, now) {
+			delete(l.Subjects, subject)
+		}
+	}
+	for addr, entry := range l.Addresses {
+		if isExpired(entry, now) {
+			delete(l.Addresses, addr)
+		}
+	}
+}
+
+// IsRevoked returns true if the given token is revoked by this list. Implements eat.RevocationChecker.
+func (l *List) IsRevoked(tok *eat.Token) (bool, error) {
+	if tok == nil {
+		return false, nil
+	}
+	if digest, ok := TokenDigest(tok); ok {
+		if entry, found := l.Digests[digest]; found && entry.Revokes(tok.IssuedAt) {
+			return true, nil
+		}
+	}
+	if tok.Subject != "" {
+		if entry, found := l.Subjects[tok.Subject]; found && entry.Revokes(tok.IssuedAt) {
+			return true, nil
+		}
+	}
+	if tok.HasEthAddr() {
+		if entry, found := l.Addresses[tok.EthAddr]; found && entry.Revokes(tok.IssuedAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Encode encodes the list in its versioned CBOR form.
+func (l *List) Encode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := codecs.NewVersionedEncoder(buf, codecs.CborV2Codec.Encoder(buf))
+	err := enc.EncodeVersioned(formatVersion, l.toSer())
+	if err != nil {
+		return nil, errors.E("List.Encode", errors.K.Invalid, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a list from its versioned CBOR form.
+func Decode(bts []byte) (*List, error) {
+	e := errors.Template("revocation.Decode", errors.K.Invalid)
+
+	r := bytes.NewReader(bts)
+	dec := codecs.NewVersionedDecoder(r, codecs.CborV2Codec.Decoder(r))
+	obj, version, err := dec.DecodeVersioned(func(version uint) interface{} {
+		return &serList{}
+	})
+	if err != nil {
+		return nil, e(err)
+	}
+	if version != formatVersion {
+		return nil, e("reason", "unsupported format version", "version", version)
+	}
+	return obj.(*serList).toList()
+}
+
+// -----------------------------------------------------------------------------
+
+// revoke adds the given entry to the map or merges it into the existing entry for the key.
+func revoke[K comparable](m map[K]Entry, key K, entry Entry) {
+	if existing, ok := m[key]; ok {
+		entry = existing.Merge(entry)
+	}
+	m[key] = entry
+}
+
+func isExpired(entry Entry, now utc.UTC) bool {
+	return !entry.Expires.IsZero() && entry.Expires.Before(now)
+}
+
+// serList is the serialization form of a List. Entries are stored in arrays with compact keys: 32 byte digests, 20
+// byte addresses and subject strings.
+type serList struct {
+	Seq uint64     `json:"seq"`
+	Dig []serEntry `json:"dig,omitempty"`
+	Sub []serEntry `json:"sub,omitempty"`
+	Adr []serEntry `json:"adr,omitempty"`
+}
+
+type serEntry struct {
+	Key []byte  `json:"k"`
+	Ibf utc.UTC `json:"ibf,omitempty"` // issued-before cutoff
+	Exp utc.UTC `json:"exp,omitempty"` // expiration of the entry
+}
+
+func (l *List) toSer() *serList {
+	res := &serList{Seq: l.Sequence}
+	for digest, entry := range l.Digests {
+		res.Dig = append(res.Dig, serEntry{Key: bytes.Clone(digest[:]), Ibf: entry.IssuedBefore, Exp: entry.Expires})
+	}
+	for subject, entry := range l.Subjects {
+		res.Sub = append(res.Sub, serEntry{Key: []byte(subject), Ibf: entry.IssuedBefore, Exp: entry.Expires})
+	}
+	for addr, entry := range l.Addresses {
+		res.Adr = append(res.Adr, serEntry{Key: addr.Bytes(), Ibf: entry.IssuedBefore, Exp: entry.Expires})
+	}
+	// sort entries for a deterministic encoding
+	for _, entries := range [][]serEntry{res.Dig, res.Sub, res.Adr} {
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].Key, entries[j].Key) < 0
+		})
+	}
+	return res
+}
+
+func (s *serList) toList() (*List, error) {
+	e := errors.Template("revocation.Decode", errors.K.Invalid)
+
+	res := NewList()
+	res.Sequence = s.Seq
+	for _, se := range s.Dig {
+		if len(se.Key) != sha256.Size {
+			return nil, e("reason", "invalid digest length", "length", len(se.Key))
+		}
+		var digest Digest
+		copy(digest[:], se.Key)
+		res.RevokeDigest(digest, se.entry())
+	}
+	for _, se := range s.Sub {
+		res.RevokeSubject(string(se.Key), se.entry())
+	}
+	for _, se := range s.Adr {
+		if len(se.Key) != common.AddressLength {
+			return nil, e("reason", "invalid address length", "length", len(se.Key))
+		}
+		res.RevokeAddress(common.BytesToAddress(se.Key), se.entry())
+	}
+	return res, nil
+}
+
+func (s serEntry) entry() Entry {
+	return Entry{IssuedBefore: s.Ibf, Expires: s.Exp}
+}
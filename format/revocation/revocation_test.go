@@ -0,0 +1,260 @@
+package revocation_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/duration"
+	"github.com/eluv-io/common-go/format/eat"
+	"github.com/eluv-io/common-go/format/id"
+	"github.com/eluv-io/common-go/format/revocation"
+	"github.com/eluv-io/common-go/util/ethutil"
+	"github.com/eluv-io/utc-go"
+)
+
+var (
+	sid = id.MustParse("ispc2gfzuWxi2krZv2SqkNz3f6UpMbJe")
+	lid = id.MustParse("ilib3RiwiP7UJJiHxFLbkL46BoVfKWrB")
+	qid = id.MustParse("iq__3RiwiP7UJJiHxFLbkL46BoVfKWrB")
+)
+
+func editorSigned(t *testing.T, issuedAt utc.UTC) *eat.Token {
+	sk, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tok, err := eat.Parse(eat.NewEditorSigned(sid, lid, qid).
+		WithIssuedAt(issuedAt).
+		WithExpires(issuedAt.Add(time.Hour)).
+		Sign(sk).
+		MustEncode())
+	require.NoError(t, err)
+	return tok
+}
+
+func TestList(t *testing.T) {
+	now := utc.Now().Truncate(time.Millisecond)
+	tok1 := editorSigned(t, now)
+	tok2 := editorSigned(t, now)
+	tok3 := editorSigned(t, now)
+
+	list := revocation.NewList()
+	list.Sequence = 1
+	require.NoError(t, list.RevokeToken(tok1))
+	list.RevokeSubject(tok2.Subject, revocation.Entry{IssuedBefore: now.Add(time.Second)})
+	list.RevokeAddress(tok3.EthAddr, revocation.Entry{IssuedBefore: now})
+	require.Equal(t, 3, list.Len())
+
+	assertRevoked := func(l *revocation.List, tok *eat.Token, expected bool) {
+		revoked, err := l.IsRevoked(tok)
+		require.NoError(t, err)
+		require.Equal(t, expected, revoked)
+	}
+
+	assertRevoked(list, tok1, true)
+	assertRevoked(list, tok2, true)
+	assertRevoked(list, tok3, false) // issued at the cutoff
+	assertRevoked(list, editorSigned(t, now), false)
+
+	unsigned := eat.New(eat.Types.Anonymous(), eat.Formats.Json())
+	require.Error(t, list.RevokeToken(unsigned))
+
+	t.Run("encode decode", func(t *testing.T) {
+		bts, err := list.Encode()
+		require.NoError(t, err)
+
+		decoded, err := revocation.Decode(bts)
+		require.NoError(t, err)
+		requireEqualLists(t, list, decoded)
+
+		bts2, err := decoded.Encode()
+		require.NoError(t, err)
+		require.Equal(t, bts, bts2)
+
+		_, err = revocation.Decode(bts[:len(bts)-1])
+		require.Error(t, err)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		other := revocation.NewList()
+		other.Sequence = 2
+		other.RevokeAddress(tok3.EthAddr, revocation.Entry{IssuedBefore: now.Add(time.Second)})
+		other.RevokeSubject(tok2.Subject, revocation.Entry{IssuedBefore: now.Add(-time.Second)})
+
+		merged := revocation.NewList()
+		merged.Merge(list)
+		merged.Merge(other)
+		require.EqualValues(t, 2, merged.Sequence)
+		assertRevoked(merged, tok1, true)
+		assertRevoked(merged, tok2, true) // the later cutoff wins
+		assertRevoked(merged, tok3, true)
+
+		// merging is commutative and idempotent
+		merged2 := revocation.NewList()
+		merged2.Merge(other)
+		merged2.Merge(list)
+		merged2.Merge(other)
+		requireEqualLists(t, merged, merged2)
+
+		// no cutoff wins over any cutoff
+		other.RevokeAddress(tok3.EthAddr, revocation.Entry{})
+		require.True(t, other.Addresses[tok3.EthAddr].IssuedBefore.IsZero())
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		pruned := revocation.NewList()
+		pruned.Merge(list)
+		pruned.Prune(now.Add(2 * time.Hour))
+		require.Equal(t, 2, pruned.Len())
+		assertRevoked(pruned, tok1, false)
+		assertRevoked(pruned, tok2, true)
+	})
+}
+
+func requireEqualLists(t *testing.T, expected, actual *revocation.List) {
+	require.Equal(t, expected.Sequence, actual.Sequence)
+	require.Equal(t, expected.Len(), actual.Len())
+	requireEqualEntries(t, expected.Digests, actual.Digests)
+	requireEqualEntries(t, expected.Subjects, actual.Subjects)
+	requireEqualEntries(t, expected.Addresses, actual.Addresses)
+}
+
+func requireEqualEntries[K comparable](t *testing.T, expected, actual map[K]revocation.Entry) {
+	require.Equal(t, len(expected), len(actual))
+	for key, entry := range expected {
+		require.Contains(t, actual, key)
+		require.True(t, entry.IssuedBefore.Equal(actual[key].IssuedBefore))
+		require.True(t, entry.Expires.Equal(actual[key].Expires))
+	}
+}
+
+func TestChecker(t *testing.T) {
+	now := utc.Now().Truncate(time.Millisecond)
+	tok1 := editorSigned(t, now)
+	tok2 := editorSigned(t, now)
+
+	checker := revocation.NewChecker(duration.Spec(time.Hour))
+
+	verifier := eat.NewVerifier(nil).WithRevocationChecker(checker)
+	_, err := verifier.Verify(tok1)
+	require.NoError(t, err)
+
+	list := revocation.NewList()
+	list.Sequence = 5
+	require.NoError(t, list.RevokeToken(tok1))
+	list.RevokeAddress(tok2.EthAddr, revocation.Entry{IssuedBefore: now})
+	checker.Apply(list)
+	require.EqualValues(t, 5, checker.Sequence())
+
+	_, err = verifier.Verify(tok1)
+	require.Error(t, err)
+	require.Equal(t, eat.RejectReasons.Revoked, eat.RejectReasonOf(err))
+
+	// tok2 was issued at the cutoff
+	_, err = verifier.Verify(tok2)
+	require.NoError(t, err)
+
+	update := revocation.NewList()
+	update.Sequence = 6
+	update.RevokeSubject(ethutil.AddressToID(tok2.EthAddr, id.User).String(), revocation.Entry{})
+	checker.Apply(update)
+	require.EqualValues(t, 6, checker.Sequence())
+
+	revoked, err := checker.IsRevoked(tok2)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestCheckerRetention(t *testing.T) {
+	now := utc.Now().Truncate(time.Millisecond)
+	clock := now
+	checker := revocation.NewChecker(duration.Spec(time.Hour)).WithClock(func() utc.UTC { return clock })
+
+	// entries are never evicted for lack of space
+	list := revocation.NewList()
+	var toks []*eat.Token
+	for i := 0; i < 1000; i++ {
+		tok := editorSigned(t, now)
+		toks = append(toks, tok)
+		require.NoError(t, list.RevokeToken(tok))
+	}
+	subject := editorSigned(t, now)
+	cutoff := editorSigned(t, now)
+	list.RevokeSubject(subject.Subject, revocation.Entry{})
+	list.RevokeAddress(cutoff.EthAddr, revocation.Entry{IssuedBefore: now.Add(time.Second)})
+	checker.Apply(list)
+	require.Equal(t, 1002, checker.Len())
+	for _, tok := range append(toks, subject, cutoff) {
+		revoked, err := checker.IsRevoked(tok)
+		require.NoError(t, err)
+		require.True(t, revoked)
+	}
+
+	// entries are pruned once they cannot revoke any valid token anymore: the token entries expire with their tokens
+	// after an hour, the cutoff entry after the max validity period
+	clock = now.Add(2 * time.Hour)
+	revoked, err := checker.IsRevoked(toks[0])
+	require.NoError(t, err)
+	require.False(t, revoked) // ignored before being evicted
+	require.Equal(t, 1002, checker.Len())
+	checker.Apply(revocation.NewList())
+	require.Equal(t, 1, checker.Len())
+	revoked, err = checker.IsRevoked(subject)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestTokenDigestMalleability(t *testing.T) {
+	now := utc.Now().Truncate(time.Millisecond)
+	tok := editorSigned(t, now)
+
+	list := revocation.NewList()
+	require.NoError(t, list.RevokeToken(tok))
+
+	// re-encode the token with an equivalent signature
+	reencode := func(adjust func(sig []byte)) *eat.Token {
+		s := tok.String()
+		prefix := tok.Type.Prefix + tok.SigType.Prefix + tok.Format.Prefix
+		bts, err := base58.Decode(s[len(prefix):])
+		require.NoError(t, err)
+		adjust(bts[:65])
+		res, err := eat.Parse(prefix + base58.Encode(bts))
+		require.NoError(t, err)
+		require.NotEqual(t, s, res.String())
+		require.NoError(t, res.VerifySignatureFrom(tok.EthAddr))
+		return res
+	}
+
+	for name, adjust := range map[string]func(sig []byte){
+		"v": func(sig []byte) {
+			// recovery ID as 0/1 instead of 27/28 or vice versa
+			if sig[64] < 27 {
+				sig[64] += 27
+			} else {
+				sig[64] -= 27
+			}
+		},
+		"s": func(sig []byte) {
+			// s replaced with n-s and flipped recovery ID
+			n := crypto.S256().Params().N
+			s := new(big.Int).SetBytes(sig[32:64])
+			new(big.Int).Sub(n, s).FillBytes(sig[32:64])
+			sig[64] ^= 1
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reencoded := reencode(adjust)
+			digest, ok := revocation.TokenDigest(reencoded)
+			require.True(t, ok)
+			expected, _ := revocation.TokenDigest(tok)
+			require.Equal(t, expected, digest)
+
+			revoked, err := list.IsRevoked(reencoded)
+			require.NoError(t, err)
+			require.True(t, revoked)
+		})
+	}
+}
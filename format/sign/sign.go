@@ -2,12 +2,16 @@ package sign
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"fmt"
 
+	"github.com/ChainSafe/go-schnorrkel"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/mr-tron/base58/base58"
 
+	"github.com/eluv-io/common-go/format/keys"
 	"github.com/eluv-io/errors-go"
 	"github.com/eluv-io/log-go"
 )
@@ -108,6 +112,10 @@ const (
 const codeLen = 1
 const prefixLen = 7
 
+// SR25519SigningContext is the signing context used for SR25519 signatures. It is the same context that is used by
+// substrate-based wallets.
+var SR25519SigningContext = []byte("substrate")
+
 var codeToPrefix = map[Code]string{}
 var prefixToCode = map[string]Code{
 	"sunk___": UNKNOWN,
@@ -223,7 +231,7 @@ func (sig *Sig) SignerAddressFromHash(hash []byte) (common.Address, error) {
 	e := errors.Template("SignerAddress", errors.K.Invalid)
 
 	switch sig.Code() {
-	case ES256K, EIP191Personal:
+	case ES256K, EIP191Personal, EIP712TypedData:
 		// continue
 	default:
 		return common.Address{}, e("reason", "address recovery not available for signature type", "sig_type", sig.Code())
@@ -240,6 +248,73 @@ func (sig *Sig) SignerAddressFromHash(hash []byte) (common.Address, error) {
 	return crypto.PubkeyToAddress(*recoverTrustPK), nil
 }
 
+// Verify verifies that this signature of the given message was produced with the secret key corresponding to the given
+// public key. Only signature types without signer recovery are supported:
+//   - ED25519 with a keys.ED25519PublicKey
+//   - SR25519 with a keys.SR25519PublicKey - using the SR25519SigningContext
+//
+// Returns nil if the signature is valid, an error otherwise.
+func (sig Sig) Verify(publicKey keys.Key, msg []byte) error {
+	e := errors.Template("verify signature", errors.K.Permission, "sig_type", sig.Code(), "public_key", publicKey)
+
+	if sig.IsNil() {
+		return e("reason", "signature is nil")
+	}
+
+	var expectedKeyCode keys.Code
+	switch sig.Code() {
+	case ED25519:
+		expectedKeyCode = keys.ED25519PublicKey
+	case SR25519:
+		expectedKeyCode = keys.SR25519PublicKey
+	default:
+		return e(errors.K.Invalid, "reason", "verification with public key not available for signature type")
+	}
+
+	err := publicKey.AssertCode(expectedKeyCode)
+	if err == nil {
+		err = publicKey.Validate()
+	}
+	if err != nil {
+		return e(errors.K.Invalid, err, "reason", "invalid public key")
+	}
+	if len(sig.Bytes()) != sig.Code().SigLen() {
+		return e(errors.K.Invalid, "reason", "invalid signature length",
+			"expected", sig.Code().SigLen(),
+			"actual", len(sig.Bytes()))
+	}
+
+	switch sig.Code() {
+	case ED25519:
+		if !ed25519.Verify(publicKey.Bytes(), msg, sig.Bytes()) {
+			return e("reason", "signature mismatch")
+		}
+	case SR25519:
+		var pkBytes [schnorrkel.PublicKeySize]byte
+		copy(pkBytes[:], publicKey.Bytes())
+		pk, err := schnorrkel.NewPublicKey(pkBytes)
+		if err != nil {
+			return e(errors.K.Invalid, err, "reason", "invalid public key")
+		}
+		var sigBytes [schnorrkel.SignatureSize]byte
+		copy(sigBytes[:], sig.Bytes())
+		srSig := &schnorrkel.Signature{}
+		err = srSig.Decode(sigBytes)
+		if err != nil {
+			return e(errors.K.Invalid, err, "reason", "invalid signature")
+		}
+		ok, err := pk.Verify(srSig, schnorrkel.NewSigningContext(SR25519SigningContext, msg))
+		if err != nil {
+			return e(err)
+		}
+		if !ok {
+			return e("reason", "signature mismatch")
+		}
+	}
+
+	return nil
+}
+
 // EthAdjustBytes remains for backward compatibility
 // deprecated - use standalone func EthAdjustBytes()
 func (sig *Sig) EthAdjustBytes() []byte {
@@ -296,7 +371,7 @@ func EthAdjustBytes(code Code, bts []byte) []byte {
 	if len(bts) == 0 {
 		return []byte{}
 	}
-	if (code != ES256K && code != EIP191Personal) || len(bts) <= 64 || bts[64] < 4 {
+	if (code != ES256K && code != EIP191Personal && code != EIP712TypedData) || len(bts) <= 64 || bts[64] < 4 {
 		return bts
 	} else {
 		adjSigBytes := make([]byte, 65)
@@ -313,21 +388,23 @@ func HashEIP191Personal(message []byte) []byte {
 	return crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), string(message))))
 }
 
-/* PENDING(LUK): Typed Data hashing will look something like this...
 // HashTypedData hashes EIP-712 conforming typed data
 // hash = keccak256("\x19${byteVersion}${domainSeparator}${hashStruct(message)}")
 // Based on github.com/ethereum/go-ethereum@v1.9.11/signer/core/signed_data.go:316 SignTypedData()
-func (s *SignatureHelper) HashTypedData(typedData core.TypedData) (hexutil.Bytes, error) {
+// See https://eips.ethereum.org/EIPS/eip-712
+func HashTypedData(typedData *apitypes.TypedData) ([]byte, error) {
+	e := errors.Template("HashTypedData", errors.K.Invalid)
+	if typedData == nil {
+		return nil, e("reason", "typed data is nil")
+	}
 	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
 	if err != nil {
-		return nil, err
+		return nil, e(err)
 	}
 	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
 	if err != nil {
-		return nil, err
+		return nil, e(err, "primary_type", typedData.PrimaryType)
 	}
 	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
-	hsh := crypto.Keccak256(rawData)
-	return hsh, nil
+	return crypto.Keccak256(rawData), nil
 }
-*/
@@ -0,0 +1,110 @@
+package sign_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/ChainSafe/go-schnorrkel"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/keys"
+	"github.com/eluv-io/common-go/format/sign"
+)
+
+// TestHashTypedData uses the example of the EIP-712 specification
+// See https://github.com/ethereum/EIPs/blob/master/assets/eip-712/Example.js
+func TestHashTypedData(t *testing.T) {
+	typedData := &apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Person": {
+				{Name: "name", Type: "string"},
+				{Name: "wallet", Type: "address"},
+			},
+			"Mail": {
+				{Name: "from", Type: "Person"},
+				{Name: "to", Type: "Person"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		PrimaryType: "Mail",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		},
+		Message: apitypes.TypedDataMessage{
+			"from": map[string]interface{}{
+				"name":   "Cow",
+				"wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+			},
+			"to": map[string]interface{}{
+				"name":   "Bob",
+				"wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB",
+			},
+			"contents": "Hello, Bob!",
+		},
+	}
+
+	hsh, err := sign.HashTypedData(typedData)
+	require.NoError(t, err)
+	require.Equal(t, "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2", hexutil.Encode(hsh))
+
+	// signature of the spec example (v = 28)
+	sig := sign.NewSig(sign.EIP712TypedData, hexutil.MustDecode(
+		"0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d"+
+			"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562"+
+			"1c"))
+	addr, err := sig.SignerAddressFromHash(hsh)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"), addr)
+
+	sk, err := crypto.HexToECDSA("c85ef7d79691fe79573b1a7064c19c1a9819ebdbd1faaab1a8ec92344438aaf4")
+	require.NoError(t, err)
+	require.Equal(t, addr, crypto.PubkeyToAddress(sk.PublicKey))
+
+	_, err = sign.HashTypedData(nil)
+	require.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	msg := []byte("some message")
+
+	edSK := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	edPK := keys.New(keys.ED25519PublicKey, edSK.Public().(ed25519.PublicKey))
+	edSig := sign.NewSig(sign.ED25519, ed25519.Sign(edSK, msg))
+	require.NoError(t, edSig.Verify(edPK, msg))
+	require.Error(t, edSig.Verify(edPK, []byte("other message")))
+
+	var seed [schnorrkel.MiniSecretKeySize]byte
+	copy(seed[:], bytes.Repeat([]byte{2}, len(seed)))
+	msk, err := schnorrkel.NewMiniSecretKeyFromRaw(seed)
+	require.NoError(t, err)
+	srSig, err := msk.ExpandEd25519().Sign(schnorrkel.NewSigningContext(sign.SR25519SigningContext, msg))
+	require.NoError(t, err)
+	srSigBytes := srSig.Encode()
+	srPKBytes := msk.Public().Encode()
+	srPK := keys.New(keys.SR25519PublicKey, srPKBytes[:])
+	sig := sign.NewSig(sign.SR25519, srSigBytes[:])
+	require.NoError(t, sig.Verify(srPK, msg))
+	require.Error(t, sig.Verify(srPK, []byte("other message")))
+
+	// key type mismatch
+	require.Error(t, edSig.Verify(srPK, msg))
+	require.Error(t, sig.Verify(edPK, msg))
+
+	// not supported for ethereum signatures
+	require.Error(t, sign.NewSig(sign.ES256K, make([]byte, 65)).Verify(edPK, msg))
+}
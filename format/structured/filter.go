@@ -9,7 +9,9 @@ import (
 	"github.com/eluv-io/errors-go"
 )
 
-// Query queries the given target data structure.
+// Query queries the given target data structure with a JSONPath expression - see
+// GlobQuery for a query based on glob paths that retains the structure of the
+// target.
 func Query(target interface{}, query string) (interface{}, error) {
 	filter, err := NewFilter(query)
 	if err != nil {
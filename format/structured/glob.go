@@ -2,12 +2,21 @@ package structured
 
 import (
 	"encoding/json"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/eluv-io/common-go/util/jsonutil"
 )
 
-const wildcard = "*"
+const (
+	wildcard          = "*"
+	recursiveWildcard = "**"
+	globSpecialChars  = `*?\`
+)
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`)
 
 // FilterGlob filters the given target according to the provided "select" and
 // "remove" paths. Only elements at "select" paths are included in the result
@@ -18,8 +27,19 @@ const wildcard = "*"
 // segments, e.g. /a/*/b or /a/*/*/b/*/c. A wildcard therefore represents all
 // keys in a map or all indices in a slice.
 //
-// Partial path segments (e.g. /a/prefix*/*suffix) are not supported. Neither is
-// the single character wildcard '?'.
+// Path segments may also be partial glob patterns, where '*' matches any
+// sequence of characters and '?' matches a single character, e.g.
+// /a/prefix*/b, /a/*suffix or /a/title-??. Array elements are matched by their
+// index, e.g. /a/1? matches the elements 10 to 19 of the slice at /a.
+//
+// The recursive wildcard '**' matches zero or more path segments, e.g.
+// /**/title matches the "title" elements at any depth and /a/**/c matches /a/c,
+// /a/b/c, /a/b/b/c, etc.
+//
+// A literal '*', '?' or '\' in a path segment is escaped with a backslash, e.g.
+// /a/x\* selects the element with key "x*" - see EscapeGlob. Note that segments
+// with unescaped '*' or '?' characters were treated as literal keys before the
+// introduction of partial patterns, unless they consisted of a single '*'.
 //
 // If no select paths are specified, a select "/" is used (i.e. selecting the
 // full target) and then applying the removals. If neither select nor remove
@@ -49,6 +69,166 @@ func FilterGlob(target interface{}, selectPaths, removePaths []Path) interface{}
 	return res
 }
 
+// EscapeGlob escapes the glob special characters '*', '?' and '\' in the given
+// path segment with a backslash, so that it matches the segment literally.
+func EscapeGlob(seg string) string {
+	if !strings.ContainsAny(seg, globSpecialChars) {
+		return seg
+	}
+	return globEscaper.Replace(seg)
+}
+
+// unescapeGlob returns the literal key of a path segment that is not a pattern,
+// removing the backslashes of escaped special characters. Backslashes that are
+// not followed by a special character are retained.
+func unescapeGlob(seg string) string {
+	if !strings.Contains(seg, `\`) {
+		return seg
+	}
+	sb := strings.Builder{}
+	for idx := 0; idx < len(seg); idx++ {
+		if isGlobEscape(seg, idx) {
+			idx++
+		}
+		sb.WriteByte(seg[idx])
+	}
+	return sb.String()
+}
+
+// isGlobEscape returns true if the given pattern has an escaping backslash at
+// the given position.
+func isGlobEscape(pattern string, idx int) bool {
+	return pattern[idx] == '\\' &&
+		idx+1 < len(pattern) &&
+		strings.IndexByte(globSpecialChars, pattern[idx+1]) >= 0
+}
+
+// MatchGlob returns true if the element at the given path is selected by the
+// given glob path, i.e. if FilterGlob with the glob as single select path
+// retains the element: the glob matches the path itself or one of its parents.
+//
+// As in FilterGlob, a wildcard '*' in the glob matches any single path segment,
+// partial patterns like 'prefix*' or 'a?c' match the segments they describe,
+// the recursive wildcard '**' matches zero or more segments, and the empty glob
+// "/" matches all paths.
+func MatchGlob(glob, path Path) bool {
+	if len(glob) == 0 {
+		return true
+	}
+	if glob[0] == recursiveWildcard {
+		for idx := 0; idx <= len(path); idx++ {
+			if MatchGlob(glob[1:], path[idx:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || !matchSegment(glob[0], path[0]) {
+		return false
+	}
+	return MatchGlob(glob[1:], path[1:])
+}
+
+// isPattern returns true if the given path segment is a glob pattern, i.e. if
+// it contains an unescaped '*' or '?'.
+func isPattern(seg string) bool {
+	if !strings.ContainsAny(seg, "*?") {
+		return false
+	}
+	for idx := 0; idx < len(seg); idx++ {
+		switch {
+		case isGlobEscape(seg, idx):
+			idx++
+		case seg[idx] == '*' || seg[idx] == '?':
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalSegment returns the canonical form of the given path segment: the
+// pattern itself or the escaped literal key. Literal segments with and without
+// superfluous escapes therefore map to the same filter node.
+func canonicalSegment(seg string) string {
+	if isPattern(seg) {
+		return seg
+	}
+	return EscapeGlob(unescapeGlob(seg))
+}
+
+// matchSegment returns true if the given path segment matches the glob
+// pattern: '*' matches any sequence of characters (including the empty one),
+// '?' matches any single character, and a backslash escapes the following
+// special character.
+func matchSegment(pattern, seg string) bool {
+	px, sx := 0, 0
+	// position of the last '*' in the pattern and of the corresponding match
+	// in the segment, used for backtracking
+	starPx, starSx := -1, 0
+	for sx < len(seg) {
+		if px < len(pattern) {
+			switch c := pattern[px]; c {
+			case '*':
+				starPx, starSx = px, sx
+				px++
+				continue
+			case '?':
+				_, size := utf8.DecodeRuneInString(seg[sx:])
+				px++
+				sx += size
+				continue
+			default:
+				escaped := isGlobEscape(pattern, px)
+				if escaped {
+					c = pattern[px+1]
+				}
+				if c == seg[sx] {
+					px++
+					if escaped {
+						px++
+					}
+					sx++
+					continue
+				}
+			}
+		}
+		if starPx < 0 {
+			return false
+		}
+		// let the last '*' match one more character
+		_, size := utf8.DecodeRuneInString(seg[starSx:])
+		starSx += size
+		px, sx = starPx+1, starSx
+	}
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+	return px == len(pattern)
+}
+
+// normalizeGlob collapses consecutive recursive wildcards and removes a
+// trailing recursive wildcard, since selecting (or removing) an element also
+// selects (or removes) all its descendants.
+func normalizeGlob(path Path) Path {
+	var res Path
+	for idx, seg := range path {
+		if seg == recursiveWildcard && (idx+1 == len(path) || path[idx+1] == recursiveWildcard) {
+			if res == nil {
+				res = make(Path, 0, len(path))
+				res = append(res, path[:idx]...)
+			}
+			continue
+		}
+		if res != nil {
+			res = append(res, seg)
+		}
+	}
+	if res == nil {
+		return path
+	}
+	return res
+}
+
 func createFilter(selectPaths, removePaths []Path) *globFilter {
 	res := &globFilter{
 		typ: typVoid,
@@ -59,6 +239,7 @@ func createFilter(selectPaths, removePaths []Path) *globFilter {
 	}
 
 	for _, path := range selectPaths {
+		path = normalizeGlob(path)
 		curr := res
 		pathLen := len(path)
 		if pathLen == 0 {
@@ -74,6 +255,7 @@ func createFilter(selectPaths, removePaths []Path) *globFilter {
 	}
 
 	for _, path := range removePaths {
+		path = normalizeGlob(path)
 		curr := res
 		pathLen := len(path)
 		if pathLen == 0 {
@@ -106,9 +288,22 @@ const (
 
 // globFilter is a node in the filter tree built from select/remove paths.
 type globFilter struct {
-	seg      string // just needed by unit test for simpler filter construction
-	typ      filterType
-	children map[string]*globFilter
+	seg       string // just needed by unit test for simpler filter construction
+	typ       filterType
+	children  map[string]*globFilter
+	patterns  []*globFilter // the children with glob patterns, including wildcards
+	recursive []*globFilter // the recursive wildcard nodes that continue matching at the next level
+}
+
+func newGlobFilter(seg string, typ filterType) *globFilter {
+	res := &globFilter{
+		seg: canonicalSegment(seg),
+		typ: typ,
+	}
+	if seg == recursiveWildcard {
+		res.recursive = []*globFilter{res}
+	}
+	return res
 }
 
 func (f *globFilter) MarshalJSON() ([]byte, error) {
@@ -127,23 +322,23 @@ func (f *globFilter) String() string {
 }
 
 func (f *globFilter) Add(seg string, typ filterType) *globFilter {
+	seg = canonicalSegment(seg)
 	isWildcard := seg == wildcard
 	if isWildcard && typ != typVoid {
 		f.children = nil
+		f.patterns = nil
 	} else if child, has := f.children[seg]; has {
 		if typ != typVoid {
 			child.typ = typ
 			child.children = nil
+			child.patterns = nil
 		}
 		return child
 	}
 	if typ == typVoid {
 		typ = f.typ
 	}
-	return f.AddChild(&globFilter{
-		seg: seg,
-		typ: typ,
-	})
+	return f.AddChild(newGlobFilter(seg, typ))
 }
 
 func (f *globFilter) AddChild(child *globFilter) *globFilter {
@@ -151,9 +346,94 @@ func (f *globFilter) AddChild(child *globFilter) *globFilter {
 		f.children = map[string]*globFilter{}
 	}
 	f.children[child.seg] = child
+	if isPattern(child.seg) {
+		f.patterns = append(f.patterns, child)
+	}
 	return child
 }
 
+// match returns the filter for the child element with the given key, or nil if
+// no filter applies to it. If multiple filter nodes match the key - e.g. a
+// literal segment and a wildcard - they are merged into a new filter node.
+func (f *globFilter) match(key string) *globFilter {
+	var res *globFilter
+	add := func(child *globFilter) {
+		if res == nil {
+			res = child
+		} else {
+			res = mergeFilters(res, child)
+		}
+	}
+	f.matchChildren(key, add)
+	for _, rec := range f.recursive {
+		// the recursive wildcard consumes the key and continues at the next level
+		add(rec)
+	}
+	return res
+}
+
+func (f *globFilter) matchChildren(key string, add func(child *globFilter)) {
+	// literal children are stored with their escaped key, which never collides with a pattern
+	if child, found := f.children[EscapeGlob(key)]; found {
+		add(child)
+	}
+	for _, child := range f.patterns {
+		if child.seg == recursiveWildcard {
+			// the recursive wildcard either consumes the key or matches zero
+			// segments, in which case its children apply to the key
+			add(child)
+			child.matchChildren(key, add)
+		} else if matchSegment(child.seg, key) {
+			add(child)
+		}
+	}
+}
+
+// mergeFilters merges the two given filter nodes into a new node without
+// modifying them. Removal takes precedence over selection.
+func mergeFilters(a, b *globFilter) *globFilter {
+	if a == b || a.typ == typRemove {
+		return a
+	}
+	if b.typ == typRemove {
+		return b
+	}
+	res := &globFilter{
+		seg: a.seg,
+		typ: a.typ,
+	}
+	if b.typ == typSelect {
+		res.typ = typSelect
+	}
+	res.recursive = append(res.recursive, a.recursive...)
+	for _, rec := range b.recursive {
+		if !containsFilter(res.recursive, rec) {
+			res.recursive = append(res.recursive, rec)
+		}
+	}
+	for seg, child := range a.children {
+		if other, found := b.children[seg]; found {
+			child = mergeFilters(child, other)
+		}
+		res.AddChild(child)
+	}
+	for seg, child := range b.children {
+		if _, found := a.children[seg]; !found {
+			res.AddChild(child)
+		}
+	}
+	return res
+}
+
+func containsFilter(filters []*globFilter, f *globFilter) bool {
+	for _, filter := range filters {
+		if filter == f {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *globFilter) Filter(target interface{}) interface{} {
 	res, _ := f.filter(target, false)
 	return res
@@ -171,37 +451,28 @@ func (f *globFilter) filter(target interface{}, selectAll bool) (interface{}, bo
 
 	node := dereference(target)
 
+	iterateAll := selectAll || len(f.patterns) > 0 || len(f.recursive) > 0
+
 	switch t := node.(type) {
 	case map[string]interface{}:
 		res := make(map[string]interface{}, len(t))
-		wcf, hasWildcard := f.children[wildcard]
-		if hasWildcard {
-			selectAll = selectAll || wcf.typ == typSelect
-		}
-		if hasWildcard || selectAll {
+		if iterateAll {
 			for k, v := range t {
-				retainWC := true
-				if hasWildcard {
-					if nv, retain := wcf.filter(v, selectAll); retain {
-						retainWC = true
-						v = nv
-					} else {
-						retainWC = false
-					}
-				}
-				if child, found := f.children[k]; found {
-					if nv, retain := child.filter(v, selectAll); retain {
-						res[k] = nv
+				child := f.match(k)
+				if child == nil {
+					if selectAll {
+						res[k] = v
 					}
 					continue
 				}
-				if retainWC {
-					res[k] = v
+				if nv, retain := child.filter(v, selectAll); retain {
+					res[k] = nv
 				}
 			}
 		} else {
-			// no wildcard
+			// only literal segments
 			for k, child := range f.children {
+				k = unescapeGlob(k)
 				if v, has := t[k]; has {
 					if nv, retain := child.filter(v, selectAll); retain {
 						res[k] = nv
@@ -215,38 +486,35 @@ func (f *globFilter) filter(target interface{}, selectAll bool) (interface{}, bo
 		return nil, false
 	case []interface{}:
 		res := make([]interface{}, 0, len(t))
-		wcf, hasWildcard := f.children[wildcard]
-		if hasWildcard {
-			selectAll = selectAll || wcf.typ == typSelect
-		}
-		var retain bool
-		if hasWildcard || selectAll {
+		if iterateAll {
 			for i, v := range t {
-				if hasWildcard {
-					v, retain = wcf.filter(v, selectAll)
-					if !retain {
-						continue
+				child := f.match(strconv.Itoa(i))
+				if child == nil {
+					if selectAll {
+						res = append(res, v)
 					}
+					continue
 				}
-				if child, found := f.children[strconv.Itoa(i)]; found {
-					v, retain = child.filter(v, selectAll)
-					if !retain {
-						continue
-					}
+				if nv, retain := child.filter(v, selectAll); retain {
+					res = append(res, nv)
 				}
-				res = append(res, v)
 			}
 		} else {
-			// no wildcard
+			// only literal segments: retain the order of the slice elements
+			children := make(map[int]*globFilter, len(f.children))
+			indices := make([]int, 0, len(f.children))
 			for k, child := range f.children {
-				i, err := strconv.Atoi(k)
-				if err != nil || i >= len(t) {
+				i, err := strconv.Atoi(unescapeGlob(k))
+				if err != nil || i < 0 || i >= len(t) {
 					continue
 				}
-				v := t[i]
-				v, retain = child.filter(v, selectAll)
-				if retain {
-					res = append(res, v)
+				children[i] = child
+				indices = append(indices, i)
+			}
+			sort.Ints(indices)
+			for _, i := range indices {
+				if nv, retain := children[i].filter(t[i], selectAll); retain {
+					res = append(res, nv)
 				}
 			}
 		}
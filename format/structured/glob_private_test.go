@@ -170,6 +170,33 @@ func TestGlobCreateFilter(t *testing.T) {
 					)),
 			),
 		},
+		{
+			name: "patterns",
+			args: args{
+				selectPaths: []Path{{"a", "b*"}, {"a", "?c"}},
+				removePaths: []Path{{"a", "b*", "d"}},
+			},
+			want: root(
+				gf("a", typVoid,
+					gf("b*", typSelect,
+						gf("d", typRemove),
+					),
+					gf("?c", typSelect),
+				),
+			),
+		},
+		{
+			name: "recursive",
+			args: args{
+				selectPaths: []Path{{"**", "**", "a"}, {"b", "**"}},
+			},
+			want: root(
+				gf("**", typVoid,
+					gf("a", typSelect),
+				),
+				gf("b", typSelect),
+			),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -179,20 +206,66 @@ func TestGlobCreateFilter(t *testing.T) {
 	}
 }
 
+func TestGlobEscape(t *testing.T) {
+	for _, seg := range []string{"", "abc", "a*", "?", `a\b`, `\*`, "**"} {
+		escaped := EscapeGlob(seg)
+		require.False(t, isPattern(escaped), seg)
+		require.Equal(t, seg, unescapeGlob(escaped), seg)
+		require.True(t, matchSegment(escaped, seg), seg)
+	}
+	require.True(t, isPattern(`a\**`))
+	require.True(t, isPattern(`\\*`))
+	require.False(t, isPattern(`a\*`))
+	require.Equal(t, `a\b`, unescapeGlob(`a\b`))
+	require.Equal(t, canonicalSegment(`a\b`), canonicalSegment(`a\\b`))
+}
+
 func root(children ...*globFilter) *globFilter {
 	return gf("", typVoid, children...)
 }
 
 func gf(seg string, typ filterType, children ...*globFilter) *globFilter {
-	res := &globFilter{
-		seg: seg,
-		typ: typ,
-	}
+	res := newGlobFilter(seg, typ)
 	for _, child := range children {
-		if res.children == nil {
-			res.children = map[string]*globFilter{}
-		}
-		res.children[child.seg] = child
+		res.AddChild(child)
 	}
 	return res
 }
+
+func TestGlobMatchSegment(t *testing.T) {
+	tests := []struct {
+		pattern string
+		seg     string
+		match   bool
+	}{
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*", "a", true},
+		{"a*", "abc", true},
+		{"a*", "ba", false},
+		{"*c", "abc", true},
+		{"*c", "abcd", false},
+		{"a*c", "ac", true},
+		{"a*c", "abcbc", true},
+		{"a*c", "abcb", false},
+		{"a?c", "abc", true},
+		{"a?c", "äöc", false},
+		{"a?c", "aöc", true},
+		{"??", "a", false},
+		{"*?", "", false},
+		{"a**b", "ab", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{`a\?*`, "a?b", true},
+		{`a\?*`, "ab", false},
+		{`a\\*`, `a\b`, true},
+		{`a\b*`, `a\bc`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.seg, func(t *testing.T) {
+			require.Equal(t, test.match, matchSegment(test.pattern, test.seg))
+		})
+	}
+}
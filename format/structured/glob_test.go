@@ -780,6 +780,176 @@ func TestFilterGlob(t *testing.T) {
 							]
 					`),
 		},
+		{
+			name: "partial-segments",
+			args: args{
+				target: tc.parse(`
+							{
+							  "title-1": {"name": "one", "size": 1},
+							  "title-2": {"name": "two", "size": 2},
+							  "title-10": {"name": "ten", "size": 10},
+							  "other": {"name": "other", "size": 0},
+							  "list": ["a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"]
+							}
+						`),
+				selectPaths: []structured.Path{
+					structured.ParsePath("/title-?/name"),
+					structured.ParsePath("/*-10"),
+					structured.ParsePath("/list/1?"),
+				},
+				removePaths: []structured.Path{
+					structured.ParsePath("/*/s*e"),
+				},
+			},
+			want: tc.parse(`
+							{
+							  "title-1": {"name": "one"},
+							  "title-2": {"name": "two"},
+							  "title-10": {"name": "ten"},
+							  "list": ["k", "l"]
+							}
+						`),
+		},
+		{
+			name: "escaped-segments",
+			args: args{
+				target: tc.parse(`
+							{
+							  "x*": {"a?b": 1, "axb": 2},
+							  "xy": {"a?b": 3},
+							  "c\\d": 4,
+							  "e": 5
+							}
+						`),
+				selectPaths: []structured.Path{
+					{`x\*`},
+					{`c\\d`},
+				},
+				removePaths: []structured.Path{
+					{"*", `a\?b`},
+				},
+			},
+			want: tc.parse(`
+							{
+							  "x*": {"axb": 2},
+							  "c\\d": 4
+							}
+						`),
+		},
+		{
+			name: "recursive-wildcard",
+			args: args{
+				target: tc.parse(`
+							{
+							  "title": "root",
+							  "a": {
+							    "title": "a",
+							    "b": [
+							      {"title": "b0", "other": "x"},
+							      {"c": {"title": "c", "other": "y"}}
+							    ]
+							  },
+							  "other": "z"
+							}
+						`),
+				selectPaths: []structured.Path{
+					structured.ParsePath("/**/title"),
+				},
+			},
+			want: tc.parse(`
+							{
+							  "title": "root",
+							  "a": {
+							    "title": "a",
+							    "b": [
+							      {"title": "b0"},
+							      {"c": {"title": "c"}}
+							    ]
+							  }
+							}
+						`),
+		},
+		{
+			name: "recursive-wildcard-remove",
+			args: args{
+				target: tc.parse(`
+							{
+							  "a": {
+							    "secret": 1,
+							    "b": {"secret": 2, "c": {"secret": 3, "d": 4}},
+							    "e": 5
+							  },
+							  "secret": 6
+							}
+						`),
+				selectPaths: []structured.Path{
+					structured.ParsePath("/a/**"),
+				},
+				removePaths: []structured.Path{
+					structured.ParsePath("/**/secret"),
+				},
+			},
+			want: tc.parse(`
+							{
+							  "a": {
+							    "b": {"c": {"d": 4}},
+							    "e": 5
+							  }
+							}
+						`),
+		},
+		{
+			name: "recursive-wildcard-middle",
+			args: args{
+				target: tc.parse(`
+							{
+							  "a": {
+							    "c": 1,
+							    "b": {"c": 2, "d": 3},
+							    "x": {"y": {"c": 4}, "z": 5}
+							  },
+							  "c": 6
+							}
+						`),
+				selectPaths: []structured.Path{
+					structured.ParsePath("/a/**/c"),
+				},
+			},
+			want: tc.parse(`
+							{
+							  "a": {
+							    "c": 1,
+							    "b": {"c": 2},
+							    "x": {"y": {"c": 4}}
+							  }
+							}
+						`),
+		},
+		{
+			name: "overlapping-literal-and-wildcard",
+			args: args{
+				target: tc.parse(`
+							{
+							  "a": {
+							    "b": {"x": 1, "y": 2, "z": 3},
+							    "c": {"x": 4, "y": 5, "z": 6}
+							  }
+							}
+						`),
+				selectPaths: []structured.Path{
+					structured.ParsePath("/a/*/x"),
+					structured.ParsePath("/a/b/y"),
+				},
+			},
+			want: tc.parse(`
+							{
+							  "a": {
+							    "b": {"x": 1, "y": 2},
+							    "c": {"x": 4}
+							  }
+							}
+						`),
+		},
 		{
 			name: "search-offerings",
 			args: args{
@@ -876,3 +1046,52 @@ func (tc *tctx) parse(s string) interface{} {
 	tc.NoError(err)
 	return res
 }
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"/", "/", true},
+		{"/", "/a/b", true},
+		{"/a", "/a", true},
+		{"/a", "/a/b/c", true},
+		{"/a", "/b", false},
+		{"/a/b", "/a", false},
+		{"/a/*/c", "/a/b/c", true},
+		{"/a/*/c", "/a/b/c/d", true},
+		{"/a/*/c", "/a/b/d", false},
+		{"/a/*", "/a", false},
+		{"/*/*", "/a/b", true},
+		{"/a*", "/abc", true},
+		{"/a*", "/bac", false},
+		{"/*c/d", "/abc/d", true},
+		{"/a?c", "/abc", true},
+		{"/a?c", "/ac", false},
+		{"/a*b*c", "/axxbyyc/d", true},
+		{"/a*b*c", "/axxbyy", false},
+		{"/**", "/a/b", true},
+		{"/**/c", "/c", true},
+		{"/**/c", "/a/b/c", true},
+		{"/**/c", "/a/b/c/d", true},
+		{"/**/c", "/a/b", false},
+		{"/a/**/c", "/a/c", true},
+		{"/a/**/c", "/a/b/b/c", true},
+		{"/a/**/c", "/b/c", false},
+		{"/a/**", "/a", true},
+	}
+	for _, test := range tests {
+		t.Run(test.glob+" "+test.path, func(t *testing.T) {
+			glob := structured.ParsePath(test.glob)
+			path := structured.ParsePath(test.path)
+			require.Equal(t, test.match, structured.MatchGlob(glob, path))
+
+			// consistent with FilterGlob
+			target := structured.NewValue(nil, nil)
+			require.NoError(t, target.Set(path, "val"))
+			filtered := structured.Wrap(structured.FilterGlob(target.Data, []structured.Path{glob}, nil))
+			require.Equal(t, test.match, filtered.Get(path...).Data == "val")
+		})
+	}
+}
@@ -0,0 +1,499 @@
+package structured
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eluv-io/errors-go"
+)
+
+// PatchOpType is the type of a JSON Patch operation as defined in RFC 6902.
+type PatchOpType string
+
+// PO defines the JSON Patch operation types.
+var PO = struct {
+	Add     PatchOpType
+	Remove  PatchOpType
+	Replace PatchOpType
+	Move    PatchOpType
+	Copy    PatchOpType
+	Test    PatchOpType
+}{
+	Add:     "add",
+	Remove:  "remove",
+	Replace: "replace",
+	Move:    "move",
+	Copy:    "copy",
+	Test:    "test",
+}
+
+// PatchOp is a JSON Patch operation as defined in RFC 6902. Paths are marshaled as JSON pointers as defined in RFC 6901:
+// the empty path denotes the root of the document and is marshaled as the empty string, while the pointer "/" denotes
+// the member with the empty key "" of the root object.
+type PatchOp struct {
+	Op    PatchOpType `json:"op"`
+	Path  Path        `json:"path"`
+	From  Path        `json:"from,omitempty"`  // the source path of move and copy operations
+	Value interface{} `json:"value,omitempty"` // the value of add, replace and test operations
+}
+
+// jsonPatchOp is the JSON representation of a PatchOp.
+type jsonPatchOp struct {
+	Op    PatchOpType      `json:"op"`
+	Path  string           `json:"path"`
+	From  *string          `json:"from,omitempty"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+func (o *PatchOp) MarshalJSON() ([]byte, error) {
+	res := map[string]interface{}{
+		"op":   o.Op,
+		"path": formatPointer(o.Path),
+	}
+	switch o.Op {
+	case PO.Add, PO.Replace, PO.Test:
+		res["value"] = o.Value
+	case PO.Move, PO.Copy:
+		res["from"] = formatPointer(o.From)
+	}
+	return json.Marshal(res)
+}
+
+func (o *PatchOp) UnmarshalJSON(bts []byte) error {
+	var j jsonPatchOp
+	err := json.Unmarshal(bts, &j)
+	if err != nil {
+		return errors.E("PatchOp.UnmarshalJSON", errors.K.Invalid, err)
+	}
+	*o = PatchOp{Op: j.Op}
+	o.Path, err = parsePointer(j.Path)
+	if err == nil && j.From != nil {
+		o.From, err = parsePointer(*j.From)
+	}
+	if err != nil {
+		return errors.E("PatchOp.UnmarshalJSON", errors.K.Invalid, err)
+	}
+	if j.Value != nil {
+		err = json.Unmarshal(*j.Value, &o.Value)
+		if err != nil {
+			return errors.E("PatchOp.UnmarshalJSON", errors.K.Invalid, err)
+		}
+	}
+	return nil
+}
+
+// formatPointer formats the given path as JSON pointer according to RFC 6901: each segment is escaped and prefixed
+// with "/", so that the empty path yields the empty string and the path with a single empty key yields "/".
+func formatPointer(p Path) string {
+	sb := strings.Builder{}
+	for _, seg := range p {
+		sb.WriteString("/")
+		sb.WriteString(rfc6901Encoder.Replace(seg))
+	}
+	return sb.String()
+}
+
+// parsePointer parses the given JSON pointer according to RFC 6901 - the inverse of formatPointer. Unlike ParsePath, it
+// rejects pointers that do not start with "/" and invalid escape sequences, and it retains empty segments.
+func parsePointer(s string) (Path, error) {
+	e := errors.Template("parse pointer", errors.K.Invalid, "pointer", s)
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '/' {
+		return nil, e("reason", "pointer must start with '/'")
+	}
+	res := strings.Split(s[1:], "/")
+	for i, seg := range res {
+		for idx := strings.IndexByte(seg, '~'); idx >= 0; idx = strings.IndexByte(seg, '~') {
+			if idx+1 == len(seg) || (seg[idx+1] != '0' && seg[idx+1] != '1') {
+				return nil, e("reason", "invalid escape sequence")
+			}
+			seg = seg[idx+2:]
+		}
+		res[i] = rfc6901Decoder.Replace(res[i])
+	}
+	return res, nil
+}
+
+// Patch is a JSON Patch document as defined in RFC 6902: a sequence of patch operations.
+type Patch []*PatchOp
+
+// Diff computes the JSON Patch that transforms the data structure a into the data structure b. Objects are compared
+// recursively, arrays element by element - elements that are added or removed at the end of an array result in add or
+// remove operations, all other differences in replace operations. Numbers are compared by value, regardless of their
+// type. The values in the patch are copies of the values in b.
+func Diff(a, b interface{}) Patch {
+	var res Patch
+	diff(nil, dereference(a), dereference(b), &res)
+	return res
+}
+
+func diff(path Path, a, b interface{}, res *Patch) {
+	switch ta := a.(type) {
+	case map[string]interface{}:
+		tb, ok := b.(map[string]interface{})
+		if !ok || (ta == nil) != (tb == nil) {
+			break
+		}
+		for _, key := range sortedKeys(ta) {
+			if _, found := tb[key]; !found {
+				*res = append(*res, &PatchOp{Op: PO.Remove, Path: path.CopyAppend(key)})
+			}
+		}
+		for _, key := range sortedKeys(tb) {
+			if va, found := ta[key]; found {
+				diff(path.CopyAppend(key), va, tb[key], res)
+			} else {
+				*res = append(*res, &PatchOp{Op: PO.Add, Path: path.CopyAppend(key), Value: Copy(tb[key])})
+			}
+		}
+		return
+	case []interface{}:
+		tb, ok := b.([]interface{})
+		if !ok || (ta == nil) != (tb == nil) {
+			break
+		}
+		idx := 0
+		for ; idx < len(ta) && idx < len(tb); idx++ {
+			diff(path.CopyAppend(strconv.Itoa(idx)), ta[idx], tb[idx], res)
+		}
+		for ; idx < len(tb); idx++ {
+			*res = append(*res, &PatchOp{Op: PO.Add, Path: path.CopyAppend(strconv.Itoa(idx)), Value: Copy(tb[idx])})
+		}
+		for idx = len(ta) - 1; idx >= len(tb); idx-- {
+			*res = append(*res, &PatchOp{Op: PO.Remove, Path: path.CopyAppend(strconv.Itoa(idx))})
+		}
+		return
+	}
+	if !equal(a, b) {
+		*res = append(*res, &PatchOp{Op: PO.Replace, Path: path.Clone(), Value: Copy(b)})
+	}
+}
+
+// ApplyPatch applies the given JSON Patch to a copy of the target data structure and returns the patched copy. The
+// patch is applied atomically: if any of its operations fails - including failing test operations - an error is
+// returned and the target remains unchanged.
+func ApplyPatch(target interface{}, patch Patch) (interface{}, error) {
+	res := Copy(dereference(target))
+	var err error
+	for idx, op := range patch {
+		res, err = op.apply(res)
+		if err != nil {
+			return target, errors.E("ApplyPatch", err, "index", idx)
+		}
+	}
+	return res, nil
+}
+
+func (o *PatchOp) apply(doc interface{}) (interface{}, error) {
+	e := errors.Template("apply patch op", errors.K.Invalid, "op", o.Op, "path", formatPointer(o.Path))
+	var err error
+	switch o.Op {
+	case PO.Add:
+		doc, err = patchAdd(doc, o.Path, Copy(o.Value))
+	case PO.Remove:
+		doc, _, err = patchRemove(doc, o.Path)
+	case PO.Replace:
+		doc, _, err = patchRemove(doc, o.Path)
+		if err == nil {
+			doc, err = patchAdd(doc, o.Path, Copy(o.Value))
+		}
+	case PO.Move:
+		if o.Path.StartsWith(o.From) && !o.Path.Equals(o.From) {
+			return nil, e("reason", "cannot move into own child", "from", formatPointer(o.From))
+		}
+		var val interface{}
+		doc, val, err = patchRemove(doc, o.From)
+		if err == nil {
+			doc, err = patchAdd(doc, o.Path, val)
+		}
+	case PO.Copy:
+		var val interface{}
+		val, err = patchGet(doc, o.From)
+		if err == nil {
+			doc, err = patchAdd(doc, o.Path, Copy(val))
+		}
+	case PO.Test:
+		var val interface{}
+		val, err = patchGet(doc, o.Path)
+		if err == nil && !equal(val, o.Value) {
+			err = errors.E("test", errors.K.Invalid, "reason", "value differs", "expected", o.Value, "actual", val)
+		}
+	default:
+		return nil, e("reason", "unknown op")
+	}
+	if err != nil {
+		return nil, e(err)
+	}
+	return doc, nil
+}
+
+// patchGet returns the value at the given path.
+func patchGet(doc interface{}, path Path) (interface{}, error) {
+	for _, seg := range path {
+		switch t := doc.(type) {
+		case map[string]interface{}:
+			val, found := t[seg]
+			if !found {
+				return nil, errors.E("get", errors.K.NotExist, "path", path)
+			}
+			doc = val
+		case []interface{}:
+			idx, err := patchIndex(seg, len(t), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = t[idx]
+		default:
+			return nil, errors.E("get", errors.K.NotExist, "reason", "element is leaf", "path", path)
+		}
+	}
+	return doc, nil
+}
+
+// patchAdd adds the given value at the given path: inserts it into an array or adds or replaces a member of an object.
+func patchAdd(doc interface{}, path Path, val interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return val, nil
+	}
+	return patchParent(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch t := parent.(type) {
+		case map[string]interface{}:
+			t[key] = val
+			return t, nil
+		case []interface{}:
+			idx, err := patchIndex(key, len(t), true)
+			if err != nil {
+				return nil, err
+			}
+			return append(t[:idx], append([]interface{}{val}, t[idx:]...)...), nil
+		}
+		return nil, errors.E("add", errors.K.Invalid, "reason", "parent is leaf")
+	})
+}
+
+// patchRemove removes the value at the given path and returns the modified document and the removed value.
+func patchRemove(doc interface{}, path Path) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	var removed interface{}
+	res, err := patchParent(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch t := parent.(type) {
+		case map[string]interface{}:
+			val, found := t[key]
+			if !found {
+				return nil, errors.E("remove", errors.K.NotExist)
+			}
+			removed = val
+			delete(t, key)
+			return t, nil
+		case []interface{}:
+			idx, err := patchIndex(key, len(t), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = t[idx]
+			return append(t[:idx:idx], t[idx+1:]...), nil
+		}
+		return nil, errors.E("remove", errors.K.NotExist, "reason", "parent is leaf")
+	})
+	return res, removed, err
+}
+
+// patchParent navigates to the parent of the given (non-empty) path, calls fn with the parent and the last path
+// segment, and replaces the parent with the result of fn.
+func patchParent(
+	doc interface{},
+	path Path,
+	fn func(parent interface{}, key string) (interface{}, error),
+) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	switch t := doc.(type) {
+	case map[string]interface{}:
+		child, found := t[path[0]]
+		if !found {
+			return nil, errors.E("patch", errors.K.NotExist, "segment", path[0])
+		}
+		child, err := patchParent(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		t[path[0]] = child
+		return t, nil
+	case []interface{}:
+		idx, err := patchIndex(path[0], len(t), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := patchParent(t[idx], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		t[idx] = child
+		return t, nil
+	}
+	return nil, errors.E("patch", errors.K.NotExist, "reason", "element is leaf", "segment", path[0])
+}
+
+// patchIndex parses the given array index as defined in RFC 6901. If add is true, the index may also be the length of
+// the array or "-", which refers to the (nonexistent) element after the last array element.
+func patchIndex(seg string, length int, add bool) (int, error) {
+	last := length - 1
+	if add {
+		last = length
+		if seg == "-" {
+			return length, nil
+		}
+	}
+	idx, err := strconv.Atoi(seg)
+	if err != nil || idx < 0 || idx > last || (len(seg) > 1 && seg[0] == '0') || seg[0] == '+' {
+		return 0, errors.E("patch index", errors.K.Invalid, err, "index", seg, "length", length)
+	}
+	return idx, nil
+}
+
+// CreateMergePatch computes the JSON Merge Patch as defined in RFC 7386 that transforms the data structure a into the
+// data structure b. Note that merge patches cannot express nil values or changes within arrays: members of objects in b
+// with a nil value are treated as removed and arrays are always replaced as a whole.
+func CreateMergePatch(a, b interface{}) interface{} {
+	a, b = dereference(a), dereference(b)
+	ta, okA := a.(map[string]interface{})
+	tb, okB := b.(map[string]interface{})
+	if !okA || !okB {
+		return Copy(b)
+	}
+	res := map[string]interface{}{}
+	for key := range ta {
+		if val, found := tb[key]; !found || val == nil {
+			res[key] = nil
+		}
+	}
+	for key, vb := range tb {
+		if vb == nil {
+			continue
+		}
+		va, found := ta[key]
+		switch {
+		case !found:
+			res[key] = Copy(vb)
+		case equal(va, vb):
+		default:
+			_, mapA := va.(map[string]interface{})
+			_, mapB := vb.(map[string]interface{})
+			if mapA && mapB {
+				res[key] = CreateMergePatch(va, vb)
+			} else {
+				res[key] = Copy(vb)
+			}
+		}
+	}
+	return res
+}
+
+// ApplyMergePatch applies the given JSON Merge Patch as defined in RFC 7386 to a copy of the target data structure and
+// returns the patched copy.
+func ApplyMergePatch(target interface{}, patch interface{}) interface{} {
+	return applyMergePatch(Copy(dereference(target)), dereference(patch))
+}
+
+func applyMergePatch(target interface{}, patch interface{}) interface{} {
+	tp, ok := patch.(map[string]interface{})
+	if !ok {
+		return Copy(patch)
+	}
+	tt, ok := target.(map[string]interface{})
+	if !ok || tt == nil {
+		tt = map[string]interface{}{}
+	}
+	for key, val := range tp {
+		if val == nil {
+			delete(tt, key)
+		} else {
+			tt[key] = applyMergePatch(tt[key], val)
+		}
+	}
+	return tt
+}
+
+// equal returns true if the given values are deeply equal. Numbers are compared by value, regardless of their type.
+func equal(a, b interface{}) bool {
+	switch ta := a.(type) {
+	case map[string]interface{}:
+		tb, ok := b.(map[string]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for key, va := range ta {
+			vb, found := tb[key]
+			if !found || !equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		tb, ok := b.([]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for idx := range ta {
+			if !equal(ta[idx], tb[idx]) {
+				return false
+			}
+		}
+		return true
+	}
+	if na, ok := toNumber(a); ok {
+		nb, ok := toNumber(b)
+		return ok && na == nb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toNumber(val interface{}) (float64, bool) {
+	switch t := val.(type) {
+	case int:
+		return float64(t), true
+	case int8:
+		return float64(t), true
+	case int16:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint:
+		return float64(t), true
+	case uint8:
+		return float64(t), true
+	case uint16:
+		return float64(t), true
+	case uint32:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,344 @@
+package structured
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/util/jsonutil"
+)
+
+func parsePatch(t *testing.T, jsn string) Patch {
+	var patch Patch
+	require.NoError(t, json.Unmarshal([]byte(jsn), &patch))
+	return patch
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string // json
+		patch   string // json
+		want    string // json - empty if error is expected
+		wantErr bool
+	}{
+		{
+			name:   "add object member",
+			target: `{"foo":"bar"}`,
+			patch:  `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:   `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:   "add array element",
+			target: `{"foo":["bar","baz"]}`,
+			patch:  `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:   `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:   "add to end of array",
+			target: `{"foo":["bar"]}`,
+			patch:  `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:   `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:   "add nested member",
+			target: `{"foo":"bar"}`,
+			patch:  `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:   `{"child":{"grandchild":{}},"foo":"bar"}`,
+		},
+		{
+			name:   "add null",
+			target: `{"foo":"bar"}`,
+			patch:  `[{"op":"add","path":"/baz","value":null}]`,
+			want:   `{"baz":null,"foo":"bar"}`,
+		},
+		{
+			name:   "replace root",
+			target: `{"foo":"bar"}`,
+			patch:  `[{"op":"replace","path":"","value":[1]}]`,
+			want:   `[1]`,
+		},
+		{
+			name:   "remove",
+			target: `{"baz":"qux","foo":["bar","qux","baz"]}`,
+			patch:  `[{"op":"remove","path":"/baz"},{"op":"remove","path":"/foo/1"}]`,
+			want:   `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:   "replace",
+			target: `{"baz":"qux","foo":"bar"}`,
+			patch:  `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:   `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:   "move",
+			target: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:  `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:   `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:   "move array element",
+			target: `{"foo":["all","grass","cows","eat"]}`,
+			patch:  `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:   `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:   "copy",
+			target: `{"a":{"b":[1]}}`,
+			patch:  `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`,
+			want:   `{"a":{"b":[1]},"c":{"b":[1,2]}}`,
+		},
+		{
+			name:   "test",
+			target: `{"baz":"qux","foo":["a",2,"c"],"n":{"x":1.0}}`,
+			patch:  `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2},{"op":"test","path":"/n","value":{"x":1}}]`,
+			want:   `{"baz":"qux","foo":["a",2,"c"],"n":{"x":1}}`,
+		},
+		{
+			name:   "escaped path",
+			target: `{"a/b":{"m~n":1}}`,
+			patch:  `[{"op":"replace","path":"/a~1b/m~0n","value":2}]`,
+			want:   `{"a/b":{"m~n":2}}`,
+		},
+		{
+			name:    "test fails",
+			target:  `{"baz":"qux"}`,
+			patch:   `[{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "add to nonexistent target",
+			target:  `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "remove nonexistent",
+			target:  `{"foo":"bar"}`,
+			patch:   `[{"op":"remove","path":"/baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "replace nonexistent",
+			target:  `{"foo":"bar"}`,
+			patch:   `[{"op":"replace","path":"/baz","value":1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid array index",
+			target:  `{"foo":["bar"]}`,
+			patch:   `[{"op":"add","path":"/foo/01","value":1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "array index out of bounds",
+			target:  `{"foo":["bar"]}`,
+			patch:   `[{"op":"add","path":"/foo/2","value":1}]`,
+			wantErr: true,
+		},
+		{
+			name:   "empty key",
+			target: `{"":1,"a":{"":2}}`,
+			patch:  `[{"op":"replace","path":"/","value":3},{"op":"remove","path":"/a/"}]`,
+			want:   `{"":3,"a":{}}`,
+		},
+		{
+			name:    "pointer without leading slash",
+			target:  `{"foo":"bar"}`,
+			patch:   `[{"op":"remove","path":"foo"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid escape sequence",
+			target:  `{"a~2":1}`,
+			patch:   `[{"op":"remove","path":"/a~2"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "move into own child",
+			target:  `{"foo":{"bar":1}}`,
+			patch:   `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown op",
+			target:  `{"foo":"bar"}`,
+			patch:   `[{"op":"blub","path":"/foo"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "atomic",
+			target:  `{"foo":["bar"]}`,
+			patch:   `[{"op":"add","path":"/foo/-","value":1},{"op":"remove","path":"/foo/0"},{"op":"test","path":"/foo/0","value":2}]`,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := parseFloat64(test.target)
+			var patch Patch
+			err := json.Unmarshal([]byte(test.patch), &patch)
+			res := target
+			if err == nil {
+				res, err = ApplyPatch(target, patch)
+			}
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, compact(test.want), jsonutil.MarshalCompactString(res))
+			}
+			// the target is never modified
+			require.Equal(t, compact(test.target), jsonutil.MarshalCompactString(target))
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string // json
+		b    string // json
+		want string // json patch
+	}{
+		{
+			name: "equal",
+			a:    `{"a":[1,{"b":2}]}`,
+			b:    `{"a":[1,{"b":2}]}`,
+			want: `null`,
+		},
+		{
+			name: "objects",
+			a:    `{"a":1,"b":{"c":2,"d":3},"e":4}`,
+			b:    `{"a":1,"b":{"c":5,"x":null},"f":6}`,
+			want: `[
+				{"op":"remove","path":"/e"},
+				{"op":"remove","path":"/b/d"},
+				{"op":"replace","path":"/b/c","value":5},
+				{"op":"add","path":"/b/x","value":null},
+				{"op":"add","path":"/f","value":6}
+			]`,
+		},
+		{
+			name: "arrays",
+			a:    `{"a":[1,2,3,4],"b":[1]}`,
+			b:    `{"a":[1,5],"b":[1,2,3]}`,
+			want: `[
+				{"op":"replace","path":"/a/1","value":5},
+				{"op":"remove","path":"/a/3"},
+				{"op":"remove","path":"/a/2"},
+				{"op":"add","path":"/b/1","value":2},
+				{"op":"add","path":"/b/2","value":3}
+			]`,
+		},
+		{
+			name: "type change",
+			a:    `{"a":[1],"b":{"c":1}}`,
+			b:    `{"a":{"0":1},"b":"c"}`,
+			want: `[
+				{"op":"replace","path":"/a","value":{"0":1}},
+				{"op":"replace","path":"/b","value":"c"}
+			]`,
+		},
+		{
+			name: "root",
+			a:    `{"a":1}`,
+			b:    `"a"`,
+			want: `[{"op":"replace","path":"","value":"a"}]`,
+		},
+		{
+			name: "escaped keys",
+			a:    `{"a/b":{"m~n":1}}`,
+			b:    `{"a/b":{"m~n":2}}`,
+			want: `[{"op":"replace","path":"/a~1b/m~0n","value":2}]`,
+		},
+		{
+			name: "empty keys",
+			a:    `{"":{"":1,"a":2}}`,
+			b:    `{"":{"":2}}`,
+			want: `[{"op":"remove","path":"//a"},{"op":"replace","path":"//","value":2}]`,
+		},
+		{
+			name: "empty key at root",
+			a:    `{}`,
+			b:    `{"":2}`,
+			want: `[{"op":"add","path":"/","value":2}]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := parseFloat64(test.a)
+			b := parseFloat64(test.b)
+			patch := Diff(a, b)
+			require.Equal(t, compact(test.want), jsonutil.MarshalCompactString(patch))
+
+			res, err := ApplyPatch(a, patch)
+			require.NoError(t, err)
+			require.Equal(t, b, res)
+
+			// the patch survives a JSON round trip
+			res, err = ApplyPatch(a, parsePatch(t, jsonutil.MarshalCompactString(patch)))
+			require.NoError(t, err)
+			require.Equal(t, b, res)
+		})
+	}
+
+	// numbers are compared by value
+	require.Empty(t, Diff(map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1.0}))
+	require.Empty(t, Diff(parse(`{"a":1}`), parseFloat64(`{"a":1}`)))
+}
+
+func TestMergePatch(t *testing.T) {
+	// test cases of RFC 7386, appendix A
+	tests := []struct {
+		target string // json
+		patch  string // json
+		want   string // json
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.target+" "+test.patch, func(t *testing.T) {
+			target := parseFloat64(test.target)
+			res := ApplyMergePatch(target, parseFloat64(test.patch))
+			require.Equal(t, compact(test.want), jsonutil.MarshalCompactString(res))
+			require.Equal(t, compact(test.target), jsonutil.MarshalCompactString(target))
+		})
+	}
+
+	createTests := []struct {
+		a    string // json
+		b    string // json
+		want string // json
+	}{
+		{`{"a":1,"b":{"c":2,"d":3},"e":[1,2]}`, `{"a":1,"b":{"c":4,"d":3},"e":[1]}`, `{"b":{"c":4},"e":[1]}`},
+		{`{"a":1,"b":2}`, `{"b":2,"c":{"d":3}}`, `{"a":null,"c":{"d":3}}`},
+		{`{"a":1}`, `{"a":1}`, `{}`},
+		{`{"a":1}`, `[1]`, `[1]`},
+		{`[1]`, `{"a":1}`, `{"a":1}`},
+	}
+	for _, test := range createTests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			a := parseFloat64(test.a)
+			b := parseFloat64(test.b)
+			patch := CreateMergePatch(a, b)
+			require.Equal(t, compact(test.want), jsonutil.MarshalCompactString(patch))
+			require.Equal(t, b, ApplyMergePatch(a, patch))
+		})
+	}
+}
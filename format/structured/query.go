@@ -0,0 +1,422 @@
+package structured
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/eluv-io/errors-go"
+)
+
+// GlobQuery is a query on generic data structures based on glob paths. It
+// combines the following steps, which are executed in this order:
+//
+//   - filtering with select and remove paths as in FilterGlob
+//   - array operations: sorting and pagination (offset/limit) of the arrays at
+//     the given glob paths
+//   - projections: reshaping of the elements at the given glob paths into new
+//     maps, with values taken from arbitrary paths within the element
+//
+// The paths of array operations and projections refer to the structure
+// resulting from the previous steps: the values used for sorting or projecting
+// have therefore to be selected by the filter.
+//
+// As with FilterGlob, the original target data structure is never modified.
+// New maps and slices are created where the query changes them, but unchanged
+// maps and slices are referenced directly.
+//
+// GlobQuery complements Query/Filter rather than replacing it: Query evaluates
+// JSONPath expressions including filter predicates and arithmetic, which glob
+// paths cannot express, and returns the matched values instead of the pruned
+// structure. Filter is therefore not routed through the glob engine. Use
+// GlobQuery (or FilterGlob) to extract parts of a data structure while
+// retaining its shape, and Query to evaluate JSONPath expressions.
+//
+// Example:
+//
+//	query := NewGlobQuery().
+//		WithSelect(ParsePath("/titles")).
+//		WithSort(ParsePath("/titles"), &SortKey{Path: ParsePath("/year"), Desc: true}).
+//		WithSlice(ParsePath("/titles"), 0, 10).
+//		WithProjection(ParsePath("/titles/*"),
+//			ProjectField("/name", "/title"),
+//			ProjectField("/info/year", "/year"))
+//	res, err := query.Apply(target)
+type GlobQuery struct {
+	Select      []Path        `json:"select,omitempty"`
+	Remove      []Path        `json:"remove,omitempty"`
+	Arrays      []*ArrayOp    `json:"arrays,omitempty"`
+	Projections []*Projection `json:"projections,omitempty"`
+}
+
+// ArrayOp sorts and paginates the arrays at the given glob path. Sorting is
+// applied before pagination.
+type ArrayOp struct {
+	Path   Path       `json:"path"`             // glob path of the arrays
+	Sort   []*SortKey `json:"sort,omitempty"`   // sort keys in order of precedence
+	Offset int        `json:"offset,omitempty"` // number of elements to skip
+	Limit  int        `json:"limit,omitempty"`  // max number of elements to retain - no limit if 0
+}
+
+// SortKey defines a sort criterion for array elements. Numbers and strings are
+// compared by value, false sorts before true. Elements that do not have a
+// value at the key's path are always sorted last. The sort is stable.
+type SortKey struct {
+	Path Path `json:"path,omitempty"` // path of the value relative to the array element
+	Desc bool `json:"desc,omitempty"` // sort in descending order
+}
+
+// Projection replaces the elements at the given glob path with new maps
+// created from the projection's fields.
+type Projection struct {
+	Path   Path               `json:"path"` // glob path of the projected elements
+	Fields []*ProjectionField `json:"fields"`
+}
+
+// ProjectionField copies the value at path From of the projected element to the
+// path To of the new element. It is omitted if the element has no value at
+// From.
+type ProjectionField struct {
+	To   Path `json:"to"`   // path in the new element
+	From Path `json:"from"` // path relative to the projected element
+}
+
+// ProjectField creates a projection field from the given "to" and "from" paths
+// in string form.
+func ProjectField(to, from string) *ProjectionField {
+	return &ProjectionField{
+		To:   ParsePath(to),
+		From: ParsePath(from),
+	}
+}
+
+// NewGlobQuery creates a new, empty query.
+func NewGlobQuery() *GlobQuery {
+	return &GlobQuery{}
+}
+
+// WithSelect adds the given select paths.
+func (q *GlobQuery) WithSelect(paths ...Path) *GlobQuery {
+	q.Select = append(q.Select, paths...)
+	return q
+}
+
+// WithRemove adds the given remove paths.
+func (q *GlobQuery) WithRemove(paths ...Path) *GlobQuery {
+	q.Remove = append(q.Remove, paths...)
+	return q
+}
+
+// WithSort sorts the arrays at the given glob path according to the given sort
+// keys.
+func (q *GlobQuery) WithSort(path Path, keys ...*SortKey) *GlobQuery {
+	q.Arrays = append(q.Arrays, &ArrayOp{
+		Path: path,
+		Sort: keys,
+	})
+	return q
+}
+
+// WithSlice limits the arrays at the given glob path to limit elements starting
+// at offset. A limit of 0 means no limit.
+func (q *GlobQuery) WithSlice(path Path, offset, limit int) *GlobQuery {
+	q.Arrays = append(q.Arrays, &ArrayOp{
+		Path:   path,
+		Offset: offset,
+		Limit:  limit,
+	})
+	return q
+}
+
+// WithProjection adds a projection of the elements at the given glob path.
+func (q *GlobQuery) WithProjection(path Path, fields ...*ProjectionField) *GlobQuery {
+	q.Projections = append(q.Projections, &Projection{
+		Path:   path,
+		Fields: fields,
+	})
+	return q
+}
+
+// Validate validates the query.
+func (q *GlobQuery) Validate() error {
+	e := errors.Template("GlobQuery.Validate", errors.K.Invalid)
+	for _, op := range q.Arrays {
+		if op == nil {
+			return e("reason", "array operation is nil")
+		}
+		if op.Offset < 0 || op.Limit < 0 {
+			return e("reason", "negative offset or limit", "path", op.Path, "offset", op.Offset, "limit", op.Limit)
+		}
+		for _, key := range op.Sort {
+			if key == nil {
+				return e("reason", "sort key is nil", "path", op.Path)
+			}
+		}
+	}
+	for _, proj := range q.Projections {
+		if proj == nil {
+			return e("reason", "projection is nil")
+		}
+		for _, field := range proj.Fields {
+			if field == nil {
+				return e("reason", "projection field is nil", "path", proj.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply applies the query to the given target and returns the result. The
+// target itself is not modified.
+func (q *GlobQuery) Apply(target interface{}) (interface{}, error) {
+	err := q.Validate()
+	if err != nil {
+		return nil, errors.E("GlobQuery.Apply", err)
+	}
+
+	res := FilterGlob(target, q.Select, q.Remove)
+	for _, op := range q.Arrays {
+		res, _ = transformGlob(res, normalizeGlob(op.Path), op.apply)
+	}
+	for _, proj := range q.Projections {
+		res, _ = transformGlob(res, normalizeGlob(proj.Path), proj.apply)
+	}
+	return res, nil
+}
+
+func (o *ArrayOp) apply(target interface{}) (interface{}, bool) {
+	arr, ok := dereference(target).([]interface{})
+	if !ok {
+		return target, false
+	}
+	if len(o.Sort) > 0 {
+		sorted := make([]interface{}, len(arr))
+		copy(sorted, arr)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return o.less(sorted[i], sorted[j])
+		})
+		arr = sorted
+	}
+	if o.Offset > 0 || o.Limit > 0 {
+		lo := o.Offset
+		if lo > len(arr) {
+			lo = len(arr)
+		}
+		hi := len(arr)
+		if o.Limit > 0 && lo+o.Limit < hi {
+			hi = lo + o.Limit
+		}
+		// limit the capacity in order to prevent appends from overwriting
+		// elements of the original slice
+		arr = arr[lo:hi:hi]
+	}
+	return arr, true
+}
+
+func (o *ArrayOp) less(a, b interface{}) bool {
+	for _, key := range o.Sort {
+		va, errA := Resolve(key.Path, a)
+		vb, errB := Resolve(key.Path, b)
+		missingA := errA != nil || va == nil
+		missingB := errB != nil || vb == nil
+		switch {
+		case missingA && missingB:
+			continue
+		case missingA:
+			return false
+		case missingB:
+			return true
+		}
+		c := compareValues(va, vb)
+		if c == 0 {
+			continue
+		}
+		if key.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// compareValues compares the two given values and returns -1, 0 or 1 if a is
+// less than, equal to or greater than b. Values of different types are ordered
+// by type: booleans, numbers, strings and all others. Values of other types are
+// considered equal.
+func compareValues(a, b interface{}) int {
+	rankA, rankB := valueRank(a), valueRank(b)
+	if rankA != rankB {
+		return compareInts(rankA, rankB)
+	}
+	switch rankA {
+	case 0:
+		ba, bb := a.(bool), b.(bool)
+		switch {
+		case ba == bb:
+			return 0
+		case bb:
+			return -1
+		}
+		return 1
+	case 1:
+		na, _ := toNumber(a)
+		nb, _ := toNumber(b)
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+		return 0
+	case 2:
+		sa, sb := a.(string), b.(string)
+		switch {
+		case sa < sb:
+			return -1
+		case sa > sb:
+			return 1
+		}
+		return 0
+	}
+	return 0
+}
+
+func valueRank(val interface{}) int {
+	if _, ok := val.(bool); ok {
+		return 0
+	}
+	if _, ok := toNumber(val); ok {
+		return 1
+	}
+	if _, ok := val.(string); ok {
+		return 2
+	}
+	return 3
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (p *Projection) apply(target interface{}) (interface{}, bool) {
+	var res interface{} = map[string]interface{}{}
+	for _, field := range p.Fields {
+		val, err := Resolve(field.From, target)
+		if err != nil {
+			continue
+		}
+		res = setCopy(res, field.To, val)
+	}
+	return res, true
+}
+
+// setCopy sets the value at the given path in target. Instead of modifying
+// target, maps along the path are copied.
+func setCopy(target interface{}, path Path, val interface{}) interface{} {
+	if len(path) == 0 {
+		return val
+	}
+	m, _ := dereference(target).(map[string]interface{})
+	res := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		res[k] = v
+	}
+	res[path[0]] = setCopy(m[path[0]], path[1:], val)
+	return res
+}
+
+// transformGlob calls fn for all elements of target that match the given glob
+// path and replaces them with fn's result if it reports a change. The maps and
+// slices along the paths of changed elements are copied, all others are
+// retained. Returns the result and whether it differs from target.
+func transformGlob(
+	target interface{},
+	glob Path,
+	fn func(val interface{}) (interface{}, bool),
+) (interface{}, bool) {
+	if len(glob) == 0 {
+		return fn(target)
+	}
+	if glob[0] == recursiveWildcard {
+		// apply to descendants first, then match zero segments
+		res, changed := transformChildren(target, wildcard, func(val interface{}) (interface{}, bool) {
+			return transformGlob(val, glob, fn)
+		})
+		res, changedRest := transformGlob(res, glob[1:], fn)
+		return res, changed || changedRest
+	}
+	return transformChildren(target, glob[0], func(val interface{}) (interface{}, bool) {
+		return transformGlob(val, glob[1:], fn)
+	})
+}
+
+// transformChildren calls fn for all children of target whose key or index
+// matches the given path segment.
+func transformChildren(
+	target interface{},
+	seg string,
+	fn func(val interface{}) (interface{}, bool),
+) (interface{}, bool) {
+	switch t := dereference(target).(type) {
+	case map[string]interface{}:
+		var res map[string]interface{}
+		update := func(k string, v interface{}) {
+			nv, changed := fn(v)
+			if !changed {
+				return
+			}
+			if res == nil {
+				res = make(map[string]interface{}, len(t))
+				for key, val := range t {
+					res[key] = val
+				}
+			}
+			res[k] = nv
+		}
+		if isPattern(seg) {
+			for k, v := range t {
+				if matchSegment(seg, k) {
+					update(k, v)
+				}
+			}
+		} else if v, found := t[unescapeGlob(seg)]; found {
+			update(unescapeGlob(seg), v)
+		}
+		if res == nil {
+			return target, false
+		}
+		return res, true
+	case []interface{}:
+		var res []interface{}
+		update := func(i int, v interface{}) {
+			nv, changed := fn(v)
+			if !changed {
+				return
+			}
+			if res == nil {
+				res = make([]interface{}, len(t))
+				copy(res, t)
+			}
+			res[i] = nv
+		}
+		if isPattern(seg) {
+			for i, v := range t {
+				if matchSegment(seg, strconv.Itoa(i)) {
+					update(i, v)
+				}
+			}
+		} else if i, err := strconv.Atoi(unescapeGlob(seg)); err == nil && i >= 0 && i < len(t) {
+			update(i, t[i])
+		}
+		if res == nil {
+			return target, false
+		}
+		return res, true
+	}
+	return target, false
+}
@@ -0,0 +1,206 @@
+package structured_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/common-go/util/jsonutil"
+)
+
+const queryTarget = `
+{
+  "name": "catalog",
+  "keywords": ["b", true, 2, "a", null, 1.5],
+  "titles": [
+    {"title": "b", "year": 2001, "rating": {"score": 7}, "tags": ["x"]},
+    {"title": "a", "year": 1999, "rating": {"score": 9}},
+    {"title": "d", "year": 2001, "rating": {"score": 8}},
+    {"title": "c"},
+    {"title": "e", "year": "unknown"}
+  ],
+  "series": {
+    "s1": {"episodes": [{"n": 3}, {"n": 1}, {"n": 2}]},
+    "s2": {"episodes": [{"n": 2}, {"n": 1}]}
+  }
+}`
+
+func TestGlobQuery(t *testing.T) {
+	p := structured.ParsePath
+
+	tests := []struct {
+		name  string
+		query *structured.GlobQuery
+		want  string // json
+	}{
+		{
+			name:  "empty",
+			query: structured.NewGlobQuery(),
+			want:  queryTarget,
+		},
+		{
+			name:  "filter only",
+			query: structured.NewGlobQuery().WithSelect(p("/titles/*/title")).WithRemove(p("/titles/1?")),
+			want:  `{"titles":[{"title":"b"},{"title":"a"},{"title":"d"},{"title":"c"},{"title":"e"}]}`,
+		},
+		{
+			name: "sort",
+			query: structured.NewGlobQuery().
+				WithSelect(p("/titles/*/title"), p("/titles/*/year")).
+				WithSort(p("/titles"), &structured.SortKey{Path: p("/year"), Desc: true}, &structured.SortKey{Path: p("/title")}),
+			want: `{"titles":[
+				{"title":"e","year":"unknown"},
+				{"title":"b","year":2001},
+				{"title":"d","year":2001},
+				{"title":"a","year":1999},
+				{"title":"c"}
+			]}`,
+		},
+		{
+			name: "sort by element",
+			query: structured.NewGlobQuery().
+				WithSelect(p("/keywords")).
+				WithSort(p("/keywords"), &structured.SortKey{}),
+			want: `{"keywords":[true,1.5,2,"a","b",null]}`,
+		},
+		{
+			name: "sort by element descending",
+			query: structured.NewGlobQuery().
+				WithSelect(p("/keywords")).
+				WithSort(p("/keywords"), &structured.SortKey{Desc: true}),
+			want: `{"keywords":["b","a",2,1.5,true,null]}`,
+		},
+		{
+			name: "slice",
+			query: structured.NewGlobQuery().
+				WithSelect(p("/titles/*/title")).
+				WithSort(p("/titles"), &structured.SortKey{Path: p("/title")}).
+				WithSlice(p("/titles"), 1, 2),
+			want: `{"titles":[{"title":"b"},{"title":"c"}]}`,
+		},
+		{
+			name: "slice out of bounds",
+			query: structured.NewGlobQuery().
+				WithSelect(p("/titles/*/title")).
+				WithSlice(p("/titles"), 3, 10),
+			want: `{"titles":[{"title":"c"},{"title":"e"}]}`,
+		},
+		{
+			name: "slice offset beyond length",
+			query: structured.NewGlobQuery().
+				WithSelect(p("/titles")).
+				WithSlice(p("/titles"), 10, 0),
+			want: `{"titles":[]}`,
+		},
+		{
+			name: "sort multiple arrays",
+			query: structured.NewGlobQuery().
+				WithSelect(p("/series")).
+				WithSort(p("/series/*/episodes"), &structured.SortKey{Path: p("/n")}).
+				WithSlice(p("/**/episodes"), 0, 2),
+			want: `{"series":{
+				"s1":{"episodes":[{"n":1},{"n":2}]},
+				"s2":{"episodes":[{"n":1},{"n":2}]}
+			}}`,
+		},
+		{
+			name: "projection",
+			query: structured.NewGlobQuery().
+				WithSelect(p("/titles")).
+				WithSlice(p("/titles"), 0, 2).
+				WithProjection(p("/titles/*"),
+					structured.ProjectField("/name", "/title"),
+					structured.ProjectField("/info/year", "/year"),
+					structured.ProjectField("/info/score", "/rating/score"),
+					structured.ProjectField("/missing", "/does/not/exist")),
+			want: `{"titles":[
+				{"name":"b","info":{"year":2001,"score":7}},
+				{"name":"a","info":{"year":1999,"score":9}}
+			]}`,
+		},
+		{
+			name: "projection of root",
+			query: structured.NewGlobQuery().
+				WithProjection(nil,
+					structured.ProjectField("/catalog", "/name"),
+					structured.ProjectField("/first", "/titles/0/title"),
+					structured.ProjectField("/count", "/series/s1/episodes/0/n")),
+			want: `{"catalog":"catalog","first":"b","count":3}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := parse(t, queryTarget)
+			res, err := test.query.Apply(target)
+			require.NoError(t, err)
+			require.Equal(t, parse(t, test.want), res, jsonutil.MarshalCompactString(res))
+
+			// the target is never modified
+			require.Equal(t, parse(t, queryTarget), target)
+
+			// the query survives a JSON round trip
+			var query structured.GlobQuery
+			require.NoError(t, json.Unmarshal([]byte(jsonutil.MarshalCompactString(test.query)), &query))
+			res, err = query.Apply(target)
+			require.NoError(t, err)
+			require.Equal(t, parse(t, test.want), res)
+
+			val := structured.Wrap(target).QueryGlob(test.query)
+			require.NoError(t, val.Error())
+			require.Equal(t, parse(t, test.want), val.Data)
+		})
+	}
+}
+
+func TestGlobQueryReuse(t *testing.T) {
+	target := parse(t, queryTarget)
+	res, err := structured.NewGlobQuery().
+		WithSort(structured.ParsePath("/series/s1/episodes"), &structured.SortKey{Path: structured.ParsePath("/n")}).
+		WithSlice(structured.ParsePath("/titles"), 0, 1).
+		Apply(target)
+	require.NoError(t, err)
+
+	// unchanged subtrees are reused
+	s2 := func(val interface{}) map[string]interface{} {
+		return structured.Wrap(val).Get("series", "s2").Map()
+	}
+	require.Same(t, &s2(target)["episodes"].([]interface{})[0], &s2(res)["episodes"].([]interface{})[0])
+
+	// appending to a sliced array does not modify the original
+	titles := structured.Wrap(res).Get("titles").Slice()
+	require.Len(t, titles, 1)
+	_ = append(titles, "appended")
+	require.Equal(t, parse(t, queryTarget), target)
+}
+
+func TestGlobQueryEscaped(t *testing.T) {
+	target := parse(t, `{"a*": [3, 1, 2], "ab": [6, 5, 4]}`)
+	res, err := structured.NewGlobQuery().
+		WithSort(structured.Path{`a\*`}, &structured.SortKey{}).
+		Apply(target)
+	require.NoError(t, err)
+	require.Equal(t, parse(t, `{"a*": [1, 2, 3], "ab": [6, 5, 4]}`), res)
+}
+
+func TestGlobQueryInvalid(t *testing.T) {
+	for _, query := range []*structured.GlobQuery{
+		structured.NewGlobQuery().WithSlice(nil, -1, 0),
+		structured.NewGlobQuery().WithSlice(nil, 0, -1),
+		structured.NewGlobQuery().WithSort(nil, nil),
+		structured.NewGlobQuery().WithProjection(nil, nil),
+		{Arrays: []*structured.ArrayOp{nil}},
+		{Projections: []*structured.Projection{nil}},
+	} {
+		_, err := query.Apply(map[string]interface{}{})
+		require.Error(t, err)
+		require.True(t, structured.Wrap(nil).QueryGlob(query).IsError())
+	}
+}
+
+func parse(t *testing.T, jsn string) interface{} {
+	var res interface{}
+	require.NoError(t, json.Unmarshal([]byte(jsn), &res))
+	return res
+}
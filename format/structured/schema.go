@@ -0,0 +1,472 @@
+package structured
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eluv-io/errors-go"
+)
+
+// Schema is a JSON Schema that validates generic data structures. It supports the following subset of JSON Schema
+// draft 2020-12:
+//
+//   - boolean schemas: true and false
+//   - type (a single type or an array of types): null, boolean, object, array, number, integer, string
+//   - enum and const
+//   - objects: properties, required, additionalProperties
+//   - arrays: items, minItems, maxItems
+//   - strings: pattern, minLength, maxLength
+//   - numbers: minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//   - $ref with JSON pointers within the same document (e.g. "#/$defs/name" or "#") and $defs
+//   - default: see ApplyDefaults
+//
+// All other keywords are ignored.
+type Schema struct {
+	always               *bool // boolean schema
+	ref                  *Schema
+	types                []string
+	enum                 []interface{}
+	constant             *interface{}
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	items                *Schema
+	minItems             *int
+	maxItems             *int
+	pattern              *regexp.Regexp
+	minLength            *int
+	maxLength            *int
+	minimum              *float64
+	maximum              *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	def                  *interface{}
+}
+
+// SchemaViolation is a violation of a schema by the element at Path.
+type SchemaViolation struct {
+	Path    Path   // the path of the violating element
+	Keyword string // the schema keyword that is violated
+	Reason  string // a description of the violation
+}
+
+func (v *SchemaViolation) String() string {
+	return v.Path.String() + ": " + v.Keyword + ": " + v.Reason
+}
+
+// ParseSchema parses a schema from its JSON representation.
+func ParseSchema(jsn []byte) (*Schema, error) {
+	var raw interface{}
+	err := json.Unmarshal(jsn, &raw)
+	if err != nil {
+		return nil, errors.E("ParseSchema", errors.K.Invalid, err)
+	}
+	return NewSchema(raw)
+}
+
+// NewSchema creates a schema from its generic representation, e.g. the result of unmarshaling the schema's JSON
+// representation into an interface{}.
+func NewSchema(raw interface{}) (*Schema, error) {
+	c := &schemaCompiler{
+		root: raw,
+		refs: map[string]*Schema{},
+	}
+	res := &Schema{}
+	err := c.compile(res, nil, raw)
+	if err != nil {
+		return nil, errors.E("NewSchema", errors.K.Invalid, err)
+	}
+	return res, nil
+}
+
+// schemaCompiler compiles the generic representation of a schema document.
+type schemaCompiler struct {
+	root interface{}
+	refs map[string]*Schema // compiled $ref targets by JSON pointer
+}
+
+func (c *schemaCompiler) compile(s *Schema, path Path, raw interface{}) error {
+	e := errors.Template("compile schema", errors.K.Invalid, "path", path)
+
+	if b, ok := raw.(bool); ok {
+		s.always = &b
+		return nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return e("reason", "schema must be an object or boolean", "type", errors.TypeOf(raw))
+	}
+
+	val := Wrap(m)
+	var err error
+	if ref, found := m["$ref"]; found {
+		s.ref, err = c.resolveRef(ref)
+		if err != nil {
+			return e(err)
+		}
+	}
+	if types, found := m["type"]; found {
+		switch t := types.(type) {
+		case string:
+			s.types = []string{t}
+		case []interface{}:
+			for _, typ := range t {
+				str, ok := typ.(string)
+				if !ok {
+					return e("reason", "invalid type", "type", typ)
+				}
+				s.types = append(s.types, str)
+			}
+		default:
+			return e("reason", "invalid type", "type", types)
+		}
+		for _, typ := range s.types {
+			switch typ {
+			case "null", "boolean", "object", "array", "number", "integer", "string":
+			default:
+				return e("reason", "unknown type", "type", typ)
+			}
+		}
+	}
+	if enum, found := m["enum"]; found {
+		s.enum, ok = enum.([]interface{})
+		if !ok {
+			return e("reason", "enum must be an array")
+		}
+	}
+	if constant, found := m["const"]; found {
+		s.constant = &constant
+	}
+	if def, found := m["default"]; found {
+		s.def = &def
+	}
+	if props, found := m["properties"]; found {
+		pm, ok := props.(map[string]interface{})
+		if !ok {
+			return e("reason", "properties must be an object")
+		}
+		s.properties = map[string]*Schema{}
+		for name, prop := range pm {
+			s.properties[name] = &Schema{}
+			err = c.compile(s.properties[name], path.CopyAppend("properties", name), prop)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if required, found := m["required"]; found {
+		ra, ok := required.([]interface{})
+		if !ok {
+			return e("reason", "required must be an array")
+		}
+		for _, name := range ra {
+			str, ok := name.(string)
+			if !ok {
+				return e("reason", "required must be an array of strings")
+			}
+			s.required = append(s.required, str)
+		}
+	}
+	if ap, found := m["additionalProperties"]; found {
+		s.additionalProperties = &Schema{}
+		err = c.compile(s.additionalProperties, path.CopyAppend("additionalProperties"), ap)
+		if err != nil {
+			return err
+		}
+	}
+	if items, found := m["items"]; found {
+		s.items = &Schema{}
+		err = c.compile(s.items, path.CopyAppend("items"), items)
+		if err != nil {
+			return err
+		}
+	}
+	if pattern, found := m["pattern"]; found {
+		str, ok := pattern.(string)
+		if !ok {
+			return e("reason", "pattern must be a string")
+		}
+		s.pattern, err = regexp.Compile(str)
+		if err != nil {
+			return e(err, "reason", "invalid pattern")
+		}
+	}
+
+	ints := map[string]**int{
+		"minItems":  &s.minItems,
+		"maxItems":  &s.maxItems,
+		"minLength": &s.minLength,
+		"maxLength": &s.maxLength,
+	}
+	for keyword, field := range ints {
+		if _, found := m[keyword]; found {
+			i, err := val.Get(keyword).IntErr()
+			if err != nil || i < 0 {
+				return e(err, "reason", "invalid "+keyword)
+			}
+			*field = &i
+		}
+	}
+	floats := map[string]**float64{
+		"minimum":          &s.minimum,
+		"maximum":          &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMinimum,
+		"exclusiveMaximum": &s.exclusiveMaximum,
+	}
+	for keyword, field := range floats {
+		if raw, found := m[keyword]; found {
+			f, ok := toNumber(raw)
+			if !ok {
+				return e("reason", "invalid "+keyword)
+			}
+			*field = &f
+		}
+	}
+	return nil
+}
+
+// resolveRef returns the compiled schema referenced by the given $ref.
+func (c *schemaCompiler) resolveRef(ref interface{}) (*Schema, error) {
+	e := errors.Template("resolve $ref", errors.K.Invalid, "ref", ref)
+	str, ok := ref.(string)
+	if !ok || !strings.HasPrefix(str, "#") {
+		return nil, e("reason", "only references within the document are supported")
+	}
+	if s, found := c.refs[str]; found {
+		return s, nil
+	}
+
+	path := ParsePath(str[1:])
+	raw, err := Resolve(path, c.root)
+	if err != nil {
+		return nil, e(err)
+	}
+	// register before compiling to support recursive schemas
+	s := &Schema{}
+	c.refs[str] = s
+	err = c.compile(s, path, raw)
+	if err != nil {
+		return nil, e(err)
+	}
+	return s, nil
+}
+
+// Validate validates the given data against this schema. Returns an error with all violations if the data is invalid.
+func (s *Schema) Validate(data interface{}) error {
+	violations := s.Violations(data)
+	if len(violations) == 0 {
+		return nil
+	}
+	reasons := make([]string, len(violations))
+	for idx, v := range violations {
+		reasons[idx] = v.String()
+	}
+	return errors.E("Schema.Validate", errors.K.Invalid, "violations", reasons)
+}
+
+// Violations validates the given data against this schema and returns all violations.
+func (s *Schema) Violations(data interface{}) []*SchemaViolation {
+	var res []*SchemaViolation
+	s.validate(nil, dereference(data), &res)
+	return res
+}
+
+func (s *Schema) validate(path Path, data interface{}, res *[]*SchemaViolation) {
+	violation := func(keyword string, format string, args ...interface{}) {
+		*res = append(*res, &SchemaViolation{
+			Path:    path.Clone(),
+			Keyword: keyword,
+			Reason:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if s.always != nil {
+		if !*s.always {
+			violation("false", "no value allowed")
+		}
+		return
+	}
+	if s.ref != nil {
+		s.ref.validate(path, data, res)
+	}
+
+	typ := schemaType(data)
+	if len(s.types) > 0 {
+		matches := false
+		for _, t := range s.types {
+			if t == typ || (t == "number" && typ == "integer") {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			violation("type", "expected %s, got %s", strings.Join(s.types, " or "), typ)
+			return
+		}
+	}
+	if s.enum != nil {
+		found := false
+		for _, val := range s.enum {
+			if equal(data, val) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violation("enum", "value %v not in %v", data, s.enum)
+		}
+	}
+	if s.constant != nil && !equal(data, *s.constant) {
+		violation("const", "expected %v, got %v", *s.constant, data)
+	}
+
+	switch t := data.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, found := t[name]; !found {
+				violation("required", "missing property %q", name)
+			}
+		}
+		for _, key := range sortedKeys(t) {
+			if prop, found := s.properties[key]; found {
+				prop.validate(path.CopyAppend(key), t[key], res)
+			} else if s.additionalProperties != nil {
+				s.additionalProperties.validate(path.CopyAppend(key), t[key], res)
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(t) < *s.minItems {
+			violation("minItems", "%d items, expected at least %d", len(t), *s.minItems)
+		}
+		if s.maxItems != nil && len(t) > *s.maxItems {
+			violation("maxItems", "%d items, expected at most %d", len(t), *s.maxItems)
+		}
+		if s.items != nil {
+			for idx, item := range t {
+				s.items.validate(path.CopyAppend(fmt.Sprint(idx)), item, res)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(t)
+		if s.minLength != nil && length < *s.minLength {
+			violation("minLength", "length %d, expected at least %d", length, *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			violation("maxLength", "length %d, expected at most %d", length, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(t) {
+			violation("pattern", "%q does not match %q", t, s.pattern.String())
+		}
+	default:
+		n, ok := toNumber(t)
+		if !ok {
+			break
+		}
+		if s.minimum != nil && n < *s.minimum {
+			violation("minimum", "%v is less than %v", n, *s.minimum)
+		}
+		if s.maximum != nil && n > *s.maximum {
+			violation("maximum", "%v is greater than %v", n, *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && n <= *s.exclusiveMinimum {
+			violation("exclusiveMinimum", "%v is not greater than %v", n, *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && n >= *s.exclusiveMaximum {
+			violation("exclusiveMaximum", "%v is not less than %v", n, *s.exclusiveMaximum)
+		}
+	}
+}
+
+// ApplyDefaults sets the default values declared in this schema on all object properties that are missing in the given
+// data, recursively for all objects and arrays covered by the schema. Like jsonutil.SetDefaults, properties that are
+// present are never overwritten - even if their value is null. The data is modified in place - like with Set() - and
+// returned. If the data itself is nil, the default of the schema is returned.
+func (s *Schema) ApplyDefaults(data interface{}) interface{} {
+	return s.applyDefaults(dereference(data), map[*Schema]bool{})
+}
+
+// applyDefaults applies the defaults to the given data. visiting tracks the schemas applied to the current element and
+// to its ancestors that were created from defaults, in order to stop the expansion of defaults of recursive schemas.
+func (s *Schema) applyDefaults(data interface{}, visiting map[*Schema]bool) interface{} {
+	if visiting[s] {
+		return data
+	}
+	visiting[s] = true
+	defer delete(visiting, s)
+
+	if data == nil && s.def != nil {
+		data = Copy(*s.def)
+	}
+	if s.ref != nil {
+		data = s.ref.applyDefaults(data, visiting)
+	}
+
+	switch t := data.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(s.properties))
+		for name := range s.properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop := s.properties[name]
+			if val, found := t[name]; found {
+				if val != nil {
+					t[name] = prop.applyDefaults(val, map[*Schema]bool{})
+				}
+			} else if def := prop.defaultValue(); def != nil {
+				t[name] = prop.applyDefaults(Copy(def), visiting)
+			}
+		}
+	case []interface{}:
+		if s.items != nil {
+			for idx, item := range t {
+				if item != nil {
+					t[idx] = s.items.applyDefaults(item, map[*Schema]bool{})
+				}
+			}
+		}
+	}
+	return data
+}
+
+// defaultValue returns the default value of this schema or the schema it references.
+func (s *Schema) defaultValue() interface{} {
+	visited := map[*Schema]bool{}
+	for cur := s; cur != nil && !visited[cur]; cur = cur.ref {
+		if cur.def != nil {
+			return *cur.def
+		}
+		visited[cur] = true
+	}
+	return nil
+}
+
+// schemaType returns the JSON Schema type of the given value, or its Go type if the value does not correspond to a JSON
+// type.
+func schemaType(data interface{}) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	}
+	if n, ok := toNumber(data); ok {
+		if n == math.Trunc(n) && !math.IsInf(n, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return errors.TypeOf(data)
+}
@@ -0,0 +1,213 @@
+package structured
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/util/jsonutil"
+)
+
+const testSchema = `{
+	"$defs": {
+		"tag": {"type": "string", "pattern": "^[a-z]+$", "maxLength": 8},
+		"node": {
+			"type": "object",
+			"properties": {
+				"name": {"type": "string"},
+				"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}
+			},
+			"required": ["name"]
+		}
+	},
+	"type": "object",
+	"properties": {
+		"title": {"type": "string", "minLength": 1},
+		"count": {"type": "integer", "minimum": 0, "exclusiveMaximum": 100, "default": 1},
+		"ratio": {"type": "number", "maximum": 1, "exclusiveMinimum": 0},
+		"kind": {"enum": ["movie", "series", null]},
+		"version": {"const": 2},
+		"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}, "minItems": 1, "maxItems": 3},
+		"tree": {"$ref": "#/$defs/node"},
+		"optional": {"type": ["string", "null"]},
+		"info": {
+			"type": "object",
+			"properties": {
+				"lang": {"type": "string", "default": "en"},
+				"flags": {"type": "object", "default": {"hd": true}}
+			},
+			"default": {},
+			"additionalProperties": false
+		}
+	},
+	"required": ["title", "tags"],
+	"additionalProperties": {"type": "string"}
+}`
+
+func TestSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data string   // json
+		want []string // violations
+	}{
+		{
+			name: "valid",
+			data: `{
+				"title": "t",
+				"count": 5,
+				"ratio": 0.5,
+				"kind": null,
+				"version": 2.0,
+				"tags": ["a", "bc"],
+				"tree": {"name": "root", "children": [{"name": "child", "children": []}]},
+				"optional": null,
+				"info": {"lang": "de"},
+				"extra": "string"
+			}`,
+		},
+		{
+			name: "missing required",
+			data: `{"count": 1}`,
+			want: []string{`/: required: missing property "title"`, `/: required: missing property "tags"`},
+		},
+		{
+			name: "wrong root type",
+			data: `[]`,
+			want: []string{"/: type: expected object, got array"},
+		},
+		{
+			name: "all violations",
+			data: `{
+				"title": "",
+				"count": 1.5,
+				"ratio": 0,
+				"kind": "clip",
+				"version": 3,
+				"tags": ["a", "B", "toolongtag", "d"],
+				"tree": {"children": [{"name": 1}]},
+				"optional": 1,
+				"info": {"unknown": 1},
+				"extra": 1
+			}`,
+			want: []string{
+				"/count: type: expected integer, got number",
+				"/extra: type: expected string, got integer",
+				"/info/unknown: false: no value allowed",
+				"/kind: enum: value clip not in [movie series <nil>]",
+				"/optional: type: expected string or null, got integer",
+				"/ratio: exclusiveMinimum: 0 is not greater than 0",
+				"/tags: maxItems: 4 items, expected at most 3",
+				`/tags/1: pattern: "B" does not match "^[a-z]+$"`,
+				"/tags/2: maxLength: length 10, expected at most 8",
+				`/title: minLength: length 0, expected at least 1`,
+				`/tree: required: missing property "name"`,
+				"/tree/children/0/name: type: expected string, got integer",
+				"/version: const: expected 2, got 3",
+			},
+		},
+		{
+			name: "number limits",
+			data: `{"title": "t", "tags": ["a"], "count": 100, "ratio": 1.5}`,
+			want: []string{
+				"/count: exclusiveMaximum: 100 is not less than 100",
+				"/ratio: maximum: 1.5 is greater than 1",
+			},
+		},
+		{
+			name: "min items",
+			data: `{"title": "t", "tags": [], "count": -1}`,
+			want: []string{"/count: minimum: -1 is less than 0", "/tags: minItems: 0 items, expected at least 1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := parseFloat64(test.data)
+			var violations []string
+			for _, v := range schema.Violations(data) {
+				violations = append(violations, v.String())
+			}
+			require.Equal(t, test.want, violations)
+
+			err := schema.Validate(data)
+			if len(test.want) == 0 {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+
+	// json.Number and native go types are supported as well
+	require.NoError(t, schema.Validate(parse(`{"title": "t", "tags": ["a"], "count": 3}`)))
+	require.NoError(t, schema.Validate(map[string]interface{}{"title": "t", "tags": []interface{}{"a"}, "count": int64(3)}))
+}
+
+func TestSchemaDefaults(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	data := parseFloat64(`{"title": "t", "tags": ["a"], "optional": null}`)
+	res := schema.ApplyDefaults(data)
+	require.Equal(t,
+		compact(`{"count":1,"info":{"flags":{"hd":true},"lang":"en"},"optional":null,"tags":["a"],"title":"t"}`),
+		jsonutil.MarshalCompactString(res))
+	require.NoError(t, schema.Validate(res))
+
+	// existing values are not overwritten
+	data = parseFloat64(`{"count": 5, "info": {"lang": "de", "flags": null}}`)
+	res = schema.ApplyDefaults(data)
+	require.Equal(t, compact(`{"count":5,"info":{"flags":null,"lang":"de"}}`), jsonutil.MarshalCompactString(res))
+
+	// defaults are copied
+	res1 := schema.ApplyDefaults(map[string]interface{}{}).(map[string]interface{})
+	res1["info"].(map[string]interface{})["flags"].(map[string]interface{})["hd"] = false
+	res2 := schema.ApplyDefaults(map[string]interface{}{})
+	require.Equal(t, true, Wrap(res2).Get("info", "flags", "hd").Value())
+
+	// recursive schemas
+	recursive, err := ParseSchema([]byte(`{"type": "object", "properties": {"self": {"$ref": "#"}, "n": {"default": 1}}}`))
+	require.NoError(t, err)
+	require.Equal(t, compact(`{"n":1,"self":{"n":1,"self":{"n":1}}}`),
+		jsonutil.MarshalCompactString(recursive.ApplyDefaults(parseFloat64(`{"self":{"self":{}}}`))))
+
+	// recursive defaults terminate
+	recursive, err = ParseSchema([]byte(`{"type": "object", "properties": {"self": {"$ref": "#", "default": {}}}}`))
+	require.NoError(t, err)
+	res = recursive.ApplyDefaults(map[string]interface{}{})
+	require.NoError(t, recursive.Validate(res))
+	require.NotNil(t, Wrap(res).Get("self").Value())
+}
+
+func TestSchemaInvalid(t *testing.T) {
+	for _, schema := range []string{
+		`"string"`,
+		`{"type": "unknown"}`,
+		`{"type": 1}`,
+		`{"enum": 1}`,
+		`{"properties": []}`,
+		`{"properties": {"a": 1}}`,
+		`{"required": [1]}`,
+		`{"pattern": "("}`,
+		`{"minLength": -1}`,
+		`{"maximum": "1"}`,
+		`{"$ref": "http://example.com/schema"}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"items": {"$ref": "#/$defs/invalid"}, "$defs": {"invalid": []}}`,
+		`{`,
+	} {
+		t.Run(schema, func(t *testing.T) {
+			_, err := ParseSchema([]byte(schema))
+			require.Error(t, err)
+		})
+	}
+
+	s, err := ParseSchema([]byte(`true`))
+	require.NoError(t, err)
+	require.NoError(t, s.Validate(parseFloat64(`{"a": 1}`)))
+	s, err = ParseSchema([]byte(`false`))
+	require.NoError(t, err)
+	require.Error(t, s.Validate(nil))
+}
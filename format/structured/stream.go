@@ -0,0 +1,783 @@
+package structured
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/eluv-io/errors-go"
+)
+
+// StreamFormat is the format of the data read by a StreamDecoder.
+type StreamFormat string
+
+// StreamFormats defines the formats supported by the StreamDecoder.
+var StreamFormats = struct {
+	JSON StreamFormat
+	CBOR StreamFormat
+}{
+	JSON: "json",
+	CBOR: "cbor",
+}
+
+// StreamStats contains statistics about the data processed by a StreamDecoder.
+type StreamStats struct {
+	BytesRead     int64 `json:"bytes_read"`     // total number of bytes read
+	BytesSkipped  int64 `json:"bytes_skipped"`  // number of bytes of skipped values
+	ValuesSkipped int64 `json:"values_skipped"` // number of skipped values, not counting their nested values
+}
+
+// maxCborKeyLen is the maximum length of map keys in CBOR documents.
+const maxCborKeyLen = 1024 * 1024
+
+// MaxStreamDepth is the maximum nesting depth of maps and arrays - and tags in
+// CBOR documents - accepted by the StreamDecoder. It is the same as the limit
+// of encoding/json.
+const MaxStreamDepth = 10000
+
+// StreamDecoder decodes JSON or CBOR documents from a reader into generic data
+// structures. Only the elements selected by the "select" and "remove" paths
+// are materialized - with the same semantics as in FilterGlob - while all other
+// elements are skipped token by token. The result is therefore the same as
+// decoding the full document and filtering it with FilterGlob, but without
+// holding the full document in memory.
+//
+// The values of selected elements are decoded with encoding/json for JSON
+// documents. For CBOR documents, they are decoded by default with a plain CBOR
+// decoder, where CBOR tags are not interpreted. Use WithCborDecoder to
+// customize the decoding - see codecs.NewCborStreamDecoder. Tagged CBOR values
+// are treated as single values, i.e. select and remove paths cannot reach into
+// them.
+//
+// Documents nested deeper than MaxStreamDepth are rejected with an error.
+//
+// A stream may contain multiple consecutive documents: each call to Decode
+// decodes the next document. Decode returns io.EOF if there are no more
+// documents.
+type StreamDecoder struct {
+	reader      io.Reader
+	format      StreamFormat
+	selectPaths []Path
+	removePaths []Path
+	useNumber   bool
+	decodeCbor  func(raw []byte) (interface{}, error)
+
+	filter *globFilter
+	src    streamSource
+	start  int64 // offset of the value read by the last call to next()
+	stats  StreamStats
+}
+
+// NewStreamDecoder creates a new decoder for documents in the given format
+// read from the given reader.
+func NewStreamDecoder(r io.Reader, format StreamFormat) *StreamDecoder {
+	return &StreamDecoder{
+		reader:     r,
+		format:     format,
+		decodeCbor: decodeCbor,
+	}
+}
+
+// WithSelect adds the given select paths.
+func (d *StreamDecoder) WithSelect(paths ...Path) *StreamDecoder {
+	d.selectPaths = append(d.selectPaths, paths...)
+	d.filter = nil
+	return d
+}
+
+// WithRemove adds the given remove paths.
+func (d *StreamDecoder) WithRemove(paths ...Path) *StreamDecoder {
+	d.removePaths = append(d.removePaths, paths...)
+	d.filter = nil
+	return d
+}
+
+// WithUseNumber decodes JSON numbers as json.Number instead of float64 if
+// enabled. It has no effect on CBOR documents.
+func (d *StreamDecoder) WithUseNumber(useNumber bool) *StreamDecoder {
+	d.useNumber = useNumber
+	return d
+}
+
+// WithCborDecoder sets the function used to decode the selected values of CBOR
+// documents. The function receives the raw bytes of a single CBOR data item.
+func (d *StreamDecoder) WithCborDecoder(fn func(raw []byte) (interface{}, error)) *StreamDecoder {
+	d.decodeCbor = fn
+	return d
+}
+
+// Stats returns the statistics of all documents decoded so far.
+func (d *StreamDecoder) Stats() StreamStats {
+	return d.stats
+}
+
+// Decode decodes the next document from the reader and returns the selected
+// elements. Returns io.EOF if the reader contains no further document.
+func (d *StreamDecoder) Decode() (interface{}, error) {
+	e := errors.Template("StreamDecoder.Decode", errors.K.Invalid, "format", d.format)
+
+	if d.src == nil {
+		switch d.format {
+		case StreamFormats.JSON:
+			dec := json.NewDecoder(d.reader)
+			if d.useNumber {
+				dec.UseNumber()
+			}
+			d.src = &jsonStreamSource{dec: dec}
+		case StreamFormats.CBOR:
+			if d.decodeCbor == nil {
+				return nil, e("reason", "cbor decoder is nil")
+			}
+			d.src = &cborStreamSource{
+				r:      bufio.NewReader(d.reader),
+				decode: d.decodeCbor,
+			}
+		default:
+			return nil, e("reason", "unsupported format")
+		}
+	}
+	if d.filter == nil {
+		d.filter = createFilter(d.selectPaths, d.removePaths)
+	}
+
+	kind, err := d.next()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, e(err)
+	}
+	res, _, err := d.decode(d.filter, kind, false)
+	d.stats.BytesRead = d.src.offset()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, e(err)
+	}
+	return res, nil
+}
+
+// next reads the start of the next value and records its offset.
+func (d *StreamDecoder) next() (streamKind, error) {
+	d.start = d.src.offset()
+	return d.src.next()
+}
+
+// skip skips the value started with the last call to next().
+func (d *StreamDecoder) skip(kind streamKind) error {
+	err := d.src.skip(kind)
+	d.stats.BytesSkipped += d.src.offset() - d.start
+	d.stats.ValuesSkipped++
+	return err
+}
+
+// decode decodes the value started with the last call to next() according to
+// the given filter. It mirrors globFilter.filter().
+func (d *StreamDecoder) decode(f *globFilter, kind streamKind, selectAll bool) (interface{}, bool, error) {
+	if f.typ == typRemove {
+		return nil, false, d.skip(kind)
+	}
+	if f.children == nil {
+		val, err := d.src.value(kind)
+		return val, true, err
+	}
+
+	selectAll = selectAll || f.typ == typSelect
+
+	// decodes the value of the child with the given key
+	decodeChild := func(key string) (interface{}, bool, error) {
+		kind, err := d.next()
+		if err != nil {
+			return nil, false, err
+		}
+		child := f.match(key)
+		if child == nil {
+			if selectAll {
+				val, err := d.src.value(kind)
+				return val, true, err
+			}
+			return nil, false, d.skip(kind)
+		}
+		return d.decode(child, kind, selectAll)
+	}
+
+	switch kind {
+	case streamKindMap:
+		res := make(map[string]interface{})
+		for {
+			key, more, err := d.src.nextKey()
+			if err != nil {
+				return nil, false, err
+			}
+			if !more {
+				break
+			}
+			val, retain, err := decodeChild(key)
+			if err != nil {
+				return nil, false, err
+			}
+			if retain {
+				res[key] = val
+			}
+		}
+		if len(res) > 0 {
+			return res, true, nil
+		}
+		return nil, false, nil
+	case streamKindArray:
+		res := make([]interface{}, 0)
+		for idx := 0; ; idx++ {
+			more, err := d.src.more()
+			if err != nil {
+				return nil, false, err
+			}
+			if !more {
+				break
+			}
+			val, retain, err := decodeChild(strconv.Itoa(idx))
+			if err != nil {
+				return nil, false, err
+			}
+			if retain {
+				res = append(res, val)
+			}
+		}
+		if len(res) > 0 {
+			return res, true, nil
+		}
+		return nil, false, nil
+	default:
+		if selectAll {
+			val, err := d.src.value(kind)
+			return val, true, err
+		}
+		return nil, false, d.skip(kind)
+	}
+}
+
+// streamKind is the kind of value read by a streamSource.
+type streamKind int
+
+const (
+	streamKindScalar streamKind = iota
+	streamKindMap
+	streamKindArray
+)
+
+// streamSource reads values of a document token by token.
+type streamSource interface {
+	// next reads the start of the next value and returns its kind.
+	next() (streamKind, error)
+	// nextKey reads the next key of the current map. Returns false at the end
+	// of the map.
+	nextKey() (key string, more bool, err error)
+	// more returns true if the current array has more elements.
+	more() (bool, error)
+	// value decodes the value started with the last call to next().
+	value(kind streamKind) (interface{}, error)
+	// skip skips the value started with the last call to next().
+	skip(kind streamKind) error
+	// offset returns the number of bytes consumed so far.
+	offset() int64
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type jsonStreamSource struct {
+	dec    *json.Decoder
+	scalar interface{} // the scalar value read by the last call to next()
+	depth  int         // the number of open maps and arrays
+}
+
+func (s *jsonStreamSource) next() (streamKind, error) {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		return streamKindMap, s.open()
+	case json.Delim('['):
+		return streamKindArray, s.open()
+	case json.Delim('}'), json.Delim(']'):
+		return 0, errors.E("next", errors.K.Invalid, "reason", "unexpected delimiter", "delimiter", tok)
+	}
+	s.scalar = tok
+	return streamKindScalar, nil
+}
+
+// open records a newly opened map or array.
+func (s *jsonStreamSource) open() error {
+	if s.depth >= MaxStreamDepth {
+		return errDepthExceeded()
+	}
+	s.depth++
+	return nil
+}
+
+func (s *jsonStreamSource) nextKey() (string, bool, error) {
+	more, err := s.more()
+	if err != nil || !more {
+		return "", false, err
+	}
+	tok, err := s.dec.Token()
+	if err != nil {
+		return "", false, err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", false, errors.E("nextKey", errors.K.Invalid, "reason", "invalid map key", "key", tok)
+	}
+	return key, true, nil
+}
+
+func (s *jsonStreamSource) more() (bool, error) {
+	if s.dec.More() {
+		return true, nil
+	}
+	// consume the closing delimiter
+	_, err := s.dec.Token()
+	s.depth--
+	return false, err
+}
+
+func (s *jsonStreamSource) value(kind streamKind) (interface{}, error) {
+	switch kind {
+	case streamKindMap:
+		res := make(map[string]interface{})
+		for {
+			key, more, err := s.nextKey()
+			if err != nil || !more {
+				return res, err
+			}
+			val, err := s.nextValue()
+			if err != nil {
+				return nil, err
+			}
+			res[key] = val
+		}
+	case streamKindArray:
+		res := make([]interface{}, 0)
+		for {
+			more, err := s.more()
+			if err != nil || !more {
+				return res, err
+			}
+			val, err := s.nextValue()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, val)
+		}
+	}
+	return s.scalar, nil
+}
+
+func (s *jsonStreamSource) nextValue() (interface{}, error) {
+	kind, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	return s.value(kind)
+}
+
+func (s *jsonStreamSource) skip(kind streamKind) error {
+	if kind == streamKindScalar {
+		return nil
+	}
+	for depth := s.depth; depth >= s.depth; {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if depth >= MaxStreamDepth {
+				return errDepthExceeded()
+			}
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	s.depth--
+	return nil
+}
+
+func (s *jsonStreamSource) offset() int64 {
+	return s.dec.InputOffset()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// CBOR major types, see https://www.rfc-editor.org/rfc/rfc8949#section-3.1
+const (
+	cborMajorBytes  = 2
+	cborMajorText   = 3
+	cborMajorArray  = 4
+	cborMajorMap    = 5
+	cborMajorTag    = 6
+	cborMajorSimple = 7
+
+	cborIndefinite = 31
+	cborBreak      = 0xff
+)
+
+type cborStreamSource struct {
+	r       *bufio.Reader
+	off     int64
+	decode  func(raw []byte) (interface{}, error)
+	capture *bytes.Buffer // if not nil, all bytes read are copied to the buffer
+
+	// the head of the data item read by the last call to next()
+	head  []byte
+	major byte
+	info  byte // additional information
+	arg   uint64
+
+	containers []*cborContainer // the stack of open maps and arrays
+}
+
+type cborContainer struct {
+	remaining  uint64 // remaining elements or key/value pairs
+	indefinite bool
+}
+
+func (s *cborStreamSource) next() (streamKind, error) {
+	err := s.readHead()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case s.major == cborMajorSimple && s.info == cborIndefinite:
+		return 0, errors.E("next", errors.K.Invalid, "reason", "unexpected break")
+	case s.major == cborMajorMap:
+		return streamKindMap, s.push()
+	case s.major == cborMajorArray:
+		return streamKindArray, s.push()
+	}
+	return streamKindScalar, nil
+}
+
+func (s *cborStreamSource) push() error {
+	if len(s.containers) >= MaxStreamDepth {
+		return errDepthExceeded()
+	}
+	s.containers = append(s.containers, &cborContainer{
+		remaining:  s.arg,
+		indefinite: s.info == cborIndefinite,
+	})
+	return nil
+}
+
+func (s *cborStreamSource) pop() {
+	s.containers = s.containers[:len(s.containers)-1]
+}
+
+func (s *cborStreamSource) nextKey() (string, bool, error) {
+	more, err := s.more()
+	if err != nil || !more {
+		return "", false, err
+	}
+	err = s.readHead()
+	if err != nil {
+		return "", false, err
+	}
+	if s.major != cborMajorText {
+		return "", false, errors.E("nextKey", errors.K.Invalid, "reason", "unsupported map key type", "major_type", s.major)
+	}
+	if s.info != cborIndefinite {
+		bts, err := s.readKey(nil, s.arg)
+		return string(bts), true, err
+	}
+	// indefinite length string: concatenation of definite length chunks
+	var key []byte
+	for {
+		err = s.readHead()
+		if err != nil {
+			return "", false, err
+		}
+		if s.major == cborMajorSimple && s.info == cborIndefinite {
+			return string(key), true, nil
+		}
+		if s.major != cborMajorText || s.info == cborIndefinite {
+			return "", false, errors.E("nextKey", errors.K.Invalid, "reason", "invalid string chunk")
+		}
+		key, err = s.readKey(key, s.arg)
+		if err != nil {
+			return "", false, err
+		}
+	}
+}
+
+// readKey reads n bytes of a map key and appends them to the given key.
+func (s *cborStreamSource) readKey(key []byte, n uint64) ([]byte, error) {
+	if n > maxCborKeyLen || uint64(len(key))+n > maxCborKeyLen {
+		return nil, errors.E("readKey", errors.K.Invalid, "reason", "map key too long", "max", maxCborKeyLen)
+	}
+	res := append(key, make([]byte, n)...)
+	err := s.read(res[len(key):])
+	return res, err
+}
+
+func (s *cborStreamSource) more() (bool, error) {
+	if len(s.containers) == 0 {
+		return false, errors.E("more", errors.K.Invalid, "reason", "not in a map or array")
+	}
+	container := s.containers[len(s.containers)-1]
+	if container.indefinite {
+		isBreak, err := s.readBreak()
+		if err != nil {
+			return false, err
+		}
+		if !isBreak {
+			return true, nil
+		}
+	} else if container.remaining > 0 {
+		container.remaining--
+		return true, nil
+	}
+	s.pop()
+	return false, nil
+}
+
+func (s *cborStreamSource) value(kind streamKind) (interface{}, error) {
+	s.capture = bytes.NewBuffer(append([]byte(nil), s.head...))
+	err := s.skip(kind)
+	raw := s.capture.Bytes()
+	s.capture = nil
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(raw)
+}
+
+func (s *cborStreamSource) skip(kind streamKind) error {
+	if kind != streamKindScalar {
+		s.pop()
+	}
+	return s.skipContent(s.major, s.info, s.arg)
+}
+
+// skipContent skips the content of the data item with the given head. Nested
+// data items are skipped iteratively: the number of remaining data items of
+// each open array, map and tag is tracked on a stack instead of recursing into
+// them.
+func (s *cborStreamSource) skipContent(major, info byte, arg uint64) error {
+	var open []cborSkipped
+	for {
+		switch major {
+		case cborMajorBytes, cborMajorText:
+			err := s.skipString(major, info, arg)
+			if err != nil {
+				return err
+			}
+		case cborMajorArray, cborMajorMap, cborMajorTag:
+			if len(s.containers)+len(open) >= MaxStreamDepth {
+				return errDepthExceeded()
+			}
+			skipped := cborSkipped{
+				remaining:     arg,
+				itemsPerEntry: 1,
+				indefinite:    info == cborIndefinite,
+			}
+			switch major {
+			case cborMajorMap:
+				if arg > math.MaxUint64/2 {
+					return errors.E("skipContent", errors.K.Invalid, "reason", "invalid length", "length", arg)
+				}
+				skipped.remaining = 2 * arg
+				skipped.itemsPerEntry = 2
+			case cborMajorTag:
+				// a tag is followed by exactly one data item
+				skipped.remaining = 1
+			}
+			open = append(open, skipped)
+		}
+		// unsigned and negative integers, simple values and floats: no content
+		// beyond the head
+
+		// close all completed containers and read the head of the next item
+		for {
+			if len(open) == 0 {
+				return nil
+			}
+			last := &open[len(open)-1]
+			if last.remaining == 0 && last.indefinite {
+				isBreak, err := s.readBreak()
+				if err != nil {
+					return err
+				}
+				if !isBreak {
+					last.remaining = last.itemsPerEntry
+				}
+			}
+			if last.remaining > 0 {
+				last.remaining--
+				break
+			}
+			open = open[:len(open)-1]
+		}
+		err := s.readHead()
+		if err != nil {
+			return err
+		}
+		if s.major == cborMajorSimple && s.info == cborIndefinite {
+			return errors.E("skipContent", errors.K.Invalid, "reason", "unexpected break")
+		}
+		major, info, arg = s.major, s.info, s.arg
+	}
+}
+
+// cborSkipped is an array, map or tag that is being skipped.
+type cborSkipped struct {
+	remaining     uint64 // remaining data items, or of the current entry if indefinite
+	itemsPerEntry uint64 // number of data items per element or key/value pair
+	indefinite    bool
+}
+
+// skipString skips the content of the byte or text string with the given head.
+func (s *cborStreamSource) skipString(major, info byte, arg uint64) error {
+	if info != cborIndefinite {
+		return s.discard(arg)
+	}
+	for {
+		err := s.readHead()
+		if err != nil {
+			return err
+		}
+		if s.major == cborMajorSimple && s.info == cborIndefinite {
+			return nil
+		}
+		if s.major != major || s.info == cborIndefinite {
+			return errors.E("skipString", errors.K.Invalid, "reason", "invalid string chunk")
+		}
+		err = s.discard(s.arg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readHead reads the head of the next data item.
+func (s *cborStreamSource) readHead() error {
+	b, err := s.readByte()
+	if err != nil {
+		return err
+	}
+	s.head = append(s.head[:0], b)
+	s.major, s.info = b>>5, b&0x1f
+	switch {
+	case s.info < 24:
+		s.arg = uint64(s.info)
+	case s.info <= 27:
+		n := 1 << (s.info - 24)
+		var buf [8]byte
+		err = s.read(buf[8-n:])
+		if err != nil {
+			return err
+		}
+		s.head = append(s.head, buf[8-n:]...)
+		s.arg = binary.BigEndian.Uint64(buf[:])
+	case s.info == cborIndefinite:
+		switch s.major {
+		case cborMajorBytes, cborMajorText, cborMajorArray, cborMajorMap, cborMajorSimple:
+			s.arg = 0
+		default:
+			return errors.E("readHead", errors.K.Invalid, "reason", "invalid indefinite length", "major_type", s.major)
+		}
+	default:
+		return errors.E("readHead", errors.K.Invalid, "reason", "invalid additional information", "info", s.info)
+	}
+	return nil
+}
+
+// readBreak consumes the next byte and returns true if it is a break.
+// Otherwise, nothing is consumed and false is returned.
+func (s *cborStreamSource) readBreak() (bool, error) {
+	bts, err := s.r.Peek(1)
+	if err != nil {
+		return false, err
+	}
+	if bts[0] != cborBreak {
+		return false, nil
+	}
+	_, err = s.readByte()
+	return true, err
+}
+
+func (s *cborStreamSource) readByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	s.off++
+	if s.capture != nil {
+		s.capture.WriteByte(b)
+	}
+	return b, nil
+}
+
+func (s *cborStreamSource) read(buf []byte) error {
+	n, err := io.ReadFull(s.r, buf)
+	s.off += int64(n)
+	if err == io.EOF && len(buf) > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if s.capture != nil {
+		s.capture.Write(buf)
+	}
+	return nil
+}
+
+func (s *cborStreamSource) discard(n uint64) error {
+	if n > math.MaxInt64 {
+		return errors.E("discard", errors.K.Invalid, "reason", "invalid length", "length", n)
+	}
+	var w io.Writer = io.Discard
+	if s.capture != nil {
+		w = s.capture
+	}
+	copied, err := io.CopyN(w, s.r, int64(n))
+	s.off += copied
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (s *cborStreamSource) offset() int64 {
+	return s.off
+}
+
+var cborDecMode = func() cbor.DecMode {
+	mode, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+// decodeCbor is the default decoder of CBOR values.
+func decodeCbor(raw []byte) (interface{}, error) {
+	var res interface{}
+	err := cborDecMode.Unmarshal(raw, &res)
+	return res, err
+}
+
+func errDepthExceeded() error {
+	return errors.E("decode", errors.K.Invalid, "reason", "max nesting depth exceeded", "max", MaxStreamDepth)
+}
@@ -0,0 +1,254 @@
+package structured_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/structured"
+	"github.com/eluv-io/common-go/util/jsonutil"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	tc := newCtx(t)
+
+	paths := structured.ParsePaths
+	tests := []struct {
+		name        string
+		selectPaths []structured.Path
+		removePaths []structured.Path
+	}{
+		{name: "all"},
+		{name: "select root", selectPaths: paths([]string{"/"})},
+		{name: "remove root", removePaths: paths([]string{"/"})},
+		{name: "select", selectPaths: paths([]string{"/public/name", "/public/asset_metadata/titles/1"})},
+		{name: "select non-existent", selectPaths: paths([]string{"/public/not-exist"})},
+		{name: "remove", removePaths: paths([]string{"/public/asset_metadata/titles/*/*/assets"})},
+		{
+			name:        "select and remove",
+			selectPaths: paths([]string{"/public/asset_metadata"}),
+			removePaths: paths([]string{"/public/asset_metadata/titles/*/*/assets"}),
+		},
+		{name: "wildcards", selectPaths: paths([]string{"/public/asset_metadata/titles/*/*/title"})},
+		{name: "patterns", selectPaths: paths([]string{"/public/asset_*", "/public/*ion"})},
+		{name: "recursive", selectPaths: paths([]string{"/**/title"}), removePaths: paths([]string{"/**/0"})},
+	}
+
+	for _, target := range []struct {
+		name string
+		data interface{}
+	}{
+		{"site", tc.site()},
+		{"site-with-arrays", tc.siteWithArrays()},
+	} {
+		jsonDoc := jsonutil.MarshalCompactString(target.data)
+		cborDoc, err := cbor.Marshal(target.data)
+		require.NoError(t, err)
+		cborData := decodeCbor(t, cborDoc)
+
+		for _, test := range tests {
+			t.Run(target.name+" "+test.name, func(t *testing.T) {
+				want := structured.FilterGlob(target.data, test.selectPaths, test.removePaths)
+
+				dec := structured.NewStreamDecoder(strings.NewReader(jsonDoc), structured.StreamFormats.JSON).
+					WithSelect(test.selectPaths...).
+					WithRemove(test.removePaths...)
+				got, err := dec.Decode()
+				require.NoError(t, err)
+				require.Equal(t, want, got)
+				requireStats(t, dec.Stats(), len(jsonDoc), want)
+
+				_, err = dec.Decode()
+				require.Equal(t, io.EOF, err)
+
+				want = structured.FilterGlob(cborData, test.selectPaths, test.removePaths)
+				dec = structured.NewStreamDecoder(bytes.NewReader(cborDoc), structured.StreamFormats.CBOR).
+					WithSelect(test.selectPaths...).
+					WithRemove(test.removePaths...)
+				got, err = dec.Decode()
+				require.NoError(t, err)
+				require.Equal(t, want, got)
+				requireStats(t, dec.Stats(), len(cborDoc), want)
+
+				_, err = dec.Decode()
+				require.Equal(t, io.EOF, err)
+			})
+		}
+	}
+}
+
+func requireStats(t *testing.T, stats structured.StreamStats, docLen int, res interface{}) {
+	require.EqualValues(t, docLen, stats.BytesRead)
+	if reflect.DeepEqual(res, nil) {
+		require.Positive(t, stats.ValuesSkipped)
+	}
+	require.Equal(t, stats.ValuesSkipped == 0, stats.BytesSkipped == 0)
+	require.LessOrEqual(t, stats.BytesSkipped, stats.BytesRead)
+}
+
+func TestStreamDecoderSkipped(t *testing.T) {
+	doc := `{"small": 1, "large": {"a": [1, 2, 3], "b": "` + strings.Repeat("x", 1000) + `"}}`
+	dec := structured.NewStreamDecoder(strings.NewReader(doc), structured.StreamFormats.JSON).
+		WithSelect(structured.ParsePath("/small"))
+	res, err := dec.Decode()
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"small": 1.0}, res)
+
+	stats := dec.Stats()
+	require.EqualValues(t, len(doc), stats.BytesRead)
+	require.EqualValues(t, 1, stats.ValuesSkipped)
+	require.Greater(t, stats.BytesSkipped, int64(1000))
+	require.Less(t, stats.BytesSkipped, int64(len(doc)-10))
+}
+
+func TestStreamDecoderMultipleDocuments(t *testing.T) {
+	docs := `{"a": 1, "b": 2} {"a": 3, "c": 4} [1, 2] "text"`
+	dec := structured.NewStreamDecoder(strings.NewReader(docs), structured.StreamFormats.JSON).
+		WithUseNumber(true).
+		WithRemove(structured.ParsePath("/a"), structured.ParsePath("/0"))
+
+	var res []interface{}
+	for {
+		val, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		res = append(res, val)
+	}
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"b": json.Number("2")},
+		map[string]interface{}{"c": json.Number("4")},
+		[]interface{}{json.Number("2")},
+		"text",
+	}, res)
+	require.EqualValues(t, 3, dec.Stats().ValuesSkipped)
+}
+
+func TestStreamDecoderCborIndefinite(t *testing.T) {
+	doc := []byte{
+		0xbf,      // indefinite length map
+		0x61, 'a', // "a"
+		0x9f, 0x01, 0x02, 0xff, // [1, 2] with indefinite length
+		0x7f, 0x61, 'b', 0x61, 'c', 0xff, // "bc" with indefinite length
+		0x5f, 0x41, 0x00, 0x42, 0x01, 0x02, 0xff, // bytes with indefinite length
+		0x61, 'd', // "d"
+		0xbf, 0x61, 'e', 0xf5, 0xff, // {"e": true} with indefinite length
+		0xff,
+	}
+	for _, test := range []struct {
+		selectPath string
+		want       interface{}
+	}{
+		{"/", map[string]interface{}{
+			"a":  []interface{}{uint64(1), uint64(2)},
+			"bc": []byte{0, 1, 2},
+			"d":  map[string]interface{}{"e": true},
+		}},
+		{"/a/1", map[string]interface{}{"a": []interface{}{uint64(2)}}},
+		{"/bc", map[string]interface{}{"bc": []byte{0, 1, 2}}},
+		{"/d/e", map[string]interface{}{"d": map[string]interface{}{"e": true}}},
+	} {
+		t.Run(test.selectPath, func(t *testing.T) {
+			dec := structured.NewStreamDecoder(bytes.NewReader(doc), structured.StreamFormats.CBOR).
+				WithSelect(structured.ParsePath(test.selectPath))
+			res, err := dec.Decode()
+			require.NoError(t, err)
+			require.Equal(t, test.want, res)
+			require.EqualValues(t, len(doc), dec.Stats().BytesRead)
+		})
+	}
+}
+
+func TestStreamDecoderInvalid(t *testing.T) {
+	cborDoc, err := cbor.Marshal(map[string]interface{}{"a": []interface{}{1, "two"}, "b": 3})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		format structured.StreamFormat
+		doc    []byte
+	}{
+		{"unknown format", "xml", []byte("<a/>")},
+		{"invalid json", structured.StreamFormats.JSON, []byte(`{"a": [1, }`)},
+		{"truncated json", structured.StreamFormats.JSON, []byte(`{"a": [1, 2`)},
+		{"truncated cbor", structured.StreamFormats.CBOR, cborDoc[:len(cborDoc)-3]},
+		{"unexpected break", structured.StreamFormats.CBOR, []byte{0xff}},
+		{"invalid additional info", structured.StreamFormats.CBOR, []byte{0x1c}},
+		{"integer map key", structured.StreamFormats.CBOR, []byte{0xa1, 0x01, 0x02}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dec := structured.NewStreamDecoder(bytes.NewReader(test.doc), test.format).
+				WithSelect(structured.ParsePath("/a/1"))
+			_, err := dec.Decode()
+			require.Error(t, err)
+			require.NotEqual(t, io.EOF, err)
+		})
+	}
+}
+
+func TestStreamDecoderMaxDepth(t *testing.T) {
+	nested := func(n int, open, leaf, close string) []byte {
+		return []byte(strings.Repeat(open, n) + leaf + strings.Repeat(close, n))
+	}
+
+	tests := []struct {
+		name   string
+		format structured.StreamFormat
+		doc    []byte
+	}{
+		{"json arrays", structured.StreamFormats.JSON, nested(1000000, "[", "1", "]")},
+		{"json maps", structured.StreamFormats.JSON, nested(1000000, `{"a":`, "1", "}")},
+		{"json skipped", structured.StreamFormats.JSON, nested(1000000, `{"b":`, "1", "}")},
+		{"cbor arrays", structured.StreamFormats.CBOR, nested(1000000, "\x81", "\x01", "")},
+		{"cbor maps", structured.StreamFormats.CBOR, nested(1000000, "\xa1\x61a", "\x01", "")},
+		{"cbor skipped", structured.StreamFormats.CBOR, nested(1000000, "\xa1\x61b", "\x01", "")},
+		{"cbor indefinite", structured.StreamFormats.CBOR, nested(1000000, "\x9f", "\x01", "\xff")},
+		{"cbor tags", structured.StreamFormats.CBOR, nested(1000000, "\xc6", "\x01", "")},
+	}
+	for _, test := range tests {
+		for _, selectPath := range []string{"/", "/a/a", "/a/0/x"} {
+			t.Run(test.name+" "+selectPath, func(t *testing.T) {
+				dec := structured.NewStreamDecoder(bytes.NewReader(test.doc), test.format).
+					WithSelect(structured.ParsePath(selectPath))
+				_, err := dec.Decode()
+				require.Error(t, err)
+				if test.format == structured.StreamFormats.CBOR {
+					// encoding/json enforces the same limit itself
+					require.Contains(t, err.Error(), "max nesting depth exceeded")
+				}
+			})
+		}
+	}
+
+	// documents within the limit are decoded
+	for _, test := range []struct {
+		format structured.StreamFormat
+		doc    []byte
+	}{
+		{structured.StreamFormats.JSON, nested(structured.MaxStreamDepth, "[", "1", "]")},
+		{structured.StreamFormats.CBOR, nested(structured.MaxStreamDepth, "\x9f", "\x01", "\xff")},
+	} {
+		dec := structured.NewStreamDecoder(bytes.NewReader(test.doc), test.format).
+			WithSelect(structured.ParsePath("/1"))
+		res, err := dec.Decode()
+		require.NoError(t, err, test.format)
+		require.Nil(t, res)
+		require.EqualValues(t, len(test.doc), dec.Stats().BytesRead)
+	}
+}
+
+func decodeCbor(t *testing.T, doc []byte) interface{} {
+	mode, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	require.NoError(t, err)
+	var res interface{}
+	require.NoError(t, mode.Unmarshal(doc, &res))
+	return res
+}
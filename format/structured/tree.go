@@ -0,0 +1,161 @@
+package structured
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/eluv-io/errors-go"
+)
+
+// Tree is an immutable, persistent tree of generic data structures. It offers
+// the same Get, Set, Merge and Delete operations as Value, but instead of
+// modifying the tree in place, mutations return a new tree and leave the
+// original tree unchanged.
+//
+// Mutations use path copying: only the maps and slices on the path from the
+// root to the modified element are copied, while all other branches are shared
+// between the original and the new tree. Mutations of large trees are
+// therefore cheap compared to copying the whole tree with Copy or Clone, and
+// any number of readers may use a tree concurrently without synchronization.
+// Use Same to find out cheaply whether two trees - or a given branch of two
+// trees - are identical.
+//
+// The tree takes ownership of the data passed to NewTree, Set and Merge, which
+// must not be modified afterwards. Likewise, the data returned by Data and Get
+// is shared with the tree and must not be modified: use Copy or Clone to
+// obtain a private copy.
+//
+// Example:
+//
+//	t1 := NewTree(metadata)
+//	t2, err := t1.Set(ParsePath("/public/name"), "new name")
+//	t2.Same(t1, "public", "asset_metadata") // true: unchanged branch is shared
+//	t2.Same(t1, "public")                   // false
+type Tree struct {
+	data interface{}
+}
+
+// NewTree creates a new tree with the given data as root.
+func NewTree(data interface{}) *Tree {
+	return &Tree{data: dereference(data)}
+}
+
+// Data returns the root of the tree.
+func (t *Tree) Data() interface{} {
+	return t.data
+}
+
+func (t *Tree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.data)
+}
+
+// Get returns the value at the given path, specified as string slice, e.g.
+//
+//	tree.Get("path", "to", "value")
+func (t *Tree) Get(path ...string) *Value {
+	return NewValue(Resolve(path, t.data))
+}
+
+// GetP returns the value at the given path, specified as a single string, e.g.
+//
+//	tree.GetP("/path/to/value")
+//
+// Alias of At()
+func (t *Tree) GetP(path string) *Value {
+	return t.At(path)
+}
+
+// At returns the value at the given path, specified as a single string, e.g.
+//
+//	tree.At("/path/to/value")
+func (t *Tree) At(path string) *Value {
+	return NewValue(Resolve(ParsePath(path), t.data))
+}
+
+// Set returns a new tree where the element at the given path is inserted or
+// replaced with the provided data. As with Set(), path elements that do not
+// exist are created as maps, and the element is removed if data is nil.
+func (t *Tree) Set(path Path, data interface{}) (*Tree, error) {
+	sub, err := resolveSub(path, t.data, true, true)
+	if err != nil {
+		return nil, errors.E("Tree.Set", err)
+	}
+	sub.Set(dereference(data), false)
+	return &Tree{data: sub.Root()}, nil
+}
+
+// Merge returns a new tree where the provided data is merged into the element
+// at the given path with the default merge options.
+func (t *Tree) Merge(path Path, data interface{}) (*Tree, error) {
+	return t.MergeWithOptions(MergeOptions{}, path, data)
+}
+
+// MergeWithOptions returns a new tree where the provided data is merged into
+// the element at the given path according to the given options. The MakeCopy
+// option is always enabled.
+func (t *Tree) MergeWithOptions(opts MergeOptions, path Path, data interface{}) (*Tree, error) {
+	opts.MakeCopy = true
+	res, err := MergeWithOptions(opts, t.data, path, data)
+	if err != nil {
+		return nil, errors.E("Tree.Merge", err)
+	}
+	return &Tree{data: res}, nil
+}
+
+// Delete returns a new tree without the element at the given path and true.
+// If the path does not exist, it returns the tree itself and false.
+func (t *Tree) Delete(path ...string) (*Tree, bool) {
+	if t.data == nil {
+		return t, false
+	}
+	sub, err := resolveSub(path, t.data, false, true)
+	if err != nil {
+		return t, false
+	}
+	sub.Set(nil, false)
+	return &Tree{data: sub.Root()}, true
+}
+
+// Same returns true if the elements at the given path are identical in this
+// and the other tree: maps and slices are compared by identity, scalar values
+// by equality. Elements that are missing in both trees are considered
+// identical.
+//
+// Since mutations copy all maps and slices from the root down to the modified
+// element, an element for which Same returns true is guaranteed to be
+// unchanged between the two trees, including all its descendants. The inverse
+// is not true: an element may have been replaced with an equal copy.
+func (t *Tree) Same(other *Tree, path ...string) bool {
+	if other == nil {
+		return false
+	}
+	a, errA := Resolve(path, t.data)
+	b, errB := Resolve(path, other.data)
+	if errA != nil || errB != nil {
+		return errA != nil && errB != nil
+	}
+	return identical(a, b)
+}
+
+// identical returns true if a and b refer to the same map or slice, or are
+// equal values of another comparable type.
+func identical(a, b interface{}) bool {
+	typ := reflect.TypeOf(a)
+	if typ != reflect.TypeOf(b) {
+		return false
+	}
+	if typ == nil {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Map:
+		return reflect.ValueOf(a).UnsafePointer() == reflect.ValueOf(b).UnsafePointer()
+	case reflect.Slice:
+		va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+		return va.Len() == vb.Len() && va.UnsafePointer() == vb.UnsafePointer()
+	case reflect.Struct, reflect.Array:
+		// may contain values that are not comparable
+		return false
+	}
+	return typ.Comparable() && a == b
+}
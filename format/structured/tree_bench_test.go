@@ -0,0 +1,120 @@
+package structured
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// benchTree creates metadata of a catalog with the given number of titles,
+// each with a few nested maps and slices.
+func benchTree(titles int) map[string]any {
+	m := make(map[string]any, titles)
+	for i := 0; i < titles; i++ {
+		m[fmt.Sprintf("title-%04d", i)] = map[string]any{
+			"title":    fmt.Sprintf("Title %d", i),
+			"year":     float64(1950 + i%70),
+			"genres":   []any{"drama", "comedy"},
+			"rating":   map[string]any{"score": float64(i % 10), "votes": float64(i * 7)},
+			"info":     map[string]any{"runtime": float64(90 + i%60), "language": "en", "country": "us"},
+			"assets":   []any{map[string]any{"type": "poster", "url": "https://example.com/poster.jpg"}},
+			"keywords": []any{"a", "b", "c", "d"},
+		}
+	}
+	return map[string]any{
+		"public": map[string]any{
+			"name":           "catalog",
+			"asset_metadata": map[string]any{"titles": m},
+		},
+	}
+}
+
+var benchTreePath = ParsePath("/public/asset_metadata/titles/title-0500/rating/score")
+
+// set
+
+func BenchmarkTree_Set_Large(b *testing.B) {
+	tree := NewTree(benchTree(1000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchSink, _ = tree.Set(benchTreePath, float64(i))
+	}
+}
+
+func BenchmarkTree_CloneSet_Large(b *testing.B) {
+	data := benchTree(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchSink, _ = Set(Clone(data), benchTreePath, float64(i))
+	}
+}
+
+// merge
+
+func BenchmarkTree_Merge_Large(b *testing.B) {
+	tree := NewTree(benchTree(1000))
+	src := map[string]any{"score": 10.0, "source": "imdb"}
+	path := benchTreePath[:len(benchTreePath)-1]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchSink, _ = tree.Merge(path, src)
+	}
+}
+
+func BenchmarkTree_CloneMerge_Large(b *testing.B) {
+	data := benchTree(1000)
+	src := map[string]any{"score": 10.0, "source": "imdb"}
+	path := benchTreePath[:len(benchTreePath)-1]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchSink, _ = Merge(Clone(data), path, src)
+	}
+}
+
+// delete
+
+func BenchmarkTree_Delete_Large(b *testing.B) {
+	tree := NewTree(benchTree(1000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchSink, _ = tree.Delete(benchTreePath...)
+	}
+}
+
+func BenchmarkTree_CloneDelete_Large(b *testing.B) {
+	data := benchTree(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchSink, _ = Delete(Clone(data), benchTreePath)
+	}
+}
+
+// comparison of snapshots
+
+func BenchmarkTree_Same_Large(b *testing.B) {
+	tree := NewTree(benchTree(1000))
+	other, _ := tree.Set(ParsePath("/public/name"), "other")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchSink = other.Same(tree, "public", "asset_metadata")
+	}
+}
+
+func BenchmarkTree_DeepEqual_Large(b *testing.B) {
+	data := benchTree(1000)
+	other, _ := Set(Clone(data), ParsePath("/public/name"), "other")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchSink = reflect.DeepEqual(
+			Wrap(data).Get("public", "asset_metadata").Data,
+			Wrap(other).Get("public", "asset_metadata").Data)
+	}
+}
@@ -0,0 +1,189 @@
+package structured_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/eluv-io/common-go/format/structured"
+)
+
+const treeTarget = `
+{
+  "public": {
+    "name": "catalog",
+    "tags": ["a", "b", "c"]
+  },
+  "titles": [
+    {"title": "one", "rating": {"score": 7}},
+    {"title": "two", "rating": {"score": 9}}
+  ],
+  "info": {"owner": {"name": "me"}, "count": 2}
+}`
+
+func TestTreeSet(t *testing.T) {
+	p := structured.ParsePath
+
+	tests := []struct {
+		name      string
+		path      structured.Path
+		data      interface{}
+		want      string   // json
+		unchanged []string // paths of branches shared with the original tree
+	}{
+		{
+			name:      "replace",
+			path:      p("/public/name"),
+			data:      "new",
+			want:      `{"public":{"name":"new","tags":["a","b","c"]},"titles":[{"title":"one","rating":{"score":7}},{"title":"two","rating":{"score":9}}],"info":{"owner":{"name":"me"},"count":2}}`,
+			unchanged: []string{"/public/tags", "/titles", "/info"},
+		},
+		{
+			name:      "insert with intermediate maps",
+			path:      p("/info/owner/address/city"),
+			data:      "zurich",
+			want:      `{"public":{"name":"catalog","tags":["a","b","c"]},"titles":[{"title":"one","rating":{"score":7}},{"title":"two","rating":{"score":9}}],"info":{"owner":{"name":"me","address":{"city":"zurich"}},"count":2}}`,
+			unchanged: []string{"/public", "/titles", "/info/count", "/info/owner/name"},
+		},
+		{
+			name:      "array element",
+			path:      p("/titles/1/title"),
+			data:      "zwei",
+			want:      `{"public":{"name":"catalog","tags":["a","b","c"]},"titles":[{"title":"one","rating":{"score":7}},{"title":"zwei","rating":{"score":9}}],"info":{"owner":{"name":"me"},"count":2}}`,
+			unchanged: []string{"/public", "/info", "/titles/0", "/titles/1/rating"},
+		},
+		{
+			name:      "remove with nil",
+			path:      p("/public/tags/1"),
+			data:      nil,
+			want:      `{"public":{"name":"catalog","tags":["a","c"]},"titles":[{"title":"one","rating":{"score":7}},{"title":"two","rating":{"score":9}}],"info":{"owner":{"name":"me"},"count":2}}`,
+			unchanged: []string{"/public/name", "/titles", "/info"},
+		},
+		{
+			name: "root",
+			path: nil,
+			data: map[string]interface{}{"new": "root"},
+			want: `{"new":"root"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			orig := structured.NewTree(parse(t, treeTarget))
+
+			tree, err := orig.Set(test.path, test.data)
+			require.NoError(t, err)
+			requireTree(t, test.want, tree)
+			requireTree(t, treeTarget, orig)
+			require.False(t, tree.Same(orig))
+
+			for _, path := range test.unchanged {
+				require.True(t, tree.Same(orig, p(path)...), path)
+			}
+		})
+	}
+}
+
+func TestTreeSetInvalid(t *testing.T) {
+	orig := structured.NewTree(parse(t, treeTarget))
+
+	for _, path := range []string{"/public/name/leaf", "/titles/5/title", "/titles/x"} {
+		tree, err := orig.Set(structured.ParsePath(path), "value")
+		require.Error(t, err, path)
+		require.Nil(t, tree)
+	}
+	requireTree(t, treeTarget, orig)
+}
+
+func TestTreeNil(t *testing.T) {
+	orig := structured.NewTree(nil)
+	require.Nil(t, orig.Data())
+
+	tree, deleted := orig.Delete("a")
+	require.False(t, deleted)
+	require.Same(t, orig, tree)
+
+	tree, err := orig.Set(structured.ParsePath("/a/b"), "c")
+	require.NoError(t, err)
+	requireTree(t, `{"a":{"b":"c"}}`, tree)
+	require.Nil(t, orig.Data())
+}
+
+func TestTreeDelete(t *testing.T) {
+	orig := structured.NewTree(parse(t, treeTarget))
+
+	tree, deleted := orig.Delete("info", "owner")
+	require.True(t, deleted)
+	requireTree(t, `{"public":{"name":"catalog","tags":["a","b","c"]},"titles":[{"title":"one","rating":{"score":7}},{"title":"two","rating":{"score":9}}],"info":{"count":2}}`, tree)
+	require.True(t, tree.Same(orig, "public"))
+	require.True(t, tree.Same(orig, "titles"))
+	require.False(t, tree.Same(orig, "info"))
+
+	tree, deleted = orig.Delete("titles", "0")
+	require.True(t, deleted)
+	requireTree(t, `{"public":{"name":"catalog","tags":["a","b","c"]},"titles":[{"title":"two","rating":{"score":9}}],"info":{"owner":{"name":"me"},"count":2}}`, tree)
+	require.True(t, tree.Same(orig, "info"))
+	require.False(t, tree.Same(orig, "titles"))
+
+	// the remaining element is shared, even though its index changed
+	require.True(t, structured.NewTree(tree.Get("titles", "0").Data).Same(structured.NewTree(orig.Get("titles", "1").Data)))
+
+	for _, path := range [][]string{{"info", "missing"}, {"titles", "2"}, {"public", "name", "leaf"}} {
+		tree, deleted = orig.Delete(path...)
+		require.False(t, deleted, path)
+		require.Same(t, orig, tree)
+	}
+
+	requireTree(t, treeTarget, orig)
+}
+
+func TestTreeMerge(t *testing.T) {
+	orig := structured.NewTree(parse(t, treeTarget))
+
+	tree, err := orig.Merge(structured.ParsePath("/info"), parse(t, `{"owner":{"email":"me@example.com"},"count":null,"new":true}`))
+	require.NoError(t, err)
+	requireTree(t, `{"public":{"name":"catalog","tags":["a","b","c"]},"titles":[{"title":"one","rating":{"score":7}},{"title":"two","rating":{"score":9}}],"info":{"owner":{"name":"me","email":"me@example.com"},"new":true}}`, tree)
+	require.True(t, tree.Same(orig, "public"))
+	require.True(t, tree.Same(orig, "titles"))
+	require.False(t, tree.Same(orig, "info", "owner"))
+
+	tree, err = orig.MergeWithOptions(
+		structured.MergeOptions{ArrayMergeMode: structured.ArrayMergeModes.Append()},
+		structured.ParsePath("/public/tags"),
+		[]interface{}{"d"})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"a", "b", "c", "d"}, tree.At("/public/tags").Data)
+	require.True(t, tree.Same(orig, "public", "name"))
+
+	requireTree(t, treeTarget, orig)
+}
+
+func TestTreeSame(t *testing.T) {
+	orig := structured.NewTree(parse(t, treeTarget))
+
+	require.True(t, orig.Same(orig))
+	require.False(t, orig.Same(nil))
+	require.False(t, orig.Same(structured.NewTree(parse(t, treeTarget))))
+	require.True(t, orig.Same(structured.NewTree(parse(t, treeTarget)), "public", "name"))
+	require.True(t, orig.Same(structured.NewTree(nil), "missing"))
+	require.False(t, orig.Same(structured.NewTree(nil), "public"))
+
+	tree, err := orig.Set(structured.ParsePath("/public/tags"), []interface{}{"a", "b", "c"})
+	require.NoError(t, err)
+	require.False(t, tree.Same(orig, "public", "tags"))
+	require.True(t, tree.Same(orig, "public", "name"))
+
+	// a shorter slice with the same backing array is not the same
+	tags := orig.Get("public", "tags").Slice()
+	tree, err = orig.Set(structured.ParsePath("/public/tags"), tags[:2])
+	require.NoError(t, err)
+	require.False(t, tree.Same(orig, "public", "tags"))
+}
+
+func requireTree(t *testing.T, want string, tree *structured.Tree) {
+	require.Equal(t, parse(t, want), tree.Data())
+
+	jsn, err := json.Marshal(tree)
+	require.NoError(t, err)
+	require.JSONEq(t, want, string(jsn))
+}
@@ -135,6 +135,7 @@ func (v *Value) At(path string) *Value {
 	return NewValue(Resolve(ParsePath(path), v.Data))
 }
 
+// Query applies the given JSONPath query to the data. See Query.
 func (v *Value) Query(query string) *Value {
 	filter, err := NewFilter(query)
 	if err != nil {
@@ -143,6 +144,11 @@ func (v *Value) Query(query string) *Value {
 	return NewValue(filter.Apply(v.Data))
 }
 
+// QueryGlob applies the given glob query to the data. See GlobQuery.
+func (v *Value) QueryGlob(query *GlobQuery) *Value {
+	return NewValue(query.Apply(v.Data))
+}
+
 func (v *Value) Clear() error {
 	return v.Set(nil, nil)
 }
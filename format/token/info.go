@@ -0,0 +1,94 @@
+package token
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/eluv-io/errors-go"
+
+	"github.com/eluv-io/common-go/format/encryption"
+	"github.com/eluv-io/common-go/format/id"
+)
+
+// Info is the information embedded in a token. Information that is not part of tokens of the given type is zero, i.e.
+// nil IDs, encryption.UNKNOWN, no flags, an Index of -1 and a zero Created time:
+//
+//	Code         | QID | NID | AllocationID | Scheme, Flags | Index | Created
+//	-------------+-----+-----+--------------+---------------+-------+--------
+//	QWriteV1     |     |     |              |               |       |
+//	QWrite       |  x  |  x  |              |               |       |   x
+//	QPartWriteV1 |     |     |              |               |       |
+//	QPartWrite   |     |     |              |       x       |       |   x
+//	LRO          |     |  x  |              |               |       |   x
+//	LocalFile    |  x  |  x  |              |               |       |
+//	Job          |     |  x  |      x       |               |   x   |
+//
+// Created is only set if the token was created with time-sortable bytes - see GenerateSortable() and SortableBytes().
+type Info struct {
+	Code         Code
+	QID          id.ID             // the ID of the content object
+	NID          id.ID             // the ID of the node that owns the token
+	AllocationID id.ID             // the ID of the allocation of a job
+	Scheme       encryption.Scheme // the encryption scheme of a content part
+	Flags        byte              // the flags of a content part write token
+	Index        int               // the index of a job in its allocation
+	Created      time.Time         // the creation time of the token
+}
+
+// Info extracts the information embedded in this token. Returns nil if the token is nil.
+func (t *Token) Info() *Info {
+	if t == nil {
+		return nil
+	}
+	res := &Info{
+		Code:  t.Code,
+		Index: -1,
+	}
+	switch t.Code {
+	case QWrite, LocalFile:
+		res.QID = t.QID
+		res.NID = t.NID
+	case LRO:
+		res.NID = t.NID
+	case Job:
+		res.NID = t.NID
+		res.AllocationID = t.AllocationID
+		if idx, err := strconv.Atoi(string(t.Bytes)); err == nil {
+			res.Index = idx
+		}
+	case QPartWrite:
+		res.Scheme = t.Scheme
+		res.Flags = t.Flags
+	}
+	res.Created, _ = t.Time()
+	return res
+}
+
+// InfoFromString parses the given token string and extracts the information embedded in the token.
+func InfoFromString(s string) (*Info, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return nil, errors.E("token.InfoFromString", err)
+	}
+	return t.Info(), nil
+}
+
+// HasQID returns true if the token refers to a content object.
+func (i *Info) HasQID() bool {
+	return i != nil && !i.QID.IsNil()
+}
+
+// HasNID returns true if the token is owned by a node.
+func (i *Info) HasNID() bool {
+	return i != nil && !i.NID.IsNil()
+}
+
+// HasAllocationID returns true if the token refers to an allocation.
+func (i *Info) HasAllocationID() bool {
+	return i != nil && !i.AllocationID.IsNil()
+}
+
+// HasIndex returns true if the token carries a creation index.
+func (i *Info) HasIndex() bool {
+	return i != nil && i.Index >= 0
+}
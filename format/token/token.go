@@ -11,10 +11,12 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/mr-tron/base58/base58"
 
+	"github.com/eluv-io/common-go/format/checksum"
 	"github.com/eluv-io/common-go/format/encryption"
 	"github.com/eluv-io/common-go/format/id"
 	"github.com/eluv-io/common-go/util/byteutil"
@@ -171,6 +173,12 @@ func (c Code) Describe() string {
 	return codeToName[c]
 }
 
+// IsSortable returns true if tokens of this type may be created with time-sortable bytes - see GenerateSortable() and
+// SortableBytes(). The creation time of such tokens is available through Token.Time().
+func (c Code) IsSortable() bool {
+	return sortableCodes[c]
+}
+
 // lint off
 const (
 	UNKNOWN      Code = iota
@@ -207,6 +215,12 @@ var codeToName = map[Code]string{
 	Job:          "allocated job",
 }
 
+var sortableCodes = map[Code]bool{
+	QWrite:     true,
+	QPartWrite: true,
+	LRO:        true,
+}
+
 // NOTE: 5 char prefix - 2 underscores!
 // This is a backward compatibility hack because the JS client code requires
 // a "tqw_" prefix for tokens! This prefix will be switched back to the
@@ -283,6 +297,12 @@ func (t *Token) String() string {
 	return t.MakeString()
 }
 
+// ChecksumString returns the checksummed string representation of this Token - see package checksum. Parse accepts
+// both the plain and the checksummed form.
+func (t *Token) ChecksumString() string {
+	return checksum.Append(t.String())
+}
+
 // MakeString recomputes the internal cached string representation of this Token
 // MakeString is not safe for calls from concurrent go-routines.
 func (t *Token) MakeString() string {
@@ -358,6 +378,15 @@ func (t *Token) AssertCode(c Code) error {
 	return nil
 }
 
+// Time returns the creation time of this token and true if it is of a sortable type and was created with time-sortable
+// bytes - see Code.IsSortable(). Returns the zero time and false otherwise.
+func (t *Token) Time() (time.Time, bool) {
+	if t == nil || !t.Code.IsSortable() {
+		return time.Time{}, false
+	}
+	return id.SortableTime(t.Bytes)
+}
+
 func (t *Token) IsNil() bool {
 	return t == nil
 }
@@ -504,6 +533,27 @@ func (t *Token) Validate() (err error) {
 	return err
 }
 
+// SortableBytes returns new time-sortable bytes for a token of a sortable type, to be passed to NewObject, NewPart or
+// NewLRO instead of the default random bytes - see id.SortableBytes().
+func SortableBytes() []byte {
+	return id.SortableBytes(time.Now())
+}
+
+// GenerateSortable creates a Token with time-sortable bytes for the given Token type. Returns nil if the type is not
+// sortable - see Code.IsSortable().
+func GenerateSortable(code Code) *Token {
+	var t *Token
+	switch code {
+	case QWrite:
+		t, _ = NewObject(code, id.Generate(id.Q), id.Generate(id.QNode), SortableBytes()...)
+	case QPartWrite:
+		t, _ = NewPart(code, encryption.None, 0, SortableBytes()...)
+	case LRO:
+		t, _ = NewLRO(code, id.Generate(id.QNode), SortableBytes()...)
+	}
+	return t
+}
+
 // Generate creates a random Token for the given Token type.
 func Generate(code Code) *Token {
 	var t *Token
@@ -536,9 +586,14 @@ func MustParse(s string) *Token {
 	return res
 }
 
-// Parse parses a token from the given string representation.
+// Parse parses a token from the given string representation, which may be in checksummed form - see
+// ChecksumString().
 func Parse(s string) (*Token, error) {
 	e := errors.Template("parse token", errors.K.Invalid, "string", s)
+	s, err := checksum.Strip(s)
+	if err != nil {
+		return nil, e(err)
+	}
 
 	if len(s) < prefixLen {
 		return nil, e("reason", "unknown prefix")
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -82,6 +83,127 @@ func testConversion(t *testing.T, tok *token.Token, code token.Code, prefix stri
 	assert.Equal(t, "blub"+encoded, fmt.Sprintf("blub%s", tok))
 }
 
+func TestChecksumString(t *testing.T) {
+	for _, tok := range []*token.Token{qwt, qpwt, lrot, jobt, token.Generate(token.QWriteV1)} {
+		cs := tok.ChecksumString()
+		require.NotEqual(t, tok.String(), cs)
+
+		parsed, err := token.Parse(cs)
+		require.NoError(t, err)
+		require.True(t, tok.Equal(parsed))
+		require.Equal(t, tok.String(), parsed.String())
+		require.True(t, parsed.IsValid())
+
+		_, err = token.Parse(cs[:len(cs)-1])
+		require.Error(t, err)
+	}
+	require.False(t, zerot.IsValid())
+	require.Error(t, (&token.Token{Code: token.QWrite, Bytes: []byte{1}}).Validate())
+}
+
+func TestSortable(t *testing.T) {
+	for _, code := range []token.Code{token.QWrite, token.QPartWrite, token.LRO} {
+		require.True(t, code.IsSortable())
+	}
+	require.False(t, token.QWriteV1.IsSortable())
+
+	start := time.Now().Add(-time.Millisecond)
+	tok1 := token.GenerateSortable(token.QWrite)
+	tok2 := token.GenerateSortable(token.QWrite)
+	require.Equal(t, -1, bytes.Compare(tok1.Bytes, tok2.Bytes))
+
+	created, ok := tok1.Time()
+	require.True(t, ok)
+	require.WithinRange(t, created, start, time.Now())
+
+	parsed, err := token.Parse(tok1.String())
+	require.NoError(t, err)
+	created2, ok := parsed.Time()
+	require.True(t, ok)
+	require.Equal(t, created, created2)
+
+	_, ok = qwt.Time()
+	require.False(t, ok)
+	require.Nil(t, token.GenerateSortable(token.QWriteV1))
+
+	// tokens are random by default
+	for _, code := range []token.Code{token.QWrite, token.QPartWrite, token.LRO} {
+		tok := token.Generate(code)
+		require.Len(t, tok.Bytes, 16)
+		_, ok = tok.Time()
+		require.False(t, ok)
+
+		tok = token.GenerateSortable(code)
+		require.NotNil(t, tok, code)
+		_, ok = tok.Time()
+		require.True(t, ok)
+	}
+
+	// sortable bytes of non-sortable types are ignored
+	lf, err := token.NewLocalFile(nid, qid, token.SortableBytes())
+	require.NoError(t, err)
+	_, ok = lf.Time()
+	require.False(t, ok)
+
+	v1, err := token.NewObject(token.QWriteV1, nil, nil, 1, 2, 3)
+	require.NoError(t, err)
+	_, ok = v1.Time()
+	require.False(t, ok)
+	_, ok = (*token.Token)(nil).Time()
+	require.False(t, ok)
+}
+
+func TestInfo(t *testing.T) {
+	sortable := token.GenerateSortable(token.QWrite)
+	tests := []struct {
+		tok  *token.Token
+		want token.Info
+	}{
+		{qwt, token.Info{Code: token.QWrite, QID: qid, NID: nid, Index: -1}},
+		{qpwt, token.Info{Code: token.QPartWrite, Scheme: encryption.ClientGen, Flags: token.PreambleQPWFlag, Index: -1}},
+		{lrot, token.Info{Code: token.LRO, NID: nid, Index: -1}},
+		{jobt, token.Info{Code: token.Job, NID: nid, AllocationID: allocationID, Index: 0}},
+		{
+			func() *token.Token {
+				t, _ := token.NewLocalFile(nid, qid, []byte{1, 2, 3})
+				return t
+			}(),
+			token.Info{Code: token.LocalFile, QID: qid, NID: nid, Index: -1},
+		},
+		{
+			func() *token.Token {
+				t, _ := token.NewObject(token.QWriteV1, nil, nil, 1, 2, 3)
+				return t
+			}(),
+			token.Info{Code: token.QWriteV1, Index: -1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.tok.String(), func(t *testing.T) {
+			require.Equal(t, test.want, *test.tok.Info())
+
+			info, err := token.InfoFromString(test.tok.String())
+			require.NoError(t, err)
+			require.Equal(t, test.want, *info)
+
+			require.Equal(t, !test.want.QID.IsNil(), info.HasQID())
+			require.Equal(t, !test.want.NID.IsNil(), info.HasNID())
+			require.Equal(t, !test.want.AllocationID.IsNil(), info.HasAllocationID())
+			require.Equal(t, test.want.Index >= 0, info.HasIndex())
+		})
+	}
+
+	info := sortable.Info()
+	created, _ := sortable.Time()
+	require.False(t, info.Created.IsZero())
+	require.Equal(t, created, info.Created)
+
+	_, err := token.InfoFromString("blub")
+	require.Error(t, err)
+	require.Nil(t, (*token.Token)(nil).Info())
+	require.False(t, (*token.Info)(nil).HasNID())
+}
+
 func TestNil(t *testing.T) {
 	tok := (*token.Token)(nil)
 	require.Nil(t, tok)
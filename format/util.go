@@ -1,6 +1,8 @@
 package format
 
 import (
+	"github.com/eluv-io/errors-go"
+
 	"github.com/eluv-io/common-go/format/hash"
 	"github.com/eluv-io/common-go/format/id"
 	"github.com/eluv-io/common-go/format/token"
@@ -68,3 +70,26 @@ func ParseQihot(qihot string) (types.QID, types.QHash, types.QWriteToken) {
 
 	return nil, nil, nil
 }
+
+// ExtractQNodeID extracts the ID of the node that owns the given content ID, hash, write token or other token string
+// (LRO handle, job token, local file token) for the purpose of routing requests to that node. Returns
+//   - (nid, nil) if the string is a token owned by a node
+//   - (nil, nil) if the string is a content ID, content hash or a token that is not bound to a node
+//   - (nil, err) if the string is neither
+func ExtractQNodeID(qihot string) (types.QNodeID, error) {
+	e := errors.Template("ExtractQNodeID", errors.K.Invalid, "qihot", qihot)
+	if _, err := id.Q.FromString(qihot); err == nil {
+		return nil, nil
+	}
+	if _, err := hash.Q.FromString(qihot); err == nil {
+		return nil, nil
+	}
+	info, err := token.InfoFromString(qihot)
+	if err != nil {
+		return nil, e(err, "reason", "neither content ID, hash nor token")
+	}
+	if !info.HasNID() {
+		return nil, nil
+	}
+	return info.NID, nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/eluv-io/common-go/format/encryption"
 	"github.com/eluv-io/common-go/format/hash"
 	"github.com/eluv-io/common-go/format/id"
 	"github.com/eluv-io/common-go/format/token"
@@ -94,3 +95,40 @@ func TestParseQhot(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractQNodeID(t *testing.T) {
+	nid := id.MustParse("inod3Sa5p3czRyYi8GnVGnh8gBDLaqJr")
+	qid := id.MustParse("iq__99d4kp14eSDEP7HWfjU4W6qmqDw")
+	lro, _ := token.NewLRO(token.LRO, nid)
+	job, _ := token.NewJob(nid, id.Generate(id.Allocation), 3)
+	lf, _ := token.NewLocalFile(nid, qid, []byte{1, 2, 3})
+	qpwt, _ := token.NewPart(token.QPartWrite, encryption.None, 0)
+
+	tests := []struct {
+		qihot   string
+		wantNid types.QNodeID
+		wantErr bool
+	}{
+		{"", nil, true},
+		{"abcd", nil, true},
+		{"iq__48iLSSjzN3PRyzwWqDmG5Dx1zkfL", nil, false},
+		{"hq__EKjpzYq4vjPxchdoSm8fUSvK2y3PYVgLPdMWP8yqRRvu4rBnv3BY1BS7pdjVjfvvsasaTZA9qq", nil, false},
+		{"tqw__8UmhDD9cZah58THfAYPf3Shj9hVzfwT51Cf4ZHKpayajzZRyMwCPiSpfS5yqRZfjkDjrtXuRmDa", nid, false},
+		{token.MustParse("tqw__8UmhDD9cZah58THfAYPf3Shj9hVzfwT51Cf4ZHKpayajzZRyMwCPiSpfS5yqRZfjkDjrtXuRmDa").ChecksumString(), nid, false},
+		{lro.String(), nid, false},
+		{job.String(), nid, false},
+		{lf.String(), nid, false},
+		{qpwt.String(), nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qihot, func(t *testing.T) {
+			nid, err := ExtractQNodeID(tt.qihot)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantNid, nid)
+		})
+	}
+}